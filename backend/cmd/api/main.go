@@ -6,6 +6,7 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"time"
 
 	"github.com/MananKakkar1/SalesBoard/backend/internal/handlers"
 	"github.com/MananKakkar1/SalesBoard/backend/internal/tools"
@@ -27,10 +28,30 @@ func main() {
 	tools.InitDB("app.db")
 	tools.InsertDummyUser()
 
+	// Uploaded files (product images, order attachments) live on local disk
+	uploadDir := os.Getenv("UPLOAD_DIR")
+	if uploadDir == "" {
+		uploadDir = "uploads"
+	}
+	tools.InitStorage(uploadDir)
+
+	// Card payments go through the gateway named by PAYMENT_PROVIDER (default: the offline fake)
+	if err := handlers.InitPaymentProvider(os.Getenv("PAYMENT_PROVIDER")); err != nil {
+		logrus.Fatal(err)
+	}
 
 	r := chi.NewRouter()
 	handlers.Handler(r)
 
+	// Apply future-dated price changes as they come due
+	handlers.StartPriceScheduler(time.Minute)
+
+	// Announce follow-up tasks as they become overdue
+	handlers.StartTaskScheduler(time.Minute)
+
+	// Place orders from recurring order templates as they come due
+	handlers.StartRecurringOrderScheduler(time.Minute)
+
 	port := os.Getenv("PORT")
 	if port == "" {
 		port = "8080"
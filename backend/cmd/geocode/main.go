@@ -0,0 +1,153 @@
+// Command geocode backfills coordinates for customer addresses and warehouses that
+// don't have them yet, using the local gazetteer. Warehouses whose latitude/longitude
+// text parses as valid coordinates get those copied to the numeric lat/lng columns.
+//
+//	go run ./cmd/geocode -db ./cmd/api/app.db
+//	go run ./cmd/geocode -db ./cmd/api/app.db -force   # also redo earlier gazetteer matches
+package main
+
+import (
+	"database/sql"
+	"flag"
+	"log"
+	"strconv"
+
+	"github.com/MananKakkar1/SalesBoard/backend/internal/geocode"
+	"github.com/MananKakkar1/SalesBoard/backend/internal/tools"
+)
+
+func must(err error) {
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+type counts struct {
+	scanned, resolved, unresolved int
+}
+
+func main() {
+	dbPath := flag.String("db", "./app.db", "SQLite file path")
+	force := flag.Bool("force", false, "re-geocode rows already resolved from the gazetteer (manual coordinates are kept)")
+	dryRun := flag.Bool("dry-run", false, "report what would change without writing")
+	flag.Parse()
+
+	tools.InitDB(*dbPath)
+	defer tools.DB.Close()
+
+	gaz := geocode.Default()
+
+	tx, err := tools.DB.Begin()
+	must(err)
+	defer tx.Rollback()
+
+	a := backfillAddresses(tx, gaz, *force)
+	log.Printf("customer addresses: %d scanned, %d resolved, %d unresolved", a.scanned, a.resolved, a.unresolved)
+	wh := backfillWarehouses(tx, gaz, *force)
+	log.Printf("warehouses: %d scanned, %d resolved, %d unresolved", wh.scanned, wh.resolved, wh.unresolved)
+
+	if *dryRun {
+		log.Printf("dry run, nothing written")
+		return
+	}
+	must(tx.Commit())
+}
+
+// pending selects rows with no coordinates, plus (with force) rows geocoded earlier.
+func pending(force bool) string {
+	if force {
+		return `(lat IS NULL OR geo_precision IN ('postal', 'city'))`
+	}
+	return `lat IS NULL`
+}
+
+func backfillAddresses(tx *sql.Tx, gaz *geocode.Gazetteer, force bool) counts {
+	rows, err := tx.Query(`
+		SELECT id, street, city, region, postal_code, country
+		FROM customer_addresses
+		WHERE ` + pending(force) + `
+		ORDER BY id`)
+	must(err)
+
+	type addr struct {
+		id int
+		q  geocode.Query
+	}
+	var list []addr
+	for rows.Next() {
+		var a addr
+		must(rows.Scan(&a.id, &a.q.Street, &a.q.City, &a.q.Region, &a.q.PostalCode, &a.q.Country))
+		list = append(list, a)
+	}
+	must(rows.Err())
+	rows.Close()
+
+	var c counts
+	for _, a := range list {
+		c.scanned++
+		res, ok := gaz.Lookup(a.q)
+		if !ok {
+			c.unresolved++
+			continue
+		}
+		_, err := tx.Exec(`UPDATE customer_addresses SET lat = ?, lng = ?, geo_precision = ? WHERE id = ?`,
+			res.Lat, res.Lng, res.Precision, a.id)
+		must(err)
+		c.resolved++
+	}
+	return c
+}
+
+func backfillWarehouses(tx *sql.Tx, gaz *geocode.Gazetteer, force bool) counts {
+	rows, err := tx.Query(`
+		SELECT id, latitude, longitude, geo_precision, city, region, postal_code, country
+		FROM warehouses
+		WHERE ` + pending(force) + `
+		ORDER BY id`)
+	must(err)
+
+	type wh struct {
+		id                  int
+		latitude, longitude string
+		precision           sql.NullString
+		q                   geocode.Query
+	}
+	var list []wh
+	for rows.Next() {
+		var w wh
+		must(rows.Scan(&w.id, &w.latitude, &w.longitude, &w.precision, &w.q.City, &w.q.Region, &w.q.PostalCode, &w.q.Country))
+		list = append(list, w)
+	}
+	must(rows.Err())
+	rows.Close()
+
+	var c counts
+	for _, w := range list {
+		c.scanned++
+		// Text entered before geocoding existed counts as manual; a forced rerun of a
+		// gazetteer match ignores the text, which was filled in from that match.
+		if !w.precision.Valid {
+			if lat, lng, err := geocode.ParseCoords(w.latitude, w.longitude); err == nil {
+				_, err := tx.Exec(`UPDATE warehouses SET lat = ?, lng = ?, geo_precision = ? WHERE id = ?`,
+					lat, lng, geocode.Manual, w.id)
+				must(err)
+				c.resolved++
+				continue
+			}
+		}
+		res, ok := gaz.Lookup(w.q)
+		if !ok {
+			log.Printf("warehouse %d: no usable latitude/longitude (%q, %q) and no gazetteer match", w.id, w.latitude, w.longitude)
+			c.unresolved++
+			continue
+		}
+		_, err := tx.Exec(`
+			UPDATE warehouses SET lat = ?, lng = ?, latitude = ?, longitude = ?, geo_precision = ?
+			WHERE id = ?`,
+			res.Lat, res.Lng, strconv.FormatFloat(res.Lat, 'f', -1, 64), strconv.FormatFloat(res.Lng, 'f', -1, 64),
+			res.Precision, w.id)
+		must(err)
+		c.resolved++
+	}
+	return c
+}
@@ -79,18 +79,29 @@ func main() {
 func seedCustomers(db *sql.DB, n int) {
 	tx, err := db.Begin()
 	must(err)
-	stmt, err := tx.Prepare(`INSERT INTO customers (name, email, phone, address, lat, lng) VALUES (?, ?, ?, ?, ?, ?)`)
+	stmt, err := tx.Prepare(`INSERT INTO customers (name, email, phone, address) VALUES (?, ?, ?, ?)`)
 	must(err)
 	defer stmt.Close()
+	addrStmt, err := tx.Prepare(`
+		INSERT INTO customer_addresses (customer_id, street, city, region, country, lat, lng,
+		                                is_default_billing, is_default_shipping, created_at)
+		VALUES (?, ?, 'Toronto', 'ON', 'CA', ?, ?, 1, 1, ?)`)
+	must(err)
+	defer addrStmt.Close()
+	now := time.Now().UTC().Format(time.RFC3339)
 
 	for i := 0; i < n; i++ {
 		name := fmt.Sprintf("Customer %06d", i)
 		email := fmt.Sprintf("c%06d@example.com", i)
 		phone := fmt.Sprintf("+1-416-%03d-%04d", rand.Intn(999), rand.Intn(9999))
-		address := fmt.Sprintf("%d Example St, Toronto, ON", randInRange(10, 9999))
+		street := fmt.Sprintf("%d Example St", randInRange(10, 9999))
 		lat := 43.6 + rand.Float64()*0.2
 		lng := -79.7 + rand.Float64()*0.3
-		_, err = stmt.Exec(name, email, phone, address, lat, lng)
+		res, err := stmt.Exec(name, email, phone, street+", Toronto, ON, CA")
+		must(err)
+		id, err := res.LastInsertId()
+		must(err)
+		_, err = addrStmt.Exec(id, street, lat, lng, now)
 		must(err)
 	}
 	must(tx.Commit())
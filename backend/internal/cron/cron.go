@@ -0,0 +1,136 @@
+// Package cron parses five-field cron expressions (minute, hour, day of month,
+// month, day of week) and finds the next time one matches. Fields take numbers,
+// "*", ranges "a-b", steps "*/n" or "a-b/n" and comma-separated lists; day of week
+// runs 0-7 with both 0 and 7 meaning Sunday. Names like MON or JAN are not supported.
+package cron
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule is a parsed expression. Each field is a bitset of the values it allows.
+type Schedule struct {
+	minute, hour, dom, month, dow uint64
+	// As in Vixie cron, when both day fields are restricted a day matching either one
+	// matches; when one of them starts with "*" both have to match.
+	domStar, dowStar bool
+}
+
+type bounds struct {
+	name     string
+	min, max int
+}
+
+var fieldBounds = [5]bounds{
+	{"minute", 0, 59},
+	{"hour", 0, 23},
+	{"day of month", 1, 31},
+	{"month", 1, 12},
+	{"day of week", 0, 7},
+}
+
+// Parse reads an expression such as "30 9 * * 1-5".
+func Parse(expr string) (*Schedule, error) {
+	parts := strings.Fields(expr)
+	if len(parts) != 5 {
+		return nil, fmt.Errorf("cron: expected 5 fields, got %d", len(parts))
+	}
+	var bits [5]uint64
+	for i, p := range parts {
+		b, err := parseField(p, fieldBounds[i])
+		if err != nil {
+			return nil, err
+		}
+		bits[i] = b
+	}
+	s := &Schedule{
+		minute:  bits[0],
+		hour:    bits[1],
+		dom:     bits[2],
+		month:   bits[3],
+		dow:     bits[4],
+		domStar: strings.HasPrefix(parts[2], "*"),
+		dowStar: strings.HasPrefix(parts[4], "*"),
+	}
+	if s.dow&(1<<7) != 0 {
+		s.dow |= 1
+	}
+	return s, nil
+}
+
+func parseField(field string, b bounds) (uint64, error) {
+	var bits uint64
+	for _, item := range strings.Split(field, ",") {
+		rng, step := item, 1
+		if i := strings.IndexByte(item, '/'); i >= 0 {
+			n, err := strconv.Atoi(item[i+1:])
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("cron: bad step in %s field %q", b.name, field)
+			}
+			rng, step = item[:i], n
+		}
+		lo, hi := b.min, b.max
+		switch {
+		case rng == "*":
+		case strings.Contains(rng, "-"):
+			a, z, _ := strings.Cut(rng, "-")
+			var err1, err2 error
+			lo, err1 = strconv.Atoi(a)
+			hi, err2 = strconv.Atoi(z)
+			if err1 != nil || err2 != nil {
+				return 0, fmt.Errorf("cron: bad range in %s field %q", b.name, field)
+			}
+		default:
+			n, err := strconv.Atoi(rng)
+			if err != nil {
+				return 0, fmt.Errorf("cron: bad value in %s field %q", b.name, field)
+			}
+			lo, hi = n, n
+			if step > 1 {
+				hi = b.max
+			}
+		}
+		if lo < b.min || hi > b.max || lo > hi {
+			return 0, fmt.Errorf("cron: %s field %q is outside %d-%d", b.name, field, b.min, b.max)
+		}
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+func (s *Schedule) dayMatches(t time.Time) bool {
+	dom := s.dom&(1<<uint(t.Day())) != 0
+	dow := s.dow&(1<<uint(t.Weekday())) != 0
+	if s.domStar || s.dowStar {
+		return dom && dow
+	}
+	return dom || dow
+}
+
+// Next returns the first matching minute after t, in t's location. It returns the
+// zero time when nothing matches within five years (for example "0 0 30 2 *").
+func (s *Schedule) Next(t time.Time) time.Time {
+	loc := t.Location()
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		switch {
+		case s.month&(1<<uint(t.Month())) == 0:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+		case !s.dayMatches(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+		case s.hour&(1<<uint(t.Hour())) == 0:
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc)
+		case s.minute&(1<<uint(t.Minute())) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
@@ -0,0 +1,178 @@
+package gateway
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Payment method tokens the fake gateway understands. Any other token is declined.
+const (
+	FakeCardOK             = "fake_ok"
+	FakeCardDeclined       = "fake_declined"
+	FakeCardDelayed        = "fake_delayed"         // pending, then authorized by webhook
+	FakeCardDelayedDecline = "fake_delayed_decline" // pending, then declined by webhook
+)
+
+// FakeSignatureHeader carries the hex HMAC-SHA256 of a fake webhook body.
+const FakeSignatureHeader = "Fake-Signature"
+
+// Fake simulates a gateway in memory. Delayed outcomes are sent WebhookDelay after
+// the authorization by calling Deliver with a signed body, as an HTTP webhook would
+// arrive; with Deliver unset they are never sent.
+type Fake struct {
+	Secret       []byte
+	WebhookDelay time.Duration
+	Deliver      func(body []byte, header http.Header)
+
+	mu      sync.Mutex
+	seq     int
+	charges map[string]*fakeCharge
+}
+
+type fakeCharge struct {
+	status             string
+	amount             float64
+	captured, refunded float64
+}
+
+type fakeWebhook struct {
+	ID            string  `json:"id"`
+	Ref           string  `json:"ref"`
+	Reference     string  `json:"reference"`
+	Status        string  `json:"status"`
+	Amount        float64 `json:"amount"`
+	DeclineReason string  `json:"declineReason,omitempty"`
+}
+
+// NewFake returns a fake gateway that signs webhooks with secret.
+func NewFake(secret string, webhookDelay time.Duration) *Fake {
+	return &Fake{Secret: []byte(secret), WebhookDelay: webhookDelay, charges: map[string]*fakeCharge{}}
+}
+
+func (f *Fake) Name() string { return "fake" }
+
+func (f *Fake) nextID(prefix string) string {
+	f.seq++
+	return fmt.Sprintf("%s_%d_%d", prefix, time.Now().Unix(), f.seq)
+}
+
+func (f *Fake) Authorize(ctx context.Context, req AuthorizeRequest) (Result, error) {
+	if req.Amount <= 0 {
+		return Result{}, fmt.Errorf("fake: amount must be positive")
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	ref := f.nextID("ch")
+	c := &fakeCharge{amount: req.Amount}
+	f.charges[ref] = c
+
+	res := Result{Ref: ref, Amount: req.Amount}
+	switch req.PaymentMethod {
+	case FakeCardOK:
+		c.status = StatusAuthorized
+	case FakeCardDelayed, FakeCardDelayedDecline:
+		c.status = StatusPending
+		f.later(ref, req, req.PaymentMethod == FakeCardDelayed)
+	case FakeCardDeclined:
+		c.status, res.DeclineReason = StatusDeclined, "card_declined"
+	default:
+		c.status, res.DeclineReason = StatusDeclined, "invalid_payment_method"
+	}
+	res.Status = c.status
+	return res, nil
+}
+
+// later settles a pending charge after WebhookDelay and delivers the webhook.
+func (f *Fake) later(ref string, req AuthorizeRequest, approve bool) {
+	time.AfterFunc(f.WebhookDelay, func() {
+		f.mu.Lock()
+		c := f.charges[ref]
+		ev := fakeWebhook{ID: f.nextID("evt"), Ref: ref, Reference: req.Reference, Amount: req.Amount}
+		if approve {
+			c.status = StatusAuthorized
+		} else {
+			c.status, ev.DeclineReason = StatusDeclined, "card_declined"
+		}
+		ev.Status = c.status
+		deliver := f.Deliver
+		f.mu.Unlock()
+
+		if deliver == nil {
+			return
+		}
+		body, _ := json.Marshal(ev)
+		header := http.Header{}
+		header.Set("Content-Type", "application/json")
+		header.Set(FakeSignatureHeader, hex.EncodeToString(f.sign(body)))
+		deliver(body, header)
+	})
+}
+
+func (f *Fake) Capture(ctx context.Context, ref string, amount float64) (Result, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	c, ok := f.charges[ref]
+	if !ok {
+		return Result{}, fmt.Errorf("fake: no charge %s", ref)
+	}
+	if c.status != StatusAuthorized {
+		return Result{}, fmt.Errorf("fake: charge %s is %s, not authorized", ref, c.status)
+	}
+	if amount <= 0 || amount > c.amount+0.005 {
+		return Result{}, fmt.Errorf("fake: capture amount must be between 0 and %.2f", c.amount)
+	}
+	c.status, c.captured = StatusCaptured, amount
+	return Result{Ref: ref, Status: StatusCaptured, Amount: amount}, nil
+}
+
+func (f *Fake) Refund(ctx context.Context, ref string, amount float64) (Result, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	c, ok := f.charges[ref]
+	if !ok {
+		return Result{}, fmt.Errorf("fake: no charge %s", ref)
+	}
+	if c.status != StatusCaptured && c.status != StatusRefunded {
+		return Result{}, fmt.Errorf("fake: charge %s is %s, not captured", ref, c.status)
+	}
+	if amount <= 0 || c.refunded+amount > c.captured+0.005 {
+		return Result{}, fmt.Errorf("fake: refund amount must be between 0 and %.2f", c.captured-c.refunded)
+	}
+	c.refunded += amount
+	if c.refunded >= c.captured-0.005 {
+		c.status = StatusRefunded
+	}
+	return Result{Ref: ref, Status: StatusRefunded, Amount: amount}, nil
+}
+
+func (f *Fake) sign(body []byte) []byte {
+	mac := hmac.New(sha256.New, f.Secret)
+	mac.Write(body)
+	return mac.Sum(nil)
+}
+
+func (f *Fake) VerifyWebhook(body []byte, header http.Header) (WebhookEvent, error) {
+	sig, err := hex.DecodeString(header.Get(FakeSignatureHeader))
+	if err != nil || !hmac.Equal(sig, f.sign(body)) {
+		return WebhookEvent{}, ErrInvalidSignature
+	}
+	var ev fakeWebhook
+	if err := json.Unmarshal(body, &ev); err != nil {
+		return WebhookEvent{}, fmt.Errorf("fake: bad webhook body: %w", err)
+	}
+	return WebhookEvent{
+		ID:            ev.ID,
+		Ref:           ev.Ref,
+		Reference:     ev.Reference,
+		Status:        ev.Status,
+		Amount:        ev.Amount,
+		DeclineReason: ev.DeclineReason,
+	}, nil
+}
@@ -0,0 +1,66 @@
+// Package gateway defines the interface card payment gateways implement and ships
+// Fake, an offline gateway for development and tests.
+//
+// A charge is authorized first, then captured (all or part of the authorized
+// amount), and may later be refunded. Gateways that can't answer an authorization
+// straight away return StatusPending and report the outcome through a webhook,
+// which the application checks with VerifyWebhook before trusting it.
+package gateway
+
+import (
+	"context"
+	"errors"
+	"net/http"
+)
+
+// Outcome of a gateway call or webhook.
+const (
+	StatusPending    = "pending"
+	StatusAuthorized = "authorized"
+	StatusDeclined   = "declined"
+	StatusCaptured   = "captured"
+	StatusRefunded   = "refunded"
+)
+
+// ErrInvalidSignature is returned by VerifyWebhook for a payload the gateway didn't sign.
+var ErrInvalidSignature = errors.New("gateway: invalid webhook signature")
+
+// AuthorizeRequest asks the gateway to hold Amount on PaymentMethod, a token the
+// gateway issued for the customer's card. Reference is ours (the payment intent id)
+// and is echoed back in webhooks.
+type AuthorizeRequest struct {
+	Reference     string
+	Amount        float64
+	PaymentMethod string
+}
+
+// Result is the gateway's answer. Ref identifies the charge at the gateway and is
+// what Capture, Refund and webhooks refer to. A decline is a Result with
+// StatusDeclined, not an error; errors mean the gateway couldn't be asked.
+type Result struct {
+	Ref           string
+	Status        string
+	Amount        float64
+	DeclineReason string
+}
+
+// WebhookEvent is a verified notification about an earlier charge.
+type WebhookEvent struct {
+	ID            string // unique per event, for ignoring redeliveries
+	Ref           string
+	Reference     string
+	Status        string
+	Amount        float64
+	DeclineReason string
+}
+
+// PaymentProvider is a card payment gateway.
+type PaymentProvider interface {
+	// Name is the provider's key in webhook URLs and on stored payment intents.
+	Name() string
+	Authorize(ctx context.Context, req AuthorizeRequest) (Result, error)
+	Capture(ctx context.Context, ref string, amount float64) (Result, error)
+	Refund(ctx context.Context, ref string, amount float64) (Result, error)
+	// VerifyWebhook checks the request came from the gateway and decodes it.
+	VerifyWebhook(body []byte, header http.Header) (WebhookEvent, error)
+}
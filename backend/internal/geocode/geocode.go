@@ -0,0 +1,295 @@
+// Package geocode resolves postal codes and city names to coordinates from a local
+// gazetteer, so addresses get lat/lng without calling an external service.
+package geocode
+
+import (
+	"bufio"
+	_ "embed"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Precision says how a result was found: the caller gave coordinates (Manual),
+// or they came from a postal code or a city entry in the gazetteer.
+const (
+	Manual = "manual"
+	Postal = "postal"
+	City   = "city"
+)
+
+// Query is an address to resolve. Country and Region are optional but narrow the match;
+// Street is only searched (comma-separated parts, right to left) when PostalCode and
+// City are both empty, which covers free-text addresses migrated from older data.
+type Query struct {
+	Street     string
+	City       string
+	Region     string
+	PostalCode string
+	Country    string
+}
+
+// Result is a resolved coordinate pair.
+type Result struct {
+	Lat       float64
+	Lng       float64
+	Precision string
+	// Match is the gazetteer key that matched, e.g. "M5V" or "Toronto".
+	Match string
+}
+
+type entry struct {
+	country, key, region string
+	lat, lng             float64
+}
+
+// Gazetteer is an in-memory index of postal code prefixes and cities.
+type Gazetteer struct {
+	postal map[string][]entry // normalized postal key
+	city   map[string][]entry // normalized city name
+}
+
+//go:embed gazetteer.csv
+var bundled string
+
+var (
+	defaultOnce sync.Once
+	defaultGaz  *Gazetteer
+)
+
+// Default returns the gazetteer bundled with the binary, or the file named by
+// GEOCODE_GAZETTEER when that is set (same format, e.g. a fuller export).
+func Default() *Gazetteer {
+	defaultOnce.Do(func() {
+		if path := os.Getenv("GEOCODE_GAZETTEER"); path != "" {
+			g, err := LoadFile(path)
+			if err == nil {
+				defaultGaz = g
+				return
+			}
+			log.Warnf("geocode: using the bundled gazetteer, %s failed to load: %v", path, err)
+		}
+		g, err := Load(strings.NewReader(bundled))
+		if err != nil {
+			panic("geocode: bundled gazetteer: " + err.Error())
+		}
+		defaultGaz = g
+	})
+	return defaultGaz
+}
+
+// LoadFile reads a gazetteer file from disk.
+func LoadFile(path string) (*Gazetteer, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Load(f)
+}
+
+// Load parses gazetteer lines of the form kind,country,key,region,lat,lng where kind
+// is "postal" or "city". Blank lines and lines starting with # are skipped.
+func Load(r io.Reader) (*Gazetteer, error) {
+	g := &Gazetteer{postal: map[string][]entry{}, city: map[string][]entry{}}
+	sc := bufio.NewScanner(r)
+	line := 0
+	for sc.Scan() {
+		line++
+		text := strings.TrimSpace(sc.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		f := strings.Split(text, ",")
+		if len(f) != 6 {
+			return nil, fmt.Errorf("line %d: want 6 fields, got %d", line, len(f))
+		}
+		lat, err1 := strconv.ParseFloat(f[4], 64)
+		lng, err2 := strconv.ParseFloat(f[5], 64)
+		if err1 != nil || err2 != nil || !ValidCoords(lat, lng) {
+			return nil, fmt.Errorf("line %d: bad coordinates", line)
+		}
+		e := entry{country: strings.ToUpper(f[1]), key: f[2], region: strings.ToUpper(f[3]), lat: lat, lng: lng}
+		switch f[0] {
+		case "postal":
+			k := normalizePostal(e.key)
+			g.postal[k] = append(g.postal[k], e)
+		case "city":
+			k := normalizeName(e.key)
+			g.city[k] = append(g.city[k], e)
+		default:
+			return nil, fmt.Errorf("line %d: unknown kind %q", line, f[0])
+		}
+	}
+	return g, sc.Err()
+}
+
+// ValidCoords reports whether lat/lng are within ±90 and ±180.
+func ValidCoords(lat, lng float64) bool {
+	return lat >= -90 && lat <= 90 && lng >= -180 && lng <= 180
+}
+
+// ParseCoords parses latitude/longitude text such as "43.65" and "-79.38".
+func ParseCoords(lat, lng string) (float64, float64, error) {
+	la, err := strconv.ParseFloat(strings.TrimSpace(lat), 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("latitude %q is not a number", lat)
+	}
+	ln, err := strconv.ParseFloat(strings.TrimSpace(lng), 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("longitude %q is not a number", lng)
+	}
+	if !ValidCoords(la, ln) {
+		return 0, 0, fmt.Errorf("latitude must be within ±90 and longitude within ±180")
+	}
+	return la, ln, nil
+}
+
+// Lookup resolves q, preferring the postal code over the city. Postal codes match
+// exactly first, then by ever shorter prefix, so "M5V 2T6" falls back to "M5V" and then "M".
+func (g *Gazetteer) Lookup(q Query) (Result, bool) {
+	country := normalizeCountry(q.Country)
+	region := strings.ToUpper(strings.TrimSpace(q.Region))
+
+	if r, ok := g.lookupPostal(q.PostalCode, country); ok {
+		return r, true
+	}
+	if r, ok := g.lookupCity(q.City, region, country); ok {
+		return r, true
+	}
+	if q.PostalCode != "" || q.City != "" {
+		return Result{}, false
+	}
+
+	parts := strings.Split(q.Street, ",")
+	for i := len(parts) - 1; i >= 0; i-- {
+		part := strings.TrimSpace(parts[i])
+		if part == "" {
+			continue
+		}
+		if r, ok := g.lookupCity(part, region, country); ok {
+			return r, true
+		}
+		// "ON M5V 2T6": try the last two words, then the last word, as a postal code.
+		words := strings.Fields(part)
+		for n := 2; n >= 1; n-- {
+			if len(words) >= n {
+				if r, ok := g.lookupPostalExact(strings.Join(words[len(words)-n:], ""), country); ok {
+					return r, true
+				}
+			}
+		}
+	}
+	return Result{}, false
+}
+
+func (g *Gazetteer) lookupPostal(code, country string) (Result, bool) {
+	k := normalizePostal(code)
+	for n := len(k); n > 0; n-- {
+		if r, ok := g.lookupPostalKey(k[:n], country); ok {
+			return r, true
+		}
+	}
+	return Result{}, false
+}
+
+// lookupPostalExact only accepts the full code or its prefixes of 3 or more characters,
+// so words in free text like "St" don't match a one-letter region code.
+func (g *Gazetteer) lookupPostalExact(code, country string) (Result, bool) {
+	k := normalizePostal(code)
+	if len(k) < 3 || !hasDigit(k) {
+		return Result{}, false
+	}
+	for n := len(k); n >= 3; n-- {
+		if r, ok := g.lookupPostalKey(k[:n], country); ok {
+			return r, true
+		}
+	}
+	return Result{}, false
+}
+
+func (g *Gazetteer) lookupPostalKey(k, country string) (Result, bool) {
+	for _, e := range g.postal[k] {
+		if country == "" || e.country == country {
+			return Result{Lat: e.lat, Lng: e.lng, Precision: Postal, Match: e.key}, true
+		}
+	}
+	return Result{}, false
+}
+
+// lookupCity prefers an entry in the given region, then the first listed entry in the
+// country (or in any country when none is given).
+func (g *Gazetteer) lookupCity(name, region, country string) (Result, bool) {
+	list := g.city[normalizeName(name)]
+	var fallback *entry
+	for i := range list {
+		e := &list[i]
+		if country != "" && e.country != country {
+			continue
+		}
+		if region != "" && e.region == region {
+			return Result{Lat: e.lat, Lng: e.lng, Precision: City, Match: e.key}, true
+		}
+		if fallback == nil {
+			fallback = e
+		}
+	}
+	if fallback == nil {
+		return Result{}, false
+	}
+	return Result{Lat: fallback.lat, Lng: fallback.lng, Precision: City, Match: fallback.key}, true
+}
+
+func normalizePostal(s string) string {
+	var b strings.Builder
+	for _, r := range strings.ToUpper(s) {
+		if r != ' ' && r != '-' {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+func normalizeName(s string) string {
+	return strings.Join(strings.Fields(strings.ToLower(s)), " ")
+}
+
+func hasDigit(s string) bool {
+	return strings.ContainsAny(s, "0123456789")
+}
+
+var countryAliases = map[string]string{
+	"CANADA":                   "CA",
+	"CAN":                      "CA",
+	"UNITED STATES":            "US",
+	"UNITED STATES OF AMERICA": "US",
+	"USA":                      "US",
+	"UNITED KINGDOM":           "GB",
+	"UK":                       "GB",
+	"GREAT BRITAIN":            "GB",
+	"IRELAND":                  "IE",
+	"FRANCE":                   "FR",
+	"GERMANY":                  "DE",
+	"SPAIN":                    "ES",
+	"ITALY":                    "IT",
+	"NETHERLANDS":              "NL",
+	"MEXICO":                   "MX",
+	"BRAZIL":                   "BR",
+	"JAPAN":                    "JP",
+	"INDIA":                    "IN",
+	"AUSTRALIA":                "AU",
+}
+
+// normalizeCountry maps a country name or code to its ISO 3166 alpha-2 code.
+func normalizeCountry(s string) string {
+	c := strings.ToUpper(strings.Join(strings.Fields(s), " "))
+	if iso, ok := countryAliases[c]; ok {
+		return iso
+	}
+	return c
+}
@@ -0,0 +1,553 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/MananKakkar1/SalesBoard/backend/internal/models"
+	"github.com/MananKakkar1/SalesBoard/backend/internal/tools"
+	"github.com/go-chi/chi"
+	log "github.com/sirupsen/logrus"
+)
+
+// recentActivityLimit is how much of the timeline the customer detail endpoint includes.
+const recentActivityLimit = 10
+
+// ---------- Input DTOs ----------
+
+type activityCU struct {
+	Kind       string `json:"kind"` // note (default) | call | task; fixed once created
+	OrderID    *int   `json:"orderId"`
+	Subject    string `json:"subject"`
+	Body       string `json:"body"`
+	DueAt      string `json:"dueAt"` // tasks: RFC3339, or YYYY-MM-DD for the end of that day (UTC)
+	AssignedTo *int   `json:"assignedTo"`
+	UserID     *int   `json:"userId"`
+}
+
+type activityUserIn struct {
+	UserID *int `json:"userId"`
+}
+
+// ---------- helpers ----------
+
+const activityColumns = `id, kind, customer_id, order_id, subject, body, due_at, assigned_to, status,
+	completed_at, completed_by, created_by, created_at, updated_at`
+
+func scanActivity(row rowScanner) (models.Activity, error) {
+	var a models.Activity
+	err := row.Scan(&a.ID, &a.Kind, &a.CustomerID, &a.OrderID, &a.Subject, &a.Body, &a.DueAt, &a.AssignedTo,
+		&a.Status, &a.CompletedAt, &a.CompletedBy, &a.CreatedBy, &a.CreatedAt, &a.UpdatedAt)
+	if err == nil && a.Status != nil && *a.Status == "open" && a.DueAt != nil {
+		a.Overdue = *a.DueAt < time.Now().UTC().Format(time.RFC3339)
+	}
+	return a, err
+}
+
+func loadActivity(q queryer, id int) (models.Activity, error) {
+	return scanActivity(q.QueryRow(`SELECT `+activityColumns+` FROM activities WHERE id = ?`, id))
+}
+
+// queryActivities runs a SELECT of activityColumns (where and order are appended).
+func queryActivities(q queryer, where, order string, args ...any) ([]models.Activity, error) {
+	rows, err := q.Query(`SELECT `+activityColumns+` FROM activities WHERE `+where+` ORDER BY `+order, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := []models.Activity{}
+	for rows.Next() {
+		a, err := scanActivity(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, a)
+	}
+	return out, rows.Err()
+}
+
+// recentActivity is the newest part of a customer's timeline.
+func recentActivity(q queryer, customerID int) ([]models.Activity, error) {
+	return queryActivities(q, `customer_id = ?`, `created_at DESC, id DESC LIMIT ?`, customerID, recentActivityLimit)
+}
+
+// parseDueAt accepts RFC3339 or a bare date, which means the end of that day in UTC.
+func parseDueAt(s string) (string, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t.UTC().Format(time.RFC3339), nil
+	}
+	if t, err := time.Parse(dateLayout, s); err == nil {
+		return t.Add(24*time.Hour - time.Second).Format(time.RFC3339), nil
+	}
+	return "", &invalidFieldError{"dueAt must be RFC3339 or YYYY-MM-DD"}
+}
+
+// normalize validates the fields and returns the due date to store.
+func (in *activityCU) normalize(q queryer) (dueAt any, err error) {
+	in.Kind = strings.TrimSpace(in.Kind)
+	if in.Kind == "" {
+		in.Kind = "note"
+	}
+	if in.Kind != "note" && in.Kind != "call" && in.Kind != "task" {
+		return nil, &invalidFieldError{"kind must be note, call or task"}
+	}
+	in.Subject = strings.TrimSpace(in.Subject)
+	in.Body = strings.TrimSpace(in.Body)
+	if in.Subject == "" && in.Body == "" {
+		return nil, &invalidFieldError{"subject or body is required"}
+	}
+	in.DueAt = strings.TrimSpace(in.DueAt)
+	if in.Kind != "task" {
+		if in.DueAt != "" || in.AssignedTo != nil {
+			return nil, &invalidFieldError{"only tasks have dueAt and assignedTo"}
+		}
+		return nil, checkUser(q, in.UserID)
+	}
+	if in.DueAt == "" {
+		return nil, &invalidFieldError{"dueAt is required for tasks"}
+	}
+	due, err := parseDueAt(in.DueAt)
+	if err != nil {
+		return nil, err
+	}
+	if in.AssignedTo == nil {
+		in.AssignedTo = in.UserID
+	}
+	if err := checkUser(q, in.AssignedTo); err != nil {
+		return nil, err
+	}
+	return due, checkUser(q, in.UserID)
+}
+
+// insertActivity logs an activity for the customer. An order, when given, must be theirs.
+func insertActivity(tx *sql.Tx, customerID int, in activityCU) (int, error) {
+	if ok, err := customerExists(tx, customerID); err != nil {
+		return 0, err
+	} else if !ok {
+		return 0, sql.ErrNoRows
+	}
+	due, err := in.normalize(tx)
+	if err != nil {
+		return 0, err
+	}
+	if in.OrderID != nil {
+		var owner int
+		err := tx.QueryRow(`SELECT customerId FROM orders WHERE orderId = ?`, *in.OrderID).Scan(&owner)
+		if err == sql.ErrNoRows || (err == nil && owner != customerID) {
+			return 0, &invalidFieldError{fmt.Sprintf("order %d is not one of customer %d's orders", *in.OrderID, customerID)}
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+	var status any
+	if in.Kind == "task" {
+		status = "open"
+	}
+	now := time.Now().UTC().Format(time.RFC3339)
+	res, err := tx.Exec(`
+		INSERT INTO activities (kind, customer_id, order_id, subject, body, due_at, assigned_to, status, created_by, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		in.Kind, customerID, in.OrderID, in.Subject, in.Body, due, in.AssignedTo, status, in.UserID, now, now,
+	)
+	if err != nil {
+		return 0, err
+	}
+	id, _ := res.LastInsertId()
+	return int(id), nil
+}
+
+func broadcastActivity(eventType string, a models.Activity) {
+	tools.SSE.Broadcast(tools.Event{
+		Type: eventType,
+		Data: map[string]any{
+			"activityId": a.ID,
+			"kind":       a.Kind,
+			"customerId": a.CustomerID,
+			"orderId":    a.OrderID,
+			"subject":    a.Subject,
+			"dueAt":      a.DueAt,
+			"assignedTo": a.AssignedTo,
+		},
+		Time: time.Now(),
+	})
+}
+
+// createActivity inserts in for customerID and answers 201 with the activity.
+func createActivity(w http.ResponseWriter, customerID int, in activityCU) {
+	tx, err := tools.DB.Begin()
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	defer tx.Rollback()
+
+	id, err := insertActivity(tx, customerID, in)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Customer not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		writeFieldError(w, err)
+		return
+	}
+	a, err := loadActivity(tx, id)
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	broadcastActivity("activity.created", a)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(a)
+}
+
+// ---------- Create on a customer (POST /api/customers/{id}/activities) ----------
+// body: { "kind": "task", "subject": "Send price list", "dueAt": "2025-07-01", "assignedTo": 2,
+//
+//	"orderId": 14, "userId": 1 }
+func createCustomerActivityHandler(w http.ResponseWriter, r *http.Request) {
+	customerID, err := atoiParam(chi.URLParam(r, "id"))
+	if err != nil {
+		tools.HandleBadRequest(w, err)
+		return
+	}
+	var in activityCU
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		tools.HandleBadRequest(w, errors.New("invalid request"))
+		return
+	}
+	createActivity(w, customerID, in)
+}
+
+// ---------- Create on an order (POST /api/orders/{id}/activities) ----------
+// Same body as for a customer; the activity goes on the order's customer's timeline.
+func createOrderActivityHandler(w http.ResponseWriter, r *http.Request) {
+	orderID, err := atoiParam(chi.URLParam(r, "id"))
+	if err != nil {
+		tools.HandleBadRequest(w, err)
+		return
+	}
+	var in activityCU
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		tools.HandleBadRequest(w, errors.New("invalid request"))
+		return
+	}
+	var customerID int
+	err = tools.DB.QueryRow(`SELECT customerId FROM orders WHERE orderId = ?`, orderID).Scan(&customerID)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Order not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	in.OrderID = &orderID
+	createActivity(w, customerID, in)
+}
+
+// ---------- Customer timeline (GET /api/customers/{id}/activities?kind=&page=&pageSize=) ----------
+// Newest first, including activities on the customer's orders.
+func getCustomerActivitiesHandler(w http.ResponseWriter, r *http.Request) {
+	customerID, err := atoiParam(chi.URLParam(r, "id"))
+	if err != nil {
+		tools.HandleBadRequest(w, err)
+		return
+	}
+	if ok, err := customerExists(tools.DB, customerID); err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	} else if !ok {
+		http.Error(w, "Customer not found", http.StatusNotFound)
+		return
+	}
+	where := `customer_id = ?`
+	args := []any{customerID}
+	if kind := r.URL.Query().Get("kind"); kind != "" {
+		if kind != "note" && kind != "call" && kind != "task" {
+			tools.HandleBadRequest(w, errors.New("kind must be note, call or task"))
+			return
+		}
+		where += ` AND kind = ?`
+		args = append(args, kind)
+	}
+	page, pageSize, offset := parsePage(r)
+
+	var total int
+	if err := tools.DB.QueryRow(`SELECT COUNT(*) FROM activities WHERE `+where, args...).Scan(&total); err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	data, err := queryActivities(tools.DB, where, `created_at DESC, id DESC LIMIT ? OFFSET ?`, append(args, pageSize, offset)...)
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"data":       data,
+		"pagination": paginationMeta(page, pageSize, total),
+	})
+}
+
+// ---------- Order timeline (GET /api/orders/{id}/activities) ----------
+func getOrderActivitiesHandler(w http.ResponseWriter, r *http.Request) {
+	orderID, err := atoiParam(chi.URLParam(r, "id"))
+	if err != nil {
+		tools.HandleBadRequest(w, err)
+		return
+	}
+	data, err := queryActivities(tools.DB, `order_id = ?`, `created_at DESC, id DESC`, orderID)
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(data)
+}
+
+// ---------- Read one (GET /api/activities/{id}) ----------
+func getActivityHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := atoiParam(chi.URLParam(r, "id"))
+	if err != nil {
+		tools.HandleBadRequest(w, err)
+		return
+	}
+	a, err := loadActivity(tools.DB, id)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Activity not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(a)
+}
+
+// ---------- Update (PUT /api/activities/{id}) ----------
+// body: { "subject", "body", "dueAt", "assignedTo" } — the kind and what it is attached
+// to don't change. A task given a new due date can fire its overdue event again.
+func updateActivityHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := atoiParam(chi.URLParam(r, "id"))
+	if err != nil {
+		tools.HandleBadRequest(w, err)
+		return
+	}
+	var in activityCU
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		tools.HandleBadRequest(w, errors.New("invalid request"))
+		return
+	}
+	cur, err := loadActivity(tools.DB, id)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Activity not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	in.Kind = cur.Kind
+	if in.Kind == "task" && in.AssignedTo == nil {
+		in.AssignedTo = cur.AssignedTo
+	}
+	due, err := in.normalize(tools.DB)
+	if err != nil {
+		writeFieldError(w, err)
+		return
+	}
+	if _, err := tools.DB.Exec(`
+		UPDATE activities
+		   SET subject = ?, body = ?, due_at = ?, assigned_to = ?, updated_at = ?,
+		       overdue_notified_at = CASE WHEN due_at IS ? THEN overdue_notified_at END
+		 WHERE id = ?`,
+		in.Subject, in.Body, due, in.AssignedTo, time.Now().UTC().Format(time.RFC3339), due, id,
+	); err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	getActivityHandler(w, r)
+}
+
+// setTaskStatus marks a task done or open again.
+func setTaskStatus(done bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := atoiParam(chi.URLParam(r, "id"))
+		if err != nil {
+			tools.HandleBadRequest(w, err)
+			return
+		}
+		var in activityUserIn
+		if r.ContentLength != 0 {
+			if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+				tools.HandleBadRequest(w, errors.New("invalid request"))
+				return
+			}
+		}
+		if err := checkUser(tools.DB, in.UserID); err != nil {
+			writeFieldError(w, err)
+			return
+		}
+		a, err := loadActivity(tools.DB, id)
+		if err == sql.ErrNoRows {
+			http.Error(w, "Activity not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			tools.HandleInternalServerError(w, err)
+			return
+		}
+		if a.Kind != "task" {
+			tools.HandleBadRequest(w, errors.New("only tasks can be completed"))
+			return
+		}
+		now := time.Now().UTC().Format(time.RFC3339)
+		if done {
+			_, err = tools.DB.Exec(
+				`UPDATE activities SET status = 'done', completed_at = ?, completed_by = ?, updated_at = ? WHERE id = ?`,
+				now, in.UserID, now, id,
+			)
+		} else {
+			_, err = tools.DB.Exec(
+				`UPDATE activities SET status = 'open', completed_at = NULL, completed_by = NULL, updated_at = ? WHERE id = ?`,
+				now, id,
+			)
+		}
+		if err != nil {
+			tools.HandleInternalServerError(w, err)
+			return
+		}
+		if a, err = loadActivity(tools.DB, id); err != nil {
+			tools.HandleInternalServerError(w, err)
+			return
+		}
+		if done {
+			broadcastActivity("task.completed", a)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(a)
+	}
+}
+
+// ---------- Delete (DELETE /api/activities/{id}) ----------
+func deleteActivityHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := atoiParam(chi.URLParam(r, "id"))
+	if err != nil {
+		tools.HandleBadRequest(w, err)
+		return
+	}
+	res, err := tools.DB.Exec(`DELETE FROM activities WHERE id = ?`, id)
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		http.Error(w, "Activity not found", http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ---------- Tasks per user (GET /api/users/{id}/tasks?status=open|done|all&overdue=true&page=&pageSize=) ----------
+// Tasks assigned to the user, soonest due first; status defaults to open.
+func getUserTasksHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := atoiParam(chi.URLParam(r, "id"))
+	if err != nil {
+		tools.HandleBadRequest(w, err)
+		return
+	}
+	if err := checkUser(tools.DB, &userID); err != nil {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+	where := `kind = 'task' AND assigned_to = ?`
+	args := []any{userID}
+	switch status := r.URL.Query().Get("status"); status {
+	case "", "open":
+		where += ` AND status = 'open'`
+	case "done":
+		where += ` AND status = 'done'`
+	case "all":
+	default:
+		tools.HandleBadRequest(w, errors.New("status must be open, done or all"))
+		return
+	}
+	if r.URL.Query().Get("overdue") == "true" {
+		where += ` AND status = 'open' AND due_at < ?`
+		args = append(args, time.Now().UTC().Format(time.RFC3339))
+	}
+	page, pageSize, offset := parsePage(r)
+
+	var total int
+	if err := tools.DB.QueryRow(`SELECT COUNT(*) FROM activities WHERE `+where, args...).Scan(&total); err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	data, err := queryActivities(tools.DB, where, `due_at, id LIMIT ? OFFSET ?`, append(args, pageSize, offset)...)
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"data":       data,
+		"pagination": paginationMeta(page, pageSize, total),
+	})
+}
+
+// ---------- Overdue tasks ----------
+
+// markOverdueTasks flags open tasks that fell due before now and haven't been
+// announced yet, and returns them.
+func markOverdueTasks(now time.Time) ([]models.Activity, error) {
+	tx, err := tools.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	ts := now.UTC().Format(time.RFC3339)
+	due, err := queryActivities(tx, `status = 'open' AND due_at < ? AND overdue_notified_at IS NULL`, `due_at, id`, ts)
+	if err != nil {
+		return nil, err
+	}
+	for _, a := range due {
+		if _, err := tx.Exec(`UPDATE activities SET overdue_notified_at = ? WHERE id = ?`, ts, a.ID); err != nil {
+			return nil, err
+		}
+	}
+	return due, tx.Commit()
+}
+
+// StartTaskScheduler sends a task.overdue event once for each open task that passes
+// its due date, checking every interval.
+func StartTaskScheduler(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			overdue, err := markOverdueTasks(time.Now())
+			if err != nil {
+				log.Errorf("task scheduler: %v", err)
+			}
+			for _, a := range overdue {
+				a.Overdue = true
+				broadcastActivity("task.overdue", a)
+			}
+			<-ticker.C
+		}
+	}()
+}
@@ -0,0 +1,432 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/MananKakkar1/SalesBoard/backend/internal/geocode"
+	"github.com/MananKakkar1/SalesBoard/backend/internal/models"
+	"github.com/MananKakkar1/SalesBoard/backend/internal/tools"
+	"github.com/go-chi/chi"
+)
+
+// ---------- Input DTOs ----------
+
+// addressCU is the body for creating or replacing an address. Nil default flags
+// leave the current setting alone (false for a new address, except a customer's
+// first address, which becomes both defaults).
+type addressCU struct {
+	Label             string   `json:"label"`
+	Street            string   `json:"street"`
+	City              string   `json:"city"`
+	Region            string   `json:"region"`
+	PostalCode        string   `json:"postalCode"`
+	Country           string   `json:"country"`
+	Lat               *float64 `json:"lat"`
+	Lng               *float64 `json:"lng"`
+	IsDefaultBilling  *bool    `json:"isDefaultBilling"`
+	IsDefaultShipping *bool    `json:"isDefaultShipping"`
+}
+
+func (a *addressCU) normalize() error {
+	for _, f := range []*string{&a.Label, &a.Street, &a.City, &a.Region, &a.PostalCode, &a.Country} {
+		*f = strings.TrimSpace(*f)
+	}
+	if a.Street == "" {
+		return &invalidFieldError{"street is required"}
+	}
+	if (a.Lat == nil) != (a.Lng == nil) {
+		return &invalidFieldError{"lat and lng must be given together"}
+	}
+	if a.Lat != nil && !geocode.ValidCoords(*a.Lat, *a.Lng) {
+		return &invalidFieldError{"lat must be within ±90 and lng within ±180"}
+	}
+	return nil
+}
+
+// geocode fills Lat/Lng from the gazetteer when the caller didn't give them and
+// returns the geo_precision to store; nil when the address couldn't be resolved.
+func (a *addressCU) geocode() *string {
+	precision := geocode.Manual
+	if a.Lat == nil {
+		res, ok := geocode.Default().Lookup(geocode.Query{
+			Street: a.Street, City: a.City, Region: a.Region, PostalCode: a.PostalCode, Country: a.Country,
+		})
+		if !ok {
+			return nil
+		}
+		a.Lat, a.Lng, precision = &res.Lat, &res.Lng, res.Precision
+	}
+	return &precision
+}
+
+// ---------- helpers ----------
+
+const addressColumns = `id, customer_id, label, street, city, region, postal_code, country, lat, lng,
+	       geo_precision, is_default_billing, is_default_shipping, created_at`
+
+func scanAddress(row rowScanner) (models.CustomerAddress, error) {
+	var a models.CustomerAddress
+	err := row.Scan(&a.ID, &a.CustomerID, &a.Label, &a.Street, &a.City, &a.Region, &a.PostalCode, &a.Country,
+		&a.Lat, &a.Lng, &a.GeoPrecision, &a.IsDefaultBilling, &a.IsDefaultShipping, &a.CreatedAt)
+	return a, err
+}
+
+// formatAddress renders an address on one line, e.g. "1 Main St, Toronto, ON M5V 2T6, CA".
+func formatAddress(a models.CustomerAddress) string {
+	parts := []string{a.Street}
+	if a.City != "" {
+		parts = append(parts, a.City)
+	}
+	if rp := strings.TrimSpace(a.Region + " " + a.PostalCode); rp != "" {
+		parts = append(parts, rp)
+	}
+	if a.Country != "" {
+		parts = append(parts, a.Country)
+	}
+	return strings.Join(parts, ", ")
+}
+
+func loadAddresses(q queryer, customerID int) ([]models.CustomerAddress, error) {
+	rows, err := q.Query(`SELECT `+addressColumns+` FROM customer_addresses WHERE customer_id = ? ORDER BY id`, customerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []models.CustomerAddress{}
+	for rows.Next() {
+		a, err := scanAddress(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, a)
+	}
+	return out, rows.Err()
+}
+
+// loadAddress returns one of the customer's addresses, or sql.ErrNoRows.
+func loadAddress(q queryer, customerID, addressID int) (models.CustomerAddress, error) {
+	return scanAddress(q.QueryRow(
+		`SELECT `+addressColumns+` FROM customer_addresses WHERE id = ? AND customer_id = ?`, addressID, customerID,
+	))
+}
+
+// resolveShippingAddress returns the order's shipping address: addressID when given
+// (it must belong to the customer), otherwise the customer's default shipping address.
+// nil means the customer has no address to ship to.
+func resolveShippingAddress(q queryer, customerID int, addressID *int) (*models.CustomerAddress, error) {
+	if addressID != nil {
+		a, err := loadAddress(q, customerID, *addressID)
+		if err == sql.ErrNoRows {
+			return nil, &invalidFieldError{fmt.Sprintf("address %d does not belong to customer %d", *addressID, customerID)}
+		}
+		if err != nil {
+			return nil, err
+		}
+		return &a, nil
+	}
+	a, err := scanAddress(q.QueryRow(
+		`SELECT `+addressColumns+` FROM customer_addresses WHERE customer_id = ? AND is_default_shipping = 1`, customerID,
+	))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+// insertAddress adds an address, geocoding it when lat/lng are missing; a customer's
+// first address becomes both defaults.
+func insertAddress(tx *sql.Tx, customerID int, in addressCU) (int, error) {
+	precision := in.geocode()
+	var existing int
+	if err := tx.QueryRow(`SELECT COUNT(*) FROM customer_addresses WHERE customer_id = ?`, customerID).Scan(&existing); err != nil {
+		return 0, err
+	}
+	billing := existing == 0 || (in.IsDefaultBilling != nil && *in.IsDefaultBilling)
+	shipping := existing == 0 || (in.IsDefaultShipping != nil && *in.IsDefaultShipping)
+	if err := clearDefaults(tx, customerID, billing, shipping); err != nil {
+		return 0, err
+	}
+	res, err := tx.Exec(`
+		INSERT INTO customer_addresses (customer_id, label, street, city, region, postal_code, country, lat, lng,
+		                                geo_precision, is_default_billing, is_default_shipping, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		customerID, in.Label, in.Street, in.City, in.Region, in.PostalCode, in.Country, in.Lat, in.Lng,
+		precision, billing, shipping, time.Now().UTC().Format(time.RFC3339),
+	)
+	if err != nil {
+		return 0, err
+	}
+	id, _ := res.LastInsertId()
+	return int(id), nil
+}
+
+// clearDefaults drops the customer's current default billing and/or shipping flag
+// so another address can take it.
+func clearDefaults(tx *sql.Tx, customerID int, billing, shipping bool) error {
+	if billing {
+		if _, err := tx.Exec(`UPDATE customer_addresses SET is_default_billing = 0 WHERE customer_id = ?`, customerID); err != nil {
+			return err
+		}
+	}
+	if shipping {
+		if _, err := tx.Exec(`UPDATE customer_addresses SET is_default_shipping = 0 WHERE customer_id = ?`, customerID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// promoteDefaults gives a customer without a default billing or shipping address
+// their oldest address for it.
+func promoteDefaults(tx *sql.Tx, customerID int) error {
+	for _, col := range []string{"is_default_billing", "is_default_shipping"} {
+		if _, err := tx.Exec(`
+			UPDATE customer_addresses SET `+col+` = 1
+			 WHERE id = (SELECT MIN(id) FROM customer_addresses WHERE customer_id = ?)
+			   AND NOT EXISTS (SELECT 1 FROM customer_addresses WHERE customer_id = ? AND `+col+` = 1)`,
+			customerID, customerID,
+		); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// syncCustomerAddress keeps customers.address (the display text the customer list
+// shows) in line with the default shipping address.
+func syncCustomerAddress(tx *sql.Tx, customerID int) error {
+	a, err := resolveShippingAddress(tx, customerID, nil)
+	if err != nil || a == nil {
+		return err
+	}
+	_, err = tx.Exec(`UPDATE customers SET address = ? WHERE id = ?`, formatAddress(*a), customerID)
+	return err
+}
+
+func customerExists(q queryer, id int) (bool, error) {
+	var n int
+	err := q.QueryRow(`SELECT COUNT(*) FROM customers WHERE id = ?`, id).Scan(&n)
+	return n > 0, err
+}
+
+// ---------- List (GET /api/customers/{id}/addresses) ----------
+func getCustomerAddressesHandler(w http.ResponseWriter, r *http.Request) {
+	customerID, err := atoiParam(chi.URLParam(r, "id"))
+	if err != nil {
+		tools.HandleBadRequest(w, err)
+		return
+	}
+	if ok, err := customerExists(tools.DB, customerID); err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	} else if !ok {
+		http.Error(w, "Customer not found", http.StatusNotFound)
+		return
+	}
+	list, err := loadAddresses(tools.DB, customerID)
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(list)
+}
+
+// ---------- Create (POST /api/customers/{id}/addresses) ----------
+// body: { "label": "HQ", "street": "1 Main St", "city": "Toronto", "region": "ON",
+//         "postalCode": "M5V 2T6", "country": "CA", "isDefaultShipping": true }
+// lat/lng are optional; without them the address is geocoded from the local gazetteer.
+func createCustomerAddressHandler(w http.ResponseWriter, r *http.Request) {
+	customerID, err := atoiParam(chi.URLParam(r, "id"))
+	if err != nil {
+		tools.HandleBadRequest(w, err)
+		return
+	}
+	var in addressCU
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		tools.HandleBadRequest(w, errors.New("invalid request"))
+		return
+	}
+	if err := in.normalize(); err != nil {
+		writeFieldError(w, err)
+		return
+	}
+
+	tx, err := tools.DB.Begin()
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	defer tx.Rollback()
+
+	if ok, err := customerExists(tx, customerID); err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	} else if !ok {
+		http.Error(w, "Customer not found", http.StatusNotFound)
+		return
+	}
+	id, err := insertAddress(tx, customerID, in)
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	if err := syncCustomerAddress(tx, customerID); err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	a, err := loadAddress(tx, customerID, id)
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(a)
+}
+
+// ---------- Update (PUT /api/customers/{id}/addresses/{addressId}) ----------
+// Replaces the address fields and geocodes again unless lat/lng are given. Setting a
+// default flag moves it from the customer's other addresses; a default can't be
+// cleared directly, only moved.
+func updateCustomerAddressHandler(w http.ResponseWriter, r *http.Request) {
+	customerID, err := atoiParam(chi.URLParam(r, "id"))
+	if err != nil {
+		tools.HandleBadRequest(w, err)
+		return
+	}
+	addressID, err := atoiParam(chi.URLParam(r, "addressId"))
+	if err != nil {
+		tools.HandleBadRequest(w, err)
+		return
+	}
+	var in addressCU
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		tools.HandleBadRequest(w, errors.New("invalid request"))
+		return
+	}
+	if err := in.normalize(); err != nil {
+		writeFieldError(w, err)
+		return
+	}
+
+	tx, err := tools.DB.Begin()
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	defer tx.Rollback()
+
+	cur, err := loadAddress(tx, customerID, addressID)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Address not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	if (in.IsDefaultBilling != nil && !*in.IsDefaultBilling && cur.IsDefaultBilling) ||
+		(in.IsDefaultShipping != nil && !*in.IsDefaultShipping && cur.IsDefaultShipping) {
+		tools.HandleBadRequest(w, errors.New("make another address the default instead of clearing this one"))
+		return
+	}
+	billing := in.IsDefaultBilling != nil && *in.IsDefaultBilling
+	shipping := in.IsDefaultShipping != nil && *in.IsDefaultShipping
+	if err := clearDefaults(tx, customerID, billing && !cur.IsDefaultBilling, shipping && !cur.IsDefaultShipping); err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	precision := in.geocode()
+
+	if _, err := tx.Exec(`
+		UPDATE customer_addresses
+		   SET label = ?, street = ?, city = ?, region = ?, postal_code = ?, country = ?, lat = ?, lng = ?,
+		       geo_precision = ?, is_default_billing = ?, is_default_shipping = ?
+		 WHERE id = ?`,
+		in.Label, in.Street, in.City, in.Region, in.PostalCode, in.Country, in.Lat, in.Lng,
+		precision, billing || cur.IsDefaultBilling, shipping || cur.IsDefaultShipping, addressID,
+	); err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	if err := syncCustomerAddress(tx, customerID); err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	a, err := loadAddress(tx, customerID, addressID)
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(a)
+}
+
+// ---------- Delete (DELETE /api/customers/{id}/addresses/{addressId}) ----------
+// Orders that shipped to the address keep their text snapshot. A removed default
+// passes to the customer's oldest remaining address.
+func deleteCustomerAddressHandler(w http.ResponseWriter, r *http.Request) {
+	customerID, err := atoiParam(chi.URLParam(r, "id"))
+	if err != nil {
+		tools.HandleBadRequest(w, err)
+		return
+	}
+	addressID, err := atoiParam(chi.URLParam(r, "addressId"))
+	if err != nil {
+		tools.HandleBadRequest(w, err)
+		return
+	}
+
+	tx, err := tools.DB.Begin()
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	defer tx.Rollback()
+
+	if _, err := loadAddress(tx, customerID, addressID); err == sql.ErrNoRows {
+		http.Error(w, "Address not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	if _, err := tx.Exec(`DELETE FROM customer_addresses WHERE id = ?`, addressID); err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	if err := promoteDefaults(tx, customerID); err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	if err := syncCustomerAddress(tx, customerID); err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
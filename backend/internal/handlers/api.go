@@ -37,6 +37,7 @@ func Handler(r *chi.Mux) {
 	r.Get("/api/customers/search-simple", searchCustomersSimpleHandler)
 	r.Get("/api/customers", getCustomersHandler)
 	r.Get("/api/customers/{id}", getCustomerByIdHandler)
+	r.Get("/api/customers/{id}/summary", getCustomerSummaryHandler)
 
 	// Products
 	r.Get("/api/products/low-stock", getLowStockProductsHandler)
@@ -77,4 +78,188 @@ func Handler(r *chi.Mux) {
 	r.Delete("/api/orders/{id}", deleteOrderHandler)
 	r.Delete("/api/customers/{id}", deleteCustomerHandler)
 	r.Post("/api/warehouses/transfer", transferInventoryHandler)
+
+	// Suppliers
+	r.Post("/api/suppliers", createSupplierHandler)
+	r.Get("/api/suppliers", getSuppliersHandler)
+	r.Get("/api/suppliers/{id}", getSupplierByIdHandler)
+	r.Put("/api/suppliers/{id}", updateSupplierHandler)
+	r.Delete("/api/suppliers/{id}", deleteSupplierHandler)
+	r.Get("/api/suppliers/{id}/products", getSupplierProductsHandler)
+	r.Patch("/api/suppliers/{id}/products", upsertSupplierProductsHandler)
+	r.Delete("/api/suppliers/{id}/products/{productId}", deleteSupplierProductHandler)
+
+	// Purchase orders
+	r.Post("/api/purchase-orders", createPurchaseOrderHandler)
+	r.Get("/api/purchase-orders", getPurchaseOrdersHandler)
+	r.Get("/api/purchase-orders/{id}", getPurchaseOrderByIdHandler)
+	r.Post("/api/purchase-orders/{id}/submit", submitPurchaseOrderHandler)
+	r.Post("/api/purchase-orders/{id}/cancel", cancelPurchaseOrderHandler)
+	r.Post("/api/purchase-orders/{id}/receive", receivePurchaseOrderHandler)
+	r.Get("/api/purchase-orders/{id}/receipts", getPurchaseOrderReceiptsHandler)
+
+	// Reorder planning
+	r.Get("/api/reorder-points", getReorderPointsHandler)
+	r.Put("/api/reorder-points", putReorderPointHandler)
+	r.Post("/api/reorder-points/recompute", recomputeReorderPointsHandler)
+	r.Delete("/api/reorder-points/{warehouseId}/{productId}", deleteReorderPointHandler)
+	r.Get("/api/reorder-suggestions", getReorderSuggestionsHandler)
+
+	// Cycle counts
+	r.Post("/api/cycle-counts", createCycleCountHandler)
+	r.Get("/api/cycle-counts", getCycleCountsHandler)
+	r.Get("/api/cycle-counts/{id}", getCycleCountByIdHandler)
+	r.Patch("/api/cycle-counts/{id}/counts", recordCycleCountsHandler)
+	r.Post("/api/cycle-counts/{id}/approve", approveCycleCountHandler)
+	r.Post("/api/cycle-counts/{id}/cancel", cancelCycleCountHandler)
+	r.Get("/api/reports/inventory-variance", getInventoryVarianceReportHandler)
+
+	// Bin locations
+	r.Post("/api/warehouses/{id}/bins", createBinHandler)
+	r.Get("/api/warehouses/{id}/bins", getWarehouseBinsHandler)
+	r.Get("/api/warehouses/{id}/bin-inventory", getWarehouseBinInventoryHandler)
+	r.Post("/api/warehouses/{id}/bins/transfer", transferBinStockHandler)
+	r.Put("/api/bins/{binId}", updateBinHandler)
+	r.Delete("/api/bins/{binId}", deleteBinHandler)
+	r.Get("/api/bins/{binId}/inventory", getBinInventoryHandler)
+	r.Get("/api/orders/{id}/pick-list", getOrderPickListHandler)
+
+	// Lots and expiry
+	r.Post("/api/warehouses/{id}/lots", createLotHandler)
+	r.Get("/api/warehouses/{id}/lots", getWarehouseLotsHandler)
+	r.Post("/api/lots/{lotId}/write-off", writeOffLotHandler)
+	r.Get("/api/reports/expiring-lots", getExpiringLotsReportHandler)
+
+	// Serial numbers
+	r.Post("/api/warehouses/{id}/serials", registerSerialsHandler)
+	r.Get("/api/serials", getSerialsHandler)
+	r.Post("/api/orders/{id}/items/{itemId}/serials", assignOrderItemSerialsHandler)
+
+	// Product variants
+	r.Put("/api/products/{id}/variant-attributes", putVariantAttributesHandler)
+	r.Get("/api/products/{id}/variants", getProductVariantsHandler)
+	r.Post("/api/products/{id}/variants", createProductVariantHandler)
+	r.Post("/api/products/{id}/variants/generate", generateProductVariantsHandler)
+	r.Put("/api/products/{id}/variants/{variantId}", updateProductVariantHandler)
+
+	// Catalogue: categories and code lookup
+	r.Get("/api/products/by-code/{code}", getProductByCodeHandler)
+	r.Post("/api/categories", createCategoryHandler)
+	r.Get("/api/categories", getCategoriesHandler)
+	r.Get("/api/categories/{id}", getCategoryByIdHandler)
+	r.Put("/api/categories/{id}", updateCategoryHandler)
+	r.Delete("/api/categories/{id}", deleteCategoryHandler)
+
+	// Kits
+	r.Get("/api/products/{id}/kit", getProductKitHandler)
+	r.Put("/api/products/{id}/kit", putProductKitHandler)
+
+	// Price history and scheduled price changes
+	r.Get("/api/products/{id}/price-history", getPriceHistoryHandler)
+	r.Post("/api/products/{id}/price-changes", createPriceChangeHandler)
+	r.Delete("/api/products/{id}/price-changes/{changeId}", cancelPriceChangeHandler)
+
+	// Costs and margins
+	r.Get("/api/products/{id}/cost", getProductCostHandler)
+	r.Put("/api/products/{id}/cost", putProductCostHandler)
+	r.Get("/api/reports/margins/products", getProductMarginsHandler)
+	r.Get("/api/reports/margins/customers", getCustomerMarginsHandler)
+	r.Get("/api/reports/margins/periods", getPeriodMarginsHandler)
+
+	// Archive / restore (DELETE archives; ?hard=true deletes unreferenced rows)
+	r.Post("/api/products/{id}/restore", restoreProductHandler)
+	r.Post("/api/customers/{id}/restore", restoreCustomerHandler)
+	r.Post("/api/warehouses/{id}/restore", restoreWarehouseHandler)
+
+	// Attachments (product images, order documents)
+	r.Post("/api/products/{id}/images", uploadProductImageHandler)
+	r.Get("/api/products/{id}/images", getProductImagesHandler)
+	r.Delete("/api/products/{id}/images/{attachmentId}", deleteProductImageHandler)
+	r.Post("/api/orders/{id}/attachments", uploadOrderAttachmentHandler)
+	r.Get("/api/orders/{id}/attachments", getOrderAttachmentsHandler)
+	r.Delete("/api/orders/{id}/attachments/{attachmentId}", deleteOrderAttachmentHandler)
+	r.Get("/api/attachments/{id}", getAttachmentFileHandler)
+	r.Get("/api/attachments/{id}/thumbnail", getAttachmentThumbnailHandler)
+
+	// Customer addresses
+	r.Get("/api/customers/{id}/addresses", getCustomerAddressesHandler)
+	r.Post("/api/customers/{id}/addresses", createCustomerAddressHandler)
+	r.Put("/api/customers/{id}/addresses/{addressId}", updateCustomerAddressHandler)
+	r.Delete("/api/customers/{id}/addresses/{addressId}", deleteCustomerAddressHandler)
+
+	// Credit terms and receivables
+	r.Get("/api/customers/{id}/credit", getCustomerCreditHandler)
+	r.Put("/api/customers/{id}/credit", putCustomerCreditHandler)
+	r.Put("/api/orders/{id}/paid", setOrderPaidHandler)
+	r.Get("/api/reports/ar-aging", getARAgingHandler)
+
+	// Payments ledger
+	r.Get("/api/payments", getPaymentsHandler)
+	r.Post("/api/payments", createPaymentHandler)
+	r.Get("/api/payments/{id}", getPaymentHandler)
+	r.Post("/api/payments/{id}/apply", applyPaymentHandler)
+
+	// Card payments through the payment gateway
+	r.Get("/api/orders/{id}/payment-intents", getOrderPaymentIntentsHandler)
+	r.Post("/api/orders/{id}/payment-intents", createPaymentIntentHandler)
+	r.Get("/api/payment-intents/{id}", getPaymentIntentHandler)
+	r.Post("/api/payment-intents/{id}/capture", intentActionHandler(captureIntent))
+	r.Post("/api/payment-intents/{id}/refund", intentActionHandler(refundIntent))
+	r.Post("/api/payment-webhooks/{provider}", paymentWebhookHandler)
+
+	// Customer tags and segments
+	r.Get("/api/customer-tags", getCustomerTagCountsHandler)
+	r.Get("/api/customers/{id}/tags", getCustomerTagsHandler)
+	r.Post("/api/customers/{id}/tags", customerTagsHandler(false))
+	r.Put("/api/customers/{id}/tags", customerTagsHandler(true))
+	r.Delete("/api/customers/{id}/tags/{tag}", deleteCustomerTagHandler)
+	r.Get("/api/customer-segments", getSegmentsHandler)
+	r.Post("/api/customer-segments", createSegmentHandler)
+	r.Get("/api/customer-segments/{id}", getSegmentHandler)
+	r.Put("/api/customer-segments/{id}", updateSegmentHandler)
+	r.Delete("/api/customer-segments/{id}", deleteSegmentHandler)
+
+	// Activity timeline and tasks
+	r.Get("/api/customers/{id}/activities", getCustomerActivitiesHandler)
+	r.Post("/api/customers/{id}/activities", createCustomerActivityHandler)
+	r.Get("/api/orders/{id}/activities", getOrderActivitiesHandler)
+	r.Post("/api/orders/{id}/activities", createOrderActivityHandler)
+	r.Get("/api/activities/{id}", getActivityHandler)
+	r.Put("/api/activities/{id}", updateActivityHandler)
+	r.Delete("/api/activities/{id}", deleteActivityHandler)
+	r.Post("/api/activities/{id}/complete", setTaskStatus(true))
+	r.Post("/api/activities/{id}/reopen", setTaskStatus(false))
+	r.Get("/api/users/{id}/tasks", getUserTasksHandler)
+
+	// Backorders waiting for stock
+	r.Get("/api/backorders", getBackordersHandler)
+
+	// Recurring order templates
+	r.Get("/api/recurring-orders", getRecurringOrdersHandler)
+	r.Post("/api/recurring-orders", createRecurringOrderHandler)
+	r.Get("/api/recurring-orders/{id}", getRecurringOrderHandler)
+	r.Put("/api/recurring-orders/{id}", updateRecurringOrderHandler)
+	r.Delete("/api/recurring-orders/{id}", deleteRecurringOrderHandler)
+	r.Get("/api/recurring-orders/{id}/runs", getRecurringOrderRunsHandler)
+	r.Post("/api/recurring-orders/{id}/pause", setRecurringOrderStatus(false))
+	r.Post("/api/recurring-orders/{id}/resume", setRecurringOrderStatus(true))
+	r.Post("/api/recurring-orders/{id}/skip-next", skipNextRecurringOrderHandler)
+	r.Post("/api/recurring-orders/{id}/run", runRecurringOrderHandler)
+
+	// Quotes and converting them into orders
+	r.Get("/api/quotes", getQuotesHandler)
+	r.Post("/api/quotes", createQuoteHandler)
+	r.Get("/api/quotes/{id}", getQuoteHandler)
+	r.Put("/api/quotes/{id}", updateQuoteHandler)
+	r.Delete("/api/quotes/{id}", deleteQuoteHandler)
+	r.Get("/api/quotes/{id}/versions", getQuoteVersionsHandler)
+	r.Post("/api/quotes/{id}/send", sendQuoteHandler)
+	r.Post("/api/quotes/{id}/accept", acceptQuoteHandler)
+
+	// Customer duplicates and merges
+	r.Get("/api/customers/duplicates", getDuplicateCustomersHandler)
+	r.Get("/api/customers/{id}/duplicates", getCustomerDuplicatesHandler)
+	r.Post("/api/customers/{id}/merge", mergeCustomerHandler)
+	r.Get("/api/customer-merges", getCustomerMergesHandler)
+	r.Post("/api/customer-merges/{id}/undo", undoCustomerMergeHandler)
 }
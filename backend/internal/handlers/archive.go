@@ -0,0 +1,276 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/MananKakkar1/SalesBoard/backend/internal/tools"
+	"github.com/go-chi/chi"
+)
+
+// Products, customers and warehouses are archived by DELETE: deleted_at is set and
+// list endpoints stop showing the row, but order history keeps pointing at it.
+// DELETE ?hard=true removes the row for good, and only when nothing references it.
+
+// deleteBlocker is one kind of reference that stops a hard delete.
+type deleteBlocker struct {
+	Reference string `json:"reference"`
+	Count     int    `json:"count"`
+}
+
+// blockerCheck counts rows of one reference; query binds the id once.
+type blockerCheck struct {
+	reference string
+	query     string
+}
+
+// archivable describes a table that supports archive, restore and checked hard deletes.
+type archivable struct {
+	table string
+	label string
+	// parentColumn links child rows (product variants) that are archived and
+	// restored together with their parent.
+	parentColumn string
+	blockers     []blockerCheck
+	// cleanup runs before a hard delete to clear rows that are safe to drop; each binds the id once.
+	cleanup []string
+	// attachments is the attachments.owner_type removed (files included) by a hard delete.
+	attachments string
+}
+
+var productArchive = archivable{
+	table:        "products",
+	label:        "Product",
+	parentColumn: "parent_id",
+	blockers: []blockerCheck{
+		{"order_items", `SELECT COUNT(*) FROM order_items WHERE productId = ?`},
+		{"order_item_components", `SELECT COUNT(*) FROM order_item_components WHERE product_id = ?`},
+		{"warehouse_inventory", `SELECT COUNT(*) FROM warehouse_inventory WHERE product_id = ? AND qty > 0`},
+		{"purchase_order_lines", `SELECT COUNT(*) FROM purchase_order_lines WHERE product_id = ?`},
+		{"kit_components", `SELECT COUNT(*) FROM kit_components WHERE component_id = ?`},
+		{"quote_lines", `SELECT COUNT(*) FROM quote_lines WHERE product_id = ?`},
+		{"recurring_order_lines", `SELECT COUNT(*) FROM recurring_order_lines WHERE product_id = ?`},
+		{"variants", `SELECT COUNT(*) FROM products WHERE parent_id = ?`},
+	},
+	cleanup: []string{
+		`DELETE FROM warehouse_inventory WHERE product_id = ? AND qty = 0`,
+		`DELETE FROM supplier_products WHERE product_id = ?`,
+	},
+	attachments: "product",
+}
+
+var customerArchive = archivable{
+	table: "customers",
+	label: "Customer",
+	blockers: []blockerCheck{
+		{"orders", `SELECT COUNT(*) FROM orders WHERE customerId = ?`},
+		{"payments", `SELECT COUNT(*) FROM payments WHERE customer_id = ?`},
+		{"quotes", `SELECT COUNT(*) FROM quotes WHERE customer_id = ?`},
+		{"customer_merges", `SELECT COUNT(*) FROM customer_merges WHERE undone_at IS NULL AND ? IN (survivor_id, merged_id)`},
+	},
+	cleanup: []string{
+		`DELETE FROM activities WHERE customer_id = ?`,
+		`DELETE FROM customer_tags WHERE customer_id = ?`,
+		`DELETE FROM recurring_orders WHERE customer_id = ?`,
+	},
+}
+
+var warehouseArchive = archivable{
+	table: "warehouses",
+	label: "Warehouse",
+	blockers: []blockerCheck{
+		{"warehouse_inventory", `SELECT COUNT(*) FROM warehouse_inventory WHERE warehouse_id = ? AND qty > 0`},
+		{"order_items", `SELECT COUNT(*) FROM order_items WHERE warehouse_id = ?`},
+		{"purchase_orders", `SELECT COUNT(*) FROM purchase_orders WHERE warehouse_id = ?`},
+		{"stock_adjustments", `SELECT COUNT(*) FROM stock_adjustments WHERE warehouse_id = ?`},
+		{"cycle_counts", `SELECT COUNT(*) FROM cycle_counts WHERE warehouse_id = ?`},
+		{"quote_lines", `SELECT COUNT(*) FROM quote_lines WHERE warehouse_id = ?`},
+		{"recurring_order_lines", `SELECT COUNT(*) FROM recurring_order_lines WHERE warehouse_id = ?`},
+	},
+}
+
+// archivedScope is the list condition for ?archived=: active rows by default,
+// only archived rows with ?archived=true. col is the deleted_at column, aliased as needed.
+func archivedScope(r *http.Request, col string) string {
+	if r.URL.Query().Get("archived") == "true" {
+		return col + " IS NOT NULL"
+	}
+	return col + " IS NULL"
+}
+
+// findDeleteBlockers returns the references that still point at id.
+func findDeleteBlockers(q queryer, checks []blockerCheck, id int) ([]deleteBlocker, error) {
+	var out []deleteBlocker
+	for _, c := range checks {
+		var n int
+		if err := q.QueryRow(c.query, id).Scan(&n); err != nil {
+			return nil, err
+		}
+		if n > 0 {
+			out = append(out, deleteBlocker{Reference: c.reference, Count: n})
+		}
+	}
+	return out, nil
+}
+
+// ensureActive fails with invalidFieldError when the row is archived; missing rows pass
+// so callers keep their own not-found handling.
+func ensureActive(q queryer, a archivable, id int) error {
+	var deletedAt sql.NullString
+	err := q.QueryRow(`SELECT deleted_at FROM `+a.table+` WHERE id = ?`, id).Scan(&deletedAt)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if deletedAt.Valid {
+		return &invalidFieldError{fmt.Sprintf("%s %d is archived", a.label, id)}
+	}
+	return nil
+}
+
+// deleteArchivable archives the row, or with ?hard=true deletes it when unreferenced.
+// A blocked hard delete is a 409 listing the references.
+func deleteArchivable(w http.ResponseWriter, r *http.Request, a archivable) {
+	id, err := atoiParam(chi.URLParam(r, "id"))
+	if err != nil {
+		tools.HandleBadRequest(w, err)
+		return
+	}
+
+	tx, err := tools.DB.Begin()
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	defer tx.Rollback()
+
+	var deletedAt sql.NullString
+	if err := tx.QueryRow(`SELECT deleted_at FROM `+a.table+` WHERE id = ?`, id).Scan(&deletedAt); err == sql.ErrNoRows {
+		http.Error(w, a.label+" not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+
+	var removedFiles []string
+	if r.URL.Query().Get("hard") == "true" {
+		blockers, err := findDeleteBlockers(tx, a.blockers, id)
+		if err != nil {
+			tools.HandleInternalServerError(w, err)
+			return
+		}
+		if len(blockers) > 0 {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusConflict)
+			_ = json.NewEncoder(w).Encode(map[string]any{
+				"error":    fmt.Sprintf("%s %d is still referenced; archive it instead", a.label, id),
+				"blockers": blockers,
+			})
+			return
+		}
+		for _, stmt := range a.cleanup {
+			if _, err := tx.Exec(stmt, id); err != nil {
+				tools.HandleInternalServerError(w, err)
+				return
+			}
+		}
+		if a.attachments != "" {
+			if removedFiles, err = deleteOwnerAttachments(tx, a.attachments, id); err != nil {
+				tools.HandleInternalServerError(w, err)
+				return
+			}
+		}
+		if _, err := tx.Exec(`DELETE FROM `+a.table+` WHERE id = ?`, id); err != nil {
+			tools.HandleInternalServerError(w, err)
+			return
+		}
+	} else if !deletedAt.Valid {
+		now := time.Now().UTC().Format(time.RFC3339)
+		if _, err := tx.Exec(`UPDATE `+a.table+` SET deleted_at = ? WHERE id = ?`, now, id); err != nil {
+			tools.HandleInternalServerError(w, err)
+			return
+		}
+		if a.parentColumn != "" {
+			if _, err := tx.Exec(
+				`UPDATE `+a.table+` SET deleted_at = ? WHERE `+a.parentColumn+` = ? AND deleted_at IS NULL`, now, id,
+			); err != nil {
+				tools.HandleInternalServerError(w, err)
+				return
+			}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	removeStoredFiles(removedFiles...)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// restoreArchivable clears deleted_at on the row and on children archived along with it.
+// A child can't be restored while its parent is archived.
+func restoreArchivable(w http.ResponseWriter, r *http.Request, a archivable) {
+	id, err := atoiParam(chi.URLParam(r, "id"))
+	if err != nil {
+		tools.HandleBadRequest(w, err)
+		return
+	}
+
+	tx, err := tools.DB.Begin()
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	defer tx.Rollback()
+
+	var deletedAt sql.NullString
+	if err := tx.QueryRow(`SELECT deleted_at FROM `+a.table+` WHERE id = ?`, id).Scan(&deletedAt); err == sql.ErrNoRows {
+		http.Error(w, a.label+" not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	if !deletedAt.Valid {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	if a.parentColumn != "" {
+		var parentArchived bool
+		if err := tx.QueryRow(`
+			SELECT EXISTS (SELECT 1 FROM `+a.table+` p JOIN `+a.table+` c ON c.`+a.parentColumn+` = p.id
+			               WHERE c.id = ? AND p.deleted_at IS NOT NULL)`, id,
+		).Scan(&parentArchived); err != nil {
+			tools.HandleInternalServerError(w, err)
+			return
+		}
+		if parentArchived {
+			http.Error(w, "parent is archived; restore it first", http.StatusConflict)
+			return
+		}
+		if _, err := tx.Exec(
+			`UPDATE `+a.table+` SET deleted_at = NULL WHERE `+a.parentColumn+` = ? AND deleted_at = ?`, id, deletedAt.String,
+		); err != nil {
+			tools.HandleInternalServerError(w, err)
+			return
+		}
+	}
+	if _, err := tx.Exec(`UPDATE `+a.table+` SET deleted_at = NULL WHERE id = ?`, id); err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,545 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"io/fs"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/MananKakkar1/SalesBoard/backend/internal/models"
+	"github.com/MananKakkar1/SalesBoard/backend/internal/tools"
+	"github.com/go-chi/chi"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	maxUploadBytes = 10 << 20   // per file
+	maxImagePixels = 25_000_000 // refuse to decode anything larger
+	thumbnailSize  = 256        // longest side, in pixels
+)
+
+// attachmentOwner is a kind of row that can own attachments.
+type attachmentOwner struct {
+	kind        string // attachments.owner_type
+	label       string
+	existsQuery string            // binds the owner id once
+	dir         string            // storage key prefix
+	types       map[string]string // allowed content type -> file extension
+}
+
+var imageTypes = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+}
+
+var productImages = attachmentOwner{
+	kind:        "product",
+	label:       "Product",
+	existsQuery: `SELECT COUNT(*) FROM products WHERE id = ?`,
+	dir:         "products",
+	types:       imageTypes,
+}
+
+// Orders also take PDFs (signed delivery notes, proofs of delivery).
+var orderAttachments = attachmentOwner{
+	kind:        "order",
+	label:       "Order",
+	existsQuery: `SELECT COUNT(*) FROM orders WHERE orderId = ?`,
+	dir:         "orders",
+	types: map[string]string{
+		"image/jpeg":      ".jpg",
+		"image/png":       ".png",
+		"image/gif":       ".gif",
+		"application/pdf": ".pdf",
+	},
+}
+
+// ---------- helpers ----------
+
+func attachmentURL(id int) string {
+	return fmt.Sprintf("/api/attachments/%d", id)
+}
+
+const attachmentColumns = `id, owner_type, owner_id, filename, content_type, size_bytes, width, height,
+	       thumbnail_key IS NOT NULL, position, uploaded_by, created_at`
+
+func scanAttachment(row rowScanner) (models.Attachment, error) {
+	var a models.Attachment
+	var hasThumb bool
+	if err := row.Scan(&a.ID, &a.OwnerType, &a.OwnerID, &a.Filename, &a.ContentType, &a.SizeBytes,
+		&a.Width, &a.Height, &hasThumb, &a.Position, &a.UploadedBy, &a.CreatedAt); err != nil {
+		return a, err
+	}
+	a.URL = attachmentURL(a.ID)
+	if hasThumb {
+		a.ThumbnailURL = a.URL + "/thumbnail"
+	}
+	return a, nil
+}
+
+// loadAttachments returns an owner's attachments, primary (lowest position) first.
+func loadAttachments(q queryer, ownerType string, ownerID int) ([]models.Attachment, error) {
+	rows, err := q.Query(`
+		SELECT `+attachmentColumns+`
+		FROM attachments
+		WHERE owner_type = ? AND owner_id = ?
+		ORDER BY position, id`, ownerType, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []models.Attachment{}
+	for rows.Next() {
+		a, err := scanAttachment(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, a)
+	}
+	return out, rows.Err()
+}
+
+// deleteOwnerAttachments removes an owner's attachment rows inside tx and returns the
+// storage keys to pass to removeStoredFiles once the transaction commits.
+func deleteOwnerAttachments(tx *sql.Tx, ownerType string, ownerID int) ([]string, error) {
+	rows, err := tx.Query(
+		`SELECT storage_key, thumbnail_key FROM attachments WHERE owner_type = ? AND owner_id = ?`,
+		ownerType, ownerID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	var keys []string
+	for rows.Next() {
+		var key string
+		var thumb sql.NullString
+		if err := rows.Scan(&key, &thumb); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		keys = append(keys, key)
+		if thumb.Valid {
+			keys = append(keys, thumb.String)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec(`DELETE FROM attachments WHERE owner_type = ? AND owner_id = ?`, ownerType, ownerID); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// removeStoredFiles deletes stored bytes after their rows are gone. Failures only leave
+// unreferenced files behind, so they are logged rather than returned.
+func removeStoredFiles(keys ...string) {
+	for _, k := range keys {
+		if err := tools.Files.Delete(k); err != nil {
+			log.Errorf("removing stored file %s: %v", k, err)
+		}
+	}
+}
+
+func randomKey() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// thumbnail shrinks src to fit within size x size, averaging the source pixels
+// behind each destination pixel. Smaller images are returned unchanged.
+func thumbnail(src image.Image, size int) image.Image {
+	b := src.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w <= size && h <= size {
+		return src
+	}
+	tw, th := size, size
+	if w > h {
+		th = max(1, h*size/w)
+	} else {
+		tw = max(1, w*size/h)
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, tw, th))
+	for y := 0; y < th; y++ {
+		y0, y1 := b.Min.Y+y*h/th, b.Min.Y+(y+1)*h/th
+		for x := 0; x < tw; x++ {
+			x0, x1 := b.Min.X+x*w/tw, b.Min.X+(x+1)*w/tw
+			var sr, sg, sb, sa, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					r, g, bl, a := src.At(sx, sy).RGBA()
+					sr, sg, sb, sa = sr+uint64(r), sg+uint64(g), sb+uint64(bl), sa+uint64(a)
+					n++
+				}
+			}
+			dst.Set(x, y, color.RGBA64{uint16(sr / n), uint16(sg / n), uint16(sb / n), uint16(sa / n)})
+		}
+	}
+	return dst
+}
+
+// imageThumbnail decodes an uploaded image and returns its size and an encoded
+// thumbnail: JPEG for JPEG sources, PNG otherwise so transparency survives.
+func imageThumbnail(f multipart.File, contentType string) (width, height int, thumb []byte, ext string, err error) {
+	cfg, _, err := image.DecodeConfig(f)
+	if err != nil {
+		return 0, 0, nil, "", &invalidFieldError{"file is not a readable image"}
+	}
+	if cfg.Width*cfg.Height > maxImagePixels {
+		return 0, 0, nil, "", &invalidFieldError{fmt.Sprintf("image is %dx%d; the limit is %d megapixels", cfg.Width, cfg.Height, maxImagePixels/1_000_000)}
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return 0, 0, nil, "", err
+	}
+	img, _, err := image.Decode(f)
+	if err != nil {
+		return 0, 0, nil, "", &invalidFieldError{"file is not a readable image"}
+	}
+
+	var buf bytes.Buffer
+	small := thumbnail(img, thumbnailSize)
+	ext = ".png"
+	if contentType == "image/jpeg" {
+		ext = ".jpg"
+		err = jpeg.Encode(&buf, small, &jpeg.Options{Quality: 80})
+	} else {
+		err = png.Encode(&buf, small)
+	}
+	if err != nil {
+		return 0, 0, nil, "", err
+	}
+	return cfg.Width, cfg.Height, buf.Bytes(), ext, nil
+}
+
+// ---------- handlers ----------
+
+// uploadAttachment stores the multipart "file" field for the owner in the {id} path
+// parameter. The content type is sniffed from the bytes, not taken from the client.
+// Images get a thumbnail. Optional form field userId records the uploader.
+func uploadAttachment(w http.ResponseWriter, r *http.Request, owner attachmentOwner) {
+	ownerID, err := atoiParam(chi.URLParam(r, "id"))
+	if err != nil {
+		tools.HandleBadRequest(w, err)
+		return
+	}
+
+	// Leave room for the multipart framing around a file at the limit
+	r.Body = http.MaxBytesReader(w, r.Body, maxUploadBytes+1<<20)
+	if err := r.ParseMultipartForm(1 << 20); err != nil {
+		var tooBig *http.MaxBytesError
+		if errors.As(err, &tooBig) {
+			http.Error(w, fmt.Sprintf("upload exceeds %d MB", maxUploadBytes>>20), http.StatusRequestEntityTooLarge)
+			return
+		}
+		tools.HandleBadRequest(w, errors.New("expected a multipart/form-data upload"))
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	f, header, err := r.FormFile("file")
+	if err != nil {
+		tools.HandleBadRequest(w, errors.New("file is required"))
+		return
+	}
+	defer f.Close()
+	if header.Size > maxUploadBytes {
+		http.Error(w, fmt.Sprintf("upload exceeds %d MB", maxUploadBytes>>20), http.StatusRequestEntityTooLarge)
+		return
+	}
+	if header.Size == 0 {
+		tools.HandleBadRequest(w, errors.New("file is empty"))
+		return
+	}
+
+	sniff := make([]byte, 512)
+	n, err := io.ReadFull(f, sniff)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	contentType, _, _ := mime.ParseMediaType(http.DetectContentType(sniff[:n]))
+	ext, ok := owner.types[contentType]
+	if !ok {
+		tools.HandleBadRequest(w, fmt.Errorf("%s is not an accepted file type", contentType))
+		return
+	}
+
+	var uploadedBy *int
+	if s := strings.TrimSpace(r.FormValue("userId")); s != "" {
+		id, err := strconv.Atoi(s)
+		if err != nil {
+			tools.HandleBadRequest(w, errors.New("invalid userId"))
+			return
+		}
+		uploadedBy = &id
+	}
+	if err := checkUser(tools.DB, uploadedBy); err != nil {
+		writeFieldError(w, err)
+		return
+	}
+
+	var exists int
+	if err := tools.DB.QueryRow(owner.existsQuery, ownerID).Scan(&exists); err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	if exists == 0 {
+		http.Error(w, owner.label+" not found", http.StatusNotFound)
+		return
+	}
+
+	var width, height *int
+	var thumb []byte
+	var thumbExt string
+	if _, isImage := imageTypes[contentType]; isImage {
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			tools.HandleInternalServerError(w, err)
+			return
+		}
+		wd, ht, t, te, err := imageThumbnail(f, contentType)
+		if err != nil {
+			writeFieldError(w, err)
+			return
+		}
+		width, height, thumb, thumbExt = &wd, &ht, t, te
+	}
+
+	base, err := randomKey()
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	key := fmt.Sprintf("%s/%d/%s%s", owner.dir, ownerID, base, ext)
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	if err := tools.Files.Put(key, f); err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	stored := []string{key}
+	var thumbKey *string
+	if thumb != nil {
+		tk := fmt.Sprintf("%s/%d/%s_thumb%s", owner.dir, ownerID, base, thumbExt)
+		if err := tools.Files.Put(tk, bytes.NewReader(thumb)); err != nil {
+			removeStoredFiles(stored...)
+			tools.HandleInternalServerError(w, err)
+			return
+		}
+		stored = append(stored, tk)
+		thumbKey = &tk
+	}
+
+	res, err := tools.DB.Exec(`
+		INSERT INTO attachments (owner_type, owner_id, filename, content_type, size_bytes, width, height,
+		                         storage_key, thumbnail_key, position, uploaded_by, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?,
+		        (SELECT COALESCE(MAX(position) + 1, 0) FROM attachments WHERE owner_type = ? AND owner_id = ?),
+		        ?, ?)`,
+		owner.kind, ownerID, header.Filename, contentType, header.Size, width, height,
+		key, thumbKey, owner.kind, ownerID, uploadedBy, time.Now().UTC().Format(time.RFC3339),
+	)
+	if err != nil {
+		removeStoredFiles(stored...)
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	id, _ := res.LastInsertId()
+
+	a, err := scanAttachment(tools.DB.QueryRow(`SELECT `+attachmentColumns+` FROM attachments WHERE id = ?`, id))
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(a)
+}
+
+func listAttachments(w http.ResponseWriter, r *http.Request, owner attachmentOwner) {
+	ownerID, err := atoiParam(chi.URLParam(r, "id"))
+	if err != nil {
+		tools.HandleBadRequest(w, err)
+		return
+	}
+	var exists int
+	if err := tools.DB.QueryRow(owner.existsQuery, ownerID).Scan(&exists); err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	if exists == 0 {
+		http.Error(w, owner.label+" not found", http.StatusNotFound)
+		return
+	}
+	list, err := loadAttachments(tools.DB, owner.kind, ownerID)
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(list)
+}
+
+func deleteAttachment(w http.ResponseWriter, r *http.Request, owner attachmentOwner) {
+	ownerID, err := atoiParam(chi.URLParam(r, "id"))
+	if err != nil {
+		tools.HandleBadRequest(w, err)
+		return
+	}
+	attachmentID, err := atoiParam(chi.URLParam(r, "attachmentId"))
+	if err != nil {
+		tools.HandleBadRequest(w, err)
+		return
+	}
+
+	var key string
+	var thumb sql.NullString
+	err = tools.DB.QueryRow(
+		`SELECT storage_key, thumbnail_key FROM attachments WHERE id = ? AND owner_type = ? AND owner_id = ?`,
+		attachmentID, owner.kind, ownerID,
+	).Scan(&key, &thumb)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Attachment not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	if _, err := tools.DB.Exec(`DELETE FROM attachments WHERE id = ?`, attachmentID); err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	removeStoredFiles(key)
+	if thumb.Valid {
+		removeStoredFiles(thumb.String)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// serveAttachment streams the original file, or its thumbnail when thumb is set.
+func serveAttachment(w http.ResponseWriter, r *http.Request, thumb bool) {
+	id, err := atoiParam(chi.URLParam(r, "id"))
+	if err != nil {
+		tools.HandleBadRequest(w, err)
+		return
+	}
+	var filename, contentType, key, createdAt string
+	var thumbKey sql.NullString
+	err = tools.DB.QueryRow(
+		`SELECT filename, content_type, storage_key, thumbnail_key, created_at FROM attachments WHERE id = ?`, id,
+	).Scan(&filename, &contentType, &key, &thumbKey, &createdAt)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Attachment not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	if thumb {
+		if !thumbKey.Valid {
+			http.Error(w, "Attachment has no thumbnail", http.StatusNotFound)
+			return
+		}
+		key = thumbKey.String
+		if strings.HasSuffix(key, ".jpg") {
+			contentType = "image/jpeg"
+		} else {
+			contentType = "image/png"
+		}
+	}
+
+	rc, err := tools.Files.Open(key)
+	if errors.Is(err, fs.ErrNotExist) {
+		http.Error(w, "Attachment file is missing", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	defer rc.Close()
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("inline", map[string]string{"filename": filename}))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	// Stored files never change, so range and conditional requests can be answered
+	if rs, ok := rc.(io.ReadSeeker); ok {
+		modTime, _ := time.Parse(time.RFC3339, createdAt)
+		http.ServeContent(w, r, "", modTime, rs)
+		return
+	}
+	_, _ = io.Copy(w, rc)
+}
+
+// ---------- Product images ----------
+
+// POST /api/products/{id}/images (multipart field "file"; JPEG, PNG or GIF)
+func uploadProductImageHandler(w http.ResponseWriter, r *http.Request) {
+	uploadAttachment(w, r, productImages)
+}
+
+// GET /api/products/{id}/images
+func getProductImagesHandler(w http.ResponseWriter, r *http.Request) {
+	listAttachments(w, r, productImages)
+}
+
+// DELETE /api/products/{id}/images/{attachmentId}
+func deleteProductImageHandler(w http.ResponseWriter, r *http.Request) {
+	deleteAttachment(w, r, productImages)
+}
+
+// ---------- Order attachments ----------
+
+// POST /api/orders/{id}/attachments (multipart field "file"; images or PDF)
+func uploadOrderAttachmentHandler(w http.ResponseWriter, r *http.Request) {
+	uploadAttachment(w, r, orderAttachments)
+}
+
+// GET /api/orders/{id}/attachments
+func getOrderAttachmentsHandler(w http.ResponseWriter, r *http.Request) {
+	listAttachments(w, r, orderAttachments)
+}
+
+// DELETE /api/orders/{id}/attachments/{attachmentId}
+func deleteOrderAttachmentHandler(w http.ResponseWriter, r *http.Request) {
+	deleteAttachment(w, r, orderAttachments)
+}
+
+// ---------- File content ----------
+
+// GET /api/attachments/{id}
+func getAttachmentFileHandler(w http.ResponseWriter, r *http.Request) {
+	serveAttachment(w, r, false)
+}
+
+// GET /api/attachments/{id}/thumbnail
+func getAttachmentThumbnailHandler(w http.ResponseWriter, r *http.Request) {
+	serveAttachment(w, r, true)
+}
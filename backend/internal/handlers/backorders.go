@@ -0,0 +1,209 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/MananKakkar1/SalesBoard/backend/internal/models"
+	"github.com/MananKakkar1/SalesBoard/backend/internal/tools"
+)
+
+// An order placed with allowBackorder takes whatever its warehouse has of a short
+// line and records the rest as a backorder. Inventory PATCH, transfers, lot and
+// serial receipts, purchase order receipts and approved cycle counts call
+// fillBackorders for the stock they add or unfreeze, which hands it to open
+// backorders oldest first. Kits are never backordered; a
+// short kit still fails the order.
+
+const backorderColumns = `id, order_id, order_item_id, product_id, warehouse_id, qty, filled_qty, status, created_at, filled_at`
+
+func scanBackorder(row rowScanner) (models.Backorder, error) {
+	var b models.Backorder
+	err := row.Scan(&b.ID, &b.OrderID, &b.OrderItemID, &b.ProductID, &b.WarehouseID, &b.Qty, &b.FilledQty,
+		&b.Status, &b.CreatedAt, &b.FilledAt)
+	return b, err
+}
+
+// queryBackorders runs a SELECT of backorderColumns (where and order are appended).
+func queryBackorders(q queryer, where, order string, args ...any) ([]models.Backorder, error) {
+	rows, err := q.Query(`SELECT `+backorderColumns+` FROM backorders WHERE `+where+` ORDER BY `+order, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := []models.Backorder{}
+	for rows.Next() {
+		b, err := scanBackorder(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, b)
+	}
+	return out, rows.Err()
+}
+
+// loadItemBackorder returns the backorder of an order line, or nil when it had none.
+func loadItemBackorder(q queryer, orderItemID int) (*models.Backorder, error) {
+	b, err := scanBackorder(q.QueryRow(`SELECT `+backorderColumns+` FROM backorders WHERE order_item_id = ?`, orderItemID))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &b, nil
+}
+
+// takeAvailableStock removes as much of qty as the warehouse can cover and returns
+// the shortfall. Errors other than a shortage are passed through.
+func takeAvailableStock(tx *sql.Tx, k stockKey, qty int) (stockRemoval, int, error) {
+	removed, err := removeWarehouseStock(tx, k.WarehouseID, k.ProductID, qty)
+	var short *insufficientStockError
+	if !errors.As(err, &short) {
+		return removed, 0, err
+	}
+	if short.Available <= 0 {
+		return stockRemoval{}, qty, nil
+	}
+	removed, err = removeWarehouseStock(tx, k.WarehouseID, k.ProductID, short.Available)
+	return removed, qty - short.Available, err
+}
+
+func insertBackorder(tx *sql.Tx, orderID, orderItemID int, k stockKey, qty int) error {
+	_, err := tx.Exec(`
+		INSERT INTO backorders (order_id, order_item_id, product_id, warehouse_id, qty, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)`,
+		orderID, orderItemID, k.ProductID, k.WarehouseID, qty, time.Now().UTC().Format(time.RFC3339),
+	)
+	return err
+}
+
+// fillBackorders allocates available stock at each key to its open backorders,
+// oldest first, recording picks and lots on the order lines. Keys frozen by a
+// cycle count are left for the next arrival. It returns the events to broadcast
+// after commit.
+func fillBackorders(tx *sql.Tx, keys ...stockKey) ([]tools.Event, error) {
+	var events []tools.Event
+	seen := map[stockKey]bool{}
+	for _, k := range keys {
+		if seen[k] {
+			continue
+		}
+		seen[k] = true
+
+		var frozen *stockFrozenError
+		if err := ensureStockNotFrozen(tx, k); errors.As(err, &frozen) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		open, err := queryBackorders(tx, `status = 'open' AND warehouse_id = ? AND product_id = ?`, `created_at, id`,
+			k.WarehouseID, k.ProductID)
+		if err != nil {
+			return nil, err
+		}
+		for _, b := range open {
+			var avail int
+			err := tx.QueryRow(
+				`SELECT available_qty FROM warehouse_inventory_available WHERE warehouse_id = ? AND product_id = ?`,
+				k.WarehouseID, k.ProductID,
+			).Scan(&avail)
+			if err != nil && err != sql.ErrNoRows {
+				return nil, err
+			}
+			if avail <= 0 {
+				break
+			}
+			take := min(avail, b.Qty-b.FilledQty)
+			removed, err := removeWarehouseStock(tx, k.WarehouseID, k.ProductID, take)
+			if err != nil {
+				return nil, err
+			}
+			if err := recordPicks(tx, b.OrderItemID, removed.Picks); err != nil {
+				return nil, err
+			}
+			if err := recordLotAllocations(tx, b.OrderItemID, removed.Lots); err != nil {
+				return nil, err
+			}
+
+			b.FilledQty += take
+			var filledAt any
+			if b.FilledQty == b.Qty {
+				b.Status, filledAt = "filled", time.Now().UTC().Format(time.RFC3339)
+			}
+			if _, err := tx.Exec(
+				`UPDATE backorders SET filled_qty = ?, status = ?, filled_at = ? WHERE id = ?`,
+				b.FilledQty, b.Status, filledAt, b.ID,
+			); err != nil {
+				return nil, err
+			}
+			events = append(events, tools.Event{
+				Type: "order.backorder_filled",
+				Data: map[string]any{
+					"backorderId": b.ID,
+					"orderId":     b.OrderID,
+					"orderItemId": b.OrderItemID,
+					"productId":   b.ProductID,
+					"warehouseId": b.WarehouseID,
+					"qty":         take,
+					"remaining":   b.Qty - b.FilledQty,
+					"status":      b.Status,
+				},
+				Time: time.Now(),
+			})
+		}
+	}
+	return events, nil
+}
+
+// ---------- List (GET /api/backorders?status=&warehouseId=&productId=&page=&pageSize=) ----------
+// Oldest first, which is the order they are filled in. status defaults to open; "all" lists every one.
+func getBackordersHandler(w http.ResponseWriter, r *http.Request) {
+	where := `1 = 1`
+	var args []any
+	switch status := r.URL.Query().Get("status"); status {
+	case "", "open":
+		where += ` AND status = 'open'`
+	case "filled":
+		where += ` AND status = 'filled'`
+	case "all":
+	default:
+		tools.HandleBadRequest(w, errors.New("status must be open, filled or all"))
+		return
+	}
+	for _, f := range []struct{ param, column string }{
+		{"warehouseId", "warehouse_id"},
+		{"productId", "product_id"},
+		{"orderId", "order_id"},
+	} {
+		if s := r.URL.Query().Get(f.param); s != "" {
+			id, err := atoiParam(s)
+			if err != nil {
+				tools.HandleBadRequest(w, err)
+				return
+			}
+			where += ` AND ` + f.column + ` = ?`
+			args = append(args, id)
+		}
+	}
+	page, pageSize, offset := parsePage(r)
+
+	var total int
+	if err := tools.DB.QueryRow(`SELECT COUNT(*) FROM backorders WHERE `+where, args...).Scan(&total); err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	data, err := queryBackorders(tools.DB, where, `created_at, id LIMIT ? OFFSET ?`, append(args, pageSize, offset)...)
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"data":       data,
+		"pagination": paginationMeta(page, pageSize, total),
+	})
+}
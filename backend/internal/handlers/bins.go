@@ -0,0 +1,471 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/MananKakkar1/SalesBoard/backend/internal/models"
+	"github.com/MananKakkar1/SalesBoard/backend/internal/tools"
+	"github.com/go-chi/chi"
+)
+
+// ---------- Input DTOs ----------
+
+type binCU struct {
+	Code        string `json:"code"`
+	Description string `json:"description"`
+}
+
+// fromBinId / toBinId of 0 mean "not in a bin", so the same call does put-away
+// (0 -> bin), bin-to-bin moves and pulling stock back out of a bin (bin -> 0).
+type binTransferBody struct {
+	ProductID int `json:"productId"`
+	FromBinID int `json:"fromBinId"`
+	ToBinID   int `json:"toBinId"`
+	Qty       int `json:"qty"`
+}
+
+// ---------- Bins CRUD ----------
+
+// POST /api/warehouses/{id}/bins
+func createBinHandler(w http.ResponseWriter, r *http.Request) {
+	warehouseID, err := atoiParam(chi.URLParam(r, "id"))
+	if err != nil {
+		tools.HandleBadRequest(w, err)
+		return
+	}
+	var body binCU
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		tools.HandleBadRequest(w, errors.New("invalid request"))
+		return
+	}
+	code := strings.ToUpper(strings.TrimSpace(body.Code))
+	if code == "" {
+		tools.HandleBadRequest(w, errors.New("code is required"))
+		return
+	}
+
+	var exists int
+	if err := tools.DB.QueryRow(`SELECT COUNT(*) FROM warehouses WHERE id = ?`, warehouseID).Scan(&exists); err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	if exists == 0 {
+		http.Error(w, "Warehouse not found", http.StatusNotFound)
+		return
+	}
+	if err := tools.DB.QueryRow(
+		`SELECT COUNT(*) FROM bins WHERE warehouse_id = ? AND code = ?`, warehouseID, code,
+	).Scan(&exists); err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	if exists > 0 {
+		http.Error(w, "A bin with this code already exists in the warehouse", http.StatusConflict)
+		return
+	}
+
+	createdAt := time.Now().UTC().Format(time.RFC3339)
+	res, err := tools.DB.Exec(
+		`INSERT INTO bins (warehouse_id, code, description, created_at) VALUES (?, ?, ?, ?)`,
+		warehouseID, code, body.Description, createdAt,
+	)
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	id, _ := res.LastInsertId()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(models.Bin{
+		ID:          int(id),
+		WarehouseID: warehouseID,
+		Code:        code,
+		Description: body.Description,
+		CreatedAt:   createdAt,
+	})
+}
+
+// GET /api/warehouses/{id}/bins
+func getWarehouseBinsHandler(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	rows, err := tools.DB.Query(`
+		SELECT b.id, b.warehouse_id, b.code, COALESCE(b.description, ''), b.created_at,
+		       COALESCE((SELECT SUM(qty) FROM bin_inventory WHERE bin_id = b.id), 0)
+		FROM bins b
+		WHERE b.warehouse_id = ?
+		ORDER BY b.code`, id)
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	defer rows.Close()
+
+	out := []models.Bin{}
+	for rows.Next() {
+		var b models.Bin
+		if err := rows.Scan(&b.ID, &b.WarehouseID, &b.Code, &b.Description, &b.CreatedAt, &b.TotalQty); err != nil {
+			tools.HandleInternalServerError(w, err)
+			return
+		}
+		out = append(out, b)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(out)
+}
+
+// PUT /api/bins/{binId}
+func updateBinHandler(w http.ResponseWriter, r *http.Request) {
+	binID := chi.URLParam(r, "binId")
+	var body binCU
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		tools.HandleBadRequest(w, errors.New("invalid request"))
+		return
+	}
+	code := strings.ToUpper(strings.TrimSpace(body.Code))
+	if code == "" {
+		tools.HandleBadRequest(w, errors.New("code is required"))
+		return
+	}
+
+	res, err := tools.DB.Exec(`UPDATE bins SET code = ?, description = ? WHERE id = ?`, code, body.Description, binID)
+	if err != nil {
+		if strings.Contains(err.Error(), "UNIQUE") {
+			http.Error(w, "A bin with this code already exists in the warehouse", http.StatusConflict)
+			return
+		}
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		http.Error(w, "Bin not found", http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// DELETE /api/bins/{binId}  (only empty bins)
+func deleteBinHandler(w http.ResponseWriter, r *http.Request) {
+	binID := chi.URLParam(r, "binId")
+	var qty int
+	if err := tools.DB.QueryRow(`SELECT COALESCE(SUM(qty), 0) FROM bin_inventory WHERE bin_id = ?`, binID).Scan(&qty); err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	if qty > 0 {
+		http.Error(w, "Bin still holds stock; move it out first", http.StatusConflict)
+		return
+	}
+	if _, err := tools.DB.Exec(`DELETE FROM bins WHERE id = ?`, binID); err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ---------- Per-bin stock ----------
+
+// GET /api/warehouses/{id}/bin-inventory?productId=
+// Per product: total on hand, the bins holding it and what is not yet in a bin.
+func getWarehouseBinInventoryHandler(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	args := []interface{}{id}
+	filter := ""
+	if s := r.URL.Query().Get("productId"); s != "" {
+		filter = "AND wi.product_id = ?"
+		args = append(args, s)
+	}
+
+	type productBins struct {
+		ProductID   int                   `json:"productId"`
+		ProductName string                `json:"productName"`
+		OnHand      int                   `json:"onHand"`
+		Unbinned    int                   `json:"unbinned"`
+		Bins        []models.PickLocation `json:"bins"`
+	}
+	out := []*productBins{}
+	byID := map[int]*productBins{}
+
+	rows, err := tools.DB.Query(`
+		SELECT wi.product_id, p.name, wi.qty
+		FROM warehouse_inventory wi
+		JOIN products p ON p.id = wi.product_id
+		WHERE wi.warehouse_id = ? `+filter+`
+		ORDER BY wi.product_id`, args...)
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	for rows.Next() {
+		pb := &productBins{Bins: []models.PickLocation{}}
+		if err := rows.Scan(&pb.ProductID, &pb.ProductName, &pb.OnHand); err != nil {
+			rows.Close()
+			tools.HandleInternalServerError(w, err)
+			return
+		}
+		pb.Unbinned = pb.OnHand
+		byID[pb.ProductID] = pb
+		out = append(out, pb)
+	}
+	rows.Close()
+
+	rows, err = tools.DB.Query(`
+		SELECT wi.product_id, b.id, b.code, bi.qty
+		FROM bin_inventory bi
+		JOIN bins b ON b.id = bi.bin_id
+		JOIN warehouse_inventory wi ON wi.warehouse_id = b.warehouse_id AND wi.product_id = bi.product_id
+		WHERE b.warehouse_id = ? `+filter+`
+		ORDER BY b.code`, args...)
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var pid int
+		var loc models.PickLocation
+		if err := rows.Scan(&pid, &loc.BinID, &loc.BinCode, &loc.Qty); err != nil {
+			tools.HandleInternalServerError(w, err)
+			return
+		}
+		if pb := byID[pid]; pb != nil {
+			pb.Bins = append(pb.Bins, loc)
+			pb.Unbinned -= loc.Qty
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(out)
+}
+
+// GET /api/bins/{binId}/inventory -> [{product_id, name, qty}]
+func getBinInventoryHandler(w http.ResponseWriter, r *http.Request) {
+	binID := chi.URLParam(r, "binId")
+	var exists int
+	if err := tools.DB.QueryRow(`SELECT COUNT(*) FROM bins WHERE id = ?`, binID).Scan(&exists); err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	if exists == 0 {
+		http.Error(w, "Bin not found", http.StatusNotFound)
+		return
+	}
+
+	rows, err := tools.DB.Query(`
+		SELECT p.id, p.name, bi.qty
+		FROM bin_inventory bi
+		JOIN products p ON p.id = bi.product_id
+		WHERE bi.bin_id = ?
+		ORDER BY p.id`, binID)
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	defer rows.Close()
+
+	out := []models.WarehouseInventoryItem{}
+	for rows.Next() {
+		var it models.WarehouseInventoryItem
+		if err := rows.Scan(&it.ProductID, &it.Name, &it.Qty); err != nil {
+			tools.HandleInternalServerError(w, err)
+			return
+		}
+		out = append(out, it)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(out)
+}
+
+// POST /api/warehouses/{id}/bins/transfer
+// body: { "productId": 1, "fromBinId": 0, "toBinId": 4, "qty": 20 }
+// Moves stock between bins of one warehouse; on-hand qty does not change.
+func transferBinStockHandler(w http.ResponseWriter, r *http.Request) {
+	warehouseID, err := atoiParam(chi.URLParam(r, "id"))
+	if err != nil {
+		tools.HandleBadRequest(w, err)
+		return
+	}
+	var body binTransferBody
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		tools.HandleBadRequest(w, errors.New("invalid request"))
+		return
+	}
+	if body.ProductID <= 0 || body.Qty <= 0 || body.FromBinID < 0 || body.ToBinID < 0 {
+		tools.HandleBadRequest(w, errors.New("productId and positive qty are required"))
+		return
+	}
+	if body.FromBinID == body.ToBinID {
+		tools.HandleBadRequest(w, errors.New("from and to bins must be different"))
+		return
+	}
+
+	tx, err := tools.DB.Begin()
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	defer tx.Rollback()
+
+	k := stockKey{warehouseID, body.ProductID}
+	if err := ensureStockNotFrozen(tx, k); err != nil {
+		writeStockError(w, err)
+		return
+	}
+
+	// Both bins must belong to this warehouse
+	for _, binID := range []int{body.FromBinID, body.ToBinID} {
+		if binID == 0 {
+			continue
+		}
+		var wh int
+		err := tx.QueryRow(`SELECT warehouse_id FROM bins WHERE id = ?`, binID).Scan(&wh)
+		if err == sql.ErrNoRows || (err == nil && wh != warehouseID) {
+			tools.HandleBadRequest(w, fmt.Errorf("bin %d is not in warehouse %d", binID, warehouseID))
+			return
+		}
+		if err != nil {
+			tools.HandleInternalServerError(w, err)
+			return
+		}
+	}
+
+	var available int
+	if body.FromBinID == 0 {
+		available, err = unbinnedQty(tx, k)
+	} else {
+		err = tx.QueryRow(
+			`SELECT COALESCE(SUM(qty), 0) FROM bin_inventory WHERE bin_id = ? AND product_id = ?`,
+			body.FromBinID, body.ProductID,
+		).Scan(&available)
+	}
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	if available < body.Qty {
+		tools.HandleBadRequest(w, errors.New("insufficient quantity in source location"))
+		return
+	}
+
+	if body.FromBinID != 0 {
+		if err := adjustBinQty(tx, body.FromBinID, body.ProductID, -body.Qty); err != nil {
+			tools.HandleInternalServerError(w, err)
+			return
+		}
+	}
+	if body.ToBinID != 0 {
+		if err := adjustBinQty(tx, body.ToBinID, body.ProductID, body.Qty); err != nil {
+			tools.HandleInternalServerError(w, err)
+			return
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ---------- Pick locations for orders ----------
+
+// recordPicks stores where an order line was picked from.
+func recordPicks(tx *sql.Tx, orderItemID int, picks []models.PickLocation) error {
+	for _, p := range picks {
+		var binID, productID interface{}
+		if p.BinID > 0 {
+			binID = p.BinID
+		}
+		if p.ProductID > 0 {
+			productID = p.ProductID
+		}
+		if _, err := tx.Exec(
+			`INSERT INTO order_item_picks (order_item_id, bin_id, bin_code, qty, product_id) VALUES (?, ?, ?, ?, ?)`,
+			orderItemID, binID, p.BinCode, p.Qty, productID,
+		); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadPicks returns the pick locations recorded for an order line.
+func loadPicks(q queryer, orderItemID int) ([]models.PickLocation, error) {
+	rows, err := q.Query(`
+		SELECT COALESCE(bin_id, 0), bin_code, qty, COALESCE(product_id, 0)
+		FROM order_item_picks
+		WHERE order_item_id = ?
+		ORDER BY bin_code = '', bin_code, product_id`, orderItemID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []models.PickLocation{}
+	for rows.Next() {
+		var p models.PickLocation
+		if err := rows.Scan(&p.BinID, &p.BinCode, &p.Qty, &p.ProductID); err != nil {
+			return nil, err
+		}
+		out = append(out, p)
+	}
+	return out, rows.Err()
+}
+
+// GET /api/orders/{id}/pick-list
+// One row per warehouse/bin/product, sorted the way a picker walks the building.
+// Kit lines are listed as their components.
+func getOrderPickListHandler(w http.ResponseWriter, r *http.Request) {
+	orderID := chi.URLParam(r, "id")
+	var exists int
+	if err := tools.DB.QueryRow(`SELECT COUNT(*) FROM orders WHERE orderId = ?`, orderID).Scan(&exists); err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	if exists == 0 {
+		http.Error(w, "Order not found", http.StatusNotFound)
+		return
+	}
+
+	rows, err := tools.DB.Query(`
+		SELECT COALESCE(oi.warehouse_id, 0), COALESCE(w.name, ''), pk.bin_code,
+		       p.id, p.name, SUM(pk.qty)
+		FROM order_item_picks pk
+		JOIN order_items oi ON oi.id = pk.order_item_id
+		JOIN products p ON p.id = COALESCE(pk.product_id, oi.productId)
+		LEFT JOIN warehouses w ON w.id = oi.warehouse_id
+		WHERE oi.orderId = ?
+		GROUP BY oi.warehouse_id, pk.bin_code, p.id
+		ORDER BY oi.warehouse_id, pk.bin_code = '', pk.bin_code, p.id`, orderID)
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	defer rows.Close()
+
+	type pickRow struct {
+		WarehouseID   int    `json:"warehouseId"`
+		WarehouseName string `json:"warehouseName"`
+		BinCode       string `json:"binCode"`
+		ProductID     int    `json:"productId"`
+		ProductName   string `json:"productName"`
+		Qty           int    `json:"qty"`
+	}
+	out := []pickRow{}
+	for rows.Next() {
+		var p pickRow
+		if err := rows.Scan(&p.WarehouseID, &p.WarehouseName, &p.BinCode, &p.ProductID, &p.ProductName, &p.Qty); err != nil {
+			tools.HandleInternalServerError(w, err)
+			return
+		}
+		out = append(out, p)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(out)
+}
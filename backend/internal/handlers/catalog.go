@@ -0,0 +1,422 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/MananKakkar1/SalesBoard/backend/internal/models"
+	"github.com/MananKakkar1/SalesBoard/backend/internal/tools"
+	"github.com/go-chi/chi"
+)
+
+// categorySubtree selects a category id and all of its descendants; bind the root id once.
+const categorySubtree = `
+	WITH RECURSIVE sub(id) AS (
+		SELECT ?
+		UNION ALL
+		SELECT c.id FROM categories c JOIN sub ON c.parent_id = sub.id
+	)
+	SELECT id FROM sub`
+
+// ---------- Input DTOs ----------
+
+type categoryCU struct {
+	Name     string `json:"name"`
+	ParentID *int   `json:"parentId"`
+}
+
+// ---------- product field checks ----------
+
+// validGTIN accepts GTIN-8, UPC-A (12), EAN-13 and GTIN-14 codes with a correct check digit.
+func validGTIN(code string) bool {
+	switch len(code) {
+	case 8, 12, 13, 14:
+	default:
+		return false
+	}
+	sum := 0
+	for i := len(code) - 2; i >= 0; i-- {
+		c := code[i]
+		if c < '0' || c > '9' {
+			return false
+		}
+		d := int(c - '0')
+		// weights alternate 3,1,3,... starting next to the check digit
+		if (len(code)-2-i)%2 == 0 {
+			d *= 3
+		}
+		sum += d
+	}
+	last := code[len(code)-1]
+	if last < '0' || last > '9' {
+		return false
+	}
+	return (10-sum%10)%10 == int(last-'0')
+}
+
+// checkCatalogFields validates SKU, barcode and category for a product. productID is
+// the product being updated (0 when creating) so it doesn't clash with itself.
+func checkCatalogFields(q queryer, productID int, sku, barcode string, categoryID *int) error {
+	if sku = strings.TrimSpace(sku); sku != "" {
+		var n int
+		if err := q.QueryRow(`SELECT COUNT(*) FROM products WHERE sku = ? COLLATE NOCASE AND id <> ?`, sku, productID).Scan(&n); err != nil {
+			return err
+		}
+		if n > 0 {
+			return &conflictError{fmt.Sprintf("sku %s is already in use", sku)}
+		}
+	}
+	if barcode = strings.TrimSpace(barcode); barcode != "" {
+		if !validGTIN(barcode) {
+			return &invalidFieldError{fmt.Sprintf("barcode %s is not a valid GTIN/EAN", barcode)}
+		}
+		var n int
+		if err := q.QueryRow(`SELECT COUNT(*) FROM products WHERE barcode = ? AND id <> ?`, barcode, productID).Scan(&n); err != nil {
+			return err
+		}
+		if n > 0 {
+			return &conflictError{fmt.Sprintf("barcode %s is already in use", barcode)}
+		}
+	}
+	if categoryID != nil && *categoryID != 0 {
+		var n int
+		if err := q.QueryRow(`SELECT COUNT(*) FROM categories WHERE id = ?`, *categoryID).Scan(&n); err != nil {
+			return err
+		}
+		if n == 0 {
+			return &invalidFieldError{fmt.Sprintf("category %d does not exist", *categoryID)}
+		}
+	}
+	return nil
+}
+
+// nullableCategory stores a categoryId of 0 as NULL (no category).
+func nullableCategory(id *int) any {
+	if id == nil || *id == 0 {
+		return nil
+	}
+	return *id
+}
+
+// ---------- Lookup (GET /api/products/by-code/{code}) ----------
+// Exact match on SKU (case-insensitive) or barcode, for scanners. Numeric codes also
+// match barcodes that differ only in leading zeros (UPC-A read as EAN-13).
+func getProductByCodeHandler(w http.ResponseWriter, r *http.Request) {
+	code := strings.TrimSpace(chi.URLParam(r, "code"))
+	if code == "" {
+		tools.HandleBadRequest(w, errors.New("code is required"))
+		return
+	}
+
+	var id int
+	err := tools.DB.QueryRow(`
+		SELECT id FROM products
+		WHERE sku = ? COLLATE NOCASE
+		   OR barcode = ?
+		   OR (? GLOB '[0-9]*' AND ? NOT GLOB '*[^0-9]*' AND ltrim(barcode, '0') = ltrim(?, '0'))
+		ORDER BY sku = ? COLLATE NOCASE DESC, barcode = ? DESC
+		LIMIT 1`,
+		code, code, code, code, code, code, code,
+	).Scan(&id)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Product not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	writeProductDetail(w, id)
+}
+
+// ---------- Categories ----------
+
+// loadCategoryTree returns every category keyed by id plus the root ids, with
+// paths filled in and product counts rolled up to ancestors.
+func loadCategoryTree(q queryer) (map[int]*models.Category, []int, error) {
+	rows, err := q.Query(`SELECT id, name, parent_id FROM categories ORDER BY name COLLATE NOCASE, id`)
+	if err != nil {
+		return nil, nil, err
+	}
+	byID := map[int]*models.Category{}
+	var order []int
+	for rows.Next() {
+		c := &models.Category{}
+		if err := rows.Scan(&c.ID, &c.Name, &c.ParentID); err != nil {
+			rows.Close()
+			return nil, nil, err
+		}
+		byID[c.ID] = c
+		order = append(order, c.ID)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	// Variants inherit the parent's category, so only top-level products are counted
+	rows, err = q.Query(`
+		SELECT category_id, COUNT(*) FROM products
+		WHERE category_id IS NOT NULL AND parent_id IS NULL
+		GROUP BY category_id`)
+	if err != nil {
+		return nil, nil, err
+	}
+	direct := map[int]int{}
+	for rows.Next() {
+		var id, n int
+		if err := rows.Scan(&id, &n); err != nil {
+			rows.Close()
+			return nil, nil, err
+		}
+		direct[id] = n
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	var roots []int
+	for _, id := range order {
+		c := byID[id]
+		names := []string{c.Name}
+		for p := c.ParentID; p != nil; p = byID[*p].ParentID {
+			names = append([]string{byID[*p].Name}, names...)
+		}
+		c.Path = strings.Join(names, " / ")
+		for cur := c; cur != nil; {
+			cur.ProductCount += direct[id]
+			if cur.ParentID == nil {
+				break
+			}
+			cur = byID[*cur.ParentID]
+		}
+		if c.ParentID == nil {
+			roots = append(roots, id)
+		}
+	}
+	return byID, roots, nil
+}
+
+// buildCategoryNode copies a category with its children nested, in name order.
+func buildCategoryNode(byID map[int]*models.Category, id int) models.Category {
+	c := *byID[id]
+	var kids []int
+	for _, other := range byID {
+		if other.ParentID != nil && *other.ParentID == id {
+			kids = append(kids, other.ID)
+		}
+	}
+	sort.Slice(kids, func(i, j int) bool {
+		a, b := byID[kids[i]], byID[kids[j]]
+		if !strings.EqualFold(a.Name, b.Name) {
+			return strings.ToLower(a.Name) < strings.ToLower(b.Name)
+		}
+		return a.ID < b.ID
+	})
+	for _, k := range kids {
+		c.Children = append(c.Children, buildCategoryNode(byID, k))
+	}
+	return c
+}
+
+// checkCategorySibling rejects a second category with the same name under one parent.
+func checkCategorySibling(q queryer, id int, name string, parentID *int) error {
+	var n int
+	if err := q.QueryRow(
+		`SELECT COUNT(*) FROM categories WHERE parent_id IS ? AND name = ? COLLATE NOCASE AND id <> ?`,
+		nullableCategory(parentID), name, id,
+	).Scan(&n); err != nil {
+		return err
+	}
+	if n > 0 {
+		return &conflictError{fmt.Sprintf("category %s already exists here", name)}
+	}
+	return nil
+}
+
+// POST /api/categories  body: { "name": "Shirts", "parentId": 1 }
+func createCategoryHandler(w http.ResponseWriter, r *http.Request) {
+	var body categoryCU
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		tools.HandleBadRequest(w, errors.New("invalid request"))
+		return
+	}
+	body.Name = strings.TrimSpace(body.Name)
+	if body.Name == "" {
+		tools.HandleBadRequest(w, errors.New("name is required"))
+		return
+	}
+	if body.ParentID != nil && *body.ParentID != 0 {
+		var n int
+		if err := tools.DB.QueryRow(`SELECT COUNT(*) FROM categories WHERE id = ?`, *body.ParentID).Scan(&n); err != nil {
+			tools.HandleInternalServerError(w, err)
+			return
+		}
+		if n == 0 {
+			tools.HandleBadRequest(w, fmt.Errorf("parent category %d does not exist", *body.ParentID))
+			return
+		}
+	}
+	if err := checkCategorySibling(tools.DB, 0, body.Name, body.ParentID); err != nil {
+		writeFieldError(w, err)
+		return
+	}
+
+	res, err := tools.DB.Exec(
+		`INSERT INTO categories (name, parent_id, created_at) VALUES (?, ?, ?)`,
+		body.Name, nullableCategory(body.ParentID), time.Now().UTC().Format(time.RFC3339),
+	)
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	id, _ := res.LastInsertId()
+
+	byID, _, err := loadCategoryTree(tools.DB)
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(byID[int(id)])
+}
+
+// GET /api/categories?flat=true
+// Nested tree by default; flat=true returns every category with its path instead.
+func getCategoriesHandler(w http.ResponseWriter, r *http.Request) {
+	byID, roots, err := loadCategoryTree(tools.DB)
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+
+	out := []models.Category{}
+	if r.URL.Query().Get("flat") == "true" {
+		for _, c := range byID {
+			out = append(out, *c)
+		}
+		sort.Slice(out, func(i, j int) bool { return strings.ToLower(out[i].Path) < strings.ToLower(out[j].Path) })
+	} else {
+		for _, id := range roots {
+			out = append(out, buildCategoryNode(byID, id))
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(out)
+}
+
+// GET /api/categories/{id}  (with its subtree)
+func getCategoryByIdHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := atoiParam(chi.URLParam(r, "id"))
+	if err != nil {
+		tools.HandleBadRequest(w, err)
+		return
+	}
+	byID, _, err := loadCategoryTree(tools.DB)
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	if byID[id] == nil {
+		http.Error(w, "Category not found", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(buildCategoryNode(byID, id))
+}
+
+// PUT /api/categories/{id}  body: { "name": "Shirts", "parentId": null }
+// Moving a category under itself or one of its descendants is rejected.
+func updateCategoryHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := atoiParam(chi.URLParam(r, "id"))
+	if err != nil {
+		tools.HandleBadRequest(w, err)
+		return
+	}
+	var body categoryCU
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		tools.HandleBadRequest(w, errors.New("invalid request"))
+		return
+	}
+	body.Name = strings.TrimSpace(body.Name)
+	if body.Name == "" {
+		tools.HandleBadRequest(w, errors.New("name is required"))
+		return
+	}
+
+	byID, _, err := loadCategoryTree(tools.DB)
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	if byID[id] == nil {
+		http.Error(w, "Category not found", http.StatusNotFound)
+		return
+	}
+	if body.ParentID != nil && *body.ParentID != 0 {
+		if byID[*body.ParentID] == nil {
+			tools.HandleBadRequest(w, fmt.Errorf("parent category %d does not exist", *body.ParentID))
+			return
+		}
+		for p := body.ParentID; p != nil; p = byID[*p].ParentID {
+			if *p == id {
+				tools.HandleBadRequest(w, errors.New("a category cannot be moved under itself"))
+				return
+			}
+		}
+	}
+	if err := checkCategorySibling(tools.DB, id, body.Name, body.ParentID); err != nil {
+		writeFieldError(w, err)
+		return
+	}
+
+	if _, err := tools.DB.Exec(
+		`UPDATE categories SET name = ?, parent_id = ? WHERE id = ?`,
+		body.Name, nullableCategory(body.ParentID), id,
+	); err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// DELETE /api/categories/{id}
+// Only empty leaf categories can be removed.
+func deleteCategoryHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := atoiParam(chi.URLParam(r, "id"))
+	if err != nil {
+		tools.HandleBadRequest(w, err)
+		return
+	}
+	var exists, children, products int
+	if err := tools.DB.QueryRow(`
+		SELECT (SELECT COUNT(*) FROM categories WHERE id = ?),
+		       (SELECT COUNT(*) FROM categories WHERE parent_id = ?),
+		       (SELECT COUNT(*) FROM products WHERE category_id = ?)`, id, id, id,
+	).Scan(&exists, &children, &products); err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	if exists == 0 {
+		http.Error(w, "Category not found", http.StatusNotFound)
+		return
+	}
+	if children > 0 || products > 0 {
+		http.Error(w, fmt.Sprintf("category has %d subcategories and %d products", children, products), http.StatusConflict)
+		return
+	}
+	if _, err := tools.DB.Exec(`DELETE FROM categories WHERE id = ?`, id); err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,355 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"strings"
+
+	"github.com/MananKakkar1/SalesBoard/backend/internal/models"
+	"github.com/MananKakkar1/SalesBoard/backend/internal/tools"
+	"github.com/go-chi/chi"
+)
+
+const (
+	costMethodAverage  = "average"
+	costMethodStandard = "standard"
+)
+
+// ---------- Input DTOs ----------
+
+type productCostIn struct {
+	CostMethod   string   `json:"costMethod"`
+	StandardCost *float64 `json:"standardCost"`
+}
+
+// ---------- helpers ----------
+
+// unitCostSQL is the current unit cost of the products row aliased as alias (NULL when unknown).
+func unitCostSQL(alias string) string {
+	return "CASE WHEN " + alias + ".cost_method = 'standard' THEN " + alias + ".standard_cost ELSE " + alias + ".average_cost END"
+}
+
+// roundCost keeps costs to 4 decimal places so repeated averaging doesn't drift.
+func roundCost(v float64) float64 {
+	return math.Round(v*10000) / 10000
+}
+
+// productUnitCost returns what one unit of a product costs now; a kit costs the sum
+// of its components. The result is nil when any needed cost is still unknown.
+func productUnitCost(q queryer, productID int) (*float64, error) {
+	var components, uncosted int
+	var kitCost sql.NullFloat64
+	if err := q.QueryRow(`
+		SELECT COUNT(*),
+		       COALESCE(SUM(CASE WHEN `+unitCostSQL("c")+` IS NULL THEN 1 ELSE 0 END), 0),
+		       SUM(kc.quantity * (`+unitCostSQL("c")+`))
+		FROM kit_components kc
+		JOIN products c ON c.id = kc.component_id
+		WHERE kc.kit_id = ?`, productID,
+	).Scan(&components, &uncosted, &kitCost); err != nil {
+		return nil, err
+	}
+	if components > 0 {
+		if uncosted > 0 || !kitCost.Valid {
+			return nil, nil
+		}
+		v := roundCost(kitCost.Float64)
+		return &v, nil
+	}
+
+	var cost sql.NullFloat64
+	if err := q.QueryRow(`SELECT `+unitCostSQL("p")+` FROM products p WHERE p.id = ?`, productID).Scan(&cost); err != nil {
+		return nil, err
+	}
+	if !cost.Valid {
+		return nil, nil
+	}
+	return &cost.Float64, nil
+}
+
+// updateAverageCost folds qty units received at unitCost into the product's
+// weighted-average cost. Call it before the units are added to warehouse_inventory.
+func updateAverageCost(tx *sql.Tx, productID, qty int, unitCost float64) error {
+	var onHand int
+	var avg sql.NullFloat64
+	if err := tx.QueryRow(`
+		SELECT COALESCE((SELECT SUM(qty) FROM warehouse_inventory WHERE product_id = p.id), 0), p.average_cost
+		FROM products p WHERE p.id = ?`, productID,
+	).Scan(&onHand, &avg); err != nil {
+		return err
+	}
+	newAvg := unitCost
+	if avg.Valid && onHand > 0 {
+		newAvg = (float64(onHand)*avg.Float64 + float64(qty)*unitCost) / float64(onHand+qty)
+	}
+	_, err := tx.Exec(`UPDATE products SET average_cost = ? WHERE id = ?`, roundCost(newAvg), productID)
+	return err
+}
+
+// loadProductCost reads a product's costing; sql.ErrNoRows when it doesn't exist.
+func loadProductCost(q queryer, productID int) (models.ProductCost, error) {
+	c := models.ProductCost{ProductID: productID}
+	if err := q.QueryRow(
+		`SELECT cost_method, average_cost, standard_cost, price FROM products WHERE id = ?`, productID,
+	).Scan(&c.CostMethod, &c.AverageCost, &c.StandardCost, &c.Price); err != nil {
+		return c, err
+	}
+	unit, err := productUnitCost(q, productID)
+	if err != nil {
+		return c, err
+	}
+	c.UnitCost = unit
+	if unit != nil && c.Price > 0 {
+		pct := math.Round((c.Price-*unit)/c.Price*10000) / 100
+		c.MarginPct = &pct
+	}
+	return c, nil
+}
+
+// ---------- Read (GET /api/products/{id}/cost) ----------
+func getProductCostHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := atoiParam(chi.URLParam(r, "id"))
+	if err != nil {
+		tools.HandleBadRequest(w, err)
+		return
+	}
+	c, err := loadProductCost(tools.DB, id)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Product not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(c)
+}
+
+// ---------- Set (PUT /api/products/{id}/cost) ----------
+// body: { "costMethod": "standard", "standardCost": 4.2 }
+// Either field may be omitted. Switching to standard needs a standard cost; the
+// average cost keeps being maintained from receipts whichever method is active.
+func putProductCostHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := atoiParam(chi.URLParam(r, "id"))
+	if err != nil {
+		tools.HandleBadRequest(w, err)
+		return
+	}
+	var in productCostIn
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		tools.HandleBadRequest(w, errors.New("invalid request"))
+		return
+	}
+	in.CostMethod = strings.TrimSpace(in.CostMethod)
+	if in.CostMethod != "" && in.CostMethod != costMethodAverage && in.CostMethod != costMethodStandard {
+		tools.HandleBadRequest(w, errors.New("costMethod must be average or standard"))
+		return
+	}
+	if in.StandardCost != nil && *in.StandardCost < 0 {
+		tools.HandleBadRequest(w, errors.New("standardCost cannot be negative"))
+		return
+	}
+
+	tx, err := tools.DB.Begin()
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	defer tx.Rollback()
+
+	current, err := loadProductCost(tx, id)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Product not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	var kit *kitStockError
+	if err := ensureNotKit(tx, id); errors.As(err, &kit) {
+		tools.HandleBadRequest(w, errors.New("a kit's cost comes from its components"))
+		return
+	} else if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+
+	method := current.CostMethod
+	if in.CostMethod != "" {
+		method = in.CostMethod
+	}
+	standard := current.StandardCost
+	if in.StandardCost != nil {
+		standard = in.StandardCost
+	}
+	if method == costMethodStandard && standard == nil {
+		tools.HandleBadRequest(w, errors.New("standardCost is required for the standard cost method"))
+		return
+	}
+	if _, err := tx.Exec(
+		`UPDATE products SET cost_method = ?, standard_cost = ? WHERE id = ?`, method, standard, id,
+	); err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	c, err := loadProductCost(tx, id)
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(c)
+}
+
+// ---------- Margin reports ----------
+// GET /api/reports/margins/products
+// GET /api/reports/margins/customers
+// GET /api/reports/margins/periods?period=day|week|month (default month)
+// All take ?from=&to= (order createdAt, to inclusive), ?productId= and ?customerId=.
+// Revenue is quantity * salePrice per order line, the same basis as orders.totalPrice.
+
+func getProductMarginsHandler(w http.ResponseWriter, r *http.Request) {
+	writeMarginReport(w, r, "product")
+}
+
+func getCustomerMarginsHandler(w http.ResponseWriter, r *http.Request) {
+	writeMarginReport(w, r, "customer")
+}
+
+func getPeriodMarginsHandler(w http.ResponseWriter, r *http.Request) {
+	writeMarginReport(w, r, "period")
+}
+
+func writeMarginReport(w http.ResponseWriter, r *http.Request, groupBy string) {
+	var conds []string
+	var args []interface{}
+	if s := strings.TrimSpace(r.URL.Query().Get("from")); s != "" {
+		conds = append(conds, "o.createdAt >= ?")
+		args = append(args, s)
+	}
+	if s := strings.TrimSpace(r.URL.Query().Get("to")); s != "" {
+		conds = append(conds, "substr(o.createdAt, 1, ?) <= ?")
+		args = append(args, len(s), s)
+	}
+	for param, col := range map[string]string{"productId": "oi.productId", "customerId": "o.customerId"} {
+		if s := r.URL.Query().Get(param); s != "" {
+			n, err := atoiParam(s)
+			if err != nil {
+				tools.HandleBadRequest(w, errors.New("invalid "+param))
+				return
+			}
+			conds = append(conds, col+" = ?")
+			args = append(args, n)
+		}
+	}
+	where := ""
+	if len(conds) > 0 {
+		where = "WHERE " + strings.Join(conds, " AND ")
+	}
+
+	var keyCols, groupCol, orderBy string
+	switch groupBy {
+	case "product":
+		keyCols = "oi.productId, COALESCE(p.name, ''), 0, '', ''"
+		groupCol = "oi.productId"
+		orderBy = "gross_margin DESC, oi.productId"
+	case "customer":
+		keyCols = "0, '', o.customerId, COALESCE(c.name, ''), ''"
+		groupCol = "o.customerId"
+		orderBy = "gross_margin DESC, o.customerId"
+	case "period":
+		var bucket string
+		switch r.URL.Query().Get("period") {
+		case "day":
+			bucket = "strftime('%Y-%m-%d', o.createdAt)"
+		case "week":
+			bucket = "strftime('%Y-W%W', o.createdAt)"
+		case "", "month":
+			bucket = "strftime('%Y-%m', o.createdAt)"
+		default:
+			tools.HandleBadRequest(w, errors.New("period must be day, week or month"))
+			return
+		}
+		keyCols = "0, '', 0, '', COALESCE(" + bucket + ", '')"
+		groupCol = bucket
+		orderBy = "1"
+	}
+
+	rows, err := tools.DB.Query(`
+		SELECT `+keyCols+`,
+		       COUNT(DISTINCT o.orderId),
+		       SUM(oi.quantity),
+		       SUM(oi.quantity * oi.salePrice),
+		       SUM(CASE WHEN oi.unit_cost IS NOT NULL THEN oi.quantity * oi.salePrice ELSE 0 END),
+		       COALESCE(SUM(oi.quantity * oi.unit_cost), 0),
+		       SUM(CASE WHEN oi.unit_cost IS NOT NULL THEN oi.quantity * (oi.salePrice - oi.unit_cost) ELSE 0 END) AS gross_margin
+		FROM order_items oi
+		JOIN orders o ON o.orderId = oi.orderId
+		LEFT JOIN products p ON p.id = oi.productId
+		LEFT JOIN customers c ON c.id = o.customerId
+		`+where+`
+		GROUP BY `+groupCol+`
+		ORDER BY `+orderBy, args...)
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	defer rows.Close()
+
+	out := []models.MarginRow{}
+	var totals models.MarginRow
+	for rows.Next() {
+		var m models.MarginRow
+		if err := rows.Scan(&m.ProductID, &m.ProductName, &m.CustomerID, &m.CustomerName, &m.Period,
+			&m.Orders, &m.Units, &m.Revenue, &m.CostedRevenue, &m.Cost, &m.GrossMargin); err != nil {
+			tools.HandleInternalServerError(w, err)
+			return
+		}
+		finishMarginRow(&m)
+		out = append(out, m)
+
+		totals.Units += m.Units
+		totals.Revenue += m.Revenue
+		totals.CostedRevenue += m.CostedRevenue
+		totals.Cost += m.Cost
+		totals.GrossMargin += m.GrossMargin
+	}
+	if err := rows.Err(); err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	// Orders can span several products, so the total is counted rather than summed
+	if err := tools.DB.QueryRow(`
+		SELECT COUNT(DISTINCT o.orderId)
+		FROM order_items oi
+		JOIN orders o ON o.orderId = oi.orderId
+		`+where, args...).Scan(&totals.Orders); err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	finishMarginRow(&totals)
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"data":   out,
+		"totals": totals,
+	})
+}
+
+// finishMarginRow fills the derived fields of a margin row.
+func finishMarginRow(m *models.MarginRow) {
+	m.UncostedRevenue = m.Revenue - m.CostedRevenue
+	if m.CostedRevenue > 0 {
+		pct := math.Round(m.GrossMargin/m.CostedRevenue*10000) / 100
+		m.MarginPct = &pct
+	}
+}
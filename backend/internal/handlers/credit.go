@@ -0,0 +1,358 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/MananKakkar1/SalesBoard/backend/internal/models"
+	"github.com/MananKakkar1/SalesBoard/backend/internal/tools"
+	"github.com/go-chi/chi"
+)
+
+// An order is owed totalPrice - amount_paid; it counts toward the customer's balance
+// while that is above half a cent. due_date is the order date plus the customer's
+// payment terms at the time of the order.
+
+// ---------- Input DTOs ----------
+
+type creditIn struct {
+	CreditLimit      *float64 `json:"creditLimit"` // null removes the limit
+	PaymentTermsDays int      `json:"paymentTermsDays"`
+	CreditAction     string   `json:"creditAction"` // block (default) | flag
+}
+
+type orderPaidIn struct {
+	Paid   bool   `json:"paid"`
+	Method string `json:"method"` // payment method when settling; default other
+	UserID *int   `json:"userId"`
+}
+
+// ---------- helpers ----------
+
+func roundMoney(v float64) float64 {
+	return math.Round(v*100) / 100
+}
+
+// dueDate is the order date plus termsDays; createdAt falls back to today when it
+// doesn't start with a YYYY-MM-DD date.
+func dueDate(createdAt string, termsDays int) string {
+	day, err := time.Parse(dateLayout, createdAt[:min(len(createdAt), len(dateLayout))])
+	if err != nil {
+		day = time.Now().UTC()
+	}
+	return day.AddDate(0, 0, termsDays).Format(dateLayout)
+}
+
+// loadCustomerCredit returns the customer's terms and balances as of today, or sql.ErrNoRows.
+func loadCustomerCredit(q queryer, customerID int) (models.CustomerCredit, error) {
+	c := models.CustomerCredit{CustomerID: customerID}
+	if err := q.QueryRow(
+		`SELECT credit_limit, payment_terms_days, credit_action FROM customers WHERE id = ?`, customerID,
+	).Scan(&c.CreditLimit, &c.PaymentTermsDays, &c.CreditAction); err != nil {
+		return c, err
+	}
+	today := time.Now().UTC().Format(dateLayout)
+	if err := q.QueryRow(`
+		SELECT COUNT(*),
+		       COALESCE(SUM(totalPrice - amount_paid), 0),
+		       COALESCE(SUM(CASE WHEN due_date < ? THEN totalPrice - amount_paid ELSE 0 END), 0)
+		FROM orders
+		WHERE customerId = ? AND totalPrice - amount_paid > 0.005`, today, customerID,
+	).Scan(&c.UnpaidOrders, &c.OutstandingBalance, &c.OverdueBalance); err != nil {
+		return c, err
+	}
+	c.OutstandingBalance = roundMoney(c.OutstandingBalance)
+	c.OverdueBalance = roundMoney(c.OverdueBalance)
+	unapplied, err := customerUnappliedCredit(q, customerID)
+	if err != nil {
+		return c, err
+	}
+	c.UnappliedCredit = unapplied
+	if c.CreditLimit != nil {
+		avail := roundMoney(*c.CreditLimit - c.OutstandingBalance)
+		c.AvailableCredit = &avail
+	}
+	return c, nil
+}
+
+// checkCreditLimit reports whether an order of amount would take the customer past
+// their credit limit. Customers without a limit never exceed it.
+func checkCreditLimit(q queryer, customerID int, amount float64) (models.CustomerCredit, bool, error) {
+	c, err := loadCustomerCredit(q, customerID)
+	if err != nil {
+		return c, false, err
+	}
+	over := c.CreditLimit != nil && c.OutstandingBalance+amount > *c.CreditLimit+0.005
+	return c, over, nil
+}
+
+// writeCreditLimitError is the 409 for an order blocked by the customer's credit limit.
+func writeCreditLimitError(w http.ResponseWriter, c models.CustomerCredit, orderTotal float64) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusConflict)
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"error": fmt.Sprintf("order total %.2f would take customer %d to %.2f, over their credit limit of %.2f",
+			orderTotal, c.CustomerID, c.OutstandingBalance+orderTotal, *c.CreditLimit),
+		"creditLimit":        c.CreditLimit,
+		"outstandingBalance": c.OutstandingBalance,
+		"orderTotal":         orderTotal,
+	})
+}
+
+// ---------- Read (GET /api/customers/{id}/credit) ----------
+func getCustomerCreditHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := atoiParam(chi.URLParam(r, "id"))
+	if err != nil {
+		tools.HandleBadRequest(w, err)
+		return
+	}
+	c, err := loadCustomerCredit(tools.DB, id)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Customer not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(c)
+}
+
+// ---------- Update (PUT /api/customers/{id}/credit) ----------
+// body: { "creditLimit": 5000, "paymentTermsDays": 30, "creditAction": "block" }
+// New terms apply to orders placed afterwards; existing due dates are kept.
+func putCustomerCreditHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := atoiParam(chi.URLParam(r, "id"))
+	if err != nil {
+		tools.HandleBadRequest(w, err)
+		return
+	}
+	var in creditIn
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		tools.HandleBadRequest(w, errors.New("invalid request"))
+		return
+	}
+	if in.CreditLimit != nil && *in.CreditLimit < 0 {
+		tools.HandleBadRequest(w, errors.New("creditLimit cannot be negative"))
+		return
+	}
+	if in.PaymentTermsDays < 0 || in.PaymentTermsDays > 365 {
+		tools.HandleBadRequest(w, errors.New("paymentTermsDays must be between 0 and 365"))
+		return
+	}
+	in.CreditAction = strings.TrimSpace(in.CreditAction)
+	if in.CreditAction == "" {
+		in.CreditAction = "block"
+	}
+	if in.CreditAction != "block" && in.CreditAction != "flag" {
+		tools.HandleBadRequest(w, errors.New("creditAction must be block or flag"))
+		return
+	}
+
+	res, err := tools.DB.Exec(
+		`UPDATE customers SET credit_limit = ?, payment_terms_days = ?, credit_action = ? WHERE id = ?`,
+		in.CreditLimit, in.PaymentTermsDays, in.CreditAction, id,
+	)
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		http.Error(w, "Customer not found", http.StatusNotFound)
+		return
+	}
+	getCustomerCreditHandler(w, r)
+}
+
+// ---------- Mark paid (PUT /api/orders/{id}/paid) ----------
+// body: { "paid": true, "method": "card" } records a payment for the whole balance;
+// false reopens an order that was settled without going through the payments ledger
+// (orders with payments applied have to be refunded instead).
+func setOrderPaidHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := atoiParam(chi.URLParam(r, "id"))
+	if err != nil {
+		tools.HandleBadRequest(w, err)
+		return
+	}
+	var in orderPaidIn
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		tools.HandleBadRequest(w, errors.New("invalid request"))
+		return
+	}
+
+	tx, err := tools.DB.Begin()
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	defer tx.Rollback()
+
+	var customerID int
+	var balance float64
+	err = tx.QueryRow(`SELECT customerId, totalPrice - amount_paid FROM orders WHERE orderId = ?`, id).Scan(&customerID, &balance)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Order not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+
+	var payment *models.Payment
+	var balances []orderBalance
+	if in.Paid {
+		if balance = roundMoney(balance); balance > 0 {
+			if in.Method == "" {
+				in.Method = "other"
+			}
+			pid, orders, err := recordPayment(tx, paymentIn{
+				CustomerID:  customerID,
+				Method:      in.Method,
+				Amount:      balance,
+				UserID:      in.UserID,
+				Allocations: []allocationIn{{OrderID: id, Amount: balance}},
+			})
+			if err != nil {
+				writeFieldError(w, err)
+				return
+			}
+			p, err := loadPayment(tx, pid)
+			if err != nil {
+				tools.HandleInternalServerError(w, err)
+				return
+			}
+			payment, balances = &p, orders
+		}
+	} else {
+		var allocations int
+		if err := tx.QueryRow(`SELECT COUNT(*) FROM payment_allocations WHERE order_id = ?`, id).Scan(&allocations); err != nil {
+			tools.HandleInternalServerError(w, err)
+			return
+		}
+		if allocations > 0 {
+			http.Error(w, "Order has payments applied; record a refund against it instead", http.StatusConflict)
+			return
+		}
+		if _, err := tx.Exec(`UPDATE orders SET amount_paid = 0, paid_at = NULL WHERE orderId = ?`, id); err != nil {
+			tools.HandleInternalServerError(w, err)
+			return
+		}
+	}
+
+	var out struct {
+		OrderID    int     `json:"orderId"`
+		TotalPrice float64 `json:"totalPrice"`
+		AmountPaid float64 `json:"amountPaid"`
+		BalanceDue float64 `json:"balanceDue"`
+		DueDate    *string `json:"dueDate"`
+		PaidAt     *string `json:"paidAt"`
+	}
+	if err := tx.QueryRow(
+		`SELECT orderId, totalPrice, amount_paid, due_date, paid_at FROM orders WHERE orderId = ?`, id,
+	).Scan(&out.OrderID, &out.TotalPrice, &out.AmountPaid, &out.DueDate, &out.PaidAt); err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	out.BalanceDue = roundMoney(out.TotalPrice - out.AmountPaid)
+	if err := tx.Commit(); err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	if payment != nil {
+		broadcastPaymentEvents("payment.recorded", *payment, balances)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(out)
+}
+
+// ---------- AR aging (GET /api/reports/ar-aging?asOf=YYYY-MM-DD&customerId=) ----------
+// Unpaid balances by customer, bucketed by days since the order date as of asOf
+// (default today). Orders dated after asOf are left out.
+func getARAgingHandler(w http.ResponseWriter, r *http.Request) {
+	asOf := time.Now().UTC().Format(dateLayout)
+	if s := strings.TrimSpace(r.URL.Query().Get("asOf")); s != "" {
+		if _, err := time.Parse(dateLayout, s); err != nil {
+			tools.HandleBadRequest(w, errors.New("asOf must be a YYYY-MM-DD date"))
+			return
+		}
+		asOf = s
+	}
+	where := ""
+	args := []any{asOf, asOf, asOf}
+	if s := r.URL.Query().Get("customerId"); s != "" {
+		cid, err := atoiParam(s)
+		if err != nil {
+			tools.HandleBadRequest(w, errors.New("invalid customerId"))
+			return
+		}
+		where = "AND customerId = ?"
+		args = append(args, cid)
+	}
+
+	rows, err := tools.DB.Query(`
+		SELECT a.customerId, COALESCE(c.name, ''),
+		       SUM(CASE WHEN a.age <= 30 THEN a.balance ELSE 0 END),
+		       SUM(CASE WHEN a.age BETWEEN 31 AND 60 THEN a.balance ELSE 0 END),
+		       SUM(CASE WHEN a.age BETWEEN 61 AND 90 THEN a.balance ELSE 0 END),
+		       SUM(CASE WHEN a.age > 90 THEN a.balance ELSE 0 END),
+		       SUM(a.balance) AS total,
+		       SUM(CASE WHEN a.due_date < a.as_of THEN a.balance ELSE 0 END)
+		FROM (
+			SELECT customerId, totalPrice - amount_paid AS balance, due_date, ? AS as_of,
+			       CAST(julianday(?) - julianday(substr(createdAt, 1, 10)) AS INTEGER) AS age
+			FROM orders
+			WHERE totalPrice - amount_paid > 0.005 AND substr(createdAt, 1, 10) <= ? `+where+`
+		) a
+		LEFT JOIN customers c ON c.id = a.customerId
+		GROUP BY a.customerId
+		ORDER BY total DESC, a.customerId`, args...)
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	defer rows.Close()
+
+	out := []models.ARAgingRow{}
+	var totals models.ARAgingRow
+	for rows.Next() {
+		var a models.ARAgingRow
+		if err := rows.Scan(&a.CustomerID, &a.CustomerName, &a.Days0To30, &a.Days31To60, &a.Days61To90,
+			&a.DaysOver90, &a.Total, &a.Overdue); err != nil {
+			tools.HandleInternalServerError(w, err)
+			return
+		}
+		for _, v := range []*float64{&a.Days0To30, &a.Days31To60, &a.Days61To90, &a.DaysOver90, &a.Total, &a.Overdue} {
+			*v = roundMoney(*v)
+		}
+		out = append(out, a)
+
+		totals.Days0To30 += a.Days0To30
+		totals.Days31To60 += a.Days31To60
+		totals.Days61To90 += a.Days61To90
+		totals.DaysOver90 += a.DaysOver90
+		totals.Total += a.Total
+		totals.Overdue += a.Overdue
+	}
+	if err := rows.Err(); err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	for _, v := range []*float64{&totals.Days0To30, &totals.Days31To60, &totals.Days61To90, &totals.DaysOver90, &totals.Total, &totals.Overdue} {
+		*v = roundMoney(*v)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"asOf":   asOf,
+		"data":   out,
+		"totals": totals,
+	})
+}
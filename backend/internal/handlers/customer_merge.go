@@ -0,0 +1,704 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/MananKakkar1/SalesBoard/backend/internal/models"
+	"github.com/MananKakkar1/SalesBoard/backend/internal/tools"
+	"github.com/go-chi/chi"
+)
+
+// ---------- Duplicate detection ----------
+
+// Score weights: a shared normalized email alone makes a candidate, a shared phone
+// needs a similar name as well, and a similar name alone never does.
+const (
+	dupEmailWeight    = 0.6
+	dupPhoneWeight    = 0.4
+	dupNameWeight     = 0.4
+	dupNameMinSim     = 0.8
+	dupDefaultMin     = 0.5
+	dupCommonTokenMax = 50 // name tokens shared by more customers than this ("customer", "inc") don't pair them
+)
+
+// dupKeys are the normalized fields compared between customers.
+type dupKeys struct {
+	customer models.Customer
+	email    string
+	phone    string
+	name     string // lower-case tokens, sorted
+	tokens   []string
+}
+
+// normalizeEmail lower-cases the address and drops "+tag" suffixes; Gmail addresses
+// also lose the dots in the local part, which Gmail ignores.
+func normalizeEmail(email string) string {
+	email = strings.ToLower(strings.TrimSpace(email))
+	at := strings.LastIndex(email, "@")
+	if at <= 0 {
+		return email
+	}
+	local, domain := email[:at], email[at+1:]
+	if i := strings.Index(local, "+"); i > 0 {
+		local = local[:i]
+	}
+	if domain == "googlemail.com" {
+		domain = "gmail.com"
+	}
+	if domain == "gmail.com" {
+		local = strings.ReplaceAll(local, ".", "")
+	}
+	return local + "@" + domain
+}
+
+// normalizePhone keeps the digits, dropping a leading North American "1" country code.
+// Fewer than 7 digits isn't a usable phone number.
+func normalizePhone(phone string) string {
+	var b strings.Builder
+	for _, r := range phone {
+		if r >= '0' && r <= '9' {
+			b.WriteRune(r)
+		}
+	}
+	d := b.String()
+	if len(d) == 11 && d[0] == '1' {
+		d = d[1:]
+	}
+	if len(d) < 7 {
+		return ""
+	}
+	return d
+}
+
+// nameTokens lower-cases the name, drops punctuation and sorts the words, so
+// "Smith, John" and "john smith" compare equal.
+func nameTokens(name string) []string {
+	tokens := strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	sort.Strings(tokens)
+	return tokens
+}
+
+// nameSimilarity is 1 minus the edit distance over the longer name's length.
+func nameSimilarity(a, b string) float64 {
+	ra, rb := []rune(a), []rune(b)
+	longest := len(ra)
+	if len(rb) > longest {
+		longest = len(rb)
+	}
+	if longest == 0 {
+		return 0
+	}
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return 1 - float64(prev[len(rb)])/float64(longest)
+}
+
+// scoreDuplicate compares two customers; reasons is empty when nothing matched.
+func scoreDuplicate(a, b *dupKeys) (score float64, reasons []string) {
+	if a.email != "" && a.email == b.email {
+		score += dupEmailWeight
+		reasons = append(reasons, "email")
+	}
+	if a.phone != "" && a.phone == b.phone {
+		score += dupPhoneWeight
+		reasons = append(reasons, "phone")
+	}
+	if sim := nameSimilarity(a.name, b.name); sim >= dupNameMinSim {
+		score += dupNameWeight * sim
+		reasons = append(reasons, "name")
+	}
+	return math.Min(1, math.Round(score*100)/100), reasons
+}
+
+// findDuplicateCandidates pairs up active customers scoring at least minScore. Only
+// customers sharing an email, a phone or a name token are compared, so the work stays
+// close to linear. With onlyID set, only pairs involving that customer are returned.
+func findDuplicateCandidates(q queryer, minScore float64, onlyID int) ([]models.DuplicateCandidate, error) {
+	rows, err := q.Query(`SELECT ` + customerColumns + ` FROM customers WHERE deleted_at IS NULL ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var all []*dupKeys
+	for rows.Next() {
+		var c models.Customer
+		if err := rows.Scan(&c.ID, &c.Name, &c.Email, &c.Phone, &c.Address, &c.DeletedAt, &c.MergedInto); err != nil {
+			return nil, err
+		}
+		tokens := nameTokens(c.Name)
+		all = append(all, &dupKeys{
+			customer: c,
+			email:    normalizeEmail(c.Email),
+			phone:    normalizePhone(c.Phone),
+			name:     strings.Join(tokens, " "),
+			tokens:   tokens,
+		})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	blocks := map[string][]int{}
+	for i, k := range all {
+		if k.email != "" {
+			blocks["e:"+k.email] = append(blocks["e:"+k.email], i)
+		}
+		if k.phone != "" {
+			blocks["p:"+k.phone] = append(blocks["p:"+k.phone], i)
+		}
+		for _, t := range k.tokens {
+			if len([]rune(t)) >= 2 {
+				blocks["n:"+t] = append(blocks["n:"+t], i)
+			}
+		}
+	}
+
+	type pair struct{ a, b int }
+	seen := map[pair]bool{}
+	out := []models.DuplicateCandidate{}
+	for key, members := range blocks {
+		if strings.HasPrefix(key, "n:") && len(members) > dupCommonTokenMax {
+			continue
+		}
+		for x := 0; x < len(members); x++ {
+			for y := x + 1; y < len(members); y++ {
+				p := pair{members[x], members[y]}
+				if p.a == p.b || seen[p] {
+					continue
+				}
+				seen[p] = true
+				a, b := all[p.a], all[p.b]
+				if onlyID != 0 && a.customer.ID != onlyID && b.customer.ID != onlyID {
+					continue
+				}
+				score, reasons := scoreDuplicate(a, b)
+				if score < minScore {
+					continue
+				}
+				first, second := a.customer, b.customer
+				if onlyID != 0 && second.ID == onlyID {
+					first, second = second, first
+				}
+				out = append(out, models.DuplicateCandidate{Customer: first, Duplicate: second, Score: score, Reasons: reasons})
+			}
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Score != out[j].Score {
+			return out[i].Score > out[j].Score
+		}
+		if out[i].Customer.ID != out[j].Customer.ID {
+			return out[i].Customer.ID < out[j].Customer.ID
+		}
+		return out[i].Duplicate.ID < out[j].Duplicate.ID
+	})
+	return out, nil
+}
+
+func parseMinScore(r *http.Request) (float64, error) {
+	s := r.URL.Query().Get("minScore")
+	if s == "" {
+		return dupDefaultMin, nil
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil || v <= 0 || v > 1 {
+		return 0, errors.New("minScore must be a number in (0, 1]")
+	}
+	return v, nil
+}
+
+// ---------- List (GET /api/customers/duplicates?minScore=&page=&pageSize=) ----------
+func getDuplicateCustomersHandler(w http.ResponseWriter, r *http.Request) {
+	minScore, err := parseMinScore(r)
+	if err != nil {
+		tools.HandleBadRequest(w, err)
+		return
+	}
+	list, err := findDuplicateCandidates(tools.DB, minScore, 0)
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	page, pageSize, offset := parsePage(r)
+	end := min(offset+pageSize, len(list))
+	data := []models.DuplicateCandidate{}
+	if offset < len(list) {
+		data = list[offset:end]
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"data":       data,
+		"pagination": paginationMeta(page, pageSize, len(list)),
+	})
+}
+
+// ---------- For one customer (GET /api/customers/{id}/duplicates?minScore=) ----------
+func getCustomerDuplicatesHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := atoiParam(chi.URLParam(r, "id"))
+	if err != nil {
+		tools.HandleBadRequest(w, err)
+		return
+	}
+	minScore, err := parseMinScore(r)
+	if err != nil {
+		tools.HandleBadRequest(w, err)
+		return
+	}
+	if ok, err := customerExists(tools.DB, id); err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	} else if !ok {
+		http.Error(w, "Customer not found", http.StatusNotFound)
+		return
+	}
+	list, err := findDuplicateCandidates(tools.DB, minScore, id)
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(list)
+}
+
+// ---------- Merge ----------
+
+// customerRef is a column pointing at customers that a merge re-points to the survivor.
+// Tables added later that belong to a customer should be listed here.
+// customer_addresses is handled separately because of its default flags.
+type customerRef struct {
+	table, key, column string
+	// unique, when set, is a column unique per customer: rows whose value the survivor
+	// already has stay with the merged customer.
+	unique string
+}
+
+var customerRefs = []customerRef{
+	{"orders", "orderId", "customerId", ""},
+	{"payments", "id", "customer_id", ""},
+	{"payment_intents", "id", "customer_id", ""},
+	{"customer_tags", "id", "customer_id", "tag"},
+	{"activities", "id", "customer_id", ""},
+	{"quotes", "id", "customer_id", ""},
+	{"recurring_orders", "id", "customer_id", ""},
+}
+
+type mergeCustomerIn struct {
+	DuplicateID int    `json:"duplicateId"`
+	UserID      *int   `json:"userId"`
+	Reason      string `json:"reason"`
+}
+
+type undoMergeIn struct {
+	UserID *int `json:"userId"`
+}
+
+const customerMergeColumns = `id, survivor_id, merged_id, merged_by, reason, created_at, undone_at, undone_by`
+
+func scanCustomerMerge(row rowScanner) (models.CustomerMerge, error) {
+	var m models.CustomerMerge
+	err := row.Scan(&m.ID, &m.SurvivorID, &m.MergedID, &m.MergedBy, &m.Reason, &m.CreatedAt, &m.UndoneAt, &m.UndoneBy)
+	return m, err
+}
+
+// loadCustomerMerge returns the merge with its moved-row counts, or sql.ErrNoRows.
+func loadCustomerMerge(q queryer, id int) (models.CustomerMerge, error) {
+	m, err := scanCustomerMerge(q.QueryRow(`SELECT `+customerMergeColumns+` FROM customer_merges WHERE id = ?`, id))
+	if err != nil {
+		return m, err
+	}
+	rows, err := q.Query(`SELECT table_name, COUNT(*) FROM customer_merge_moves WHERE merge_id = ? GROUP BY table_name`, id)
+	if err != nil {
+		return m, err
+	}
+	defer rows.Close()
+	m.Moved = map[string]int{}
+	for rows.Next() {
+		var table string
+		var n int
+		if err := rows.Scan(&table, &n); err != nil {
+			return m, err
+		}
+		m.Moved[table] = n
+	}
+	return m, rows.Err()
+}
+
+// mergeState is what a merge or undo needs to know about a customer.
+type mergeState struct {
+	deletedAt  sql.NullString
+	mergedInto sql.NullInt64
+}
+
+func loadMergeState(q queryer, id int) (mergeState, error) {
+	var s mergeState
+	err := q.QueryRow(`SELECT deleted_at, merged_into FROM customers WHERE id = ?`, id).Scan(&s.deletedAt, &s.mergedInto)
+	return s, err
+}
+
+// mergeCustomers moves the duplicate's addresses and references to the survivor and
+// archives the duplicate. The survivor's default addresses win; the duplicate's keep
+// their flags only when the survivor has no default of that kind.
+func mergeCustomers(tx *sql.Tx, survivorID, duplicateID int, in mergeCustomerIn) (int, error) {
+	if survivorID == duplicateID {
+		return 0, &invalidFieldError{"a customer can't be merged into itself"}
+	}
+	survivor, err := loadMergeState(tx, survivorID)
+	if err == sql.ErrNoRows {
+		return 0, &invalidFieldError{fmt.Sprintf("customer %d does not exist", survivorID)}
+	} else if err != nil {
+		return 0, err
+	}
+	if survivor.deletedAt.Valid {
+		return 0, &conflictError{fmt.Sprintf("customer %d is archived; restore it before merging into it", survivorID)}
+	}
+	dup, err := loadMergeState(tx, duplicateID)
+	if err == sql.ErrNoRows {
+		return 0, &invalidFieldError{fmt.Sprintf("customer %d does not exist", duplicateID)}
+	} else if err != nil {
+		return 0, err
+	}
+	if dup.mergedInto.Valid {
+		return 0, &conflictError{fmt.Sprintf("customer %d was already merged into customer %d", duplicateID, dup.mergedInto.Int64)}
+	}
+	if err := checkUser(tx, in.UserID); err != nil {
+		return 0, err
+	}
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	res, err := tx.Exec(`
+		INSERT INTO customer_merges (survivor_id, merged_id, merged_deleted_at, merged_by, reason, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)`,
+		survivorID, duplicateID, dup.deletedAt, in.UserID, strings.TrimSpace(in.Reason), now,
+	)
+	if err != nil {
+		return 0, err
+	}
+	id64, _ := res.LastInsertId()
+	mergeID := int(id64)
+
+	if _, err := tx.Exec(`
+		INSERT INTO customer_merge_moves (merge_id, table_name, row_id, was_default_billing, was_default_shipping)
+		SELECT ?, 'customer_addresses', id, is_default_billing, is_default_shipping
+		FROM customer_addresses WHERE customer_id = ?`, mergeID, duplicateID,
+	); err != nil {
+		return 0, err
+	}
+	for _, col := range []string{"is_default_billing", "is_default_shipping"} {
+		if _, err := tx.Exec(`
+			UPDATE customer_addresses SET `+col+` = 0
+			 WHERE customer_id = ?
+			   AND EXISTS (SELECT 1 FROM customer_addresses WHERE customer_id = ? AND `+col+` = 1)`,
+			duplicateID, survivorID,
+		); err != nil {
+			return 0, err
+		}
+	}
+	if _, err := tx.Exec(`UPDATE customer_addresses SET customer_id = ? WHERE customer_id = ?`, survivorID, duplicateID); err != nil {
+		return 0, err
+	}
+
+	for _, ref := range customerRefs {
+		movable, args := "", []any{mergeID, ref.table, duplicateID}
+		if ref.unique != "" {
+			movable = ` AND ` + ref.unique + ` NOT IN (SELECT ` + ref.unique + ` FROM ` + ref.table + ` WHERE ` + ref.column + ` = ?)`
+			args = append(args, survivorID)
+		}
+		if _, err := tx.Exec(`
+			INSERT INTO customer_merge_moves (merge_id, table_name, row_id)
+			SELECT ?, ?, `+ref.key+` FROM `+ref.table+` WHERE `+ref.column+` = ?`+movable, args...,
+		); err != nil {
+			return 0, err
+		}
+		if _, err := tx.Exec(`
+			UPDATE `+ref.table+` SET `+ref.column+` = ?
+			 WHERE `+ref.key+` IN (SELECT row_id FROM customer_merge_moves WHERE merge_id = ? AND table_name = ?)`,
+			survivorID, mergeID, ref.table,
+		); err != nil {
+			return 0, err
+		}
+	}
+
+	if _, err := tx.Exec(
+		`UPDATE customers SET deleted_at = COALESCE(deleted_at, ?), merged_into = ? WHERE id = ?`, now, survivorID, duplicateID,
+	); err != nil {
+		return 0, err
+	}
+	return mergeID, syncCustomerAddress(tx, survivorID)
+}
+
+// undoCustomerMerge moves the rows listed for the merge back to the merged customer and
+// un-archives it. Rows created for the survivor since the merge stay where they are.
+func undoCustomerMerge(tx *sql.Tx, m models.CustomerMerge, userID *int) error {
+	if m.UndoneAt != nil {
+		return &conflictError{fmt.Sprintf("merge %d was already undone", m.ID)}
+	}
+	survivor, err := loadMergeState(tx, m.SurvivorID)
+	if err != nil {
+		return err
+	}
+	if survivor.mergedInto.Valid {
+		return &conflictError{fmt.Sprintf(
+			"customer %d has since been merged into customer %d; undo that merge first", m.SurvivorID, survivor.mergedInto.Int64,
+		)}
+	}
+	if err := checkUser(tx, userID); err != nil {
+		return err
+	}
+
+	var mergedDeletedAt sql.NullString
+	if err := tx.QueryRow(`SELECT merged_deleted_at FROM customer_merges WHERE id = ?`, m.ID).Scan(&mergedDeletedAt); err != nil {
+		return err
+	}
+
+	// Flags come off first so neither customer briefly holds two defaults.
+	if _, err := tx.Exec(`
+		UPDATE customer_addresses SET is_default_billing = 0, is_default_shipping = 0, customer_id = ?
+		 WHERE customer_id = ?
+		   AND id IN (SELECT row_id FROM customer_merge_moves WHERE merge_id = ? AND table_name = 'customer_addresses')`,
+		m.MergedID, m.SurvivorID, m.ID,
+	); err != nil {
+		return err
+	}
+	if err := clearDefaults(tx, m.MergedID, true, true); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`
+		UPDATE customer_addresses
+		   SET is_default_billing  = (SELECT was_default_billing FROM customer_merge_moves mv
+		                              WHERE mv.merge_id = ? AND mv.table_name = 'customer_addresses' AND mv.row_id = customer_addresses.id),
+		       is_default_shipping = (SELECT was_default_shipping FROM customer_merge_moves mv
+		                              WHERE mv.merge_id = ? AND mv.table_name = 'customer_addresses' AND mv.row_id = customer_addresses.id)
+		 WHERE customer_id = ?
+		   AND id IN (SELECT row_id FROM customer_merge_moves WHERE merge_id = ? AND table_name = 'customer_addresses')`,
+		m.ID, m.ID, m.MergedID, m.ID,
+	); err != nil {
+		return err
+	}
+
+	for _, ref := range customerRefs {
+		if _, err := tx.Exec(`
+			UPDATE `+ref.table+` SET `+ref.column+` = ?
+			 WHERE `+ref.column+` = ?
+			   AND `+ref.key+` IN (SELECT row_id FROM customer_merge_moves WHERE merge_id = ? AND table_name = ?)`,
+			m.MergedID, m.SurvivorID, m.ID, ref.table,
+		); err != nil {
+			return err
+		}
+	}
+
+	if _, err := tx.Exec(
+		`UPDATE customers SET deleted_at = ?, merged_into = NULL WHERE id = ?`, mergedDeletedAt, m.MergedID,
+	); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(
+		`UPDATE customer_merges SET undone_at = ?, undone_by = ? WHERE id = ?`,
+		time.Now().UTC().Format(time.RFC3339), userID, m.ID,
+	); err != nil {
+		return err
+	}
+	for _, id := range []int{m.SurvivorID, m.MergedID} {
+		if err := promoteDefaults(tx, id); err != nil {
+			return err
+		}
+		if err := syncCustomerAddress(tx, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ---------- Merge (POST /api/customers/{id}/merge) ----------
+// body: { "duplicateId": 42, "userId": 1, "reason": "same person, old email" }
+// {id} survives; the duplicate's orders and addresses move to it and the duplicate is archived.
+func mergeCustomerHandler(w http.ResponseWriter, r *http.Request) {
+	survivorID, err := atoiParam(chi.URLParam(r, "id"))
+	if err != nil {
+		tools.HandleBadRequest(w, err)
+		return
+	}
+	var in mergeCustomerIn
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		tools.HandleBadRequest(w, errors.New("invalid request"))
+		return
+	}
+	if in.DuplicateID <= 0 {
+		tools.HandleBadRequest(w, errors.New("duplicateId is required"))
+		return
+	}
+
+	tx, err := tools.DB.Begin()
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	defer tx.Rollback()
+
+	if ok, err := customerExists(tx, survivorID); err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	} else if !ok {
+		http.Error(w, "Customer not found", http.StatusNotFound)
+		return
+	}
+	mergeID, err := mergeCustomers(tx, survivorID, in.DuplicateID, in)
+	if err != nil {
+		writeFieldError(w, err)
+		return
+	}
+	m, err := loadCustomerMerge(tx, mergeID)
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+
+	tools.SSE.Broadcast(tools.Event{
+		Type: "customer.merged",
+		Data: map[string]any{"mergeId": m.ID, "survivorId": m.SurvivorID, "mergedId": m.MergedID},
+		Time: time.Now(),
+	})
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(m)
+}
+
+// ---------- Undo (POST /api/customer-merges/{id}/undo) ----------
+// body (optional): { "userId": 1 }
+func undoCustomerMergeHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := atoiParam(chi.URLParam(r, "id"))
+	if err != nil {
+		tools.HandleBadRequest(w, err)
+		return
+	}
+	var in undoMergeIn
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+			tools.HandleBadRequest(w, errors.New("invalid request"))
+			return
+		}
+	}
+
+	tx, err := tools.DB.Begin()
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	defer tx.Rollback()
+
+	m, err := loadCustomerMerge(tx, id)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Merge not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	if err := undoCustomerMerge(tx, m, in.UserID); err != nil {
+		writeFieldError(w, err)
+		return
+	}
+	if m, err = loadCustomerMerge(tx, id); err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+
+	tools.SSE.Broadcast(tools.Event{
+		Type: "customer.merge_undone",
+		Data: map[string]any{"mergeId": m.ID, "survivorId": m.SurvivorID, "mergedId": m.MergedID},
+		Time: time.Now(),
+	})
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(m)
+}
+
+// ---------- Log (GET /api/customer-merges?customerId=&page=&pageSize=) ----------
+// customerId matches either side of the merge. Newest first.
+func getCustomerMergesHandler(w http.ResponseWriter, r *http.Request) {
+	where := "1 = 1"
+	var args []any
+	if s := r.URL.Query().Get("customerId"); s != "" {
+		cid, err := atoiParam(s)
+		if err != nil {
+			tools.HandleBadRequest(w, err)
+			return
+		}
+		where = "(survivor_id = ? OR merged_id = ?)"
+		args = append(args, cid, cid)
+	}
+	page, pageSize, offset := parsePage(r)
+
+	var total int
+	if err := tools.DB.QueryRow(`SELECT COUNT(*) FROM customer_merges WHERE `+where, args...).Scan(&total); err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	rows, err := tools.DB.Query(
+		`SELECT id FROM customer_merges WHERE `+where+` ORDER BY id DESC LIMIT ? OFFSET ?`,
+		append(args, pageSize, offset)...,
+	)
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			tools.HandleInternalServerError(w, err)
+			return
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+
+	data := []models.CustomerMerge{}
+	for _, id := range ids {
+		m, err := loadCustomerMerge(tools.DB, id)
+		if err != nil {
+			tools.HandleInternalServerError(w, err)
+			return
+		}
+		data = append(data, m)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"data":       data,
+		"pagination": paginationMeta(page, pageSize, total),
+	})
+}
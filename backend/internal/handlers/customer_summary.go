@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/MananKakkar1/SalesBoard/backend/internal/models"
+	"github.com/MananKakkar1/SalesBoard/backend/internal/tools"
+	"github.com/go-chi/chi"
+)
+
+// ---------- Summary (GET /api/customers/{id}/summary?top=&page=&pageSize=) ----------
+// Revenue is orders.totalPrice, as in /api/orders/total; top products are ranked by
+// revenue (top=, default 5, max 50) and the order list is paginated newest first.
+func getCustomerSummaryHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := atoiParam(chi.URLParam(r, "id"))
+	if err != nil {
+		tools.HandleBadRequest(w, err)
+		return
+	}
+	top := 5
+	if s := r.URL.Query().Get("top"); s != "" {
+		if top, err = strconv.Atoi(s); err != nil || top < 1 || top > 50 {
+			tools.HandleBadRequest(w, errors.New("top must be between 1 and 50"))
+			return
+		}
+	}
+	page, pageSize, offset := parsePage(r)
+
+	var s models.CustomerSummary
+	err = tools.DB.QueryRow(
+		"SELECT "+customerColumns+" FROM customers WHERE id = ?", id,
+	).Scan(&s.Customer.ID, &s.Customer.Name, &s.Customer.Email, &s.Customer.Phone, &s.Customer.Address,
+		&s.Customer.DeletedAt, &s.Customer.MergedInto)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Customer not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+
+	if err := tools.DB.QueryRow(`
+		SELECT COUNT(*), COALESCE(SUM(totalPrice), 0), MIN(createdAt), MAX(createdAt)
+		FROM orders WHERE customerId = ?`, id,
+	).Scan(&s.OrderCount, &s.LifetimeRevenue, &s.FirstOrderAt, &s.LastOrderAt); err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	if err := tools.DB.QueryRow(`
+		SELECT COALESCE(SUM(oi.quantity), 0)
+		FROM order_items oi JOIN orders o ON o.orderId = oi.orderId
+		WHERE o.customerId = ?`, id,
+	).Scan(&s.UnitsPurchased); err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	s.LifetimeRevenue = math.Round(s.LifetimeRevenue*100) / 100
+	if s.OrderCount > 0 {
+		s.AverageOrderValue = math.Round(s.LifetimeRevenue/float64(s.OrderCount)*100) / 100
+	}
+
+	rows, err := tools.DB.Query(`
+		SELECT oi.productId, COALESCE(p.name, ''), SUM(oi.quantity),
+		       SUM(oi.quantity * oi.salePrice) AS revenue, COUNT(DISTINCT o.orderId)
+		FROM order_items oi
+		JOIN orders o ON o.orderId = oi.orderId
+		LEFT JOIN products p ON p.id = oi.productId
+		WHERE o.customerId = ?
+		GROUP BY oi.productId
+		ORDER BY revenue DESC, oi.productId
+		LIMIT ?`, id, top)
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	s.TopProducts = []models.CustomerTopProduct{}
+	for rows.Next() {
+		var p models.CustomerTopProduct
+		if err := rows.Scan(&p.ProductID, &p.ProductName, &p.Quantity, &p.Revenue, &p.Orders); err != nil {
+			rows.Close()
+			tools.HandleInternalServerError(w, err)
+			return
+		}
+		p.Revenue = math.Round(p.Revenue*100) / 100
+		s.TopProducts = append(s.TopProducts, p)
+	}
+	if err := rows.Err(); err != nil {
+		rows.Close()
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	rows.Close()
+
+	rows, err = tools.DB.Query(`
+		SELECT o.orderId, o.createdAt, o.totalPrice, COALESCE(o.shipping_cost, 0),
+		       COUNT(oi.id), COALESCE(SUM(oi.quantity), 0)
+		FROM orders o
+		LEFT JOIN order_items oi ON oi.orderId = o.orderId
+		WHERE o.customerId = ?
+		GROUP BY o.orderId
+		ORDER BY o.createdAt DESC, o.orderId DESC
+		LIMIT ? OFFSET ?`, id, pageSize, offset)
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	defer rows.Close()
+	s.Orders = []models.CustomerOrderRow{}
+	for rows.Next() {
+		var o models.CustomerOrderRow
+		if err := rows.Scan(&o.OrderID, &o.CreatedAt, &o.TotalPrice, &o.ShippingCost, &o.ItemCount, &o.Units); err != nil {
+			tools.HandleInternalServerError(w, err)
+			return
+		}
+		s.Orders = append(s.Orders, o)
+	}
+	if err := rows.Err(); err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	s.Pagination = paginationMeta(page, pageSize, s.OrderCount)
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(s)
+}
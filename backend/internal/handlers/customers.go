@@ -4,6 +4,7 @@ import (
 	"database/sql"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
 	"strings"
@@ -13,20 +14,49 @@ import (
 	"github.com/go-chi/chi"
 )
 
-// createCustomerHandler creates a new customer in the database
+// customerColumns is the column list scanned into models.Customer.
+const customerColumns = "id, name, email, phone, address, deleted_at, merged_into"
+
+// createCustomerHandler creates a new customer in the database.
+// Structured "addresses" may be given; otherwise the free-text address becomes the
+// customer's default address. "tags" is optional.
 func createCustomerHandler(w http.ResponseWriter, r *http.Request) {
-	var customer models.Customer
+	var customer struct {
+		models.Customer
+		Addresses []addressCU `json:"addresses"`
+	}
 	if err := json.NewDecoder(r.Body).Decode(&customer); err != nil {
 		tools.HandleBadRequest(w, errors.New("invalid request"))
 		return
 	}
 
-	if customer.Name == "" || customer.Email == "" || customer.Phone == "" || customer.Address == "" {
-		tools.HandleBadRequest(w, errors.New("name and email are required"))
+	if customer.Name == "" || customer.Email == "" || customer.Phone == "" || (customer.Address == "" && len(customer.Addresses) == 0) {
+		tools.HandleBadRequest(w, errors.New("name, email, phone and an address are required"))
+		return
+	}
+	if len(customer.Addresses) == 0 {
+		customer.Addresses = []addressCU{{Street: customer.Address}}
+	}
+	for i := range customer.Addresses {
+		if err := customer.Addresses[i].normalize(); err != nil {
+			writeFieldError(w, err)
+			return
+		}
+	}
+	tags, err := normalizeTags(customer.Tags)
+	if err != nil {
+		writeFieldError(w, err)
 		return
 	}
 
-	_, err := tools.DB.Exec(
+	tx, err := tools.DB.Begin()
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(
 		"INSERT INTO customers (name, email, phone, address) VALUES (?, ?, ?, ?)",
 		customer.Name, customer.Email, customer.Phone, customer.Address,
 	)
@@ -34,9 +64,28 @@ func createCustomerHandler(w http.ResponseWriter, r *http.Request) {
 		tools.HandleInternalServerError(w, err)
 		return
 	}
+	id, _ := res.LastInsertId()
+	for _, a := range customer.Addresses {
+		if _, err := insertAddress(tx, int(id), a); err != nil {
+			tools.HandleInternalServerError(w, err)
+			return
+		}
+	}
+	if err := syncCustomerAddress(tx, int(id)); err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	if err := addCustomerTags(tx, int(id), tags); err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
 }
 
-// getCustomersHandler gets a list of customers with search and pagination
+// getCustomersHandler gets a list of customers with search and pagination, filtered by ?tag= and ?segment=
 func getCustomersHandler(w http.ResponseWriter, r *http.Request) {
 	search := strings.TrimSpace(r.URL.Query().Get("search"))
 	pageStr := r.URL.Query().Get("page")
@@ -58,16 +107,23 @@ func getCustomersHandler(w http.ResponseWriter, r *http.Request) {
 	var totalCount int
 	var countQuery string
 	var countArgs []interface{}
+	scope := archivedScope(r, "deleted_at")
+	filter, filterArgs, err := customerFilters(r)
+	if err != nil {
+		writeFieldError(w, err)
+		return
+	}
+	scope += filter
 	if search != "" {
 		likeQuery := "%" + strings.ToLower(search) + "%"
-		countQuery = "SELECT COUNT(*) FROM customers WHERE LOWER(name) LIKE ? OR LOWER(email) LIKE ?"
-		countArgs = []interface{}{likeQuery, likeQuery}
+		countQuery = "SELECT COUNT(*) FROM customers WHERE " + scope + " AND (LOWER(name) LIKE ? OR LOWER(email) LIKE ?)"
+		countArgs = append(append([]interface{}{}, filterArgs...), likeQuery, likeQuery)
 	} else {
-		countQuery = "SELECT COUNT(*) FROM customers"
-		countArgs = []interface{}{}
+		countQuery = "SELECT COUNT(*) FROM customers WHERE " + scope
+		countArgs = append([]interface{}{}, filterArgs...)
 	}
 
-	err := tools.DB.QueryRow(countQuery, countArgs...).Scan(&totalCount)
+	err = tools.DB.QueryRow(countQuery, countArgs...).Scan(&totalCount)
 	if err != nil {
 		tools.HandleInternalServerError(w, err)
 		return
@@ -82,11 +138,11 @@ func getCustomersHandler(w http.ResponseWriter, r *http.Request) {
 	var args []interface{}
 	if search != "" {
 		likeQuery := "%" + strings.ToLower(search) + "%"
-		dataQuery = "SELECT id, name, email, phone, address FROM customers WHERE LOWER(name) LIKE ? OR LOWER(email) LIKE ? ORDER BY id LIMIT ? OFFSET ?"
-		args = []interface{}{likeQuery, likeQuery, pageSize, offset}
+		dataQuery = "SELECT " + customerColumns + " FROM customers WHERE " + scope + " AND (LOWER(name) LIKE ? OR LOWER(email) LIKE ?) ORDER BY id LIMIT ? OFFSET ?"
+		args = append(append([]interface{}{}, filterArgs...), likeQuery, likeQuery, pageSize, offset)
 	} else {
-		dataQuery = "SELECT id, name, email, phone, address FROM customers ORDER BY id LIMIT ? OFFSET ?"
-		args = []interface{}{pageSize, offset}
+		dataQuery = "SELECT " + customerColumns + " FROM customers WHERE " + scope + " ORDER BY id LIMIT ? OFFSET ?"
+		args = append(append([]interface{}{}, filterArgs...), pageSize, offset)
 	}
 	rows, err = tools.DB.Query(dataQuery, args...)
 	if err != nil {
@@ -97,7 +153,7 @@ func getCustomersHandler(w http.ResponseWriter, r *http.Request) {
 	var customers []models.Customer
 	for rows.Next() {
 		var c models.Customer
-		if err := rows.Scan(&c.ID, &c.Name, &c.Email, &c.Phone, &c.Address); err != nil {
+		if err := rows.Scan(&c.ID, &c.Name, &c.Email, &c.Phone, &c.Address, &c.DeletedAt, &c.MergedInto); err != nil {
 			tools.HandleInternalServerError(w, err)
 			return
 		}
@@ -124,9 +180,9 @@ func getCustomerByIdHandler(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "id")
 	var c models.Customer
 	err := tools.DB.QueryRow(
-		"SELECT id, name, email, phone, address FROM customers WHERE id = ?",
+		"SELECT "+customerColumns+" FROM customers WHERE id = ?",
 		id,
-	).Scan(&c.ID, &c.Name, &c.Email, &c.Phone, &c.Address)
+	).Scan(&c.ID, &c.Name, &c.Email, &c.Phone, &c.Address, &c.DeletedAt, &c.MergedInto)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			http.Error(w, "Customer not found", http.StatusNotFound)
@@ -135,6 +191,26 @@ func getCustomerByIdHandler(w http.ResponseWriter, r *http.Request) {
 		tools.HandleInternalServerError(w, err)
 		return
 	}
+	addresses, err := loadAddresses(tools.DB, c.ID)
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	c.Addresses = addresses
+	credit, err := loadCustomerCredit(tools.DB, c.ID)
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	c.Credit = &credit
+	if c.Tags, err = loadCustomerTags(tools.DB, c.ID); err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	if c.RecentActivity, err = recentActivity(tools.DB, c.ID); err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(c)
 }
@@ -162,17 +238,29 @@ func updateCustomerDataHandler(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusNoContent)
 }
 
-// deleteCustomerHandler deletes a customer from the database
+// deleteCustomerHandler archives a customer; ?hard=true deletes one with no orders
 func deleteCustomerHandler(w http.ResponseWriter, r *http.Request) {
-	id := chi.URLParam(r, "id")
-	_, err := tools.DB.Exec("DELETE FROM customers WHERE id = ?", id)
-	if err != nil {
+	deleteArchivable(w, r, customerArchive)
+}
+
+// restoreCustomerHandler un-archives a customer; a merged customer comes back only by undoing the merge
+func restoreCustomerHandler(w http.ResponseWriter, r *http.Request) {
+	var mergeID int
+	err := tools.DB.QueryRow(
+		`SELECT id FROM customer_merges WHERE merged_id = ? AND undone_at IS NULL`, chi.URLParam(r, "id"),
+	).Scan(&mergeID)
+	if err == nil {
+		http.Error(w, fmt.Sprintf("customer was merged; undo merge %d instead", mergeID), http.StatusConflict)
+		return
+	}
+	if err != sql.ErrNoRows {
 		tools.HandleInternalServerError(w, err)
 		return
 	}
+	restoreArchivable(w, r, customerArchive)
 }
 
-// searchCustomersHandler searches for customers with pagination
+// searchCustomersHandler searches for customers with pagination, filtered by ?tag= and ?segment=
 func searchCustomersHandler(w http.ResponseWriter, r *http.Request) {
 	query := strings.TrimSpace(r.URL.Query().Get("q"))
 	pageStr := r.URL.Query().Get("page")
@@ -195,16 +283,23 @@ func searchCustomersHandler(w http.ResponseWriter, r *http.Request) {
 	var countQuery string
 	var countArgs []interface{}
 
+	scope := archivedScope(r, "deleted_at")
+	filter, filterArgs, err := customerFilters(r)
+	if err != nil {
+		writeFieldError(w, err)
+		return
+	}
+	scope += filter
 	if query != "" {
 		likeQuery := "%" + strings.ToLower(query) + "%"
-		countQuery = "SELECT COUNT(*) FROM customers WHERE LOWER(name) LIKE ? OR LOWER(email) LIKE ?"
-		countArgs = []interface{}{likeQuery, likeQuery}
+		countQuery = "SELECT COUNT(*) FROM customers WHERE " + scope + " AND (LOWER(name) LIKE ? OR LOWER(email) LIKE ?)"
+		countArgs = append(append([]interface{}{}, filterArgs...), likeQuery, likeQuery)
 	} else {
-		countQuery = "SELECT COUNT(*) FROM customers"
-		countArgs = []interface{}{}
+		countQuery = "SELECT COUNT(*) FROM customers WHERE " + scope
+		countArgs = append([]interface{}{}, filterArgs...)
 	}
 
-	err := tools.DB.QueryRow(countQuery, countArgs...).Scan(&totalCount)
+	err = tools.DB.QueryRow(countQuery, countArgs...).Scan(&totalCount)
 	if err != nil {
 		tools.HandleInternalServerError(w, err)
 		return
@@ -219,11 +314,11 @@ func searchCustomersHandler(w http.ResponseWriter, r *http.Request) {
 	var args []interface{}
 	if query != "" {
 		likeQuery := "%" + strings.ToLower(query) + "%"
-		dataQuery = "SELECT id, name, email, phone, address FROM customers WHERE LOWER(name) LIKE ? OR LOWER(email) LIKE ? ORDER BY id LIMIT ? OFFSET ?"
-		args = []interface{}{likeQuery, likeQuery, pageSize, offset}
+		dataQuery = "SELECT " + customerColumns + " FROM customers WHERE " + scope + " AND (LOWER(name) LIKE ? OR LOWER(email) LIKE ?) ORDER BY id LIMIT ? OFFSET ?"
+		args = append(append([]interface{}{}, filterArgs...), likeQuery, likeQuery, pageSize, offset)
 	} else {
-		dataQuery = "SELECT id, name, email, phone, address FROM customers ORDER BY id LIMIT ? OFFSET ?"
-		args = []interface{}{pageSize, offset}
+		dataQuery = "SELECT " + customerColumns + " FROM customers WHERE " + scope + " ORDER BY id LIMIT ? OFFSET ?"
+		args = append(append([]interface{}{}, filterArgs...), pageSize, offset)
 	}
 	rows, err = tools.DB.Query(dataQuery, args...)
 	if err != nil {
@@ -234,7 +329,7 @@ func searchCustomersHandler(w http.ResponseWriter, r *http.Request) {
 	var customers []models.Customer
 	for rows.Next() {
 		var c models.Customer
-		if err := rows.Scan(&c.ID, &c.Name, &c.Email, &c.Phone, &c.Address); err != nil {
+		if err := rows.Scan(&c.ID, &c.Name, &c.Email, &c.Phone, &c.Address, &c.DeletedAt, &c.MergedInto); err != nil {
 			tools.HandleInternalServerError(w, err)
 			return
 		}
@@ -259,7 +354,7 @@ func searchCustomersHandler(w http.ResponseWriter, r *http.Request) {
 // getTotalCustomersHandler returns the total number of customers
 func getTotalCustomersHandler(w http.ResponseWriter, r *http.Request) {
 	var count int
-	err := tools.DB.QueryRow("SELECT COUNT(*) FROM customers").Scan(&count)
+	err := tools.DB.QueryRow("SELECT COUNT(*) FROM customers WHERE deleted_at IS NULL").Scan(&count)
 	if err != nil {
 		tools.HandleInternalServerError(w, err)
 		return
@@ -271,7 +366,7 @@ func getTotalCustomersHandler(w http.ResponseWriter, r *http.Request) {
 // getRecentCustomersHandler returns the 3 most recently added customers
 func getRecentCustomersHandler(w http.ResponseWriter, r *http.Request) {
 	rows, err := tools.DB.Query(
-		"SELECT id, name, email, phone, address FROM customers ORDER BY id DESC LIMIT 3",
+		"SELECT " + customerColumns + " FROM customers WHERE deleted_at IS NULL ORDER BY id DESC LIMIT 3",
 	)
 	if err != nil {
 		tools.HandleInternalServerError(w, err)
@@ -282,7 +377,7 @@ func getRecentCustomersHandler(w http.ResponseWriter, r *http.Request) {
 	var customers []models.Customer
 	for rows.Next() {
 		var c models.Customer
-		if err := rows.Scan(&c.ID, &c.Name, &c.Email, &c.Phone, &c.Address); err != nil {
+		if err := rows.Scan(&c.ID, &c.Name, &c.Email, &c.Phone, &c.Address, &c.DeletedAt, &c.MergedInto); err != nil {
 			tools.HandleInternalServerError(w, err)
 			return
 		}
@@ -293,22 +388,26 @@ func getRecentCustomersHandler(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(customers)
 }
 
-// searchCustomersSimpleHandler searches for customers without pagination (for dropdowns)
+// searchCustomersSimpleHandler searches for customers without pagination (for dropdowns); accepts ?tag= and ?segment=
 func searchCustomersSimpleHandler(w http.ResponseWriter, r *http.Request) {
 	query := strings.TrimSpace(r.URL.Query().Get("q"))
 
 	var rows *sql.Rows
-	var err error
 	var dataQuery string
 	var args []interface{}
 
+	filter, filterArgs, err := customerFilters(r)
+	if err != nil {
+		writeFieldError(w, err)
+		return
+	}
 	if query != "" {
 		likeQuery := "%" + strings.ToLower(query) + "%"
-		dataQuery = "SELECT id, name, email, phone, address FROM customers WHERE LOWER(name) LIKE ? OR LOWER(email) LIKE ? ORDER BY id"
-		args = []interface{}{likeQuery, likeQuery}
+		dataQuery = "SELECT " + customerColumns + " FROM customers WHERE deleted_at IS NULL" + filter + " AND (LOWER(name) LIKE ? OR LOWER(email) LIKE ?) ORDER BY id"
+		args = append(filterArgs, likeQuery, likeQuery)
 	} else {
-		dataQuery = "SELECT id, name, email, phone, address FROM customers ORDER BY id"
-		args = []interface{}{}
+		dataQuery = "SELECT " + customerColumns + " FROM customers WHERE deleted_at IS NULL" + filter + " ORDER BY id"
+		args = filterArgs
 	}
 
 	rows, err = tools.DB.Query(dataQuery, args...)
@@ -321,7 +420,7 @@ func searchCustomersSimpleHandler(w http.ResponseWriter, r *http.Request) {
 	var customers []models.Customer
 	for rows.Next() {
 		var c models.Customer
-		if err := rows.Scan(&c.ID, &c.Name, &c.Email, &c.Phone, &c.Address); err != nil {
+		if err := rows.Scan(&c.ID, &c.Name, &c.Email, &c.Phone, &c.Address, &c.DeletedAt, &c.MergedInto); err != nil {
 			tools.HandleInternalServerError(w, err)
 			return
 		}
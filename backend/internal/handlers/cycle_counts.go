@@ -0,0 +1,660 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/MananKakkar1/SalesBoard/backend/internal/models"
+	"github.com/MananKakkar1/SalesBoard/backend/internal/tools"
+	"github.com/go-chi/chi"
+)
+
+// Cycle counts: a session freezes products in one warehouse (see ensureStockNotFrozen),
+// collects counted quantities and, on approval, posts the differences as stock
+// adjustments with a reason instead of silently overwriting qty.
+const (
+	cycleCountOpen      = "open"
+	cycleCountApproved  = "approved"
+	cycleCountCancelled = "cancelled"
+)
+
+// ---------- Input DTOs ----------
+
+type createCycleCountIn struct {
+	WarehouseID int    `json:"warehouseId"`
+	ProductIDs  []int  `json:"productIds"` // optional; empty = everything stocked in the warehouse
+	Notes       string `json:"notes"`
+}
+
+type cycleCountEntry struct {
+	ProductID  int `json:"productId"`
+	CountedQty int `json:"countedQty"`
+}
+
+type cycleCountEntries struct {
+	Items []cycleCountEntry `json:"items"`
+}
+
+type cycleCountLineReason struct {
+	ProductID int    `json:"productId"`
+	Reason    string `json:"reason"`
+}
+
+type approveCycleCountIn struct {
+	Reason string                 `json:"reason"` // default reason for every line with a variance
+	Items  []cycleCountLineReason `json:"items"`  // optional per-line overrides
+}
+
+// ---------- Create (POST /api/cycle-counts) ----------
+func createCycleCountHandler(w http.ResponseWriter, r *http.Request) {
+	var in createCycleCountIn
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		tools.HandleBadRequest(w, errors.New("invalid request"))
+		return
+	}
+	if in.WarehouseID <= 0 {
+		tools.HandleBadRequest(w, errors.New("warehouseId is required"))
+		return
+	}
+
+	tx, err := tools.DB.Begin()
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	defer tx.Rollback()
+
+	var exists int
+	if err := tx.QueryRow(`SELECT COUNT(*) FROM warehouses WHERE id = ?`, in.WarehouseID).Scan(&exists); err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	if exists == 0 {
+		http.Error(w, "Warehouse not found", http.StatusNotFound)
+		return
+	}
+
+	productIDs := in.ProductIDs
+	if len(productIDs) == 0 {
+		rows, err := tx.Query(`SELECT product_id FROM warehouse_inventory WHERE warehouse_id = ? ORDER BY product_id`, in.WarehouseID)
+		if err != nil {
+			tools.HandleInternalServerError(w, err)
+			return
+		}
+		for rows.Next() {
+			var id int
+			if err := rows.Scan(&id); err != nil {
+				rows.Close()
+				tools.HandleInternalServerError(w, err)
+				return
+			}
+			productIDs = append(productIDs, id)
+		}
+		rows.Close()
+		if len(productIDs) == 0 {
+			tools.HandleBadRequest(w, errors.New("warehouse has no stocked products to count"))
+			return
+		}
+	}
+
+	createdAt := time.Now().UTC().Format(time.RFC3339)
+	res, err := tx.Exec(
+		`INSERT INTO cycle_counts (warehouse_id, status, notes, created_at) VALUES (?, ?, ?, ?)`,
+		in.WarehouseID, cycleCountOpen, in.Notes, createdAt,
+	)
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	ccID, _ := res.LastInsertId()
+
+	seen := map[int]bool{}
+	for _, pid := range productIDs {
+		if pid <= 0 || seen[pid] {
+			continue
+		}
+		seen[pid] = true
+
+		// One open session per product and warehouse
+		if err := ensureStockNotFrozen(tx, stockKey{in.WarehouseID, pid}); err != nil {
+			writeStockError(w, err)
+			return
+		}
+
+		var pexists int
+		if err := tx.QueryRow(`SELECT COUNT(*) FROM products WHERE id = ?`, pid).Scan(&pexists); err != nil {
+			tools.HandleInternalServerError(w, err)
+			return
+		}
+		if pexists == 0 {
+			tools.HandleBadRequest(w, fmt.Errorf("product %d does not exist", pid))
+			return
+		}
+
+		// Snapshot the system quantity at freeze time
+		if _, err := tx.Exec(`
+			INSERT INTO cycle_count_lines (cycle_count_id, product_id, system_qty)
+			VALUES (?, ?, COALESCE((SELECT qty FROM warehouse_inventory WHERE warehouse_id = ? AND product_id = ?), 0))`,
+			ccID, pid, in.WarehouseID, pid,
+		); err != nil {
+			tools.HandleInternalServerError(w, err)
+			return
+		}
+	}
+
+	cc, err := loadCycleCount(tx, int(ccID))
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(cc)
+}
+
+// ---------- List (GET /api/cycle-counts?warehouseId=&status=&page=&pageSize=) ----------
+func getCycleCountsHandler(w http.ResponseWriter, r *http.Request) {
+	page, pageSize, offset := parsePage(r)
+
+	var conds []string
+	var args []interface{}
+	if s := r.URL.Query().Get("warehouseId"); s != "" {
+		conds = append(conds, "warehouse_id = ?")
+		args = append(args, s)
+	}
+	if s := strings.TrimSpace(r.URL.Query().Get("status")); s != "" {
+		conds = append(conds, "status = ?")
+		args = append(args, s)
+	}
+	where := ""
+	if len(conds) > 0 {
+		where = "WHERE " + strings.Join(conds, " AND ")
+	}
+
+	var totalCount int
+	if err := tools.DB.QueryRow(`SELECT COUNT(*) FROM cycle_counts `+where, args...).Scan(&totalCount); err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+
+	rows, err := tools.DB.Query(`
+		SELECT id, warehouse_id, status, COALESCE(notes, ''), created_at, COALESCE(closed_at, '')
+		FROM cycle_counts `+where+`
+		ORDER BY id DESC
+		LIMIT ? OFFSET ?`, append(args, pageSize, offset)...)
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	defer rows.Close()
+
+	list := []models.CycleCount{}
+	for rows.Next() {
+		var cc models.CycleCount
+		if err := rows.Scan(&cc.ID, &cc.WarehouseID, &cc.Status, &cc.Notes, &cc.CreatedAt, &cc.ClosedAt); err != nil {
+			tools.HandleInternalServerError(w, err)
+			return
+		}
+		list = append(list, cc)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"data":       list,
+		"pagination": paginationMeta(page, pageSize, totalCount),
+	})
+}
+
+// ---------- Read one (GET /api/cycle-counts/{id}) ----------
+// Lines carry the variance (counted - system) once a count has been entered.
+func getCycleCountByIdHandler(w http.ResponseWriter, r *http.Request) {
+	ccID, err := atoiParam(chi.URLParam(r, "id"))
+	if err != nil {
+		tools.HandleBadRequest(w, err)
+		return
+	}
+
+	tx, err := tools.DB.Begin()
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	defer tx.Rollback()
+
+	cc, err := loadCycleCount(tx, ccID)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Cycle count not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(cc)
+}
+
+// ---------- Enter counts (PATCH /api/cycle-counts/{id}/counts) ----------
+// body: { "items": [ { "productId": 1, "countedQty": 118 } ] }  (re-counting overwrites)
+func recordCycleCountsHandler(w http.ResponseWriter, r *http.Request) {
+	ccID, err := atoiParam(chi.URLParam(r, "id"))
+	if err != nil {
+		tools.HandleBadRequest(w, err)
+		return
+	}
+	var in cycleCountEntries
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		tools.HandleBadRequest(w, errors.New("invalid request"))
+		return
+	}
+	if len(in.Items) == 0 {
+		tools.HandleBadRequest(w, errors.New("no counts to record"))
+		return
+	}
+
+	tx, err := tools.DB.Begin()
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	defer tx.Rollback()
+
+	if ok := requireOpenCycleCount(w, tx, ccID); !ok {
+		return
+	}
+
+	countedAt := time.Now().UTC().Format(time.RFC3339)
+	for _, it := range in.Items {
+		if it.ProductID <= 0 || it.CountedQty < 0 {
+			tools.HandleBadRequest(w, errors.New("each item requires productId > 0 and countedQty >= 0"))
+			return
+		}
+		res, err := tx.Exec(
+			`UPDATE cycle_count_lines SET counted_qty = ?, counted_at = ? WHERE cycle_count_id = ? AND product_id = ?`,
+			it.CountedQty, countedAt, ccID, it.ProductID,
+		)
+		if err != nil {
+			tools.HandleInternalServerError(w, err)
+			return
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			tools.HandleBadRequest(w, fmt.Errorf("product %d is not part of this cycle count", it.ProductID))
+			return
+		}
+	}
+
+	cc, err := loadCycleCount(tx, ccID)
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(cc)
+}
+
+// ---------- Approve (POST /api/cycle-counts/{id}/approve) ----------
+// body: { "reason": "shrinkage", "items": [ { "productId": 3, "reason": "damaged" } ] }
+// Every line must be counted. Lines with a variance become stock_adjustments rows and
+// warehouse_inventory is set to the counted quantity; the products are then unfrozen.
+func approveCycleCountHandler(w http.ResponseWriter, r *http.Request) {
+	ccID, err := atoiParam(chi.URLParam(r, "id"))
+	if err != nil {
+		tools.HandleBadRequest(w, err)
+		return
+	}
+	var in approveCycleCountIn
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		tools.HandleBadRequest(w, errors.New("invalid request"))
+		return
+	}
+	reasons := map[int]string{}
+	for _, it := range in.Items {
+		if s := strings.TrimSpace(it.Reason); s != "" {
+			reasons[it.ProductID] = s
+		}
+	}
+	defaultReason := strings.TrimSpace(in.Reason)
+
+	tx, err := tools.DB.Begin()
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	defer tx.Rollback()
+
+	if ok := requireOpenCycleCount(w, tx, ccID); !ok {
+		return
+	}
+
+	cc, err := loadCycleCount(tx, ccID)
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	for _, ln := range cc.Lines {
+		if ln.CountedQty == nil {
+			tools.HandleBadRequest(w, fmt.Errorf("product %d has not been counted yet", ln.ProductID))
+			return
+		}
+		if *ln.Variance != 0 && reasons[ln.ProductID] == "" && defaultReason == "" {
+			tools.HandleBadRequest(w, fmt.Errorf("a reason is required for the variance on product %d", ln.ProductID))
+			return
+		}
+	}
+
+	// Close the session first so the inventory helpers no longer see these products as frozen
+	closedAt := time.Now().UTC().Format(time.RFC3339)
+	if _, err := tx.Exec(
+		`UPDATE cycle_counts SET status = ?, closed_at = ? WHERE id = ?`, cycleCountApproved, closedAt, ccID,
+	); err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+
+	adjusted := 0
+	for _, ln := range cc.Lines {
+		if *ln.Variance == 0 {
+			continue
+		}
+		reason := reasons[ln.ProductID]
+		if reason == "" {
+			reason = defaultReason
+		}
+
+		k := stockKey{cc.WarehouseID, ln.ProductID}
+		var before int
+		if err := tx.QueryRow(
+			`SELECT COALESCE(SUM(qty), 0) FROM warehouse_inventory WHERE warehouse_id = ? AND product_id = ?`,
+			k.WarehouseID, k.ProductID,
+		).Scan(&before); err != nil {
+			tools.HandleInternalServerError(w, err)
+			return
+		}
+		if err := setWarehouseStock(tx, k.WarehouseID, k.ProductID, *ln.CountedQty); err != nil {
+			writeStockError(w, err)
+			return
+		}
+		if _, err := tx.Exec(`
+			INSERT INTO stock_adjustments
+				(warehouse_id, product_id, qty_before, qty_after, delta, reason, source, source_id, created_at)
+			VALUES (?, ?, ?, ?, ?, ?, 'cycle_count', ?, ?)`,
+			k.WarehouseID, k.ProductID, before, *ln.CountedQty, *ln.CountedQty-before, reason, ccID, closedAt,
+		); err != nil {
+			tools.HandleInternalServerError(w, err)
+			return
+		}
+		if _, err := tx.Exec(
+			`UPDATE cycle_count_lines SET reason = ? WHERE cycle_count_id = ? AND product_id = ?`,
+			reason, ccID, ln.ProductID,
+		); err != nil {
+			tools.HandleInternalServerError(w, err)
+			return
+		}
+		adjusted++
+	}
+
+	// Every counted product is unfrozen now, so stock that arrived during the count
+	// can go to the backorders fillBackorders skipped while it was frozen
+	counted := make([]stockKey, 0, len(cc.Lines))
+	for _, ln := range cc.Lines {
+		counted = append(counted, stockKey{cc.WarehouseID, ln.ProductID})
+	}
+	filled, err := fillBackorders(tx, counted...)
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	alerts, err := checkReorderPoints(tx, counted...)
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	cc, err = loadCycleCount(tx, ccID)
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+
+	tools.SSE.Broadcast(tools.Event{
+		Type: "cycle_count.approved",
+		Data: map[string]any{"cycleCountId": ccID, "warehouseId": cc.WarehouseID, "adjustments": adjusted},
+		Time: time.Now(),
+	})
+	if adjusted > 0 {
+		tools.SSE.Broadcast(tools.Event{
+			Type: "warehouse.inventory_updated",
+			Data: map[string]any{"warehouseId": cc.WarehouseID, "count": adjusted},
+			Time: time.Now(),
+		})
+	}
+	broadcastEvents(filled)
+	broadcastEvents(alerts)
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(cc)
+}
+
+// ---------- Cancel (POST /api/cycle-counts/{id}/cancel) ----------
+// Unfreezes the products without touching stock.
+func cancelCycleCountHandler(w http.ResponseWriter, r *http.Request) {
+	ccID, err := atoiParam(chi.URLParam(r, "id"))
+	if err != nil {
+		tools.HandleBadRequest(w, err)
+		return
+	}
+
+	tx, err := tools.DB.Begin()
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	defer tx.Rollback()
+
+	if ok := requireOpenCycleCount(w, tx, ccID); !ok {
+		return
+	}
+	if _, err := tx.Exec(
+		`UPDATE cycle_counts SET status = ?, closed_at = ? WHERE id = ?`,
+		cycleCountCancelled, time.Now().UTC().Format(time.RFC3339), ccID,
+	); err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ---------- Variance report (GET /api/reports/inventory-variance) ----------
+// ?warehouseId=&from=&to=&period=day|week|month
+// Aggregates approved cycle-count adjustments per warehouse and product, optionally bucketed by period.
+func getInventoryVarianceReportHandler(w http.ResponseWriter, r *http.Request) {
+	conds := []string{"sa.source = 'cycle_count'"}
+	var args []interface{}
+	if s := r.URL.Query().Get("warehouseId"); s != "" {
+		conds = append(conds, "sa.warehouse_id = ?")
+		args = append(args, s)
+	}
+	if s := strings.TrimSpace(r.URL.Query().Get("from")); s != "" {
+		conds = append(conds, "sa.created_at >= ?")
+		args = append(args, s)
+	}
+	if s := strings.TrimSpace(r.URL.Query().Get("to")); s != "" {
+		// inclusive end date: "2025-01-31" should cover that whole day
+		conds = append(conds, "substr(sa.created_at, 1, ?) <= ?")
+		args = append(args, len(s), s)
+	}
+
+	bucket := "''"
+	switch r.URL.Query().Get("period") {
+	case "":
+	case "day":
+		bucket = "strftime('%Y-%m-%d', sa.created_at)"
+	case "week":
+		bucket = "strftime('%Y-W%W', sa.created_at)"
+	case "month":
+		bucket = "strftime('%Y-%m', sa.created_at)"
+	default:
+		tools.HandleBadRequest(w, errors.New("period must be day, week or month"))
+		return
+	}
+
+	rows, err := tools.DB.Query(`
+		SELECT `+bucket+` AS period, sa.warehouse_id, w.name, sa.product_id, p.name,
+		       COUNT(*) AS adjustments,
+		       SUM(sa.delta) AS net_units,
+		       SUM(CASE WHEN sa.delta < 0 THEN -sa.delta ELSE 0 END) AS shrink_units,
+		       SUM(CASE WHEN sa.delta > 0 THEN sa.delta ELSE 0 END) AS over_units,
+		       SUM(sa.delta * p.price) AS net_value
+		FROM stock_adjustments sa
+		JOIN warehouses w ON w.id = sa.warehouse_id
+		JOIN products p   ON p.id = sa.product_id
+		WHERE `+strings.Join(conds, " AND ")+`
+		GROUP BY period, sa.warehouse_id, sa.product_id
+		ORDER BY period, sa.warehouse_id, net_value ASC`, args...)
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	defer rows.Close()
+
+	type varianceRow struct {
+		Period        string  `json:"period,omitempty"`
+		WarehouseID   int     `json:"warehouseId"`
+		WarehouseName string  `json:"warehouseName"`
+		ProductID     int     `json:"productId"`
+		ProductName   string  `json:"productName"`
+		Adjustments   int     `json:"adjustments"`
+		NetUnits      int     `json:"netUnits"`
+		ShrinkUnits   int     `json:"shrinkUnits"`
+		OverUnits     int     `json:"overUnits"`
+		NetValue      float64 `json:"netValue"`
+	}
+	type warehouseTotal struct {
+		WarehouseID   int     `json:"warehouseId"`
+		WarehouseName string  `json:"warehouseName"`
+		NetUnits      int     `json:"netUnits"`
+		ShrinkUnits   int     `json:"shrinkUnits"`
+		OverUnits     int     `json:"overUnits"`
+		NetValue      float64 `json:"netValue"`
+	}
+
+	out := []varianceRow{}
+	totals := map[int]*warehouseTotal{}
+	var order []int
+	for rows.Next() {
+		var v varianceRow
+		if err := rows.Scan(&v.Period, &v.WarehouseID, &v.WarehouseName, &v.ProductID, &v.ProductName,
+			&v.Adjustments, &v.NetUnits, &v.ShrinkUnits, &v.OverUnits, &v.NetValue); err != nil {
+			tools.HandleInternalServerError(w, err)
+			return
+		}
+		out = append(out, v)
+
+		t := totals[v.WarehouseID]
+		if t == nil {
+			t = &warehouseTotal{WarehouseID: v.WarehouseID, WarehouseName: v.WarehouseName}
+			totals[v.WarehouseID] = t
+			order = append(order, v.WarehouseID)
+		}
+		t.NetUnits += v.NetUnits
+		t.ShrinkUnits += v.ShrinkUnits
+		t.OverUnits += v.OverUnits
+		t.NetValue += v.NetValue
+	}
+	byWarehouse := make([]warehouseTotal, 0, len(order))
+	for _, id := range order {
+		byWarehouse = append(byWarehouse, *totals[id])
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"data":        out,
+		"byWarehouse": byWarehouse,
+	})
+}
+
+// ---------- helpers ----------
+
+// requireOpenCycleCount writes 404/409 and returns false unless the session exists and is open.
+func requireOpenCycleCount(w http.ResponseWriter, tx *sql.Tx, ccID int) bool {
+	var status string
+	err := tx.QueryRow(`SELECT status FROM cycle_counts WHERE id = ?`, ccID).Scan(&status)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Cycle count not found", http.StatusNotFound)
+		return false
+	}
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return false
+	}
+	if status != cycleCountOpen {
+		http.Error(w, fmt.Sprintf("cycle count is %s", status), http.StatusConflict)
+		return false
+	}
+	return true
+}
+
+// loadCycleCount reads a session with its lines and variances. Returns sql.ErrNoRows if missing.
+func loadCycleCount(tx *sql.Tx, id int) (models.CycleCount, error) {
+	var cc models.CycleCount
+	err := tx.QueryRow(`
+		SELECT id, warehouse_id, status, COALESCE(notes, ''), created_at, COALESCE(closed_at, '')
+		FROM cycle_counts WHERE id = ?`, id,
+	).Scan(&cc.ID, &cc.WarehouseID, &cc.Status, &cc.Notes, &cc.CreatedAt, &cc.ClosedAt)
+	if err != nil {
+		return cc, err
+	}
+
+	rows, err := tx.Query(`
+		SELECT l.product_id, p.name, p.price, l.system_qty, l.counted_qty, COALESCE(l.reason, '')
+		FROM cycle_count_lines l
+		JOIN products p ON p.id = l.product_id
+		WHERE l.cycle_count_id = ?
+		ORDER BY l.product_id`, id)
+	if err != nil {
+		return cc, err
+	}
+	defer rows.Close()
+
+	cc.Lines = []models.CycleCountLine{}
+	for rows.Next() {
+		var ln models.CycleCountLine
+		var price float64
+		var counted sql.NullInt64
+		if err := rows.Scan(&ln.ProductID, &ln.ProductName, &price, &ln.SystemQty, &counted, &ln.Reason); err != nil {
+			return cc, err
+		}
+		if counted.Valid {
+			c := int(counted.Int64)
+			v := c - ln.SystemQty
+			ln.CountedQty = &c
+			ln.Variance = &v
+			ln.VarianceValue = float64(v) * price
+		}
+		cc.Lines = append(cc.Lines, ln)
+	}
+	return cc, rows.Err()
+}
@@ -0,0 +1,460 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/MananKakkar1/SalesBoard/backend/internal/models"
+	"github.com/MananKakkar1/SalesBoard/backend/internal/tools"
+)
+
+// Shared write path for warehouse_inventory. Handlers that move stock in or out
+// should call these instead of writing their own SQL so the warehouse_inventory
+// triggers (productsCount) and any bookkeeping stay in one place.
+
+var errInvalidStockQty = errors.New("qty must be positive")
+
+// queryer is satisfied by both *sql.DB and *sql.Tx so read helpers can run inside or outside a transaction.
+type queryer interface {
+	Query(query string, args ...any) (*sql.Rows, error)
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+// stockKey identifies one warehouse_inventory row.
+type stockKey struct {
+	WarehouseID int
+	ProductID   int
+}
+
+// insufficientStockError is returned when a deduction can't be covered.
+// Missing is set when the warehouse has no inventory row for the product at all.
+type insufficientStockError struct {
+	stockKey
+	Available int
+	Requested int
+	Missing   bool
+}
+
+func (e *insufficientStockError) Error() string {
+	if e.Missing {
+		return fmt.Sprintf("no inventory for product %d in warehouse %d", e.ProductID, e.WarehouseID)
+	}
+	return fmt.Sprintf("insufficient stock for product %d in warehouse %d", e.ProductID, e.WarehouseID)
+}
+
+// lotConflictError is returned when a lot number is reused with a different expiry date.
+type lotConflictError struct {
+	LotNumber string
+	ExpiresAt string
+}
+
+func (e *lotConflictError) Error() string {
+	return fmt.Sprintf("lot %s is already recorded with expiry %s", e.LotNumber, e.ExpiresAt)
+}
+
+// stockFrozenError is returned when a product is locked by an open cycle count.
+type stockFrozenError struct {
+	stockKey
+	CycleCountID int
+}
+
+func (e *stockFrozenError) Error() string {
+	return fmt.Sprintf("product %d in warehouse %d is frozen by open cycle count %d",
+		e.ProductID, e.WarehouseID, e.CycleCountID)
+}
+
+// writeStockError maps errors from the helpers below onto HTTP responses:
+// shortages, unavailable serials and stock written against a kit are the caller's
+// fault (400); frozen stock, lot mismatches and duplicate serials are conflicts (409).
+func writeStockError(w http.ResponseWriter, err error) {
+	var short *insufficientStockError
+	var frozen *stockFrozenError
+	var lot *lotConflictError
+	var serial *serialUnavailableError
+	var dupSerial *serialConflictError
+	var kit *kitStockError
+	switch {
+	case errors.As(err, &short), errors.As(err, &serial), errors.As(err, &kit):
+		tools.HandleBadRequest(w, err)
+	case errors.As(err, &frozen), errors.As(err, &lot), errors.As(err, &dupSerial):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		tools.HandleInternalServerError(w, err)
+	}
+}
+
+// ensureStockNotFrozen fails while the product is part of an open cycle count in that warehouse.
+func ensureStockNotFrozen(tx *sql.Tx, k stockKey) error {
+	var ccID int
+	err := tx.QueryRow(`
+		SELECT cc.id
+		FROM cycle_count_lines l
+		JOIN cycle_counts cc ON cc.id = l.cycle_count_id
+		WHERE cc.status = 'open' AND cc.warehouse_id = ? AND l.product_id = ?
+		LIMIT 1`, k.WarehouseID, k.ProductID,
+	).Scan(&ccID)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return &stockFrozenError{stockKey: k, CycleCountID: ccID}
+}
+
+// addWarehouseStock increases on-hand qty for a product in a warehouse,
+// creating the inventory row when the product is new to that warehouse.
+func addWarehouseStock(tx *sql.Tx, warehouseID, productID, qty int) error {
+	if qty <= 0 {
+		return errInvalidStockQty
+	}
+	if err := ensureNotKit(tx, productID); err != nil {
+		return err
+	}
+	if err := ensureStockNotFrozen(tx, stockKey{warehouseID, productID}); err != nil {
+		return err
+	}
+	_, err := tx.Exec(`
+		INSERT INTO warehouse_inventory (warehouse_id, product_id, qty)
+		VALUES (?, ?, ?)
+		ON CONFLICT(warehouse_id, product_id)
+		DO UPDATE SET qty = qty + excluded.qty`,
+		warehouseID, productID, qty,
+	)
+	return err
+}
+
+// stockRemoval describes where removed units came from: bins for the picker and
+// lots for traceability. Both are independent breakdowns of the same qty.
+type stockRemoval struct {
+	Picks []models.PickLocation
+	Lots  []models.LotAllocation
+}
+
+// removeWarehouseStock decreases on-hand qty, failing with *insufficientStockError
+// when the warehouse can't cover the full quantity. Expired lots don't count as
+// available. Units are taken from bins first (by bin code), then from stock not
+// assigned to a bin; lots are consumed first-expiring-first-out, then unlotted stock.
+func removeWarehouseStock(tx *sql.Tx, warehouseID, productID, qty int) (stockRemoval, error) {
+	var out stockRemoval
+	if qty <= 0 {
+		return out, errInvalidStockQty
+	}
+	k := stockKey{warehouseID, productID}
+	if err := ensureStockNotFrozen(tx, k); err != nil {
+		return out, err
+	}
+
+	var avail int
+	err := tx.QueryRow(
+		`SELECT available_qty FROM warehouse_inventory_available WHERE warehouse_id = ? AND product_id = ?`,
+		warehouseID, productID,
+	).Scan(&avail)
+	if err == sql.ErrNoRows {
+		return out, &insufficientStockError{stockKey: k, Requested: qty, Missing: true}
+	}
+	if err != nil {
+		return out, err
+	}
+	if avail < qty {
+		return out, &insufficientStockError{stockKey: k, Available: avail, Requested: qty}
+	}
+
+	if _, err := tx.Exec(`
+		UPDATE warehouse_inventory
+		SET qty = qty - ?
+		WHERE warehouse_id = ? AND product_id = ?`,
+		qty, warehouseID, productID,
+	); err != nil {
+		return out, err
+	}
+
+	bins, err := binStock(tx, k, "ASC")
+	if err != nil {
+		return out, err
+	}
+	remaining := qty
+	for _, b := range bins {
+		if remaining == 0 {
+			break
+		}
+		take := min(b.Qty, remaining)
+		if err := adjustBinQty(tx, b.BinID, productID, -take); err != nil {
+			return out, err
+		}
+		out.Picks = append(out.Picks, models.PickLocation{BinID: b.BinID, BinCode: b.BinCode, Qty: take})
+		remaining -= take
+	}
+	if remaining > 0 {
+		out.Picks = append(out.Picks, models.PickLocation{Qty: remaining})
+	}
+
+	// Non-expired lots can always cover whatever unlotted stock can't,
+	// since avail already excludes the expired ones.
+	lots, err := sellableLots(tx, k)
+	if err != nil {
+		return out, err
+	}
+	remaining = qty
+	for _, l := range lots {
+		if remaining == 0 {
+			break
+		}
+		take := min(l.Qty, remaining)
+		if err := adjustLotQty(tx, l.LotID, -take); err != nil {
+			return out, err
+		}
+		l.Qty = take
+		out.Lots = append(out.Lots, l)
+		remaining -= take
+	}
+	return out, nil
+}
+
+// addWarehouseLotStock is addWarehouseStock for units that arrive under a lot number.
+// expiresAt is YYYY-MM-DD or "" for lots without an expiry date.
+func addWarehouseLotStock(tx *sql.Tx, warehouseID, productID int, lotNumber, expiresAt string, qty int) error {
+	if err := addWarehouseStock(tx, warehouseID, productID, qty); err != nil {
+		return err
+	}
+	return assignLot(tx, stockKey{warehouseID, productID}, lotNumber, expiresAt, qty)
+}
+
+// setWarehouseStock overwrites on-hand qty (absolute count) for a product in a warehouse.
+func setWarehouseStock(tx *sql.Tx, warehouseID, productID, qty int) error {
+	if qty < 0 {
+		return errors.New("qty cannot be negative")
+	}
+	if err := ensureNotKit(tx, productID); err != nil {
+		return err
+	}
+	if err := ensureStockNotFrozen(tx, stockKey{warehouseID, productID}); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`
+		INSERT INTO warehouse_inventory (warehouse_id, product_id, qty)
+		VALUES (?, ?, ?)
+		ON CONFLICT(warehouse_id, product_id)
+		DO UPDATE SET qty = excluded.qty`,
+		warehouseID, productID, qty,
+	); err != nil {
+		return err
+	}
+	k := stockKey{warehouseID, productID}
+	if err := trimBinsToOnHand(tx, k); err != nil {
+		return err
+	}
+	return trimLotsToOnHand(tx, k)
+}
+
+// ---------- bins ----------
+
+// binStock lists the bins holding a product in a warehouse, ordered by bin code.
+func binStock(tx *sql.Tx, k stockKey, order string) ([]models.PickLocation, error) {
+	if order != "DESC" {
+		order = "ASC"
+	}
+	rows, err := tx.Query(`
+		SELECT b.id, b.code, bi.qty
+		FROM bin_inventory bi
+		JOIN bins b ON b.id = bi.bin_id
+		WHERE b.warehouse_id = ? AND bi.product_id = ? AND bi.qty > 0
+		ORDER BY b.code `+order, k.WarehouseID, k.ProductID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []models.PickLocation
+	for rows.Next() {
+		var b models.PickLocation
+		if err := rows.Scan(&b.BinID, &b.BinCode, &b.Qty); err != nil {
+			return nil, err
+		}
+		out = append(out, b)
+	}
+	return out, rows.Err()
+}
+
+// adjustBinQty adds delta (may be negative) to a bin's qty for a product, dropping empty rows.
+func adjustBinQty(tx *sql.Tx, binID, productID, delta int) error {
+	if delta < 0 {
+		// existing row only; an INSERT with a negative qty would trip the CHECK before the upsert
+		if _, err := tx.Exec(
+			`UPDATE bin_inventory SET qty = qty + ? WHERE bin_id = ? AND product_id = ?`,
+			delta, binID, productID,
+		); err != nil {
+			return err
+		}
+	} else if _, err := tx.Exec(`
+		INSERT INTO bin_inventory (bin_id, product_id, qty)
+		VALUES (?, ?, ?)
+		ON CONFLICT(bin_id, product_id)
+		DO UPDATE SET qty = qty + excluded.qty`,
+		binID, productID, delta,
+	); err != nil {
+		return err
+	}
+	_, err := tx.Exec(`DELETE FROM bin_inventory WHERE bin_id = ? AND product_id = ? AND qty = 0`, binID, productID)
+	return err
+}
+
+// unbinnedQty is on-hand stock that is not assigned to any bin.
+func unbinnedQty(tx *sql.Tx, k stockKey) (int, error) {
+	var n int
+	err := tx.QueryRow(`
+		SELECT COALESCE((SELECT qty FROM warehouse_inventory WHERE warehouse_id = ? AND product_id = ?), 0)
+		     - COALESCE((SELECT SUM(bi.qty) FROM bin_inventory bi JOIN bins b ON b.id = bi.bin_id
+		                  WHERE b.warehouse_id = ? AND bi.product_id = ?), 0)`,
+		k.WarehouseID, k.ProductID, k.WarehouseID, k.ProductID,
+	).Scan(&n)
+	return n, err
+}
+
+// trimBinsToOnHand keeps SUM(bin qty) <= warehouse qty after an absolute set lowered
+// the on-hand figure, taking the excess out of the last bins (by code) first.
+func trimBinsToOnHand(tx *sql.Tx, k stockKey) error {
+	excess, err := unbinnedQty(tx, k)
+	if err != nil || excess >= 0 {
+		return err
+	}
+	excess = -excess
+	bins, err := binStock(tx, k, "DESC")
+	if err != nil {
+		return err
+	}
+	for _, b := range bins {
+		if excess == 0 {
+			break
+		}
+		take := min(b.Qty, excess)
+		if err := adjustBinQty(tx, b.BinID, k.ProductID, -take); err != nil {
+			return err
+		}
+		excess -= take
+	}
+	return nil
+}
+
+// ---------- lots ----------
+
+// sellableLots lists non-expired lots for a product in FEFO order; lots without an
+// expiry date go last.
+func sellableLots(tx *sql.Tx, k stockKey) ([]models.LotAllocation, error) {
+	rows, err := tx.Query(`
+		SELECT id, lot_number, expires_at, qty
+		FROM inventory_lots
+		WHERE warehouse_id = ? AND product_id = ? AND qty > 0
+		  AND (expires_at IS NULL OR expires_at >= date('now'))
+		ORDER BY expires_at IS NULL, expires_at, id`, k.WarehouseID, k.ProductID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []models.LotAllocation
+	for rows.Next() {
+		var l models.LotAllocation
+		if err := rows.Scan(&l.LotID, &l.LotNumber, &l.ExpiresAt, &l.Qty); err != nil {
+			return nil, err
+		}
+		out = append(out, l)
+	}
+	return out, rows.Err()
+}
+
+// assignLot records qty of already on-hand stock under a lot number.
+func assignLot(tx *sql.Tx, k stockKey, lotNumber, expiresAt string, qty int) error {
+	var existing sql.NullString
+	err := tx.QueryRow(`
+		SELECT expires_at FROM inventory_lots
+		WHERE warehouse_id = ? AND product_id = ? AND lot_number = ?`,
+		k.WarehouseID, k.ProductID, lotNumber,
+	).Scan(&existing)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+	if existing.Valid && expiresAt != "" && existing.String != expiresAt {
+		return &lotConflictError{LotNumber: lotNumber, ExpiresAt: existing.String}
+	}
+
+	var exp any
+	if expiresAt != "" {
+		exp = expiresAt
+	}
+	_, err = tx.Exec(`
+		INSERT INTO inventory_lots (warehouse_id, product_id, lot_number, expires_at, qty, received_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT(warehouse_id, product_id, lot_number)
+		DO UPDATE SET qty = qty + excluded.qty, expires_at = COALESCE(expires_at, excluded.expires_at)`,
+		k.WarehouseID, k.ProductID, lotNumber, exp, qty, time.Now().UTC().Format(time.RFC3339),
+	)
+	return err
+}
+
+// adjustLotQty takes units out of a lot (delta < 0). Empty lots are kept so order
+// lines can still point at them for tracing.
+func adjustLotQty(tx *sql.Tx, lotID, delta int) error {
+	_, err := tx.Exec(`UPDATE inventory_lots SET qty = qty + ? WHERE id = ?`, delta, lotID)
+	return err
+}
+
+// unlottedQty is on-hand stock that is not recorded under any lot.
+func unlottedQty(tx *sql.Tx, k stockKey) (int, error) {
+	var n int
+	err := tx.QueryRow(`
+		SELECT COALESCE((SELECT qty FROM warehouse_inventory WHERE warehouse_id = ? AND product_id = ?), 0)
+		     - COALESCE((SELECT SUM(qty) FROM inventory_lots WHERE warehouse_id = ? AND product_id = ?), 0)`,
+		k.WarehouseID, k.ProductID, k.WarehouseID, k.ProductID,
+	).Scan(&n)
+	return n, err
+}
+
+// trimLotsToOnHand keeps SUM(lot qty) <= warehouse qty after an absolute set. Expired
+// lots are assumed gone first, then the latest-expiring ones.
+func trimLotsToOnHand(tx *sql.Tx, k stockKey) error {
+	excess, err := unlottedQty(tx, k)
+	if err != nil || excess >= 0 {
+		return err
+	}
+	excess = -excess
+
+	rows, err := tx.Query(`
+		SELECT id, qty
+		FROM inventory_lots
+		WHERE warehouse_id = ? AND product_id = ? AND qty > 0
+		ORDER BY CASE WHEN expires_at < date('now') THEN 0 ELSE 1 END,
+		         COALESCE(expires_at, '9999-12-31') DESC, id DESC`, k.WarehouseID, k.ProductID)
+	if err != nil {
+		return err
+	}
+	type lotQty struct{ id, qty int }
+	var lots []lotQty
+	for rows.Next() {
+		var l lotQty
+		if err := rows.Scan(&l.id, &l.qty); err != nil {
+			rows.Close()
+			return err
+		}
+		lots = append(lots, l)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, l := range lots {
+		if excess == 0 {
+			break
+		}
+		take := min(l.qty, excess)
+		if err := adjustLotQty(tx, l.id, -take); err != nil {
+			return err
+		}
+		excess -= take
+	}
+	return nil
+}
@@ -0,0 +1,328 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/MananKakkar1/SalesBoard/backend/internal/models"
+	"github.com/MananKakkar1/SalesBoard/backend/internal/tools"
+	"github.com/go-chi/chi"
+)
+
+// kitStockError is returned when stock is written directly against a kit; kits only
+// hold stock through their components.
+type kitStockError struct {
+	ProductID int
+}
+
+func (e *kitStockError) Error() string {
+	return fmt.Sprintf("product %d is a kit; its stock comes from its components", e.ProductID)
+}
+
+// ---------- Input DTOs ----------
+
+type kitComponentIn struct {
+	ProductID int `json:"productId"`
+	Quantity  int `json:"quantity"`
+}
+
+type kitBody struct {
+	Components []kitComponentIn `json:"components"`
+}
+
+// ---------- helpers ----------
+
+// loadKitComponents returns a kit's components in product order; empty for ordinary products.
+func loadKitComponents(q queryer, kitID int) ([]models.KitComponent, error) {
+	rows, err := q.Query(`
+		SELECT kc.component_id, p.name, COALESCE(p.sku, ''), kc.quantity,
+		       COALESCE((SELECT SUM(available_qty) FROM warehouse_inventory_available a WHERE a.product_id = p.id), 0)
+		FROM kit_components kc
+		JOIN products p ON p.id = kc.component_id
+		WHERE kc.kit_id = ?
+		ORDER BY kc.component_id`, kitID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []models.KitComponent{}
+	for rows.Next() {
+		var c models.KitComponent
+		if err := rows.Scan(&c.ProductID, &c.ProductName, &c.SKU, &c.Quantity, &c.AvailableQty); err != nil {
+			return nil, err
+		}
+		out = append(out, c)
+	}
+	return out, rows.Err()
+}
+
+// ensureNotKit fails with *kitStockError when productID is a kit.
+func ensureNotKit(tx *sql.Tx, productID int) error {
+	var n int
+	if err := tx.QueryRow(`SELECT COUNT(*) FROM kit_components WHERE kit_id = ?`, productID).Scan(&n); err != nil {
+		return err
+	}
+	if n > 0 {
+		return &kitStockError{ProductID: productID}
+	}
+	return nil
+}
+
+// removeKitStock deducts qty kits' worth of every component from a warehouse. Picks and
+// lots are tagged with the component they came from. The first short component fails
+// the whole deduction with *insufficientStockError naming that component.
+func removeKitStock(tx *sql.Tx, warehouseID int, components []models.KitComponent, qty int) (stockRemoval, []models.OrderItemComponent, error) {
+	var out stockRemoval
+	used := make([]models.OrderItemComponent, 0, len(components))
+	for _, c := range components {
+		need := c.Quantity * qty
+		removed, err := removeWarehouseStock(tx, warehouseID, c.ProductID, need)
+		if err != nil {
+			return out, nil, err
+		}
+		for _, p := range removed.Picks {
+			p.ProductID = c.ProductID
+			out.Picks = append(out.Picks, p)
+		}
+		for _, l := range removed.Lots {
+			l.ProductID = c.ProductID
+			out.Lots = append(out.Lots, l)
+		}
+		used = append(used, models.OrderItemComponent{ProductID: c.ProductID, ProductName: c.ProductName, Qty: need})
+	}
+	return out, used, nil
+}
+
+// recordOrderItemComponents stores what a kit order line consumed.
+func recordOrderItemComponents(tx *sql.Tx, orderItemID int, used []models.OrderItemComponent) error {
+	for _, c := range used {
+		if _, err := tx.Exec(
+			`INSERT INTO order_item_components (order_item_id, product_id, product_name, qty) VALUES (?, ?, ?, ?)`,
+			orderItemID, c.ProductID, c.ProductName, c.Qty,
+		); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadOrderItemComponents returns the components recorded for a kit order line.
+func loadOrderItemComponents(q queryer, orderItemID int) ([]models.OrderItemComponent, error) {
+	rows, err := q.Query(`
+		SELECT product_id, product_name, qty
+		FROM order_item_components
+		WHERE order_item_id = ?
+		ORDER BY id`, orderItemID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []models.OrderItemComponent{}
+	for rows.Next() {
+		var c models.OrderItemComponent
+		if err := rows.Scan(&c.ProductID, &c.ProductName, &c.Qty); err != nil {
+			return nil, err
+		}
+		out = append(out, c)
+	}
+	return out, rows.Err()
+}
+
+// writeKit sends a kit's components and the number of complete kits per warehouse.
+func writeKit(w http.ResponseWriter, kitID int) {
+	components, err := loadKitComponents(tools.DB, kitID)
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+
+	type warehouseAvail struct {
+		WarehouseID   int    `json:"warehouseId"`
+		WarehouseName string `json:"warehouseName"`
+		AvailableQty  int    `json:"availableQty"`
+	}
+	warehouses := []warehouseAvail{}
+	total := 0
+	if len(components) > 0 {
+		rows, err := tools.DB.Query(`
+			SELECT w.id, w.name, a.available_qty
+			FROM product_inventory_available a
+			JOIN warehouses w ON w.id = a.warehouse_id
+			WHERE a.product_id = ?
+			ORDER BY w.id`, kitID)
+		if err != nil {
+			tools.HandleInternalServerError(w, err)
+			return
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var wa warehouseAvail
+			if err := rows.Scan(&wa.WarehouseID, &wa.WarehouseName, &wa.AvailableQty); err != nil {
+				tools.HandleInternalServerError(w, err)
+				return
+			}
+			total += wa.AvailableQty
+			warehouses = append(warehouses, wa)
+		}
+		if err := rows.Err(); err != nil {
+			tools.HandleInternalServerError(w, err)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"productId":    kitID,
+		"isKit":        len(components) > 0,
+		"components":   components,
+		"availableQty": total,
+		"warehouses":   warehouses,
+	})
+}
+
+// ---------- Read (GET /api/products/{id}/kit) ----------
+func getProductKitHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := atoiParam(chi.URLParam(r, "id"))
+	if err != nil {
+		tools.HandleBadRequest(w, err)
+		return
+	}
+	var n int
+	if err := tools.DB.QueryRow(`SELECT COUNT(*) FROM products WHERE id = ?`, id).Scan(&n); err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	if n == 0 {
+		http.Error(w, "Product not found", http.StatusNotFound)
+		return
+	}
+	writeKit(w, id)
+}
+
+// ---------- Define (PUT /api/products/{id}/kit) ----------
+// body: { "components": [ { "productId": 3, "quantity": 2 }, ... ] }
+// Replaces the component list; an empty list turns the kit back into an ordinary product.
+// Kits don't nest, can't be serial-tracked and can't hold stock of their own.
+func putProductKitHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := atoiParam(chi.URLParam(r, "id"))
+	if err != nil {
+		tools.HandleBadRequest(w, err)
+		return
+	}
+	var body kitBody
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		tools.HandleBadRequest(w, errors.New("invalid request"))
+		return
+	}
+
+	tx, err := tools.DB.Begin()
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	defer tx.Rollback()
+
+	var serialTracked bool
+	var variants, asComponent, onHand int
+	err = tx.QueryRow(`
+		SELECT p.serial_tracked,
+		       (SELECT COUNT(*) FROM products v WHERE v.parent_id = p.id),
+		       (SELECT COUNT(*) FROM kit_components kc WHERE kc.component_id = p.id),
+		       COALESCE((SELECT SUM(qty) FROM warehouse_inventory wi WHERE wi.product_id = p.id), 0)
+		FROM products p WHERE p.id = ?`, id,
+	).Scan(&serialTracked, &variants, &asComponent, &onHand)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Product not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+
+	if len(body.Components) > 0 {
+		switch {
+		case serialTracked:
+			tools.HandleBadRequest(w, errors.New("a serial-tracked product cannot be a kit"))
+			return
+		case variants > 0:
+			tools.HandleBadRequest(w, errors.New("a product with variants cannot be a kit; define the kit on a variant"))
+			return
+		case asComponent > 0:
+			tools.HandleBadRequest(w, errors.New("product is a component of another kit; kits cannot be nested"))
+			return
+		case onHand > 0:
+			http.Error(w, fmt.Sprintf("product has %d units on hand; clear its stock before making it a kit", onHand), http.StatusConflict)
+			return
+		}
+	}
+
+	seen := map[int]bool{}
+	for _, c := range body.Components {
+		if c.ProductID <= 0 || c.Quantity <= 0 {
+			tools.HandleBadRequest(w, errors.New("each component requires productId > 0 and quantity > 0"))
+			return
+		}
+		if c.ProductID == id {
+			tools.HandleBadRequest(w, errors.New("a kit cannot contain itself"))
+			return
+		}
+		if seen[c.ProductID] {
+			tools.HandleBadRequest(w, fmt.Errorf("product %d is listed twice", c.ProductID))
+			return
+		}
+		seen[c.ProductID] = true
+
+		var compTracked bool
+		var compVariants, compIsKit int
+		err := tx.QueryRow(`
+			SELECT p.serial_tracked,
+			       (SELECT COUNT(*) FROM products v WHERE v.parent_id = p.id),
+			       (SELECT COUNT(*) FROM kit_components kc WHERE kc.kit_id = p.id)
+			FROM products p WHERE p.id = ?`, c.ProductID,
+		).Scan(&compTracked, &compVariants, &compIsKit)
+		if err == sql.ErrNoRows {
+			tools.HandleBadRequest(w, fmt.Errorf("product %d does not exist", c.ProductID))
+			return
+		}
+		if err != nil {
+			tools.HandleInternalServerError(w, err)
+			return
+		}
+		switch {
+		case compIsKit > 0:
+			tools.HandleBadRequest(w, fmt.Errorf("product %d is itself a kit; kits cannot be nested", c.ProductID))
+			return
+		case compVariants > 0:
+			tools.HandleBadRequest(w, fmt.Errorf("product %d has variants; use a specific variant as the component", c.ProductID))
+			return
+		case compTracked:
+			tools.HandleBadRequest(w, fmt.Errorf("product %d is serial-tracked and cannot be a kit component", c.ProductID))
+			return
+		}
+	}
+
+	if _, err := tx.Exec(`DELETE FROM kit_components WHERE kit_id = ?`, id); err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	for _, c := range body.Components {
+		if _, err := tx.Exec(
+			`INSERT INTO kit_components (kit_id, component_id, quantity) VALUES (?, ?, ?)`,
+			id, c.ProductID, c.Quantity,
+		); err != nil {
+			tools.HandleInternalServerError(w, err)
+			return
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	writeKit(w, id)
+}
@@ -0,0 +1,455 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/MananKakkar1/SalesBoard/backend/internal/models"
+	"github.com/MananKakkar1/SalesBoard/backend/internal/tools"
+	"github.com/go-chi/chi"
+)
+
+const (
+	dateLayout          = "2006-01-02"
+	defaultExpiryDays   = 30
+	maxExpiryReportDays = 3650
+)
+
+// ---------- Input DTOs ----------
+
+// fromUnlotted assigns stock already on hand to a lot instead of receiving new units,
+// which is how existing inventory gets its lot numbers.
+type lotReceiveBody struct {
+	ProductID    int    `json:"productId"`
+	LotNumber    string `json:"lotNumber"`
+	ExpiresAt    string `json:"expiresAt"`
+	Qty          int    `json:"qty"`
+	FromUnlotted bool   `json:"fromUnlotted"`
+}
+
+type lotWriteOffBody struct {
+	Reason string `json:"reason"`
+}
+
+// normalizeLot trims the lot number and reduces expiresAt to YYYY-MM-DD.
+// An expiry date without a lot number is rejected.
+func normalizeLot(lotNumber, expiresAt string) (string, string, error) {
+	lotNumber = strings.TrimSpace(lotNumber)
+	expiresAt = strings.TrimSpace(expiresAt)
+	if expiresAt == "" {
+		return lotNumber, "", nil
+	}
+	if lotNumber == "" {
+		return "", "", errors.New("expiresAt requires a lotNumber")
+	}
+	if t, err := time.Parse(dateLayout, expiresAt); err == nil {
+		return lotNumber, t.Format(dateLayout), nil
+	}
+	t, err := time.Parse(time.RFC3339, expiresAt)
+	if err != nil {
+		return "", "", errors.New("expiresAt must be YYYY-MM-DD")
+	}
+	return lotNumber, t.UTC().Format(dateLayout), nil
+}
+
+// ---------- Receive / assign (POST /api/warehouses/{id}/lots) ----------
+// body: { "productId": 1, "lotNumber": "L-42", "expiresAt": "2026-03-31", "qty": 50, "fromUnlotted": false }
+func createLotHandler(w http.ResponseWriter, r *http.Request) {
+	warehouseID, err := atoiParam(chi.URLParam(r, "id"))
+	if err != nil {
+		tools.HandleBadRequest(w, err)
+		return
+	}
+	var body lotReceiveBody
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		tools.HandleBadRequest(w, errors.New("invalid request"))
+		return
+	}
+	lotNumber, expiresAt, err := normalizeLot(body.LotNumber, body.ExpiresAt)
+	if err != nil {
+		tools.HandleBadRequest(w, err)
+		return
+	}
+	if body.ProductID <= 0 || lotNumber == "" || body.Qty <= 0 {
+		tools.HandleBadRequest(w, errors.New("productId, lotNumber and positive qty are required"))
+		return
+	}
+
+	tx, err := tools.DB.Begin()
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	defer tx.Rollback()
+
+	var exists int
+	if err := tx.QueryRow(`SELECT COUNT(*) FROM warehouses WHERE id = ?`, warehouseID).Scan(&exists); err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	if exists == 0 {
+		http.Error(w, "Warehouse not found", http.StatusNotFound)
+		return
+	}
+	if err := tx.QueryRow(`SELECT COUNT(*) FROM products WHERE id = ?`, body.ProductID).Scan(&exists); err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	if exists == 0 {
+		tools.HandleBadRequest(w, fmt.Errorf("product %d does not exist", body.ProductID))
+		return
+	}
+
+	k := stockKey{warehouseID, body.ProductID}
+	if body.FromUnlotted {
+		if err := ensureStockNotFrozen(tx, k); err != nil {
+			writeStockError(w, err)
+			return
+		}
+		free, err := unlottedQty(tx, k)
+		if err != nil {
+			tools.HandleInternalServerError(w, err)
+			return
+		}
+		if free < body.Qty {
+			tools.HandleBadRequest(w, fmt.Errorf("only %d unlotted units of product %d in warehouse %d", free, body.ProductID, warehouseID))
+			return
+		}
+		if err := assignLot(tx, k, lotNumber, expiresAt, body.Qty); err != nil {
+			writeStockError(w, err)
+			return
+		}
+	} else if err := addWarehouseLotStock(tx, warehouseID, body.ProductID, lotNumber, expiresAt, body.Qty); err != nil {
+		writeStockError(w, err)
+		return
+	}
+
+	// New stock goes to open backorders first; assigning a lot to unlotted stock adds none
+	var filled []tools.Event
+	if !body.FromUnlotted {
+		if filled, err = fillBackorders(tx, k); err != nil {
+			tools.HandleInternalServerError(w, err)
+			return
+		}
+	}
+	alerts, err := checkReorderPoints(tx, k)
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+
+	var lot models.InventoryLot
+	if err := scanLot(tx.QueryRow(lotSelect+`
+		WHERE l.warehouse_id = ? AND l.product_id = ? AND l.lot_number = ?`,
+		warehouseID, body.ProductID, lotNumber), &lot); err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+
+	if !body.FromUnlotted {
+		tools.SSE.Broadcast(tools.Event{
+			Type: "warehouse.inventory_updated",
+			Data: map[string]any{"warehouseId": warehouseID, "count": 1},
+			Time: time.Now(),
+		})
+	}
+	broadcastEvents(filled)
+	broadcastEvents(alerts)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(lot)
+}
+
+const lotSelect = `
+	SELECT l.id, l.warehouse_id, l.product_id, p.name, l.lot_number, l.expires_at, l.qty,
+	       COALESCE(l.expires_at < date('now'), 0), l.received_at
+	FROM inventory_lots l
+	JOIN products p ON p.id = l.product_id`
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanLot(row rowScanner, l *models.InventoryLot) error {
+	return row.Scan(&l.ID, &l.WarehouseID, &l.ProductID, &l.ProductName, &l.LotNumber,
+		&l.ExpiresAt, &l.Qty, &l.Expired, &l.ReceivedAt)
+}
+
+// ---------- List (GET /api/warehouses/{id}/lots?productId=&includeExpired=true) ----------
+// Lots come back in FEFO order, i.e. the order orders will consume them.
+func getWarehouseLotsHandler(w http.ResponseWriter, r *http.Request) {
+	warehouseID, err := atoiParam(chi.URLParam(r, "id"))
+	if err != nil {
+		tools.HandleBadRequest(w, err)
+		return
+	}
+
+	conds := []string{"l.warehouse_id = ?", "l.qty > 0"}
+	args := []interface{}{warehouseID}
+	if s := r.URL.Query().Get("productId"); s != "" {
+		conds = append(conds, "l.product_id = ?")
+		args = append(args, s)
+	}
+	if r.URL.Query().Get("includeExpired") != "true" {
+		conds = append(conds, "(l.expires_at IS NULL OR l.expires_at >= date('now'))")
+	}
+
+	rows, err := tools.DB.Query(lotSelect+`
+		WHERE `+strings.Join(conds, " AND ")+`
+		ORDER BY l.product_id, l.expires_at IS NULL, l.expires_at, l.id`, args...)
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	defer rows.Close()
+
+	out := []models.InventoryLot{}
+	for rows.Next() {
+		var l models.InventoryLot
+		if err := scanLot(rows, &l); err != nil {
+			tools.HandleInternalServerError(w, err)
+			return
+		}
+		out = append(out, l)
+	}
+	if err := rows.Err(); err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(out)
+}
+
+// ---------- Write-off (POST /api/lots/{lotId}/write-off) ----------
+// Removes whatever is left of a lot (typically expired) from on-hand stock and
+// records it as a stock adjustment.
+func writeOffLotHandler(w http.ResponseWriter, r *http.Request) {
+	lotID, err := atoiParam(chi.URLParam(r, "lotId"))
+	if err != nil {
+		tools.HandleBadRequest(w, err)
+		return
+	}
+	var body lotWriteOffBody
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			tools.HandleBadRequest(w, errors.New("invalid request"))
+			return
+		}
+	}
+
+	tx, err := tools.DB.Begin()
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	defer tx.Rollback()
+
+	var lot models.InventoryLot
+	err = scanLot(tx.QueryRow(lotSelect+` WHERE l.id = ?`, lotID), &lot)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Lot not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+
+	k := stockKey{lot.WarehouseID, lot.ProductID}
+	if err := ensureStockNotFrozen(tx, k); err != nil {
+		writeStockError(w, err)
+		return
+	}
+	var before int
+	if err := tx.QueryRow(
+		`SELECT qty FROM warehouse_inventory WHERE warehouse_id = ? AND product_id = ?`,
+		k.WarehouseID, k.ProductID,
+	).Scan(&before); err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	if _, err := tx.Exec(
+		`UPDATE warehouse_inventory SET qty = qty - ? WHERE warehouse_id = ? AND product_id = ?`,
+		lot.Qty, k.WarehouseID, k.ProductID,
+	); err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	if err := adjustLotQty(tx, lot.ID, -lot.Qty); err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	if err := trimBinsToOnHand(tx, k); err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+
+	reason := strings.TrimSpace(body.Reason)
+	if reason == "" {
+		reason = "lot " + lot.LotNumber + " written off"
+		if lot.Expired {
+			reason = "lot " + lot.LotNumber + " expired"
+		}
+	}
+	if _, err := tx.Exec(`
+		INSERT INTO stock_adjustments
+			(warehouse_id, product_id, qty_before, qty_after, delta, reason, source, source_id, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, 'lot_write_off', ?, ?)`,
+		k.WarehouseID, k.ProductID, before, before-lot.Qty, -lot.Qty, reason, lot.ID,
+		time.Now().UTC().Format(time.RFC3339),
+	); err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+
+	alerts, err := checkReorderPoints(tx, k)
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+
+	tools.SSE.Broadcast(tools.Event{
+		Type: "warehouse.inventory_updated",
+		Data: map[string]any{"warehouseId": lot.WarehouseID, "count": 1},
+		Time: time.Now(),
+	})
+	broadcastEvents(alerts)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ---------- Report (GET /api/reports/expiring-lots?days=30&warehouseId=) ----------
+// Lots with stock left that expire within the next N days. Lots that have already
+// expired are included (expired=true, negative daysLeft) since they still need handling.
+func getExpiringLotsReportHandler(w http.ResponseWriter, r *http.Request) {
+	days := defaultExpiryDays
+	if s := r.URL.Query().Get("days"); s != "" {
+		d, err := strconv.Atoi(s)
+		if err != nil || d < 0 || d > maxExpiryReportDays {
+			tools.HandleBadRequest(w, fmt.Errorf("days must be between 0 and %d", maxExpiryReportDays))
+			return
+		}
+		days = d
+	}
+
+	conds := []string{"l.qty > 0", "l.expires_at IS NOT NULL", "l.expires_at <= date('now', ?)"}
+	args := []interface{}{fmt.Sprintf("+%d days", days)}
+	if s := r.URL.Query().Get("warehouseId"); s != "" {
+		conds = append(conds, "l.warehouse_id = ?")
+		args = append(args, s)
+	}
+
+	rows, err := tools.DB.Query(`
+		SELECT l.id, l.warehouse_id, w.name, l.product_id, p.name, l.lot_number, l.expires_at, l.qty,
+		       CAST(julianday(l.expires_at) - julianday(date('now')) AS INTEGER) AS days_left,
+		       l.qty * p.price AS value
+		FROM inventory_lots l
+		JOIN warehouses w ON w.id = l.warehouse_id
+		JOIN products p   ON p.id = l.product_id
+		WHERE `+strings.Join(conds, " AND ")+`
+		ORDER BY l.expires_at, l.warehouse_id, l.product_id`, args...)
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	defer rows.Close()
+
+	type expiringRow struct {
+		LotID         int     `json:"lotId"`
+		WarehouseID   int     `json:"warehouseId"`
+		WarehouseName string  `json:"warehouseName"`
+		ProductID     int     `json:"productId"`
+		ProductName   string  `json:"productName"`
+		LotNumber     string  `json:"lotNumber"`
+		ExpiresAt     string  `json:"expiresAt"`
+		Qty           int     `json:"qty"`
+		DaysLeft      int     `json:"daysLeft"`
+		Expired       bool    `json:"expired"`
+		Value         float64 `json:"value"`
+	}
+	out := []expiringRow{}
+	var totalQty int
+	var totalValue float64
+	for rows.Next() {
+		var e expiringRow
+		if err := rows.Scan(&e.LotID, &e.WarehouseID, &e.WarehouseName, &e.ProductID, &e.ProductName,
+			&e.LotNumber, &e.ExpiresAt, &e.Qty, &e.DaysLeft, &e.Value); err != nil {
+			tools.HandleInternalServerError(w, err)
+			return
+		}
+		e.Expired = e.DaysLeft < 0
+		totalQty += e.Qty
+		totalValue += e.Value
+		out = append(out, e)
+	}
+	if err := rows.Err(); err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"days":       days,
+		"data":       out,
+		"totalQty":   totalQty,
+		"totalValue": totalValue,
+	})
+}
+
+// ---------- order line lots ----------
+
+// recordLotAllocations stores which lots an order line consumed.
+func recordLotAllocations(tx *sql.Tx, orderItemID int, lots []models.LotAllocation) error {
+	for _, l := range lots {
+		var productID interface{}
+		if l.ProductID > 0 {
+			productID = l.ProductID
+		}
+		if _, err := tx.Exec(
+			`INSERT INTO order_item_lots (order_item_id, lot_id, lot_number, expires_at, qty, product_id) VALUES (?, ?, ?, ?, ?, ?)`,
+			orderItemID, l.LotID, l.LotNumber, l.ExpiresAt, l.Qty, productID,
+		); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadLotAllocations returns the lots recorded for an order line.
+func loadLotAllocations(q queryer, orderItemID int) ([]models.LotAllocation, error) {
+	rows, err := q.Query(`
+		SELECT COALESCE(lot_id, 0), lot_number, expires_at, qty, COALESCE(product_id, 0)
+		FROM order_item_lots
+		WHERE order_item_id = ?
+		ORDER BY id`, orderItemID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []models.LotAllocation{}
+	for rows.Next() {
+		var l models.LotAllocation
+		if err := rows.Scan(&l.LotID, &l.LotNumber, &l.ExpiresAt, &l.Qty, &l.ProductID); err != nil {
+			return nil, err
+		}
+		out = append(out, l)
+	}
+	return out, rows.Err()
+}
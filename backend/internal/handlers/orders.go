@@ -44,6 +44,8 @@ type orderItemIn struct {
 	Quantity    int     `json:"quantity"`
 	SalePrice   float64 `json:"salePrice"`
 	WarehouseID int     `json:"warehouseId"` // required: which warehouse fulfills this line
+	// optional for serial-tracked products: one per unit, or assigned later at fulfilment
+	Serials []string `json:"serials"`
 }
 
 type createOrderIn struct {
@@ -53,6 +55,57 @@ type createOrderIn struct {
 	TotalPrice   float64       `json:"totalPrice"` // accepted but recomputed server-side
 	CreatedAt    string        `json:"createdAt"`  // optional; fallback to now
 	ProductItems []orderItemIn `json:"productItems"`
+	// optional; defaults to the customer's default shipping address
+	ShippingAddressID *int `json:"shippingAddressId"`
+	// optional; accept an order over the customer's credit limit, flagged for review
+	AllowOverCredit bool `json:"allowOverCredit"`
+	// optional; take what is in stock of a short line and backorder the rest.
+	// Kits and lines with serials still need their full quantity; a short kit
+	// fails the order with a 400 naming the kit.
+	AllowBackorder bool `json:"allowBackorder"`
+}
+
+// createdOrder is what createOrderTx wrote: the response body and what the events sent after commit need.
+type createdOrder struct {
+	OrderID           int
+	CustomerID        int
+	UserID            int
+	CreatedAt         string
+	TotalPrice        float64
+	ShippingCost      float64
+	ShippingAddressID *int
+	DueDate           string
+	CreditFlagged     bool
+	Backorders        []models.Backorder
+
+	credit models.CustomerCredit
+	alerts []tools.Event
+}
+
+// creditLimitError is an order blocked by the customer's credit limit (409).
+type creditLimitError struct {
+	credit models.CustomerCredit
+	total  float64
+}
+
+func (e *creditLimitError) Error() string {
+	return fmt.Sprintf("order total %.2f is over customer %d's credit limit", e.total, e.credit.CustomerID)
+}
+
+// writeOrderError sends a createOrderTx failure: credit limit blocks as 409,
+// field errors through writeFieldError and everything else through writeStockError.
+func writeOrderError(w http.ResponseWriter, err error) {
+	var credit *creditLimitError
+	var invalid *invalidFieldError
+	var conflict *conflictError
+	switch {
+	case errors.As(err, &credit):
+		writeCreditLimitError(w, credit.credit, credit.total)
+	case errors.As(err, &invalid), errors.As(err, &conflict):
+		writeFieldError(w, err)
+	default:
+		writeStockError(w, err)
+	}
 }
 
 // ---------- Create (POST /api/orders) ----------
@@ -68,14 +121,39 @@ func createOrderHandler(w http.ResponseWriter, r *http.Request) {
 		tools.HandleBadRequest(w, errors.New("invalid request"))
 		return
 	}
-	if in.OrderID == 0 || in.CustomerID == 0 || in.UserID == 0 || len(in.ProductItems) == 0 {
-		tools.HandleBadRequest(w, errors.New("orderId, customerId, userId, productItems are required"))
+
+	tx, err := tools.DB.Begin()
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
 		return
 	}
+	defer tx.Rollback()
+
+	o, err := createOrderTx(tx, in)
+	if err != nil {
+		writeOrderError(w, err)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	o.broadcast()
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(o.response())
+}
+
+// createOrderTx validates the order, deducts its stock and writes it inside tx.
+// Errors are typed for writeOrderError; anything untyped is a 500.
+func createOrderTx(tx *sql.Tx, in createOrderIn) (*createdOrder, error) {
+	if in.OrderID == 0 || in.CustomerID == 0 || in.UserID == 0 || len(in.ProductItems) == 0 {
+		return nil, &invalidFieldError{"orderId, customerId, userId, productItems are required"}
+	}
 	for _, it := range in.ProductItems {
 		if it.ProductID <= 0 || it.Quantity <= 0 || it.WarehouseID <= 0 {
-			tools.HandleBadRequest(w, errors.New("each item requires productId > 0, quantity > 0, warehouseId > 0"))
-			return
+			return nil, &invalidFieldError{"each item requires productId > 0, quantity > 0, warehouseId > 0"}
 		}
 	}
 
@@ -84,101 +162,220 @@ func createOrderHandler(w http.ResponseWriter, r *http.Request) {
 		createdAt = time.Now().UTC().Format(time.RFC3339)
 	}
 
-	tx, err := tools.DB.Begin()
+	// Archived customers, products and warehouses can't take new orders
+	if err := ensureActive(tx, customerArchive, in.CustomerID); err != nil {
+		return nil, err
+	}
+	for _, it := range in.ProductItems {
+		if err := ensureActive(tx, productArchive, it.ProductID); err != nil {
+			return nil, err
+		}
+		if err := ensureActive(tx, warehouseArchive, it.WarehouseID); err != nil {
+			return nil, err
+		}
+	}
+
+	shipTo, err := resolveShippingAddress(tx, in.CustomerID, in.ShippingAddressID)
 	if err != nil {
-		tools.HandleInternalServerError(w, err)
-		return
+		return nil, err
+	}
+	var shipToID *int
+	var shipToText *string
+	if shipTo != nil {
+		text := formatAddress(*shipTo)
+		shipToID, shipToText = &shipTo.ID, &text
 	}
-	defer tx.Rollback()
 
 	// Insert order shell
 	if _, err := tx.Exec(
-		`INSERT INTO orders (orderId, customerId, userId, totalPrice, createdAt)
-		 VALUES (?, ?, ?, ?, ?)`,
-		in.OrderID, in.CustomerID, in.UserID, 0, createdAt,
+		`INSERT INTO orders (orderId, customerId, userId, totalPrice, createdAt, shipping_address_id, shipping_address)
+		 VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		in.OrderID, in.CustomerID, in.UserID, 0, createdAt, shipToID, shipToText,
 	); err != nil {
-		tools.HandleInternalServerError(w, err)
-		return
+		return nil, err
 	}
 
 	var computedTotal float64
+	weightByWarehouse := map[int]float64{}
+	// Stock only goes down here, so the touched rows can only raise reorder alerts
+	touched := make([]stockKey, 0, len(in.ProductItems))
 
 	for _, it := range in.ProductItems {
-		// Check availability in selected warehouse
-		var avail int
-		err := tx.QueryRow(
-			`SELECT qty FROM warehouse_inventory WHERE warehouse_id = ? AND product_id = ?`,
-			it.WarehouseID, it.ProductID,
-		).Scan(&avail)
-		if err == sql.ErrNoRows {
-			tools.HandleBadRequest(w, fmt.Errorf("no inventory for product %d in warehouse %d", it.ProductID, it.WarehouseID))
-			return
+		serials, err := normalizeSerials(it.Serials)
+		if err != nil {
+			return nil, &invalidFieldError{err.Error()}
+		}
+		tracked, err := isSerialTracked(tx, it.ProductID)
+		if err != nil {
+			return nil, err
+		}
+		if err := checkSerialCount(tracked, false, it.ProductID, it.Quantity, serials); err != nil {
+			return nil, &invalidFieldError{err.Error()}
 		}
+		components, err := loadKitComponents(tx, it.ProductID)
 		if err != nil {
-			tools.HandleInternalServerError(w, err)
-			return
+			return nil, err
 		}
-		if avail < it.Quantity {
-			tools.HandleBadRequest(w, fmt.Errorf("insufficient stock for product %d in warehouse %d", it.ProductID, it.WarehouseID))
-			return
+		if len(components) > 0 && len(serials) > 0 {
+			return nil, &invalidFieldError{fmt.Sprintf("product %d is a kit; serials cannot be assigned to it", it.ProductID)}
 		}
 
-		// Deduct stock
-		if _, err := tx.Exec(
-			`UPDATE warehouse_inventory
-			    SET qty = qty - ?
-			  WHERE warehouse_id = ? AND product_id = ?`,
-			it.Quantity, it.WarehouseID, it.ProductID,
-		); err != nil {
-			tools.HandleInternalServerError(w, err)
-			return
+		// Check availability in selected warehouse and deduct; a kit deducts its components
+		var removed stockRemoval
+		var used []models.OrderItemComponent
+		var backordered int
+		if len(components) > 0 {
+			removed, used, err = removeKitStock(tx, it.WarehouseID, components, it.Quantity)
+			for _, c := range components {
+				touched = append(touched, stockKey{it.WarehouseID, c.ProductID})
+			}
+			var short *insufficientStockError
+			if in.AllowBackorder && errors.As(err, &short) {
+				return nil, &invalidFieldError{fmt.Sprintf("product %d is a kit and kits can't be backordered: %v", it.ProductID, err)}
+			}
+		} else if in.AllowBackorder && len(serials) == 0 {
+			removed, backordered, err = takeAvailableStock(tx, stockKey{it.WarehouseID, it.ProductID}, it.Quantity)
+			touched = append(touched, stockKey{it.WarehouseID, it.ProductID})
+		} else {
+			removed, err = removeWarehouseStock(tx, it.WarehouseID, it.ProductID, it.Quantity)
+			touched = append(touched, stockKey{it.WarehouseID, it.ProductID})
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		// Snapshot the unit cost so later cost changes don't rewrite past margins
+		unitCost, err := productUnitCost(tx, it.ProductID)
+		if err != nil {
+			return nil, err
 		}
 
 		// Insert order item with warehouse_id
-		if _, err := tx.Exec(
-			`INSERT INTO order_items (orderId, productId, quantity, salePrice, warehouse_id)
-			 VALUES (?, ?, ?, ?, ?)`,
-			in.OrderID, it.ProductID, it.Quantity, it.SalePrice, it.WarehouseID,
-		); err != nil {
-			tools.HandleInternalServerError(w, err)
-			return
+		res, err := tx.Exec(
+			`INSERT INTO order_items (orderId, productId, quantity, salePrice, warehouse_id, unit_cost)
+			 VALUES (?, ?, ?, ?, ?, ?)`,
+			in.OrderID, it.ProductID, it.Quantity, it.SalePrice, it.WarehouseID, unitCost,
+		)
+		if err != nil {
+			return nil, err
+		}
+		itemID, _ := res.LastInsertId()
+
+		// Remember which bins and lots the units come from, for the pick list and lot tracing
+		if err := recordPicks(tx, int(itemID), removed.Picks); err != nil {
+			return nil, err
+		}
+		if err := recordLotAllocations(tx, int(itemID), removed.Lots); err != nil {
+			return nil, err
+		}
+		if err := recordOrderItemComponents(tx, int(itemID), used); err != nil {
+			return nil, err
+		}
+		if err := sellSerials(tx, stockKey{it.WarehouseID, it.ProductID}, int(itemID), serials); err != nil {
+			return nil, err
+		}
+		if backordered > 0 {
+			if err := insertBackorder(tx, in.OrderID, int(itemID), stockKey{it.WarehouseID, it.ProductID}, backordered); err != nil {
+				return nil, err
+			}
+		}
+
+		var weight float64
+		if err := tx.QueryRow(`SELECT COALESCE(weight, 1) FROM products WHERE id = ?`, it.ProductID).Scan(&weight); err != nil {
+			return nil, err
 		}
+		weightByWarehouse[it.WarehouseID] += weight * float64(it.Quantity)
 
 		computedTotal += float64(it.Quantity) * it.SalePrice
 	}
 
-	// Update total
+	shippingCost, err := orderShippingCost(tx, shipTo, weightByWarehouse, defaultShipParams)
+	if err != nil {
+		return nil, err
+	}
+
+	// Customers over their credit limit are blocked, or flagged when their credit
+	// action is "flag" or the caller allows it
+	credit, overLimit, err := checkCreditLimit(tx, in.CustomerID, computedTotal)
+	if err != nil {
+		return nil, err
+	}
+	if overLimit && credit.CreditAction == "block" && !in.AllowOverCredit {
+		return nil, &creditLimitError{credit, computedTotal}
+	}
+	due := dueDate(createdAt, credit.PaymentTermsDays)
+
+	// Update totals
 	if _, err := tx.Exec(
-		`UPDATE orders SET totalPrice = ? WHERE orderId = ?`,
-		computedTotal, in.OrderID,
+		`UPDATE orders SET totalPrice = ?, shipping_cost = ?, due_date = ?, credit_flagged = ? WHERE orderId = ?`,
+		computedTotal, shippingCost, due, overLimit, in.OrderID,
 	); err != nil {
-		tools.HandleInternalServerError(w, err)
-		return
+		return nil, err
 	}
 
-	if err := tx.Commit(); err != nil {
-		tools.HandleInternalServerError(w, err)
-		return
+	alerts, err := checkReorderPoints(tx, touched...)
+	if err != nil {
+		return nil, err
 	}
+	backorders, err := queryBackorders(tx, `order_id = ?`, `id`, in.OrderID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &createdOrder{
+		OrderID:           in.OrderID,
+		CustomerID:        in.CustomerID,
+		UserID:            in.UserID,
+		CreatedAt:         createdAt,
+		TotalPrice:        computedTotal,
+		ShippingCost:      shippingCost,
+		ShippingAddressID: shipToID,
+		DueDate:           due,
+		CreditFlagged:     overLimit,
+		Backorders:        backorders,
+		credit:            credit,
+		alerts:            alerts,
+	}, nil
+}
 
-	// SSE notify
+// broadcast sends the SSE events for a committed order.
+func (o *createdOrder) broadcast() {
 	tools.SSE.Broadcast(tools.Event{
 		Type: "order.created",
 		Data: map[string]any{
-			"orderId":    in.OrderID,
-			"customerId": in.CustomerID,
-			"userId":     in.UserID,
-			"totalPrice": computedTotal,
-			"createdAt":  createdAt,
+			"orderId":    o.OrderID,
+			"customerId": o.CustomerID,
+			"userId":     o.UserID,
+			"totalPrice": o.TotalPrice,
+			"createdAt":  o.CreatedAt,
 		},
 		Time: time.Now(),
 	})
+	broadcastEvents(o.alerts)
+	if o.CreditFlagged {
+		tools.SSE.Broadcast(tools.Event{
+			Type: "customer.credit_exceeded",
+			Data: map[string]any{
+				"customerId":         o.CustomerID,
+				"orderId":            o.OrderID,
+				"creditLimit":        o.credit.CreditLimit,
+				"outstandingBalance": roundMoney(o.credit.OutstandingBalance + o.TotalPrice),
+			},
+			Time: time.Now(),
+		})
+	}
+}
 
-	w.Header().Set("Content-Type", "application/json")
-	_ = json.NewEncoder(w).Encode(map[string]any{
-		"orderId":    in.OrderID,
-		"totalPrice": computedTotal,
-	})
+func (o *createdOrder) response() map[string]any {
+	return map[string]any{
+		"orderId":           o.OrderID,
+		"totalPrice":        o.TotalPrice,
+		"shippingCost":      o.ShippingCost,
+		"shippingAddressId": o.ShippingAddressID,
+		"dueDate":           o.DueDate,
+		"creditFlagged":     o.CreditFlagged,
+		"backorders":        o.Backorders,
+	}
 }
 
 // ---------- List (GET /api/orders?search=&page=&pageSize=) ----------
@@ -286,17 +483,28 @@ func getOrderByIDHandler(w http.ResponseWriter, r *http.Request) {
 	_ = ensureOrderItemsHasWarehouseColumn(tools.DB)
 
 	var o struct {
-		OrderID    int     `json:"orderId"`
-		CustomerID int     `json:"customerId"`
-		UserID     int     `json:"userId"`
-		TotalPrice float64 `json:"totalPrice"`
-		CreatedAt  string  `json:"createdAt"`
+		OrderID           int     `json:"orderId"`
+		CustomerID        int     `json:"customerId"`
+		UserID            int     `json:"userId"`
+		TotalPrice        float64 `json:"totalPrice"`
+		CreatedAt         string  `json:"createdAt"`
+		ShippingCost      float64 `json:"shippingCost"`
+		ShippingAddressID *int    `json:"shippingAddressId"`
+		ShippingAddress   *string `json:"shippingAddress"`
+		DueDate           *string `json:"dueDate"`
+		AmountPaid        float64 `json:"amountPaid"`
+		PaidAt            *string `json:"paidAt"`
+		CreditFlagged     bool    `json:"creditFlagged"`
 	}
 	if err := tools.DB.QueryRow(
-		`SELECT orderId, customerId, userId, totalPrice, createdAt
+		`SELECT orderId, customerId, userId, totalPrice, createdAt,
+		        COALESCE(shipping_cost, 0), shipping_address_id, shipping_address,
+		        due_date, amount_paid, paid_at, credit_flagged
 		   FROM orders WHERE orderId = ?`,
 		orderID,
-	).Scan(&o.OrderID, &o.CustomerID, &o.UserID, &o.TotalPrice, &o.CreatedAt); err != nil {
+	).Scan(&o.OrderID, &o.CustomerID, &o.UserID, &o.TotalPrice, &o.CreatedAt,
+		&o.ShippingCost, &o.ShippingAddressID, &o.ShippingAddress,
+		&o.DueDate, &o.AmountPaid, &o.PaidAt, &o.CreditFlagged); err != nil {
 		if err == sql.ErrNoRows {
 			http.Error(w, "Order not found", http.StatusNotFound); return
 		}
@@ -304,18 +512,25 @@ func getOrderByIDHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	type itemOut struct {
-		ProductID     int     `json:"productId"`
-		Quantity      int     `json:"quantity"`
-		SalePrice     float64 `json:"salePrice"`
-		WarehouseID   int     `json:"warehouseId"`
-		WarehouseName string  `json:"warehouseName"`
+		ID            int                         `json:"id"`
+		ProductID     int                         `json:"productId"`
+		Quantity      int                         `json:"quantity"`
+		SalePrice     float64                     `json:"salePrice"`
+		WarehouseID   int                         `json:"warehouseId"`
+		WarehouseName string                      `json:"warehouseName"`
+		PickLocations []models.PickLocation       `json:"pickLocations"`
+		Lots          []models.LotAllocation      `json:"lots"`
+		Serials       []string                    `json:"serials"`
+		Components    []models.OrderItemComponent `json:"components,omitempty"` // kit lines only
+		Backorder     *models.Backorder           `json:"backorder,omitempty"`
 	}
 
 	items := []itemOut{}
 
 	// Preferred query (uses warehouse_id). If it fails with "no such column", fall back without that column.
 	const withWarehouse = `
-		SELECT oi.productId,
+		SELECT oi.id,
+		       oi.productId,
 		       oi.quantity,
 		       oi.salePrice,
 		       COALESCE(oi.warehouse_id, 0) AS warehouse_id,
@@ -330,7 +545,7 @@ func getOrderByIDHandler(w http.ResponseWriter, r *http.Request) {
 	if err != nil && strings.Contains(strings.ToLower(err.Error()), "no such column") {
 		// fallback: older schema without warehouse_id
 		rows, err = tools.DB.Query(
-			`SELECT oi.productId, oi.quantity, oi.salePrice
+			`SELECT oi.id, oi.productId, oi.quantity, oi.salePrice
 			   FROM order_items oi
 			  WHERE oi.orderId = ?
 		   ORDER BY oi.rowid ASC`,
@@ -343,7 +558,7 @@ func getOrderByIDHandler(w http.ResponseWriter, r *http.Request) {
 
 		for rows.Next() {
 			var it itemOut
-			if err := rows.Scan(&it.ProductID, &it.Quantity, &it.SalePrice); err != nil {
+			if err := rows.Scan(&it.ID, &it.ProductID, &it.Quantity, &it.SalePrice); err != nil {
 				tools.HandleInternalServerError(w, err); return
 			}
 			// warehouse fields remain zero/empty on legacy rows
@@ -355,21 +570,67 @@ func getOrderByIDHandler(w http.ResponseWriter, r *http.Request) {
 		defer rows.Close()
 		for rows.Next() {
 			var it itemOut
-			if err := rows.Scan(&it.ProductID, &it.Quantity, &it.SalePrice, &it.WarehouseID, &it.WarehouseName); err != nil {
+			if err := rows.Scan(&it.ID, &it.ProductID, &it.Quantity, &it.SalePrice, &it.WarehouseID, &it.WarehouseName); err != nil {
 				tools.HandleInternalServerError(w, err); return
 			}
 			items = append(items, it)
 		}
 	}
 
+	for i := range items {
+		picks, err := loadPicks(tools.DB, items[i].ID)
+		if err != nil {
+			tools.HandleInternalServerError(w, err); return
+		}
+		items[i].PickLocations = picks
+		lots, err := loadLotAllocations(tools.DB, items[i].ID)
+		if err != nil {
+			tools.HandleInternalServerError(w, err); return
+		}
+		items[i].Lots = lots
+		serials, err := loadOrderItemSerials(tools.DB, items[i].ID)
+		if err != nil {
+			tools.HandleInternalServerError(w, err); return
+		}
+		items[i].Serials = serials
+		components, err := loadOrderItemComponents(tools.DB, items[i].ID)
+		if err != nil {
+			tools.HandleInternalServerError(w, err); return
+		}
+		items[i].Components = components
+		backorder, err := loadItemBackorder(tools.DB, items[i].ID)
+		if err != nil {
+			tools.HandleInternalServerError(w, err); return
+		}
+		items[i].Backorder = backorder
+	}
+	attachments, err := loadAttachments(tools.DB, orderAttachments.kind, o.OrderID)
+	if err != nil {
+		tools.HandleInternalServerError(w, err); return
+	}
+	payments, err := loadOrderPayments(tools.DB, o.OrderID)
+	if err != nil {
+		tools.HandleInternalServerError(w, err); return
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	_ = json.NewEncoder(w).Encode(map[string]any{
-		"orderId":      o.OrderID,
-		"customerId":   o.CustomerID,
-		"userId":       o.UserID,
-		"totalPrice":   o.TotalPrice,
-		"createdAt":    o.CreatedAt,
-		"productItems": items,
+		"orderId":           o.OrderID,
+		"customerId":        o.CustomerID,
+		"userId":            o.UserID,
+		"totalPrice":        o.TotalPrice,
+		"createdAt":         o.CreatedAt,
+		"productItems":      items,
+		"attachments":       attachments,
+		"shippingCost":      o.ShippingCost,
+		"shippingAddressId": o.ShippingAddressID,
+		"shippingAddress":   o.ShippingAddress,
+		"dueDate":           o.DueDate,
+		"amountPaid":        o.AmountPaid,
+		"balanceDue":        roundMoney(o.TotalPrice - o.AmountPaid),
+		"paidAt":            o.PaidAt,
+		"creditFlagged":     o.CreditFlagged,
+		"payments":          payments,
 	})
 }
 
@@ -385,6 +646,40 @@ func deleteOrderHandler(w http.ResponseWriter, r *http.Request) {
 	if err != nil { tools.HandleInternalServerError(w, err); return }
 	defer tx.Rollback()
 
+	id, _ := strconv.Atoi(orderID)
+	var allocations int
+	if err := tx.QueryRow(`SELECT COUNT(*) FROM payment_allocations WHERE order_id = ?`, id).Scan(&allocations); err != nil {
+		tools.HandleInternalServerError(w, err); return
+	}
+	if allocations > 0 {
+		http.Error(w, "Order has payments applied; refund them before deleting it", http.StatusConflict)
+		return
+	}
+	var openIntents int
+	if err := tx.QueryRow(
+		`SELECT COUNT(*) FROM payment_intents WHERE order_id = ? AND status IN ('pending', 'authorized')`, id,
+	).Scan(&openIntents); err != nil {
+		tools.HandleInternalServerError(w, err); return
+	}
+	if openIntents > 0 {
+		http.Error(w, "Order has a card authorization open", http.StatusConflict)
+		return
+	}
+	if _, err := tx.Exec(`DELETE FROM payment_intents WHERE order_id = ?`, id); err != nil {
+		tools.HandleInternalServerError(w, err); return
+	}
+	// Notes about the order stay on the customer's timeline.
+	if _, err := tx.Exec(`UPDATE activities SET order_id = NULL WHERE order_id = ?`, id); err != nil {
+		tools.HandleInternalServerError(w, err); return
+	}
+	// A quote accepted as this order stays accepted, without the order.
+	if _, err := tx.Exec(`UPDATE quotes SET order_id = NULL WHERE order_id = ?`, id); err != nil {
+		tools.HandleInternalServerError(w, err); return
+	}
+	files, err := deleteOwnerAttachments(tx, orderAttachments.kind, id)
+	if err != nil {
+		tools.HandleInternalServerError(w, err); return
+	}
 	if _, err := tx.Exec(`DELETE FROM order_items WHERE orderId = ?`, orderID); err != nil {
 		tools.HandleInternalServerError(w, err); return
 	}
@@ -394,6 +689,7 @@ func deleteOrderHandler(w http.ResponseWriter, r *http.Request) {
 	if err := tx.Commit(); err != nil {
 		tools.HandleInternalServerError(w, err); return
 	}
+	removeStoredFiles(files...)
 
 	w.WriteHeader(http.StatusNoContent)
 }
@@ -515,6 +811,8 @@ func searchOrdersHandler(w http.ResponseWriter, r *http.Request) {
 // ---------- Stats ----------
 
 // GET /api/orders/total (total revenue)
+// totalCost and grossMargin cover the order lines with a unit cost snapshot;
+// see /api/reports/margins/* for breakdowns.
 func getTotalRevenueHandler(w http.ResponseWriter, r *http.Request) {
 	var sum sql.NullFloat64
 	if err := tools.DB.QueryRow(`SELECT SUM(totalPrice) FROM orders`).Scan(&sum); err != nil {
@@ -522,8 +820,15 @@ func getTotalRevenueHandler(w http.ResponseWriter, r *http.Request) {
 	}
 	total := 0.0
 	if sum.Valid { total = sum.Float64 }
+	var cost, margin float64
+	if err := tools.DB.QueryRow(`
+		SELECT COALESCE(SUM(quantity * unit_cost), 0),
+		       COALESCE(SUM(quantity * (salePrice - unit_cost)), 0)
+		FROM order_items WHERE unit_cost IS NOT NULL`).Scan(&cost, &margin); err != nil {
+		tools.HandleInternalServerError(w, err); return
+	}
 	w.Header().Set("Content-Type", "application/json")
-	_ = json.NewEncoder(w).Encode(map[string]float64{"totalRevenue": total})
+	_ = json.NewEncoder(w).Encode(map[string]float64{"totalRevenue": total, "totalCost": cost, "grossMargin": margin})
 }
 
 // GET /api/orders/total-orders (count)
@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/MananKakkar1/SalesBoard/backend/internal/tools"
+)
+
+var errInvalidID = errors.New("invalid id")
+
+// conflictError reports a uniqueness clash (duplicate SKU, barcode, ...) that handlers send as 409.
+type conflictError struct{ msg string }
+
+func (e *conflictError) Error() string { return e.msg }
+
+// atoiParam parses a positive integer path parameter such as {id}.
+func atoiParam(s string) (int, error) {
+	n, err := strconv.Atoi(s)
+	if err != nil || n <= 0 {
+		return 0, errInvalidID
+	}
+	return n, nil
+}
+
+// parsePage reads ?page= and ?pageSize= the same way the list handlers do
+// (page defaults to 1, pageSize to 20 and is capped at 100).
+func parsePage(r *http.Request) (page, pageSize, offset int) {
+	page = 1
+	pageSize = 20
+	if p, err := strconv.Atoi(r.URL.Query().Get("page")); err == nil && p > 0 {
+		page = p
+	}
+	if ps, err := strconv.Atoi(r.URL.Query().Get("pageSize")); err == nil && ps > 0 && ps <= 100 {
+		pageSize = ps
+	}
+	return page, pageSize, (page - 1) * pageSize
+}
+
+// paginationMeta builds the "pagination" object returned next to "data" by list endpoints.
+func paginationMeta(page, pageSize, totalCount int) map[string]any {
+	totalPages := (totalCount + pageSize - 1) / pageSize
+	return map[string]any{
+		"page":       page,
+		"pageSize":   pageSize,
+		"totalCount": totalCount,
+		"totalPages": totalPages,
+		"hasNext":    page < totalPages,
+		"hasPrev":    page > 1,
+	}
+}
+
+// invalidFieldError is a request field that fails validation (400).
+type invalidFieldError struct{ msg string }
+
+func (e *invalidFieldError) Error() string { return e.msg }
+
+// gatewayError is a payment gateway call that failed (502).
+type gatewayError struct{ err error }
+
+func (e *gatewayError) Error() string { return "payment gateway: " + e.err.Error() }
+
+// writeFieldError sends conflictError as 409, invalidFieldError as 400 and gatewayError as 502.
+func writeFieldError(w http.ResponseWriter, err error) {
+	var conflict *conflictError
+	var invalid *invalidFieldError
+	var gw *gatewayError
+	switch {
+	case errors.As(err, &conflict):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.As(err, &invalid):
+		tools.HandleBadRequest(w, err)
+	case errors.As(err, &gw):
+		http.Error(w, err.Error(), http.StatusBadGateway)
+	default:
+		tools.HandleInternalServerError(w, err)
+	}
+}
@@ -0,0 +1,585 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/MananKakkar1/SalesBoard/backend/internal/gateway"
+	"github.com/MananKakkar1/SalesBoard/backend/internal/models"
+	"github.com/MananKakkar1/SalesBoard/backend/internal/tools"
+	"github.com/go-chi/chi"
+	log "github.com/sirupsen/logrus"
+)
+
+// A payment intent charges an order's card through the configured gateway: it is
+// authorized (at once, or later by webhook), captured into the payments ledger, and
+// may be refunded back out of it. Authorizations still pending or authorized hold
+// their amount against the order's balance so it can't be charged twice.
+
+// paymentProvider is the gateway used for card payments; set it with InitPaymentProvider.
+var paymentProvider gateway.PaymentProvider
+
+// InitPaymentProvider selects the card payment gateway by name. Only "fake", the
+// offline simulator, is built in and it is the default; FAKE_WEBHOOK_DELAY (a Go
+// duration, default 3s) and FAKE_GATEWAY_SECRET configure it.
+func InitPaymentProvider(name string) error {
+	switch name {
+	case "", "fake":
+		delay := 3 * time.Second
+		if s := os.Getenv("FAKE_WEBHOOK_DELAY"); s != "" {
+			d, err := time.ParseDuration(s)
+			if err != nil {
+				return fmt.Errorf("FAKE_WEBHOOK_DELAY: %w", err)
+			}
+			delay = d
+		}
+		secret := os.Getenv("FAKE_GATEWAY_SECRET")
+		if secret == "" {
+			secret = "fake-gateway-secret"
+		}
+		f := gateway.NewFake(secret, delay)
+		f.Deliver = func(body []byte, header http.Header) {
+			if err := handleGatewayWebhook(f, body, header); err != nil {
+				log.Errorf("fake gateway webhook: %v", err)
+			}
+		}
+		paymentProvider = f
+		return nil
+	}
+	return fmt.Errorf("unknown payment provider %q", name)
+}
+
+// ---------- Input DTOs ----------
+
+type paymentIntentIn struct {
+	Amount        *float64 `json:"amount"` // default: the order's balance not already held
+	PaymentMethod string   `json:"paymentMethod"`
+	Capture       bool     `json:"capture"` // capture as soon as it is authorized
+	UserID        *int     `json:"userId"`
+}
+
+type intentAmountIn struct {
+	Amount *float64 `json:"amount"`
+	UserID *int     `json:"userId"`
+}
+
+// ---------- helpers ----------
+
+const intentColumns = `id, order_id, customer_id, provider, provider_ref, payment_method, amount, status,
+	auto_capture, amount_captured, amount_refunded, decline_reason, created_by, created_at, updated_at`
+
+func scanIntent(row rowScanner) (models.PaymentIntent, error) {
+	var pi models.PaymentIntent
+	err := row.Scan(&pi.ID, &pi.OrderID, &pi.CustomerID, &pi.Provider, &pi.ProviderRef, &pi.PaymentMethod,
+		&pi.Amount, &pi.Status, &pi.AutoCapture, &pi.AmountCaptured, &pi.AmountRefunded, &pi.DeclineReason,
+		&pi.CreatedBy, &pi.CreatedAt, &pi.UpdatedAt)
+	return pi, err
+}
+
+func loadIntent(q queryer, id int) (models.PaymentIntent, error) {
+	return scanIntent(q.QueryRow(`SELECT `+intentColumns+` FROM payment_intents WHERE id = ?`, id))
+}
+
+func broadcastIntent(pi models.PaymentIntent) {
+	tools.SSE.Broadcast(tools.Event{
+		Type: "payment_intent." + pi.Status,
+		Data: map[string]any{
+			"intentId":       pi.ID,
+			"orderId":        pi.OrderID,
+			"customerId":     pi.CustomerID,
+			"amount":         pi.Amount,
+			"amountCaptured": pi.AmountCaptured,
+			"amountRefunded": pi.AmountRefunded,
+			"declineReason":  pi.DeclineReason,
+		},
+		Time: time.Now(),
+	})
+}
+
+// checkIntentProvider makes sure the intent's gateway is the one configured now.
+func checkIntentProvider(pi models.PaymentIntent) error {
+	if paymentProvider == nil || paymentProvider.Name() != pi.Provider {
+		return &conflictError{fmt.Sprintf("payment intent %d was made with provider %q, which isn't configured", pi.ID, pi.Provider)}
+	}
+	return nil
+}
+
+// execer is satisfied by both *sql.DB and *sql.Tx.
+type execer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+}
+
+// setIntentOutcome records the gateway's answer to an authorization.
+func setIntentOutcome(q execer, id int, res gateway.Result) error {
+	var reason any
+	if res.DeclineReason != "" {
+		reason = res.DeclineReason
+	}
+	_, err := q.Exec(
+		`UPDATE payment_intents SET provider_ref = ?, status = ?, decline_reason = ?, updated_at = ? WHERE id = ?`,
+		res.Ref, res.Status, reason, time.Now().UTC().Format(time.RFC3339), id,
+	)
+	return err
+}
+
+// captureIntent captures an authorized intent (amount nil captures all of it) and
+// records the money in the ledger against the order. Anything beyond what the order
+// still owes stays on the customer as credit.
+func captureIntent(ctx context.Context, id int, amount *float64, userID *int) (models.PaymentIntent, error) {
+	pi, err := loadIntent(tools.DB, id)
+	if err != nil {
+		return pi, err
+	}
+	if pi.Status != gateway.StatusAuthorized {
+		return pi, &conflictError{fmt.Sprintf("payment intent %d is %s, not authorized", id, pi.Status)}
+	}
+	amt := pi.Amount
+	if amount != nil {
+		amt = roundMoney(*amount)
+	}
+	if amt <= 0 || amt > pi.Amount+0.005 {
+		return pi, &invalidFieldError{fmt.Sprintf("amount must be greater than 0 and at most %.2f", pi.Amount)}
+	}
+	if err := checkIntentProvider(pi); err != nil {
+		return pi, err
+	}
+	if err := checkUser(tools.DB, userID); err != nil {
+		return pi, err
+	}
+
+	res, err := paymentProvider.Capture(ctx, pi.ProviderRef, amt)
+	if err != nil {
+		return pi, &gatewayError{err}
+	}
+	if err := recordIntentPayment(pi, "payment", res.Amount, userID); err != nil {
+		log.Errorf("payment intent %d: captured %s at the gateway but not recorded: %v", id, res.Ref, err)
+		return pi, err
+	}
+	return loadIntent(tools.DB, id)
+}
+
+// refundIntent refunds a captured intent (amount nil refunds what's left) and takes
+// the refund back off the order, or out of the customer's credit beyond what the
+// order has been paid.
+func refundIntent(ctx context.Context, id int, amount *float64, userID *int) (models.PaymentIntent, error) {
+	pi, err := loadIntent(tools.DB, id)
+	if err != nil {
+		return pi, err
+	}
+	if pi.Status != gateway.StatusCaptured {
+		return pi, &conflictError{fmt.Sprintf("payment intent %d is %s, not captured", id, pi.Status)}
+	}
+	left := roundMoney(pi.AmountCaptured - pi.AmountRefunded)
+	amt := left
+	if amount != nil {
+		amt = roundMoney(*amount)
+	}
+	if amt <= 0 || amt > left+0.005 {
+		return pi, &invalidFieldError{fmt.Sprintf("amount must be greater than 0 and at most %.2f", left)}
+	}
+	if err := checkIntentProvider(pi); err != nil {
+		return pi, err
+	}
+	if err := checkUser(tools.DB, userID); err != nil {
+		return pi, err
+	}
+	var paid float64
+	var customerID int
+	if err := tools.DB.QueryRow(`SELECT customerId, amount_paid FROM orders WHERE orderId = ?`, pi.OrderID).Scan(&customerID, &paid); err != nil {
+		return pi, err
+	}
+	credit, err := customerUnappliedCredit(tools.DB, customerID)
+	if err != nil {
+		return pi, err
+	}
+	if amt > paid+credit+0.005 {
+		return pi, &conflictError{fmt.Sprintf(
+			"order %d has %.2f paid and the customer %.2f credit, less than the %.2f refund", pi.OrderID, paid, credit, amt,
+		)}
+	}
+
+	res, err := paymentProvider.Refund(ctx, pi.ProviderRef, amt)
+	if err != nil {
+		return pi, &gatewayError{err}
+	}
+	if err := recordIntentPayment(pi, "refund", res.Amount, userID); err != nil {
+		log.Errorf("payment intent %d: refunded %.2f of %s at the gateway but not recorded: %v", id, res.Amount, res.Ref, err)
+		return pi, err
+	}
+	return loadIntent(tools.DB, id)
+}
+
+// recordIntentPayment writes a gateway capture or refund to the ledger, allocated to
+// the intent's order as far as the order allows, and moves the intent along.
+func recordIntentPayment(pi models.PaymentIntent, kind string, amount float64, userID *int) error {
+	tx, err := tools.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// The order's customer, not the intent's: a merge may have moved the order since.
+	var customerID int
+	var total, paid float64
+	if err := tx.QueryRow(
+		`SELECT customerId, totalPrice, amount_paid FROM orders WHERE orderId = ?`, pi.OrderID,
+	).Scan(&customerID, &total, &paid); err != nil {
+		return err
+	}
+	allocate := roundMoney(min(amount, total-paid))
+	if kind == "refund" {
+		allocate = roundMoney(min(amount, paid))
+	}
+	in := paymentIn{
+		CustomerID: customerID,
+		Kind:       kind,
+		Method:     "card",
+		Amount:     amount,
+		Reference:  pi.ProviderRef,
+		Notes:      fmt.Sprintf("%s payment intent %d", pi.Provider, pi.ID),
+		UserID:     userID,
+	}
+	if allocate > 0 {
+		in.Allocations = []allocationIn{{OrderID: pi.OrderID, Amount: allocate}}
+	}
+	paymentID, orders, err := recordPayment(tx, in)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`UPDATE payments SET intent_id = ? WHERE id = ?`, pi.ID, paymentID); err != nil {
+		return err
+	}
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	if kind == "refund" {
+		refunded := roundMoney(pi.AmountRefunded + amount)
+		status := gateway.StatusCaptured
+		if refunded >= pi.AmountCaptured-0.005 {
+			status = gateway.StatusRefunded
+		}
+		_, err = tx.Exec(`UPDATE payment_intents SET amount_refunded = ?, status = ?, updated_at = ? WHERE id = ?`,
+			refunded, status, now, pi.ID)
+	} else {
+		_, err = tx.Exec(`UPDATE payment_intents SET amount_captured = ?, status = ?, updated_at = ? WHERE id = ?`,
+			amount, gateway.StatusCaptured, now, pi.ID)
+	}
+	if err != nil {
+		return err
+	}
+	p, err := loadPayment(tx, paymentID)
+	if err != nil {
+		return err
+	}
+	updated, err := loadIntent(tx, pi.ID)
+	if err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	if kind == "refund" {
+		tools.SSE.Broadcast(tools.Event{
+			Type: "payment_intent.refunded",
+			Data: map[string]any{"intentId": pi.ID, "orderId": pi.OrderID, "amount": amount, "amountRefunded": updated.AmountRefunded},
+			Time: time.Now(),
+		})
+		broadcastPaymentEvents("payment.refunded", p, orders)
+	} else {
+		broadcastIntent(updated)
+		broadcastPaymentEvents("payment.recorded", p, orders)
+	}
+	return nil
+}
+
+var errUnknownCharge = errors.New("no payment intent for this charge")
+
+// handleGatewayWebhook applies a gateway's verified notification that a pending
+// authorization went through or was declined. Redelivered events are ignored.
+func handleGatewayWebhook(p gateway.PaymentProvider, body []byte, header http.Header) error {
+	ev, err := p.VerifyWebhook(body, header)
+	if err != nil {
+		return err
+	}
+
+	tx, err := tools.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(
+		`INSERT OR IGNORE INTO payment_webhook_events (provider, event_id, received_at) VALUES (?, ?, ?)`,
+		p.Name(), ev.ID, time.Now().UTC().Format(time.RFC3339),
+	)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil
+	}
+	pi, err := scanIntent(tx.QueryRow(
+		`SELECT `+intentColumns+` FROM payment_intents WHERE provider = ? AND provider_ref = ?`, p.Name(), ev.Ref,
+	))
+	if err == sql.ErrNoRows {
+		return errUnknownCharge
+	}
+	if err != nil {
+		return err
+	}
+	settled := pi.Status == gateway.StatusPending &&
+		(ev.Status == gateway.StatusAuthorized || ev.Status == gateway.StatusDeclined)
+	if settled {
+		if err := setIntentOutcome(tx, pi.ID, gateway.Result{Ref: ev.Ref, Status: ev.Status, DeclineReason: ev.DeclineReason}); err != nil {
+			return err
+		}
+		if pi, err = loadIntent(tx, pi.ID); err != nil {
+			return err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	if !settled {
+		return nil
+	}
+
+	broadcastIntent(pi)
+	if pi.Status == gateway.StatusAuthorized && pi.AutoCapture {
+		if _, err := captureIntent(context.Background(), pi.ID, nil, pi.CreatedBy); err != nil {
+			return fmt.Errorf("auto-capture of payment intent %d: %w", pi.ID, err)
+		}
+	}
+	return nil
+}
+
+// ---------- Create (POST /api/orders/{id}/payment-intents) ----------
+// body: { "paymentMethod": "fake_ok", "amount": 40, "capture": true }
+// Authorizes the card at the gateway. A pending authorization is settled later by
+// webhook; with "capture" it is then captured straight away.
+func createPaymentIntentHandler(w http.ResponseWriter, r *http.Request) {
+	orderID, err := atoiParam(chi.URLParam(r, "id"))
+	if err != nil {
+		tools.HandleBadRequest(w, err)
+		return
+	}
+	var in paymentIntentIn
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		tools.HandleBadRequest(w, errors.New("invalid request"))
+		return
+	}
+	in.PaymentMethod = strings.TrimSpace(in.PaymentMethod)
+	if in.PaymentMethod == "" {
+		tools.HandleBadRequest(w, errors.New("paymentMethod is required"))
+		return
+	}
+	if paymentProvider == nil {
+		http.Error(w, "No payment provider configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	tx, err := tools.DB.Begin()
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	defer tx.Rollback()
+
+	var customerID int
+	var balance float64
+	err = tx.QueryRow(`SELECT customerId, totalPrice - amount_paid FROM orders WHERE orderId = ?`, orderID).Scan(&customerID, &balance)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Order not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	var held float64
+	if err := tx.QueryRow(
+		`SELECT COALESCE(SUM(amount), 0) FROM payment_intents WHERE order_id = ? AND status IN ('pending', 'authorized')`, orderID,
+	).Scan(&held); err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	chargeable := roundMoney(balance - held)
+	amount := chargeable
+	if in.Amount != nil {
+		amount = roundMoney(*in.Amount)
+		if amount <= 0 {
+			tools.HandleBadRequest(w, errors.New("amount must be greater than 0"))
+			return
+		}
+	}
+	if amount <= 0 || amount > chargeable+0.005 {
+		http.Error(w, fmt.Sprintf("Order %d has %.2f left to charge", orderID, max(chargeable, 0)), http.StatusConflict)
+		return
+	}
+	if err := checkUser(tx, in.UserID); err != nil {
+		writeFieldError(w, err)
+		return
+	}
+	now := time.Now().UTC().Format(time.RFC3339)
+	res, err := tx.Exec(`
+		INSERT INTO payment_intents (order_id, customer_id, provider, payment_method, amount, status, auto_capture,
+		                             created_by, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, 'pending', ?, ?, ?, ?)`,
+		orderID, customerID, paymentProvider.Name(), in.PaymentMethod, amount, in.Capture, in.UserID, now, now,
+	)
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	id64, _ := res.LastInsertId()
+	id := int(id64)
+	if err := tx.Commit(); err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+
+	// The gateway is called outside the transaction; the intent row already holds
+	// the amount so a concurrent request can't charge it again.
+	auth, err := paymentProvider.Authorize(r.Context(), gateway.AuthorizeRequest{
+		Reference:     strconv.Itoa(id),
+		Amount:        amount,
+		PaymentMethod: in.PaymentMethod,
+	})
+	if err != nil {
+		_ = setIntentOutcome(tools.DB, id, gateway.Result{Status: "failed", DeclineReason: err.Error()})
+		writeFieldError(w, &gatewayError{err})
+		return
+	}
+	if err := setIntentOutcome(tools.DB, id, auth); err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	pi, err := loadIntent(tools.DB, id)
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	broadcastIntent(pi)
+
+	if pi.Status == gateway.StatusAuthorized && in.Capture {
+		if pi, err = captureIntent(r.Context(), id, nil, in.UserID); err != nil {
+			writeFieldError(w, err)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(pi)
+}
+
+// ---------- List (GET /api/orders/{id}/payment-intents) ----------
+func getOrderPaymentIntentsHandler(w http.ResponseWriter, r *http.Request) {
+	orderID, err := atoiParam(chi.URLParam(r, "id"))
+	if err != nil {
+		tools.HandleBadRequest(w, err)
+		return
+	}
+	rows, err := tools.DB.Query(`SELECT `+intentColumns+` FROM payment_intents WHERE order_id = ? ORDER BY id`, orderID)
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	defer rows.Close()
+	out := []models.PaymentIntent{}
+	for rows.Next() {
+		pi, err := scanIntent(rows)
+		if err != nil {
+			tools.HandleInternalServerError(w, err)
+			return
+		}
+		out = append(out, pi)
+	}
+	if err := rows.Err(); err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(out)
+}
+
+// ---------- Read one (GET /api/payment-intents/{id}) ----------
+func getPaymentIntentHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := atoiParam(chi.URLParam(r, "id"))
+	if err != nil {
+		tools.HandleBadRequest(w, err)
+		return
+	}
+	pi, err := loadIntent(tools.DB, id)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Payment intent not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(pi)
+}
+
+// intentActionHandler wraps capture and refund: body { "amount": 20 } (optional).
+func intentActionHandler(action func(context.Context, int, *float64, *int) (models.PaymentIntent, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := atoiParam(chi.URLParam(r, "id"))
+		if err != nil {
+			tools.HandleBadRequest(w, err)
+			return
+		}
+		var in intentAmountIn
+		if err := json.NewDecoder(r.Body).Decode(&in); err != nil && err != io.EOF {
+			tools.HandleBadRequest(w, errors.New("invalid request"))
+			return
+		}
+		pi, err := action(r.Context(), id, in.Amount, in.UserID)
+		if err == sql.ErrNoRows {
+			http.Error(w, "Payment intent not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			writeFieldError(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(pi)
+	}
+}
+
+// ---------- Webhook (POST /api/payment-webhooks/{provider}) ----------
+// Called by the gateway; the signature is checked by the provider's VerifyWebhook.
+func paymentWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	if paymentProvider == nil || paymentProvider.Name() != chi.URLParam(r, "provider") {
+		http.Error(w, "Unknown payment provider", http.StatusNotFound)
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+	if err != nil {
+		tools.HandleBadRequest(w, errors.New("invalid request"))
+		return
+	}
+	err = handleGatewayWebhook(paymentProvider, body, r.Header)
+	switch {
+	case errors.Is(err, gateway.ErrInvalidSignature):
+		tools.HandleUnauthorized(w, err)
+	case errors.Is(err, errUnknownCharge):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case err != nil:
+		writeFieldError(w, err)
+	default:
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
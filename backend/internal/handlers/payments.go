@@ -0,0 +1,562 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/MananKakkar1/SalesBoard/backend/internal/models"
+	"github.com/MananKakkar1/SalesBoard/backend/internal/tools"
+	"github.com/go-chi/chi"
+)
+
+var paymentMethods = map[string]bool{"cash": true, "card": true, "bank_transfer": true, "cheque": true, "other": true}
+
+// ---------- Input DTOs ----------
+
+type allocationIn struct {
+	OrderID int     `json:"orderId"`
+	Amount  float64 `json:"amount"`
+}
+
+type paymentIn struct {
+	CustomerID  int            `json:"customerId"`
+	Kind        string         `json:"kind"` // payment (default) | refund
+	Method      string         `json:"method"`
+	Amount      float64        `json:"amount"`
+	Reference   string         `json:"reference"`
+	Notes       string         `json:"notes"`
+	ReceivedAt  string         `json:"receivedAt"` // optional; RFC3339 or YYYY-MM-DD, default now
+	UserID      *int           `json:"userId"`
+	Allocations []allocationIn `json:"allocations"`
+	// AutoApply allocates a payment to the customer's unpaid orders, oldest due first,
+	// when no allocations are given.
+	AutoApply bool `json:"autoApply"`
+}
+
+type applyPaymentIn struct {
+	Allocations []allocationIn `json:"allocations"`
+	AutoApply   bool           `json:"autoApply"`
+}
+
+// orderBalance is an order's payment state after a payment touched it.
+type orderBalance struct {
+	OrderID    int     `json:"orderId"`
+	AmountPaid float64 `json:"amountPaid"`
+	BalanceDue float64 `json:"balanceDue"`
+	// BecamePaid is set when this payment settled the order.
+	BecamePaid bool `json:"-"`
+}
+
+// ---------- helpers ----------
+
+const paymentColumns = `id, customer_id, kind, method, amount, reference, notes, received_at, recorded_by, created_at, intent_id`
+
+func scanPayment(row rowScanner) (models.Payment, error) {
+	var p models.Payment
+	err := row.Scan(&p.ID, &p.CustomerID, &p.Kind, &p.Method, &p.Amount, &p.Reference, &p.Notes,
+		&p.ReceivedAt, &p.RecordedBy, &p.CreatedAt, &p.IntentID)
+	return p, err
+}
+
+// loadPayment returns the payment with its allocations, or sql.ErrNoRows.
+func loadPayment(q queryer, id int) (models.Payment, error) {
+	p, err := scanPayment(q.QueryRow(`SELECT `+paymentColumns+` FROM payments WHERE id = ?`, id))
+	if err != nil {
+		return p, err
+	}
+	rows, err := q.Query(
+		`SELECT id, payment_id, order_id, amount, created_at FROM payment_allocations WHERE payment_id = ? ORDER BY id`, id,
+	)
+	if err != nil {
+		return p, err
+	}
+	defer rows.Close()
+	p.Allocations = []models.PaymentAllocation{}
+	allocated := 0.0
+	for rows.Next() {
+		var a models.PaymentAllocation
+		if err := rows.Scan(&a.ID, &a.PaymentID, &a.OrderID, &a.Amount, &a.CreatedAt); err != nil {
+			return p, err
+		}
+		allocated += a.Amount
+		p.Allocations = append(p.Allocations, a)
+	}
+	p.Unapplied = roundMoney(p.Amount - allocated)
+	return p, rows.Err()
+}
+
+// loadOrderPayments lists the payments and refunds allocated to an order.
+func loadOrderPayments(q queryer, orderID int) ([]models.OrderPayment, error) {
+	rows, err := q.Query(`
+		SELECT p.id, p.kind, p.method, p.reference, p.received_at, a.amount
+		FROM payment_allocations a
+		JOIN payments p ON p.id = a.payment_id
+		WHERE a.order_id = ?
+		ORDER BY p.received_at, a.id`, orderID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := []models.OrderPayment{}
+	for rows.Next() {
+		var p models.OrderPayment
+		if err := rows.Scan(&p.PaymentID, &p.Kind, &p.Method, &p.Reference, &p.ReceivedAt, &p.Amount); err != nil {
+			return nil, err
+		}
+		out = append(out, p)
+	}
+	return out, rows.Err()
+}
+
+// customerUnappliedCredit is what the customer has paid in beyond their allocations,
+// less refunds paid out of it.
+func customerUnappliedCredit(q queryer, customerID int) (float64, error) {
+	var v float64
+	err := q.QueryRow(`
+		SELECT COALESCE(SUM(
+		         CASE p.kind WHEN 'refund' THEN -1 ELSE 1 END *
+		         (p.amount - COALESCE((SELECT SUM(a.amount) FROM payment_allocations a WHERE a.payment_id = p.id), 0))
+		       ), 0)
+		FROM payments p WHERE p.customer_id = ?`, customerID,
+	).Scan(&v)
+	return roundMoney(v), err
+}
+
+// autoAllocations spreads amount over the customer's unpaid orders, oldest due date first.
+func autoAllocations(q queryer, customerID int, amount float64) ([]allocationIn, error) {
+	rows, err := q.Query(`
+		SELECT orderId, totalPrice - amount_paid
+		FROM orders
+		WHERE customerId = ? AND totalPrice - amount_paid > 0.005
+		ORDER BY due_date, orderId`, customerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []allocationIn
+	for rows.Next() && amount > 0.005 {
+		var a allocationIn
+		var due float64
+		if err := rows.Scan(&a.OrderID, &due); err != nil {
+			return nil, err
+		}
+		a.Amount = roundMoney(min(due, amount))
+		amount -= a.Amount
+		out = append(out, a)
+	}
+	return out, rows.Err()
+}
+
+// allocatePayment applies a payment to orders, or takes a refund back off them, and
+// keeps orders.amount_paid and paid_at in step. A payment can't take an order past
+// its total; a refund can't take back more than the order has been paid.
+func allocatePayment(tx *sql.Tx, p models.Payment, allocs []allocationIn) ([]orderBalance, error) {
+	now := time.Now().UTC().Format(time.RFC3339)
+	sign := 1.0
+	if p.Kind == "refund" {
+		sign = -1
+	}
+	var out []orderBalance
+	for _, a := range allocs {
+		amount := roundMoney(a.Amount)
+		if a.OrderID <= 0 || amount <= 0 {
+			return nil, &invalidFieldError{"each allocation requires orderId > 0 and amount > 0"}
+		}
+		var customerID int
+		var total, paid float64
+		var paidAt sql.NullString
+		err := tx.QueryRow(
+			`SELECT customerId, totalPrice, amount_paid, paid_at FROM orders WHERE orderId = ?`, a.OrderID,
+		).Scan(&customerID, &total, &paid, &paidAt)
+		if err == sql.ErrNoRows {
+			return nil, &invalidFieldError{fmt.Sprintf("order %d does not exist", a.OrderID)}
+		}
+		if err != nil {
+			return nil, err
+		}
+		if customerID != p.CustomerID {
+			return nil, &invalidFieldError{fmt.Sprintf("order %d belongs to another customer", a.OrderID)}
+		}
+		if p.Kind == "refund" && amount > paid+0.005 {
+			return nil, &invalidFieldError{fmt.Sprintf("order %d has only %.2f paid to refund", a.OrderID, paid)}
+		}
+		if p.Kind != "refund" && amount > total-paid+0.005 {
+			return nil, &invalidFieldError{fmt.Sprintf("order %d has only %.2f due", a.OrderID, roundMoney(total-paid))}
+		}
+
+		if _, err := tx.Exec(
+			`INSERT INTO payment_allocations (payment_id, order_id, amount, created_at) VALUES (?, ?, ?, ?)`,
+			p.ID, a.OrderID, amount, now,
+		); err != nil {
+			return nil, err
+		}
+		paid = roundMoney(paid + sign*amount)
+		settled := paid >= total-0.005
+		var newPaidAt any
+		if settled {
+			newPaidAt = now
+			if paidAt.Valid {
+				newPaidAt = paidAt.String
+			}
+		}
+		if _, err := tx.Exec(
+			`UPDATE orders SET amount_paid = ?, paid_at = ? WHERE orderId = ?`, paid, newPaidAt, a.OrderID,
+		); err != nil {
+			return nil, err
+		}
+		out = append(out, orderBalance{
+			OrderID:    a.OrderID,
+			AmountPaid: paid,
+			BalanceDue: roundMoney(total - paid),
+			BecamePaid: settled && !paidAt.Valid,
+		})
+	}
+	return out, nil
+}
+
+// recordPayment validates and stores a payment or refund with its allocations.
+func recordPayment(tx *sql.Tx, in paymentIn) (int, []orderBalance, error) {
+	in.Kind = strings.TrimSpace(in.Kind)
+	if in.Kind == "" {
+		in.Kind = "payment"
+	}
+	if in.Kind != "payment" && in.Kind != "refund" {
+		return 0, nil, &invalidFieldError{"kind must be payment or refund"}
+	}
+	in.Method = strings.TrimSpace(in.Method)
+	if !paymentMethods[in.Method] {
+		return 0, nil, &invalidFieldError{"method must be one of cash, card, bank_transfer, cheque, other"}
+	}
+	in.Amount = roundMoney(in.Amount)
+	if in.Amount <= 0 {
+		return 0, nil, &invalidFieldError{"amount must be greater than 0"}
+	}
+	receivedAt := time.Now().UTC().Format(time.RFC3339)
+	if s := strings.TrimSpace(in.ReceivedAt); s != "" {
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			if t, err = time.Parse(dateLayout, s); err != nil {
+				return 0, nil, &invalidFieldError{"receivedAt must be RFC3339 or YYYY-MM-DD"}
+			}
+		}
+		receivedAt = t.UTC().Format(time.RFC3339)
+	}
+	if ok, err := customerExists(tx, in.CustomerID); err != nil {
+		return 0, nil, err
+	} else if !ok {
+		return 0, nil, &invalidFieldError{fmt.Sprintf("customer %d does not exist", in.CustomerID)}
+	}
+	if err := checkUser(tx, in.UserID); err != nil {
+		return 0, nil, err
+	}
+
+	allocs := in.Allocations
+	if len(allocs) == 0 && in.AutoApply {
+		if in.Kind == "refund" {
+			return 0, nil, &invalidFieldError{"autoApply is for payments; give a refund's allocations explicitly"}
+		}
+		var err error
+		if allocs, err = autoAllocations(tx, in.CustomerID, in.Amount); err != nil {
+			return 0, nil, err
+		}
+	}
+	allocated := 0.0
+	for _, a := range allocs {
+		allocated += roundMoney(a.Amount)
+	}
+	if allocated > in.Amount+0.005 {
+		return 0, nil, &invalidFieldError{fmt.Sprintf("allocations total %.2f, more than the %.2f %s", allocated, in.Amount, in.Kind)}
+	}
+	if in.Kind == "refund" {
+		credit, err := customerUnappliedCredit(tx, in.CustomerID)
+		if err != nil {
+			return 0, nil, err
+		}
+		if unallocated := roundMoney(in.Amount - allocated); unallocated > credit+0.005 {
+			return 0, nil, &invalidFieldError{fmt.Sprintf(
+				"%.2f of the refund isn't allocated to orders but the customer has only %.2f credit", unallocated, credit,
+			)}
+		}
+	}
+
+	res, err := tx.Exec(`
+		INSERT INTO payments (customer_id, kind, method, amount, reference, notes, received_at, recorded_by, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		in.CustomerID, in.Kind, in.Method, in.Amount, strings.TrimSpace(in.Reference), strings.TrimSpace(in.Notes),
+		receivedAt, in.UserID, time.Now().UTC().Format(time.RFC3339),
+	)
+	if err != nil {
+		return 0, nil, err
+	}
+	id, _ := res.LastInsertId()
+	p := models.Payment{ID: int(id), CustomerID: in.CustomerID, Kind: in.Kind}
+	orders, err := allocatePayment(tx, p, allocs)
+	if err != nil {
+		return 0, nil, err
+	}
+	return p.ID, orders, nil
+}
+
+// broadcastPaymentEvents tells dashboards about a payment and any orders it settled.
+func broadcastPaymentEvents(eventType string, p models.Payment, orders []orderBalance) {
+	if orders == nil {
+		orders = []orderBalance{}
+	}
+	now := time.Now()
+	tools.SSE.Broadcast(tools.Event{
+		Type: eventType,
+		Data: map[string]any{
+			"paymentId":  p.ID,
+			"customerId": p.CustomerID,
+			"kind":       p.Kind,
+			"method":     p.Method,
+			"amount":     p.Amount,
+			"unapplied":  p.Unapplied,
+			"orders":     orders,
+		},
+		Time: now,
+	})
+	for _, o := range orders {
+		if o.BecamePaid {
+			tools.SSE.Broadcast(tools.Event{
+				Type: "order.paid",
+				Data: map[string]any{"orderId": o.OrderID, "customerId": p.CustomerID, "amountPaid": o.AmountPaid},
+				Time: now,
+			})
+		}
+	}
+}
+
+// ---------- Create (POST /api/payments) ----------
+// body: { "customerId": 3, "method": "bank_transfer", "amount": 250, "reference": "INV-1042",
+//
+//	"allocations": [ { "orderId": 17, "amount": 200 } ] }
+//
+// Whatever isn't allocated stays on the customer as credit. A refund ("kind": "refund")
+// allocates the amounts taken back off orders; the rest is paid out of the customer's credit.
+func createPaymentHandler(w http.ResponseWriter, r *http.Request) {
+	var in paymentIn
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		tools.HandleBadRequest(w, errors.New("invalid request"))
+		return
+	}
+
+	tx, err := tools.DB.Begin()
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	defer tx.Rollback()
+
+	id, orders, err := recordPayment(tx, in)
+	if err != nil {
+		writeFieldError(w, err)
+		return
+	}
+	p, err := loadPayment(tx, id)
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+
+	eventType := "payment.recorded"
+	if p.Kind == "refund" {
+		eventType = "payment.refunded"
+	}
+	broadcastPaymentEvents(eventType, p, orders)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(p)
+}
+
+// ---------- Apply credit (POST /api/payments/{id}/apply) ----------
+// body: { "allocations": [ { "orderId": 18, "amount": 50 } ] } or { "autoApply": true }
+// Allocates the unapplied part of an earlier payment to orders.
+func applyPaymentHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := atoiParam(chi.URLParam(r, "id"))
+	if err != nil {
+		tools.HandleBadRequest(w, err)
+		return
+	}
+	var in applyPaymentIn
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		tools.HandleBadRequest(w, errors.New("invalid request"))
+		return
+	}
+
+	tx, err := tools.DB.Begin()
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	defer tx.Rollback()
+
+	p, err := loadPayment(tx, id)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Payment not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	if p.Kind != "payment" {
+		tools.HandleBadRequest(w, errors.New("only payments can be applied to orders"))
+		return
+	}
+	// Refunds paid out of credit come off what's left to apply.
+	credit, err := customerUnappliedCredit(tx, p.CustomerID)
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	available := min(p.Unapplied, credit)
+	allocs := in.Allocations
+	if len(allocs) == 0 && in.AutoApply {
+		if allocs, err = autoAllocations(tx, p.CustomerID, available); err != nil {
+			tools.HandleInternalServerError(w, err)
+			return
+		}
+		if len(allocs) == 0 {
+			tools.HandleBadRequest(w, errors.New("nothing to apply: no unapplied credit or no unpaid orders"))
+			return
+		}
+	}
+	if len(allocs) == 0 {
+		tools.HandleBadRequest(w, errors.New("allocations or autoApply is required"))
+		return
+	}
+	total := 0.0
+	for _, a := range allocs {
+		total += roundMoney(a.Amount)
+	}
+	if total > available+0.005 {
+		tools.HandleBadRequest(w, fmt.Errorf("payment %d has only %.2f left to apply", p.ID, max(available, 0)))
+		return
+	}
+	orders, err := allocatePayment(tx, p, allocs)
+	if err != nil {
+		writeFieldError(w, err)
+		return
+	}
+	if p, err = loadPayment(tx, id); err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+
+	broadcastPaymentEvents("payment.applied", p, orders)
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(p)
+}
+
+// ---------- Read one (GET /api/payments/{id}) ----------
+func getPaymentHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := atoiParam(chi.URLParam(r, "id"))
+	if err != nil {
+		tools.HandleBadRequest(w, err)
+		return
+	}
+	p, err := loadPayment(tools.DB, id)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Payment not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(p)
+}
+
+// ---------- List (GET /api/payments?customerId=&orderId=&kind=&page=&pageSize=) ----------
+// Newest first.
+func getPaymentsHandler(w http.ResponseWriter, r *http.Request) {
+	var conds []string
+	var args []any
+	if s := r.URL.Query().Get("customerId"); s != "" {
+		n, err := atoiParam(s)
+		if err != nil {
+			tools.HandleBadRequest(w, errors.New("invalid customerId"))
+			return
+		}
+		conds = append(conds, "customer_id = ?")
+		args = append(args, n)
+	}
+	if s := r.URL.Query().Get("orderId"); s != "" {
+		n, err := atoiParam(s)
+		if err != nil {
+			tools.HandleBadRequest(w, errors.New("invalid orderId"))
+			return
+		}
+		conds = append(conds, "id IN (SELECT payment_id FROM payment_allocations WHERE order_id = ?)")
+		args = append(args, n)
+	}
+	if s := r.URL.Query().Get("kind"); s != "" {
+		if s != "payment" && s != "refund" {
+			tools.HandleBadRequest(w, errors.New("kind must be payment or refund"))
+			return
+		}
+		conds = append(conds, "kind = ?")
+		args = append(args, s)
+	}
+	where := ""
+	if len(conds) > 0 {
+		where = "WHERE " + strings.Join(conds, " AND ")
+	}
+	page, pageSize, offset := parsePage(r)
+
+	var total int
+	if err := tools.DB.QueryRow(`SELECT COUNT(*) FROM payments `+where, args...).Scan(&total); err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	rows, err := tools.DB.Query(
+		`SELECT id FROM payments `+where+` ORDER BY received_at DESC, id DESC LIMIT ? OFFSET ?`,
+		append(args, pageSize, offset)...,
+	)
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			tools.HandleInternalServerError(w, err)
+			return
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+
+	data := []models.Payment{}
+	for _, id := range ids {
+		p, err := loadPayment(tools.DB, id)
+		if err != nil {
+			tools.HandleInternalServerError(w, err)
+			return
+		}
+		data = append(data, p)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"data":       data,
+		"pagination": paginationMeta(page, pageSize, total),
+	})
+}
@@ -0,0 +1,449 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/MananKakkar1/SalesBoard/backend/internal/models"
+	"github.com/MananKakkar1/SalesBoard/backend/internal/tools"
+	"github.com/go-chi/chi"
+)
+
+const (
+	priceScheduled = "scheduled"
+	priceApplied   = "applied"
+	priceCancelled = "cancelled"
+)
+
+// ---------- Input DTOs ----------
+
+// effectiveFrom is RFC3339 or YYYY-MM-DD (midnight UTC); empty or past means now.
+type priceChangeIn struct {
+	Price         float64 `json:"price"`
+	EffectiveFrom string  `json:"effectiveFrom"`
+	UserID        *int    `json:"userId"`
+	Reason        string  `json:"reason"`
+}
+
+// ---------- helpers ----------
+
+// parseEffectiveFrom normalises an effective date to RFC3339 UTC.
+func parseEffectiveFrom(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if t, err := time.Parse(dateLayout, s); err == nil {
+		return t.UTC(), nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}, errors.New("effectiveFrom must be RFC3339 or YYYY-MM-DD")
+	}
+	return t.UTC(), nil
+}
+
+// checkUser rejects a userId that doesn't exist; nil means the change is unattributed.
+func checkUser(q queryer, userID *int) error {
+	if userID == nil {
+		return nil
+	}
+	var n int
+	if err := q.QueryRow(`SELECT COUNT(*) FROM users WHERE userId = ?`, *userID).Scan(&n); err != nil {
+		return err
+	}
+	if n == 0 {
+		return &invalidFieldError{fmt.Sprintf("user %d does not exist", *userID)}
+	}
+	return nil
+}
+
+// recordPrice writes the history for a price that takes effect at effectiveFrom: the
+// open row is closed and scheduledID (or a new row when 0) becomes the current one.
+// It doesn't touch products.price.
+func recordPrice(tx *sql.Tx, productID int, price float64, effectiveFrom string, changedBy *int, reason string, scheduledID int) error {
+	now := time.Now().UTC().Format(time.RFC3339)
+	if _, err := tx.Exec(`
+		UPDATE product_price_history SET effective_to = ?
+		WHERE product_id = ? AND status = ? AND effective_to IS NULL`,
+		effectiveFrom, productID, priceApplied,
+	); err != nil {
+		return err
+	}
+	if scheduledID > 0 {
+		_, err := tx.Exec(
+			`UPDATE product_price_history SET status = ?, applied_at = ? WHERE id = ?`,
+			priceApplied, now, scheduledID,
+		)
+		return err
+	}
+	_, err := tx.Exec(`
+		INSERT INTO product_price_history
+			(product_id, price, effective_from, status, changed_by, reason, created_at, applied_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		productID, price, effectiveFrom, priceApplied, changedBy, reason, now, now,
+	)
+	return err
+}
+
+// applyPrice makes price current for a product and records it. A price set directly
+// on a variant becomes its override; variants that follow a parent get the new price
+// with their own history rows.
+func applyPrice(tx *sql.Tx, productID int, price float64, effectiveFrom string, changedBy *int, reason string, scheduledID int) error {
+	if err := recordPrice(tx, productID, price, effectiveFrom, changedBy, reason, scheduledID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`
+		UPDATE products
+		SET price = ?, price_override = CASE WHEN parent_id IS NULL THEN price_override ELSE ? END
+		WHERE id = ?`, price, price, productID,
+	); err != nil {
+		return err
+	}
+
+	rows, err := tx.Query(`SELECT id FROM products WHERE parent_id = ? AND price_override IS NULL AND price <> ?`, productID, price)
+	if err != nil {
+		return err
+	}
+	var variantIDs []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+		variantIDs = append(variantIDs, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for _, id := range variantIDs {
+		if err := recordPrice(tx, id, price, effectiveFrom, changedBy, "follows parent", 0); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(`UPDATE products SET price = ? WHERE id = ?`, price, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+const priceChangeSelect = `
+	SELECT h.id, h.product_id, h.price, h.effective_from, h.effective_to, h.status,
+	       h.changed_by, COALESCE(u.name, ''), h.reason, h.created_at, h.applied_at
+	FROM product_price_history h
+	LEFT JOIN users u ON u.userId = h.changed_by`
+
+func scanPriceChange(row rowScanner) (models.PriceChange, error) {
+	var c models.PriceChange
+	err := row.Scan(&c.ID, &c.ProductID, &c.Price, &c.EffectiveFrom, &c.EffectiveTo, &c.Status,
+		&c.ChangedBy, &c.ChangedByName, &c.Reason, &c.CreatedAt, &c.AppliedAt)
+	return c, err
+}
+
+// ---------- Scheduler ----------
+
+// applyDuePriceChanges applies scheduled changes whose effective time has passed, oldest
+// first, each in its own transaction. It returns the rows applied.
+func applyDuePriceChanges(now time.Time) ([]models.PriceChange, error) {
+	rows, err := tools.DB.Query(`
+		SELECT id, product_id, price, effective_from, changed_by, reason
+		FROM product_price_history
+		WHERE status = ? AND effective_from <= ?
+		ORDER BY effective_from, id`, priceScheduled, now.UTC().Format(time.RFC3339))
+	if err != nil {
+		return nil, err
+	}
+	var due []models.PriceChange
+	for rows.Next() {
+		var c models.PriceChange
+		if err := rows.Scan(&c.ID, &c.ProductID, &c.Price, &c.EffectiveFrom, &c.ChangedBy, &c.Reason); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		due = append(due, c)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var applied []models.PriceChange
+	for _, c := range due {
+		tx, err := tools.DB.Begin()
+		if err != nil {
+			return applied, err
+		}
+		if err := applyPrice(tx, c.ProductID, c.Price, c.EffectiveFrom, c.ChangedBy, c.Reason, c.ID); err != nil {
+			tx.Rollback()
+			return applied, err
+		}
+		if err := tx.Commit(); err != nil {
+			return applied, err
+		}
+		c.Status = priceApplied
+		applied = append(applied, c)
+	}
+	return applied, nil
+}
+
+// StartPriceScheduler applies due scheduled price changes right away and then every
+// interval, announcing each one as product.price_changed.
+func StartPriceScheduler(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			applied, err := applyDuePriceChanges(time.Now())
+			if err != nil {
+				log.Errorf("price scheduler: %v", err)
+			}
+			for _, c := range applied {
+				tools.SSE.Broadcast(tools.Event{
+					Type: "product.price_changed",
+					Data: map[string]any{
+						"productId":     c.ProductID,
+						"price":         c.Price,
+						"priceChangeId": c.ID,
+						"effectiveFrom": c.EffectiveFrom,
+					},
+					Time: time.Now(),
+				})
+			}
+			<-ticker.C
+		}
+	}()
+}
+
+// ---------- History (GET /api/products/{id}/price-history?status=&page=&pageSize=) ----------
+// Newest first; scheduled changes sort ahead of the current price.
+func getPriceHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := atoiParam(chi.URLParam(r, "id"))
+	if err != nil {
+		tools.HandleBadRequest(w, err)
+		return
+	}
+	var exists int
+	if err := tools.DB.QueryRow(`SELECT COUNT(*) FROM products WHERE id = ?`, id).Scan(&exists); err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	if exists == 0 {
+		http.Error(w, "Product not found", http.StatusNotFound)
+		return
+	}
+
+	where := "h.product_id = ?"
+	args := []any{id}
+	switch status := r.URL.Query().Get("status"); status {
+	case "":
+	case priceScheduled, priceApplied, priceCancelled:
+		where += " AND h.status = ?"
+		args = append(args, status)
+	default:
+		tools.HandleBadRequest(w, errors.New("status must be scheduled, applied or cancelled"))
+		return
+	}
+
+	page, pageSize, offset := parsePage(r)
+	var total int
+	if err := tools.DB.QueryRow(`SELECT COUNT(*) FROM product_price_history h WHERE `+where, args...).Scan(&total); err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	rows, err := tools.DB.Query(priceChangeSelect+`
+		WHERE `+where+`
+		ORDER BY h.effective_from DESC, h.id DESC
+		LIMIT ? OFFSET ?`, append(args, pageSize, offset)...)
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	defer rows.Close()
+
+	out := []models.PriceChange{}
+	for rows.Next() {
+		c, err := scanPriceChange(rows)
+		if err != nil {
+			tools.HandleInternalServerError(w, err)
+			return
+		}
+		out = append(out, c)
+	}
+	if err := rows.Err(); err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"data":       out,
+		"pagination": paginationMeta(page, pageSize, total),
+	})
+}
+
+// ---------- Change (POST /api/products/{id}/price-changes) ----------
+// body: { "price": 12.5, "effectiveFrom": "2026-01-01", "userId": 1, "reason": "supplier increase" }
+// A future effectiveFrom schedules the change; otherwise it applies immediately.
+func createPriceChangeHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := atoiParam(chi.URLParam(r, "id"))
+	if err != nil {
+		tools.HandleBadRequest(w, err)
+		return
+	}
+	var in priceChangeIn
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		tools.HandleBadRequest(w, errors.New("invalid request"))
+		return
+	}
+	if in.Price <= 0 {
+		tools.HandleBadRequest(w, errors.New("price must be positive"))
+		return
+	}
+	now := time.Now().UTC()
+	effective := now
+	if strings.TrimSpace(in.EffectiveFrom) != "" {
+		if effective, err = parseEffectiveFrom(in.EffectiveFrom); err != nil {
+			tools.HandleBadRequest(w, err)
+			return
+		}
+	}
+	scheduled := effective.After(now)
+	if !scheduled {
+		effective = now
+	}
+	effectiveFrom := effective.Format(time.RFC3339)
+	reason := strings.TrimSpace(in.Reason)
+
+	tx, err := tools.DB.Begin()
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	defer tx.Rollback()
+
+	var exists int
+	if err := tx.QueryRow(`SELECT COUNT(*) FROM products WHERE id = ?`, id).Scan(&exists); err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	if exists == 0 {
+		http.Error(w, "Product not found", http.StatusNotFound)
+		return
+	}
+	if err := checkUser(tx, in.UserID); err != nil {
+		writeFieldError(w, err)
+		return
+	}
+
+	var changeID int64
+	if scheduled {
+		var clash int
+		if err := tx.QueryRow(
+			`SELECT COUNT(*) FROM product_price_history WHERE product_id = ? AND status = ? AND effective_from = ?`,
+			id, priceScheduled, effectiveFrom,
+		).Scan(&clash); err != nil {
+			tools.HandleInternalServerError(w, err)
+			return
+		}
+		if clash > 0 {
+			http.Error(w, "a price change is already scheduled for "+effectiveFrom, http.StatusConflict)
+			return
+		}
+		res, err := tx.Exec(`
+			INSERT INTO product_price_history
+				(product_id, price, effective_from, status, changed_by, reason, created_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?)`,
+			id, in.Price, effectiveFrom, priceScheduled, in.UserID, reason, now.Format(time.RFC3339),
+		)
+		if err != nil {
+			tools.HandleInternalServerError(w, err)
+			return
+		}
+		changeID, _ = res.LastInsertId()
+	} else {
+		if err := applyPrice(tx, id, in.Price, effectiveFrom, in.UserID, reason, 0); err != nil {
+			tools.HandleInternalServerError(w, err)
+			return
+		}
+		if err := tx.QueryRow(
+			`SELECT id FROM product_price_history WHERE product_id = ? AND status = ? AND effective_to IS NULL`,
+			id, priceApplied,
+		).Scan(&changeID); err != nil {
+			tools.HandleInternalServerError(w, err)
+			return
+		}
+	}
+
+	c, err := scanPriceChange(tx.QueryRow(priceChangeSelect+` WHERE h.id = ?`, changeID))
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	if !scheduled {
+		tools.SSE.Broadcast(tools.Event{
+			Type: "product.price_changed",
+			Data: map[string]any{"productId": id, "price": in.Price, "priceChangeId": c.ID, "effectiveFrom": effectiveFrom},
+			Time: time.Now(),
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(c)
+}
+
+// ---------- Cancel (DELETE /api/products/{id}/price-changes/{changeId}) ----------
+// Only scheduled changes can be cancelled; applied ones are history.
+func cancelPriceChangeHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := atoiParam(chi.URLParam(r, "id"))
+	if err != nil {
+		tools.HandleBadRequest(w, err)
+		return
+	}
+	changeID, err := atoiParam(chi.URLParam(r, "changeId"))
+	if err != nil {
+		tools.HandleBadRequest(w, err)
+		return
+	}
+
+	var status string
+	err = tools.DB.QueryRow(
+		`SELECT status FROM product_price_history WHERE id = ? AND product_id = ?`, changeID, id,
+	).Scan(&status)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Price change not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	if status != priceScheduled {
+		http.Error(w, "only scheduled price changes can be cancelled; this one is "+status, http.StatusConflict)
+		return
+	}
+	// status is re-checked so a change the scheduler just applied isn't cancelled
+	res, err := tools.DB.Exec(
+		`UPDATE product_price_history SET status = ? WHERE id = ? AND status = ?`,
+		priceCancelled, changeID, priceScheduled,
+	)
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		http.Error(w, "price change was applied before it could be cancelled", http.StatusConflict)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
 	log "github.com/sirupsen/logrus"
 
@@ -29,20 +30,103 @@ func createProductHandler(w http.ResponseWriter, r *http.Request) {
 		tools.HandleBadRequest(w, errors.New("name and price are required"))
 		return
 	}
+	if err := checkCatalogFields(tools.DB, 0, product.SKU, product.Barcode, product.CategoryID); err != nil {
+		writeFieldError(w, err)
+		return
+	}
+
+	tx, err := tools.DB.Begin()
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	defer tx.Rollback()
 
 	// stock field kept for legacy compatibility; real stock is derived from warehouse_inventory.
-	_, err := tools.DB.Exec(
-		"INSERT INTO products (name, price, stock) VALUES (?, ?, COALESCE(?, 0))",
-		product.Name, product.Price, product.Stock,
+	res, err := tx.Exec(
+		`INSERT INTO products (name, price, stock, serial_tracked, sku, barcode, description, category_id)
+		 VALUES (?, ?, COALESCE(?, 0), ?, ?, ?, ?, ?)`,
+		product.Name, product.Price, product.Stock, product.SerialTracked,
+		nullableCode(product.SKU), nullableCode(product.Barcode), strings.TrimSpace(product.Description),
+		nullableCategory(product.CategoryID),
 	)
 	if err != nil {
 		tools.HandleInternalServerError(w, err)
 		return
 	}
+	id, _ := res.LastInsertId()
+	if err := recordPrice(tx, int(id), product.Price, time.Now().UTC().Format(time.RFC3339), nil, "initial price", 0); err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
 }
 
 // -------------------- Read (List/Search) --------------------
 
+type productRow struct {
+	ID              int                     `json:"id"`
+	Name            string                  `json:"name"`
+	Price           float64                 `json:"price"`
+	Stock           int                     `json:"stock"`           // legacy stock column
+	TotalStock      int                     `json:"totalStock"`      // derived from warehouse_inventory, excluding expired lots; complete kits for a kit
+	WarehousesCount int                     `json:"warehousesCount"` // number of warehouses with qty > 0
+	SerialTracked   bool                    `json:"serialTracked"`
+	ParentID        *int                    `json:"parentId,omitempty"`
+	SKU             string                  `json:"sku,omitempty"`
+	Barcode         string                  `json:"barcode,omitempty"`
+	Description     string                  `json:"description,omitempty"`
+	CategoryID      *int                    `json:"categoryId,omitempty"`
+	CategoryPath    string                  `json:"categoryPath,omitempty"`
+	IsKit           bool                    `json:"isKit"`
+	KitComponents   []models.KitComponent   `json:"kitComponents,omitempty"`
+	Attributes      map[string]string       `json:"attributes,omitempty"`
+	PriceOverride   *float64                `json:"priceOverride,omitempty"`
+	VariantCount    int                     `json:"variantCount"`
+	Variants        []models.ProductVariant `json:"variants,omitempty"`
+	DeletedAt       *string                 `json:"deletedAt,omitempty"`
+	ImageURL        string                  `json:"imageUrl,omitempty"` // primary image
+	ThumbnailURL    string                  `json:"thumbnailUrl,omitempty"`
+	Images          []models.Attachment     `json:"images,omitempty"`
+}
+
+// productRowColumns matches scanProductRow; it expects products as p and an inv CTE.
+const productRowColumns = `p.id, p.name, p.price, p.stock,
+		       COALESCE(inv.total_stock, 0) AS total_stock,
+		       COALESCE(inv.warehouses_count, 0) AS warehouses_count,
+		       p.serial_tracked, p.parent_id, COALESCE(p.sku, ''), p.attributes, p.price_override,
+		       (SELECT COUNT(*) FROM products v WHERE v.parent_id = p.id) AS variant_count,
+		       COALESCE(p.barcode, ''), COALESCE(p.description, ''), p.category_id,
+		       EXISTS (SELECT 1 FROM kit_components kc WHERE kc.kit_id = p.id) AS is_kit,
+		       p.deleted_at,
+		       (SELECT a.id FROM attachments a WHERE a.owner_type = 'product' AND a.owner_id = p.id
+		         ORDER BY a.position, a.id LIMIT 1) AS image_id`
+
+func scanProductRow(row rowScanner) (productRow, error) {
+	var pr productRow
+	var attrs sql.NullString
+	var imageID sql.NullInt64
+	if err := row.Scan(&pr.ID, &pr.Name, &pr.Price, &pr.Stock, &pr.TotalStock, &pr.WarehousesCount,
+		&pr.SerialTracked, &pr.ParentID, &pr.SKU, &attrs, &pr.PriceOverride, &pr.VariantCount,
+		&pr.Barcode, &pr.Description, &pr.CategoryID, &pr.IsKit, &pr.DeletedAt, &imageID); err != nil {
+		return pr, err
+	}
+	if imageID.Valid {
+		// product images always have a thumbnail
+		pr.ImageURL = attachmentURL(int(imageID.Int64))
+		pr.ThumbnailURL = pr.ImageURL + "/thumbnail"
+	}
+	if attrs.Valid && attrs.String != "" {
+		if err := json.Unmarshal([]byte(attrs.String), &pr.Attributes); err != nil {
+			return pr, err
+		}
+	}
+	return pr, nil
+}
+
 // getProductsHandler gets a list of products with search/pagination and derived totals
 func getProductsHandler(w http.ResponseWriter, r *http.Request) {
 	search := strings.TrimSpace(r.URL.Query().Get("search"))
@@ -62,19 +146,49 @@ func getProductsHandler(w http.ResponseWriter, r *http.Request) {
 	}
 	offset := (page - 1) * pageSize
 
-	// Count products (search applies to product name)
-	var totalCount int
-	var countQuery string
-	var countArgs []interface{}
+	// ?variants=group (default) lists top-level products with their variants nested
+	// and stock rolled up; ?variants=expand lists sellable rows: standalone products
+	// and variants, without the parents that only hold variants.
+	mode := r.URL.Query().Get("variants")
+	var scope, invKey string
+	switch mode {
+	case "", "group":
+		mode = "group"
+		scope = "p.parent_id IS NULL"
+		invKey = "COALESCE(pr.parent_id, a.product_id)"
+	case "expand":
+		scope = "NOT EXISTS (SELECT 1 FROM products v WHERE v.parent_id = p.id)"
+		invKey = "a.product_id"
+	default:
+		tools.HandleBadRequest(w, errors.New("variants must be group or expand"))
+		return
+	}
+
+	// Archived products are hidden unless ?archived=true
+	scope += " AND " + archivedScope(r, "p.deleted_at")
+
+	// Search applies to name, SKU and barcode
+	var filterArgs []interface{}
 	if search != "" {
 		likeQuery := "%" + strings.ToLower(search) + "%"
-		countQuery = "SELECT COUNT(*) FROM products WHERE LOWER(name) LIKE ?"
-		countArgs = []interface{}{likeQuery}
-	} else {
-		countQuery = "SELECT COUNT(*) FROM products"
-		countArgs = []interface{}{}
+		scope += " AND (LOWER(p.name) LIKE ? OR LOWER(COALESCE(p.sku, '')) LIKE ? OR COALESCE(p.barcode, '') LIKE ?)"
+		filterArgs = append(filterArgs, likeQuery, likeQuery, likeQuery)
+	}
+	// ?categoryId= covers the whole subtree; variants follow their parent's category
+	if c := r.URL.Query().Get("categoryId"); c != "" {
+		categoryID, err := strconv.Atoi(c)
+		if err != nil {
+			tools.HandleBadRequest(w, errors.New("invalid categoryId"))
+			return
+		}
+		scope += ` AND COALESCE(p.category_id, (SELECT pp.category_id FROM products pp WHERE pp.id = p.parent_id))
+			IN (` + categorySubtree + `)`
+		filterArgs = append(filterArgs, categoryID)
 	}
-	if err := tools.DB.QueryRow(countQuery, countArgs...).Scan(&totalCount); err != nil {
+
+	var totalCount int
+	countQuery := "SELECT COUNT(*) FROM products p WHERE " + scope
+	if err := tools.DB.QueryRow(countQuery, filterArgs...).Scan(&totalCount); err != nil {
 		tools.HandleInternalServerError(w, err)
 		return
 	}
@@ -84,46 +198,23 @@ func getProductsHandler(w http.ResponseWriter, r *http.Request) {
 	hasPrev := page > 1
 
 	// Data query: include legacy p.stock AND derived totals from warehouse_inventory
-	var rows *sql.Rows
-	var dataQuery string
-	var args []interface{}
-	if search != "" {
-		likeQuery := "%" + strings.ToLower(search) + "%"
-		dataQuery = `
-		WITH inv AS (
-			SELECT product_id,
-			       SUM(qty) AS total_stock,
-			       SUM(CASE WHEN qty > 0 THEN 1 ELSE 0 END) AS warehouses_count
-			FROM warehouse_inventory
-			GROUP BY product_id
-		)
-		SELECT p.id, p.name, p.price, p.stock,
-		       COALESCE(inv.total_stock, 0) AS total_stock,
-		       COALESCE(inv.warehouses_count, 0) AS warehouses_count
-		FROM products p
-		LEFT JOIN inv ON inv.product_id = p.id
-		WHERE LOWER(p.name) LIKE ?
-		ORDER BY p.id
-		LIMIT ? OFFSET ?`
-		args = []interface{}{likeQuery, pageSize, offset}
-	} else {
-		dataQuery = `
+	inv := `
 		WITH inv AS (
-			SELECT product_id,
-			       SUM(qty) AS total_stock,
-			       SUM(CASE WHEN qty > 0 THEN 1 ELSE 0 END) AS warehouses_count
-			FROM warehouse_inventory
-			GROUP BY product_id
-		)
-		SELECT p.id, p.name, p.price, p.stock,
-		       COALESCE(inv.total_stock, 0) AS total_stock,
-		       COALESCE(inv.warehouses_count, 0) AS warehouses_count
+			SELECT ` + invKey + ` AS product_id,
+			       SUM(a.available_qty) AS total_stock,
+			       COUNT(DISTINCT CASE WHEN a.available_qty > 0 THEN a.warehouse_id END) AS warehouses_count
+			FROM product_inventory_available a
+			JOIN products pr ON pr.id = a.product_id
+			GROUP BY 1
+		)`
+	dataQuery := inv + `
+		SELECT ` + productRowColumns + `
 		FROM products p
 		LEFT JOIN inv ON inv.product_id = p.id
+		WHERE ` + scope + `
 		ORDER BY p.id
 		LIMIT ? OFFSET ?`
-		args = []interface{}{pageSize, offset}
-	}
+	args := append(filterArgs, pageSize, offset)
 	rows, err := tools.DB.Query(dataQuery, args...)
 	if err != nil {
 		tools.HandleInternalServerError(w, err)
@@ -131,23 +222,33 @@ func getProductsHandler(w http.ResponseWriter, r *http.Request) {
 	}
 	defer rows.Close()
 
-	type ProductRow struct {
-		ID              int     `json:"id"`
-		Name            string  `json:"name"`
-		Price           float64 `json:"price"`
-		Stock           int     `json:"stock"`           // legacy stock column
-		TotalStock      int     `json:"totalStock"`      // derived from warehouse_inventory
-		WarehousesCount int     `json:"warehousesCount"` // number of warehouses with qty > 0
-	}
-	var items []ProductRow
+	var items []productRow
+	var parentIDs []int
 	for rows.Next() {
-		var pr ProductRow
-		if err := rows.Scan(&pr.ID, &pr.Name, &pr.Price, &pr.Stock, &pr.TotalStock, &pr.WarehousesCount); err != nil {
+		pr, err := scanProductRow(rows)
+		if err != nil {
 			tools.HandleInternalServerError(w, err)
 			return
 		}
+		if pr.VariantCount > 0 {
+			parentIDs = append(parentIDs, pr.ID)
+		}
 		items = append(items, pr)
 	}
+	if err := rows.Err(); err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	if mode == "group" {
+		variants, err := loadVariants(tools.DB, parentIDs...)
+		if err != nil {
+			tools.HandleInternalServerError(w, err)
+			return
+		}
+		for i := range items {
+			items[i].Variants = variants[items[i].ID]
+		}
+	}
 
 	resp := map[string]interface{}{
 		"data": items,
@@ -174,35 +275,39 @@ func searchProductsHandler(w http.ResponseWriter, r *http.Request) {
 
 // getProductByIdHandler gets a single product and includes derived totals
 func getProductByIdHandler(w http.ResponseWriter, r *http.Request) {
-	id := chi.URLParam(r, "id")
-	type ProductOut struct {
-		ID              int     `json:"id"`
-		Name            string  `json:"name"`
-		Price           float64 `json:"price"`
-		// legacy column; not authoritative anymore
-		Stock           int     `json:"stock"`
-		TotalStock      int     `json:"totalStock"`
-		WarehousesCount int     `json:"warehousesCount"`
+	id, err := atoiParam(chi.URLParam(r, "id"))
+	if err != nil {
+		tools.HandleBadRequest(w, err)
+		return
 	}
+	writeProductDetail(w, id)
+}
 
+// writeProductDetail sends one product with variant definitions, variants, kit
+// components, images and category path.
+func writeProductDetail(w http.ResponseWriter, id int) {
+	// A parent's totals include its variants' stock
 	row := tools.DB.QueryRow(`
 		WITH inv AS (
-			SELECT product_id,
-			       SUM(qty) AS total_stock,
-			       SUM(CASE WHEN qty > 0 THEN 1 ELSE 0 END) AS warehouses_count
-			FROM warehouse_inventory
-			WHERE product_id = ?
-			GROUP BY product_id
+			SELECT COALESCE(pr.parent_id, a.product_id) AS product_id,
+			       SUM(a.available_qty) AS total_stock,
+			       COUNT(DISTINCT CASE WHEN a.available_qty > 0 THEN a.warehouse_id END) AS warehouses_count
+			FROM product_inventory_available a
+			JOIN products pr ON pr.id = a.product_id
+			WHERE a.product_id = ? OR pr.parent_id = ?
+			GROUP BY 1
 		)
-		SELECT p.id, p.name, p.price, p.stock,
-		       COALESCE(inv.total_stock, 0) AS total_stock,
-		       COALESCE(inv.warehouses_count, 0) AS warehouses_count
+		SELECT `+productRowColumns+`
 		FROM products p
 		LEFT JOIN inv ON inv.product_id = p.id
-		WHERE p.id = ?`, id, id)
+		WHERE p.id = ?`, id, id, id)
 
-	var out ProductOut
-	if err := row.Scan(&out.ID, &out.Name, &out.Price, &out.Stock, &out.TotalStock, &out.WarehousesCount); err != nil {
+	var out struct {
+		productRow
+		VariantAttributes map[string][]string `json:"variantAttributes,omitempty"`
+	}
+	pr, err := scanProductRow(row)
+	if err != nil {
 		if err == sql.ErrNoRows {
 			http.Error(w, "Product not found", http.StatusNotFound)
 			return
@@ -210,13 +315,53 @@ func getProductByIdHandler(w http.ResponseWriter, r *http.Request) {
 		tools.HandleInternalServerError(w, err)
 		return
 	}
+	out.productRow = pr
+	var defs sql.NullString
+	if err := tools.DB.QueryRow(`SELECT variant_attributes FROM products WHERE id = ?`, pr.ID).Scan(&defs); err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	if defs.Valid && defs.String != "" {
+		if err := json.Unmarshal([]byte(defs.String), &out.VariantAttributes); err != nil {
+			tools.HandleInternalServerError(w, err)
+			return
+		}
+	}
+	if pr.VariantCount > 0 {
+		variants, err := loadVariants(tools.DB, pr.ID)
+		if err != nil {
+			tools.HandleInternalServerError(w, err)
+			return
+		}
+		out.Variants = variants[pr.ID]
+	}
+	if pr.IsKit {
+		if out.KitComponents, err = loadKitComponents(tools.DB, pr.ID); err != nil {
+			tools.HandleInternalServerError(w, err)
+			return
+		}
+	}
+	if out.Images, err = loadAttachments(tools.DB, productImages.kind, pr.ID); err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	if pr.CategoryID != nil {
+		byID, _, err := loadCategoryTree(tools.DB)
+		if err != nil {
+			tools.HandleInternalServerError(w, err)
+			return
+		}
+		if c := byID[*pr.CategoryID]; c != nil {
+			out.CategoryPath = c.Path
+		}
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	_ = json.NewEncoder(w).Encode(out)
 }
 
 // getProductInventoryHandler returns a per-warehouse breakdown for a product
-// Response: [{ "warehouse_id": 1, "warehouse_name": "A", "qty": 10 }, ...]
+// Response: [{ "warehouse_id": 1, "warehouse_name": "A", "qty": 10, "expired_qty": 0, "available_qty": 10 }, ...]
 func getProductInventoryHandler(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "id")
 	if id == "" {
@@ -225,9 +370,10 @@ func getProductInventoryHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	rows, err := tools.DB.Query(`
-		SELECT w.id AS warehouse_id, w.name AS warehouse_name, COALESCE(i.qty, 0) AS qty
+		SELECT w.id AS warehouse_id, w.name AS warehouse_name, COALESCE(i.qty, 0) AS qty,
+		       COALESCE(i.expired_qty, 0) AS expired_qty, COALESCE(i.available_qty, 0) AS available_qty
 		FROM warehouses w
-		LEFT JOIN warehouse_inventory i
+		LEFT JOIN product_inventory_available i
 		  ON i.warehouse_id = w.id AND i.product_id = ?
 		ORDER BY w.id ASC
 	`, id)
@@ -241,11 +387,13 @@ func getProductInventoryHandler(w http.ResponseWriter, r *http.Request) {
 		WarehouseID   int    `json:"warehouse_id"`
 		WarehouseName string `json:"warehouse_name"`
 		Qty           int    `json:"qty"`
+		ExpiredQty    int    `json:"expired_qty"`
+		AvailableQty  int    `json:"available_qty"`
 	}
 	var out []rowT
 	for rows.Next() {
 		var r rowT
-		if err := rows.Scan(&r.WarehouseID, &r.WarehouseName, &r.Qty); err != nil {
+		if err := rows.Scan(&r.WarehouseID, &r.WarehouseName, &r.Qty, &r.ExpiredQty, &r.AvailableQty); err != nil {
 			tools.HandleInternalServerError(w, err)
 			return
 		}
@@ -260,8 +408,23 @@ func getProductInventoryHandler(w http.ResponseWriter, r *http.Request) {
 
 // updateProductHandler updates an existing product's information
 func updateProductHandler(w http.ResponseWriter, r *http.Request) {
-	id := chi.URLParam(r, "id")
-	var p models.Product
+	id, err := atoiParam(chi.URLParam(r, "id"))
+	if err != nil {
+		tools.HandleBadRequest(w, err)
+		return
+	}
+	// serialTracked and the catalogue fields are optional so clients that only send
+	// name/price don't clear them; send "" (or categoryId 0) to clear explicitly.
+	// A new price is recorded in the price history against userId.
+	var p struct {
+		models.Product
+		UserID        *int    `json:"userId"`
+		SerialTracked *bool   `json:"serialTracked"`
+		SKU           *string `json:"sku"`
+		Barcode       *string `json:"barcode"`
+		Description   *string `json:"description"`
+		CategoryID    *int    `json:"categoryId"`
+	}
 	if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
 		tools.HandleBadRequest(w, errors.New("invalid request"))
 		return
@@ -270,25 +433,79 @@ func updateProductHandler(w http.ResponseWriter, r *http.Request) {
 		tools.HandleBadRequest(w, errors.New("name and price are required"))
 		return
 	}
-	_, err := tools.DB.Exec(
-		"UPDATE products SET name=?, price=? WHERE id=?",
-		p.Name, p.Price, id,
-	)
+	var sku, barcode string
+	if p.SKU != nil {
+		sku = *p.SKU
+	}
+	if p.Barcode != nil {
+		barcode = *p.Barcode
+	}
+	tx, err := tools.DB.Begin()
 	if err != nil {
 		tools.HandleInternalServerError(w, err)
 		return
 	}
-	w.WriteHeader(http.StatusNoContent)
-}
+	defer tx.Rollback()
 
-// deleteProductHandler deletes a product from the database
-func deleteProductHandler(w http.ResponseWriter, r *http.Request) {
-	id := chi.URLParam(r, "id")
-	_, err := tools.DB.Exec("DELETE FROM products WHERE id = ?", id)
+	var oldPrice float64
+	if err := tx.QueryRow(`SELECT price FROM products WHERE id = ?`, id).Scan(&oldPrice); err == sql.ErrNoRows {
+		http.Error(w, "Product not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	if err := checkCatalogFields(tx, id, sku, barcode, p.CategoryID); err != nil {
+		writeFieldError(w, err)
+		return
+	}
+	if err := checkUser(tx, p.UserID); err != nil {
+		writeFieldError(w, err)
+		return
+	}
+
+	// CASE keeps a column when its field was omitted and lets "" store NULL
+	_, err = tx.Exec(`
+		UPDATE products SET name=?, serial_tracked=COALESCE(?, serial_tracked),
+		       sku = CASE WHEN ? THEN ? ELSE sku END,
+		       barcode = CASE WHEN ? THEN ? ELSE barcode END,
+		       description = COALESCE(?, description),
+		       category_id = CASE WHEN ? THEN ? ELSE category_id END
+		WHERE id=?`,
+		p.Name, p.SerialTracked,
+		p.SKU != nil, nullableCode(sku),
+		p.Barcode != nil, nullableCode(barcode),
+		p.Description,
+		p.CategoryID != nil, nullableCategory(p.CategoryID),
+		id,
+	)
 	if err != nil {
 		tools.HandleInternalServerError(w, err)
 		return
 	}
+	// Variants without their own price follow the parent (applyPrice carries it over)
+	if p.Price != oldPrice {
+		if err := applyPrice(tx, id, p.Price, time.Now().UTC().Format(time.RFC3339), p.UserID, "", 0); err != nil {
+			tools.HandleInternalServerError(w, err)
+			return
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// deleteProductHandler archives a product and its variants; ?hard=true deletes it
+// when no orders, stock, purchase orders, kits or variants reference it.
+func deleteProductHandler(w http.ResponseWriter, r *http.Request) {
+	deleteArchivable(w, r, productArchive)
+}
+
+// restoreProductHandler un-archives a product and the variants archived with it.
+func restoreProductHandler(w http.ResponseWriter, r *http.Request) {
+	restoreArchivable(w, r, productArchive)
 }
 
 // -------------------- Search (simple for dropdowns) --------------------
@@ -303,16 +520,20 @@ func searchProductsSimpleHandler(w http.ResponseWriter, r *http.Request) {
 
 	if query != "" {
 		like := "%" + strings.ToLower(query) + "%"
+		// Exact code matches (scanned SKU or barcode) sort first
 		rows, err = tools.DB.Query(
-			`SELECT id, name, price, stock
+			`SELECT id, name, price, stock, COALESCE(sku, ''), COALESCE(barcode, '')
 			   FROM products
-			  WHERE LOWER(name) LIKE ?
-			  ORDER BY id`, like,
+			  WHERE deleted_at IS NULL
+			    AND (LOWER(name) LIKE ? OR LOWER(COALESCE(sku, '')) LIKE ? OR COALESCE(barcode, '') LIKE ?)
+			  ORDER BY (sku = ? COLLATE NOCASE OR barcode = ?) DESC, id`,
+			like, like, like, query, query,
 		)
 	} else {
 		rows, err = tools.DB.Query(
-			`SELECT id, name, price, stock
+			`SELECT id, name, price, stock, COALESCE(sku, ''), COALESCE(barcode, '')
 			   FROM products
+			  WHERE deleted_at IS NULL
 			  ORDER BY id`,
 		)
 	}
@@ -328,7 +549,7 @@ func searchProductsSimpleHandler(w http.ResponseWriter, r *http.Request) {
 	var products []models.Product
 	for rows.Next() {
 		var p models.Product
-		if err := rows.Scan(&p.ID, &p.Name, &p.Price, &p.Stock); err != nil {
+		if err := rows.Scan(&p.ID, &p.Name, &p.Price, &p.Stock, &p.SKU, &p.Barcode); err != nil {
 			log.Errorf("searchProductsSimple scan error: %v", err)
 			tools.HandleInternalServerError(w, err)
 			return
@@ -350,7 +571,7 @@ func searchProductsSimpleHandler(w http.ResponseWriter, r *http.Request) {
 // getTotalProductsHandler returns the total number of products
 func getTotalProductsHandler(w http.ResponseWriter, r *http.Request) {
 	var count int
-	err := tools.DB.QueryRow("SELECT COUNT(*) FROM products").Scan(&count)
+	err := tools.DB.QueryRow("SELECT COUNT(*) FROM products WHERE deleted_at IS NULL").Scan(&count)
 	if err != nil {
 		tools.HandleInternalServerError(w, err)
 		return
@@ -362,7 +583,7 @@ func getTotalProductsHandler(w http.ResponseWriter, r *http.Request) {
 // getRecentProductsHandler returns the 3 most recently added products
 func getRecentProductsHandler(w http.ResponseWriter, r *http.Request) {
 	rows, err := tools.DB.Query(
-		"SELECT id, name, price, stock FROM products ORDER BY id DESC LIMIT 3",
+		"SELECT id, name, price, stock FROM products WHERE deleted_at IS NULL ORDER BY id DESC LIMIT 3",
 	)
 	if err != nil {
 		tools.HandleInternalServerError(w, err)
@@ -437,15 +658,15 @@ func getLowStockProductsHandler(w http.ResponseWriter, r *http.Request) {
 
 	rows, err := tools.DB.Query(`
 		WITH inv AS (
-			SELECT product_id, SUM(qty) AS total_stock
-			FROM warehouse_inventory
+			SELECT product_id, SUM(available_qty) AS total_stock
+			FROM product_inventory_available
 			GROUP BY product_id
 		)
 		SELECT p.id, p.name, p.price,
 		       COALESCE(inv.total_stock, 0) AS total_stock
 		FROM products p
 		LEFT JOIN inv ON inv.product_id = p.id
-		WHERE COALESCE(inv.total_stock, 0) <= ?
+		WHERE p.deleted_at IS NULL AND COALESCE(inv.total_stock, 0) <= ?
 		ORDER BY total_stock ASC, p.id ASC
 		LIMIT ?`, threshold, limit)
 	if err != nil {
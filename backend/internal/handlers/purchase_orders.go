@@ -0,0 +1,582 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/MananKakkar1/SalesBoard/backend/internal/models"
+	"github.com/MananKakkar1/SalesBoard/backend/internal/tools"
+	"github.com/go-chi/chi"
+)
+
+// Purchase order lifecycle
+const (
+	poStatusDraft             = "draft"
+	poStatusOrdered           = "ordered"
+	poStatusPartiallyReceived = "partially_received"
+	poStatusReceived          = "received"
+	poStatusCancelled         = "cancelled"
+)
+
+// ---------- Input DTOs ----------
+
+type poLineIn struct {
+	ProductID int      `json:"productId"`
+	Qty       int      `json:"qty"`
+	UnitCost  *float64 `json:"unitCost"` // optional; defaults to supplier_products.supplier_cost
+}
+
+type createPurchaseOrderIn struct {
+	SupplierID  int        `json:"supplierId"`
+	WarehouseID int        `json:"warehouseId"`
+	Notes       string     `json:"notes"`
+	ExpectedAt  string     `json:"expectedAt"`
+	Lines       []poLineIn `json:"lines"`
+}
+
+// lotNumber/expiresAt are optional; received units without a lot number stay unlotted.
+// serials are required (one per unit) for serial-tracked products.
+type poReceiveLineIn struct {
+	LineID    int      `json:"lineId"`
+	Qty       int      `json:"qty"`
+	LotNumber string   `json:"lotNumber"`
+	ExpiresAt string   `json:"expiresAt"`
+	Serials   []string `json:"serials"`
+}
+
+type poReceiveIn struct {
+	Lines []poReceiveLineIn `json:"lines"`
+}
+
+// ---------- Create (POST /api/purchase-orders) ----------
+func createPurchaseOrderHandler(w http.ResponseWriter, r *http.Request) {
+	var in createPurchaseOrderIn
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		tools.HandleBadRequest(w, errors.New("invalid request"))
+		return
+	}
+	if in.SupplierID <= 0 || in.WarehouseID <= 0 || len(in.Lines) == 0 {
+		tools.HandleBadRequest(w, errors.New("supplierId, warehouseId and lines are required"))
+		return
+	}
+	for _, ln := range in.Lines {
+		if ln.ProductID <= 0 || ln.Qty <= 0 || (ln.UnitCost != nil && *ln.UnitCost < 0) {
+			tools.HandleBadRequest(w, errors.New("each line requires productId > 0, qty > 0 and a non-negative unitCost"))
+			return
+		}
+	}
+
+	tx, err := tools.DB.Begin()
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	defer tx.Rollback()
+
+	var exists int
+	if err := tx.QueryRow(`SELECT COUNT(*) FROM suppliers WHERE id = ?`, in.SupplierID).Scan(&exists); err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	if exists == 0 {
+		tools.HandleBadRequest(w, errors.New("supplier does not exist"))
+		return
+	}
+	if err := tx.QueryRow(`SELECT COUNT(*) FROM warehouses WHERE id = ?`, in.WarehouseID).Scan(&exists); err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	if exists == 0 {
+		tools.HandleBadRequest(w, errors.New("warehouse does not exist"))
+		return
+	}
+	if err := ensureActive(tx, warehouseArchive, in.WarehouseID); err != nil {
+		writeFieldError(w, err)
+		return
+	}
+
+	res, err := tx.Exec(
+		`INSERT INTO purchase_orders (supplier_id, warehouse_id, status, notes, expected_at, created_at)
+		 VALUES (?, ?, ?, ?, ?, ?)`,
+		in.SupplierID, in.WarehouseID, poStatusDraft, in.Notes, strings.TrimSpace(in.ExpectedAt),
+		time.Now().UTC().Format(time.RFC3339),
+	)
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	poID, _ := res.LastInsertId()
+
+	for _, ln := range in.Lines {
+		var unitCost float64
+		if ln.UnitCost != nil {
+			unitCost = *ln.UnitCost
+		} else {
+			err := tx.QueryRow(
+				`SELECT supplier_cost FROM supplier_products WHERE supplier_id = ? AND product_id = ?`,
+				in.SupplierID, ln.ProductID,
+			).Scan(&unitCost)
+			if err == sql.ErrNoRows {
+				tools.HandleBadRequest(w, fmt.Errorf("no supplier cost for product %d; pass unitCost", ln.ProductID))
+				return
+			}
+			if err != nil {
+				tools.HandleInternalServerError(w, err)
+				return
+			}
+		}
+
+		var pexists int
+		if err := tx.QueryRow(`SELECT COUNT(*) FROM products WHERE id = ?`, ln.ProductID).Scan(&pexists); err != nil {
+			tools.HandleInternalServerError(w, err)
+			return
+		}
+		if pexists == 0 {
+			tools.HandleBadRequest(w, fmt.Errorf("product %d does not exist", ln.ProductID))
+			return
+		}
+		if err := ensureActive(tx, productArchive, ln.ProductID); err != nil {
+			writeFieldError(w, err)
+			return
+		}
+		// Kits can't be received; order their components instead
+		if err := ensureNotKit(tx, ln.ProductID); err != nil {
+			writeStockError(w, err)
+			return
+		}
+
+		if _, err := tx.Exec(
+			`INSERT INTO purchase_order_lines (purchase_order_id, product_id, qty_ordered, unit_cost)
+			 VALUES (?, ?, ?, ?)`,
+			poID, ln.ProductID, ln.Qty, unitCost,
+		); err != nil {
+			tools.HandleInternalServerError(w, err)
+			return
+		}
+	}
+
+	po, err := loadPurchaseOrder(tx, int(poID))
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(po)
+}
+
+// ---------- List (GET /api/purchase-orders?status=&supplierId=&warehouseId=&page=&pageSize=) ----------
+func getPurchaseOrdersHandler(w http.ResponseWriter, r *http.Request) {
+	page, pageSize, offset := parsePage(r)
+
+	var conds []string
+	var args []interface{}
+	if s := strings.TrimSpace(r.URL.Query().Get("status")); s != "" {
+		conds = append(conds, "po.status = ?")
+		args = append(args, s)
+	}
+	if s := r.URL.Query().Get("supplierId"); s != "" {
+		conds = append(conds, "po.supplier_id = ?")
+		args = append(args, s)
+	}
+	if s := r.URL.Query().Get("warehouseId"); s != "" {
+		conds = append(conds, "po.warehouse_id = ?")
+		args = append(args, s)
+	}
+	where := ""
+	if len(conds) > 0 {
+		where = "WHERE " + strings.Join(conds, " AND ")
+	}
+
+	var totalCount int
+	if err := tools.DB.QueryRow(`SELECT COUNT(*) FROM purchase_orders po `+where, args...).Scan(&totalCount); err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+
+	rows, err := tools.DB.Query(`
+		SELECT po.id, po.supplier_id, po.warehouse_id, po.status, COALESCE(po.notes, ''),
+		       COALESCE(po.expected_at, ''), po.created_at,
+		       COALESCE((SELECT SUM(l.qty_ordered * l.unit_cost)
+		                   FROM purchase_order_lines l
+		                  WHERE l.purchase_order_id = po.id), 0) AS total_cost
+		FROM purchase_orders po `+where+`
+		ORDER BY po.id DESC
+		LIMIT ? OFFSET ?`, append(args, pageSize, offset)...)
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	defer rows.Close()
+
+	list := []models.PurchaseOrder{}
+	for rows.Next() {
+		var po models.PurchaseOrder
+		if err := rows.Scan(&po.ID, &po.SupplierID, &po.WarehouseID, &po.Status, &po.Notes,
+			&po.ExpectedAt, &po.CreatedAt, &po.TotalCost); err != nil {
+			tools.HandleInternalServerError(w, err)
+			return
+		}
+		list = append(list, po)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"data":       list,
+		"pagination": paginationMeta(page, pageSize, totalCount),
+	})
+}
+
+// ---------- Read one (GET /api/purchase-orders/{id}) ----------
+func getPurchaseOrderByIdHandler(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	poID, err := atoiParam(id)
+	if err != nil {
+		tools.HandleBadRequest(w, err)
+		return
+	}
+
+	tx, err := tools.DB.Begin()
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	defer tx.Rollback()
+
+	po, err := loadPurchaseOrder(tx, poID)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Purchase order not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(po)
+}
+
+// ---------- Status transitions ----------
+
+// POST /api/purchase-orders/{id}/submit  (draft -> ordered)
+func submitPurchaseOrderHandler(w http.ResponseWriter, r *http.Request) {
+	setPurchaseOrderStatus(w, r, []string{poStatusDraft}, poStatusOrdered)
+}
+
+// POST /api/purchase-orders/{id}/cancel  (only before anything was received)
+func cancelPurchaseOrderHandler(w http.ResponseWriter, r *http.Request) {
+	setPurchaseOrderStatus(w, r, []string{poStatusDraft, poStatusOrdered}, poStatusCancelled)
+}
+
+func setPurchaseOrderStatus(w http.ResponseWriter, r *http.Request, from []string, to string) {
+	id := chi.URLParam(r, "id")
+
+	var status string
+	err := tools.DB.QueryRow(`SELECT status FROM purchase_orders WHERE id = ?`, id).Scan(&status)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Purchase order not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+
+	allowed := false
+	for _, s := range from {
+		if s == status {
+			allowed = true
+			break
+		}
+	}
+	if !allowed {
+		http.Error(w, fmt.Sprintf("cannot move purchase order from %s to %s", status, to), http.StatusConflict)
+		return
+	}
+
+	// The status is checked again in the UPDATE so a receive that lands in between wins.
+	args := []any{to, id}
+	for _, s := range from {
+		args = append(args, s)
+	}
+	res, err := tools.DB.Exec(
+		`UPDATE purchase_orders SET status = ? WHERE id = ? AND status IN (?`+strings.Repeat(", ?", len(from)-1)+`)`, args...,
+	)
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		http.Error(w, fmt.Sprintf("purchase order changed while moving it to %s; reload and retry", to), http.StatusConflict)
+		return
+	}
+	getPurchaseOrderByIdHandler(w, r)
+}
+
+// ---------- Receiving (POST /api/purchase-orders/{id}/receive) ----------
+// body: { "lines": [ { "lineId": 3, "qty": 10, "lotNumber": "L-42", "expiresAt": "2026-03-31", "serials": [] }, ... ] }
+// Received quantities are posted into warehouse_inventory of the PO's warehouse and
+// folded into each product's weighted-average cost at the line's unit cost.
+func receivePurchaseOrderHandler(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	poID, err := atoiParam(id)
+	if err != nil {
+		tools.HandleBadRequest(w, err)
+		return
+	}
+
+	var in poReceiveIn
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		tools.HandleBadRequest(w, errors.New("invalid request"))
+		return
+	}
+	if len(in.Lines) == 0 {
+		tools.HandleBadRequest(w, errors.New("no lines to receive"))
+		return
+	}
+
+	tx, err := tools.DB.Begin()
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	defer tx.Rollback()
+
+	var status string
+	var warehouseID int
+	err = tx.QueryRow(`SELECT status, warehouse_id FROM purchase_orders WHERE id = ?`, poID).Scan(&status, &warehouseID)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Purchase order not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	if status != poStatusOrdered && status != poStatusPartiallyReceived {
+		http.Error(w, fmt.Sprintf("cannot receive a %s purchase order", status), http.StatusConflict)
+		return
+	}
+
+	receivedAt := time.Now().UTC().Format(time.RFC3339)
+	var touched []stockKey
+	for _, ln := range in.Lines {
+		if ln.LineID <= 0 || ln.Qty <= 0 {
+			tools.HandleBadRequest(w, errors.New("each line requires lineId > 0 and qty > 0"))
+			return
+		}
+		lotNumber, expiresAt, err := normalizeLot(ln.LotNumber, ln.ExpiresAt)
+		if err != nil {
+			tools.HandleBadRequest(w, fmt.Errorf("line %d: %w", ln.LineID, err))
+			return
+		}
+
+		var productID, ordered, received int
+		var unitCost float64
+		err = tx.QueryRow(`
+			SELECT product_id, qty_ordered, qty_received, unit_cost
+			FROM purchase_order_lines
+			WHERE id = ? AND purchase_order_id = ?`, ln.LineID, poID,
+		).Scan(&productID, &ordered, &received, &unitCost)
+		if err == sql.ErrNoRows {
+			tools.HandleBadRequest(w, fmt.Errorf("line %d is not on this purchase order", ln.LineID))
+			return
+		}
+		if err != nil {
+			tools.HandleInternalServerError(w, err)
+			return
+		}
+		if received+ln.Qty > ordered {
+			tools.HandleBadRequest(w, fmt.Errorf("line %d: receiving %d would exceed ordered qty (%d of %d received)",
+				ln.LineID, ln.Qty, received, ordered))
+			return
+		}
+
+		if _, err := tx.Exec(
+			`UPDATE purchase_order_lines SET qty_received = qty_received + ? WHERE id = ?`,
+			ln.Qty, ln.LineID,
+		); err != nil {
+			tools.HandleInternalServerError(w, err)
+			return
+		}
+		if _, err := tx.Exec(
+			`INSERT INTO purchase_order_receipts (purchase_order_id, line_id, qty, received_at) VALUES (?, ?, ?, ?)`,
+			poID, ln.LineID, ln.Qty, receivedAt,
+		); err != nil {
+			tools.HandleInternalServerError(w, err)
+			return
+		}
+		serials, err := normalizeSerials(ln.Serials)
+		if err != nil {
+			tools.HandleBadRequest(w, fmt.Errorf("line %d: %w", ln.LineID, err))
+			return
+		}
+		tracked, err := isSerialTracked(tx, productID)
+		if err != nil {
+			tools.HandleInternalServerError(w, err)
+			return
+		}
+		if err := checkSerialCount(tracked, true, productID, ln.Qty, serials); err != nil {
+			tools.HandleBadRequest(w, fmt.Errorf("line %d: %w", ln.LineID, err))
+			return
+		}
+
+		// Average cost has to see the on-hand qty before these units arrive
+		if err := updateAverageCost(tx, productID, ln.Qty, unitCost); err != nil {
+			tools.HandleInternalServerError(w, err)
+			return
+		}
+		if lotNumber != "" {
+			err = addWarehouseLotStock(tx, warehouseID, productID, lotNumber, expiresAt, ln.Qty)
+		} else {
+			err = addWarehouseStock(tx, warehouseID, productID, ln.Qty)
+		}
+		if err != nil {
+			writeStockError(w, err)
+			return
+		}
+		if err := registerSerials(tx, stockKey{warehouseID, productID}, serials, poID); err != nil {
+			writeStockError(w, err)
+			return
+		}
+		touched = append(touched, stockKey{warehouseID, productID})
+	}
+
+	// Fully received once every line is complete
+	var open int
+	if err := tx.QueryRow(
+		`SELECT COUNT(*) FROM purchase_order_lines WHERE purchase_order_id = ? AND qty_received < qty_ordered`, poID,
+	).Scan(&open); err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	newStatus := poStatusReceived
+	if open > 0 {
+		newStatus = poStatusPartiallyReceived
+	}
+	if _, err := tx.Exec(`UPDATE purchase_orders SET status = ? WHERE id = ?`, newStatus, poID); err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+
+	filled, err := fillBackorders(tx, touched...)
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	// Receipts clear the reorder flag so the next drop is announced again; filling
+	// backorders can take the stock straight back below it
+	alerts, err := checkReorderPoints(tx, touched...)
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+
+	po, err := loadPurchaseOrder(tx, poID)
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+
+	tools.SSE.Broadcast(tools.Event{
+		Type: "purchase_order.received",
+		Data: map[string]any{"purchaseOrderId": poID, "warehouseId": warehouseID, "status": newStatus},
+		Time: time.Now(),
+	})
+	tools.SSE.Broadcast(tools.Event{
+		Type: "warehouse.inventory_updated",
+		Data: map[string]any{"warehouseId": warehouseID, "count": len(in.Lines)},
+		Time: time.Now(),
+	})
+	broadcastEvents(filled)
+	broadcastEvents(alerts)
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(po)
+}
+
+// GET /api/purchase-orders/{id}/receipts
+func getPurchaseOrderReceiptsHandler(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	rows, err := tools.DB.Query(`
+		SELECT rc.id, rc.line_id, l.product_id, rc.qty, rc.received_at
+		FROM purchase_order_receipts rc
+		JOIN purchase_order_lines l ON l.id = rc.line_id
+		WHERE rc.purchase_order_id = ?
+		ORDER BY rc.id`, id)
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	defer rows.Close()
+
+	type receiptRow struct {
+		ID         int    `json:"id"`
+		LineID     int    `json:"lineId"`
+		ProductID  int    `json:"productId"`
+		Qty        int    `json:"qty"`
+		ReceivedAt string `json:"receivedAt"`
+	}
+	out := []receiptRow{}
+	for rows.Next() {
+		var rc receiptRow
+		if err := rows.Scan(&rc.ID, &rc.LineID, &rc.ProductID, &rc.Qty, &rc.ReceivedAt); err != nil {
+			tools.HandleInternalServerError(w, err)
+			return
+		}
+		out = append(out, rc)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(out)
+}
+
+// ---------- helpers ----------
+
+// loadPurchaseOrder reads a purchase order with its lines. Returns sql.ErrNoRows if missing.
+func loadPurchaseOrder(tx *sql.Tx, id int) (models.PurchaseOrder, error) {
+	var po models.PurchaseOrder
+	err := tx.QueryRow(`
+		SELECT id, supplier_id, warehouse_id, status, COALESCE(notes, ''), COALESCE(expected_at, ''), created_at
+		FROM purchase_orders WHERE id = ?`, id,
+	).Scan(&po.ID, &po.SupplierID, &po.WarehouseID, &po.Status, &po.Notes, &po.ExpectedAt, &po.CreatedAt)
+	if err != nil {
+		return po, err
+	}
+
+	rows, err := tx.Query(`
+		SELECT l.id, l.product_id, p.name, l.qty_ordered, l.qty_received, l.unit_cost
+		FROM purchase_order_lines l
+		JOIN products p ON p.id = l.product_id
+		WHERE l.purchase_order_id = ?
+		ORDER BY l.id`, id)
+	if err != nil {
+		return po, err
+	}
+	defer rows.Close()
+
+	po.Lines = []models.PurchaseOrderLine{}
+	for rows.Next() {
+		var l models.PurchaseOrderLine
+		if err := rows.Scan(&l.ID, &l.ProductID, &l.ProductName, &l.QtyOrdered, &l.QtyReceived, &l.UnitCost); err != nil {
+			return po, err
+		}
+		po.TotalCost += float64(l.QtyOrdered) * l.UnitCost
+		po.Lines = append(po.Lines, l)
+	}
+	return po, rows.Err()
+}
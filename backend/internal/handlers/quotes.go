@@ -0,0 +1,725 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/MananKakkar1/SalesBoard/backend/internal/models"
+	"github.com/MananKakkar1/SalesBoard/backend/internal/tools"
+	"github.com/go-chi/chi"
+)
+
+// Quotes price lines for a customer without touching stock. Each edit saves a new
+// version; accepting the quote turns its current version into an order through
+// createOrderTx, so it gets the same checks and stock deductions as POST /api/orders.
+
+// defaultQuoteValidDays is how long a new quote stays open when validUntil is not given.
+const defaultQuoteValidDays = 30
+
+// ---------- Input DTOs ----------
+
+type quoteLineIn struct {
+	ProductID   int      `json:"productId"`
+	Quantity    int      `json:"quantity"`
+	UnitPrice   *float64 `json:"unitPrice"`   // optional; defaults to the product's current price
+	WarehouseID *int     `json:"warehouseId"` // optional; may also be chosen on accept
+}
+
+type quoteCU struct {
+	CustomerID        int           `json:"customerId"` // create only
+	ValidUntil        string        `json:"validUntil"` // YYYY-MM-DD, last day the quote can be accepted
+	ShippingAddressID *int          `json:"shippingAddressId"`
+	Notes             string        `json:"notes"`
+	Lines             []quoteLineIn `json:"lines"`
+	UserID            *int          `json:"userId"`
+}
+
+type quoteStatusIn struct {
+	UserID *int `json:"userId"`
+}
+
+type acceptQuoteIn struct {
+	OrderID         int  `json:"orderId"`     // optional; defaults to the next order id
+	UserID          *int `json:"userId"`      // optional; defaults to the quote's author
+	WarehouseID     int  `json:"warehouseId"` // fulfils the lines that have no warehouse
+	AllowOverCredit bool `json:"allowOverCredit"`
+	AllowBackorder  bool `json:"allowBackorder"`
+}
+
+// ---------- helpers ----------
+
+const quoteColumns = `q.id, q.customer_id, q.status, q.version, v.valid_until, v.shipping_address_id, v.notes,
+	v.total, q.order_id, q.sent_at, q.accepted_at, q.created_by, q.created_at, q.updated_at
+	FROM quotes q JOIN quote_versions v ON v.quote_id = q.id AND v.version = q.version`
+
+func scanQuote(row rowScanner) (models.Quote, error) {
+	var q models.Quote
+	err := row.Scan(&q.ID, &q.CustomerID, &q.Status, &q.Version, &q.ValidUntil, &q.ShippingAddressID, &q.Notes,
+		&q.Total, &q.OrderID, &q.SentAt, &q.AcceptedAt, &q.CreatedBy, &q.CreatedAt, &q.UpdatedAt)
+	return q, err
+}
+
+// loadQuote returns the quote at its current version, lines included.
+func loadQuote(q queryer, id int) (models.Quote, error) {
+	quote, err := scanQuote(q.QueryRow(`SELECT `+quoteColumns+` WHERE q.id = ?`, id))
+	if err != nil {
+		return quote, err
+	}
+	quote.Lines, err = loadQuoteLines(q, id, quote.Version)
+	return quote, err
+}
+
+func loadQuoteLines(q queryer, quoteID, version int) ([]models.QuoteLine, error) {
+	rows, err := q.Query(`
+		SELECT l.product_id, COALESCE(p.name, ''), l.warehouse_id, l.quantity, l.unit_price
+		FROM quote_lines l
+		LEFT JOIN products p ON p.id = l.product_id
+		WHERE l.quote_id = ? AND l.version = ?
+		ORDER BY l.id`, quoteID, version,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := []models.QuoteLine{}
+	for rows.Next() {
+		var l models.QuoteLine
+		if err := rows.Scan(&l.ProductID, &l.ProductName, &l.WarehouseID, &l.Quantity, &l.UnitPrice); err != nil {
+			return nil, err
+		}
+		l.LineTotal = roundMoney(float64(l.Quantity) * l.UnitPrice)
+		out = append(out, l)
+	}
+	return out, rows.Err()
+}
+
+// expireQuotes marks open quotes past their validUntil as expired. It runs before
+// quotes are read or changed, so the stored status is never stale.
+func expireQuotes(ex execer) error {
+	_, err := ex.Exec(`
+		UPDATE quotes SET status = 'expired', updated_at = ?
+		WHERE status IN ('draft', 'sent')
+		  AND (SELECT valid_until FROM quote_versions v WHERE v.quote_id = quotes.id AND v.version = quotes.version) < ?`,
+		time.Now().UTC().Format(time.RFC3339), time.Now().UTC().Format(dateLayout),
+	)
+	return err
+}
+
+// normalize validates the quote for customerID and fills in default prices.
+func (in *quoteCU) normalize(q queryer, customerID int) error {
+	in.ValidUntil = strings.TrimSpace(in.ValidUntil)
+	if _, err := time.Parse(dateLayout, in.ValidUntil); err != nil {
+		return &invalidFieldError{"validUntil must be YYYY-MM-DD"}
+	}
+	if in.ValidUntil < time.Now().UTC().Format(dateLayout) {
+		return &invalidFieldError{"validUntil cannot be in the past"}
+	}
+	in.Notes = strings.TrimSpace(in.Notes)
+	if len(in.Lines) == 0 {
+		return &invalidFieldError{"lines are required"}
+	}
+	for i := range in.Lines {
+		l := &in.Lines[i]
+		if l.ProductID <= 0 || l.Quantity <= 0 {
+			return &invalidFieldError{"each line requires productId > 0 and quantity > 0"}
+		}
+		var price float64
+		err := q.QueryRow(`SELECT price FROM products WHERE id = ?`, l.ProductID).Scan(&price)
+		if err == sql.ErrNoRows {
+			return &invalidFieldError{fmt.Sprintf("product %d does not exist", l.ProductID)}
+		}
+		if err != nil {
+			return err
+		}
+		if err := ensureActive(q, productArchive, l.ProductID); err != nil {
+			return err
+		}
+		if l.UnitPrice == nil {
+			l.UnitPrice = &price
+		} else if *l.UnitPrice < 0 {
+			return &invalidFieldError{"unitPrice cannot be negative"}
+		}
+		if l.WarehouseID != nil {
+			var n int
+			if err := q.QueryRow(`SELECT COUNT(*) FROM warehouses WHERE id = ?`, *l.WarehouseID).Scan(&n); err != nil {
+				return err
+			}
+			if n == 0 {
+				return &invalidFieldError{fmt.Sprintf("warehouse %d does not exist", *l.WarehouseID)}
+			}
+			if err := ensureActive(q, warehouseArchive, *l.WarehouseID); err != nil {
+				return err
+			}
+		}
+	}
+	if in.ShippingAddressID != nil {
+		if _, err := resolveShippingAddress(q, customerID, in.ShippingAddressID); err != nil {
+			return err
+		}
+	}
+	return checkUser(q, in.UserID)
+}
+
+// insertQuoteVersion saves in as the given version of the quote.
+func insertQuoteVersion(tx *sql.Tx, quoteID, version int, in quoteCU) error {
+	var total float64
+	for _, l := range in.Lines {
+		total += float64(l.Quantity) * *l.UnitPrice
+	}
+	if _, err := tx.Exec(`
+		INSERT INTO quote_versions (quote_id, version, valid_until, shipping_address_id, notes, total, created_by, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		quoteID, version, in.ValidUntil, in.ShippingAddressID, in.Notes, roundMoney(total), in.UserID,
+		time.Now().UTC().Format(time.RFC3339),
+	); err != nil {
+		return err
+	}
+	for _, l := range in.Lines {
+		if _, err := tx.Exec(`
+			INSERT INTO quote_lines (quote_id, version, product_id, warehouse_id, quantity, unit_price)
+			VALUES (?, ?, ?, ?, ?, ?)`,
+			quoteID, version, l.ProductID, l.WarehouseID, l.Quantity, *l.UnitPrice,
+		); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func broadcastQuote(eventType string, q models.Quote) {
+	tools.SSE.Broadcast(tools.Event{
+		Type: eventType,
+		Data: map[string]any{
+			"quoteId":    q.ID,
+			"customerId": q.CustomerID,
+			"status":     q.Status,
+			"version":    q.Version,
+			"total":      q.Total,
+			"validUntil": q.ValidUntil,
+			"orderId":    q.OrderID,
+		},
+		Time: time.Now(),
+	})
+}
+
+// loadQuoteForChange expires stale quotes and loads id inside tx, answering 404 itself.
+func loadQuoteForChange(w http.ResponseWriter, tx *sql.Tx, id int) (models.Quote, bool) {
+	if err := expireQuotes(tx); err != nil {
+		tools.HandleInternalServerError(w, err)
+		return models.Quote{}, false
+	}
+	q, err := loadQuote(tx, id)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Quote not found", http.StatusNotFound)
+		return q, false
+	}
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return q, false
+	}
+	return q, true
+}
+
+// ---------- Create (POST /api/quotes) ----------
+// body: { "customerId": 3, "validUntil": "2025-08-01", "notes": "",
+//
+//	"lines": [ { "productId": 5, "quantity": 10, "unitPrice": 9.5, "warehouseId": 1 } ], "userId": 1 }
+func createQuoteHandler(w http.ResponseWriter, r *http.Request) {
+	var in quoteCU
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		tools.HandleBadRequest(w, errors.New("invalid request"))
+		return
+	}
+	if strings.TrimSpace(in.ValidUntil) == "" {
+		in.ValidUntil = time.Now().UTC().AddDate(0, 0, defaultQuoteValidDays).Format(dateLayout)
+	}
+
+	tx, err := tools.DB.Begin()
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	defer tx.Rollback()
+
+	if ok, err := customerExists(tx, in.CustomerID); err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	} else if !ok {
+		tools.HandleBadRequest(w, fmt.Errorf("customer %d does not exist", in.CustomerID))
+		return
+	}
+	if err := ensureActive(tx, customerArchive, in.CustomerID); err != nil {
+		writeFieldError(w, err)
+		return
+	}
+	if err := in.normalize(tx, in.CustomerID); err != nil {
+		writeFieldError(w, err)
+		return
+	}
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	res, err := tx.Exec(
+		`INSERT INTO quotes (customer_id, status, version, created_by, created_at, updated_at) VALUES (?, 'draft', 1, ?, ?, ?)`,
+		in.CustomerID, in.UserID, now, now,
+	)
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	id, _ := res.LastInsertId()
+	if err := insertQuoteVersion(tx, int(id), 1, in); err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	q, err := loadQuote(tx, int(id))
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	broadcastQuote("quote.created", q)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(q)
+}
+
+// ---------- List (GET /api/quotes?customerId=&status=&page=&pageSize=) ----------
+func getQuotesHandler(w http.ResponseWriter, r *http.Request) {
+	if err := expireQuotes(tools.DB); err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	where := `1 = 1`
+	var args []any
+	if s := r.URL.Query().Get("customerId"); s != "" {
+		id, err := atoiParam(s)
+		if err != nil {
+			tools.HandleBadRequest(w, err)
+			return
+		}
+		where += ` AND q.customer_id = ?`
+		args = append(args, id)
+	}
+	if status := r.URL.Query().Get("status"); status != "" {
+		switch status {
+		case "draft", "sent", "accepted", "expired":
+		default:
+			tools.HandleBadRequest(w, errors.New("status must be draft, sent, accepted or expired"))
+			return
+		}
+		where += ` AND q.status = ?`
+		args = append(args, status)
+	}
+	page, pageSize, offset := parsePage(r)
+
+	var total int
+	if err := tools.DB.QueryRow(`SELECT COUNT(*) FROM quotes q WHERE `+where, args...).Scan(&total); err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	rows, err := tools.DB.Query(`SELECT `+quoteColumns+` WHERE `+where+` ORDER BY q.created_at DESC, q.id DESC LIMIT ? OFFSET ?`,
+		append(args, pageSize, offset)...)
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	defer rows.Close()
+	data := []models.Quote{}
+	for rows.Next() {
+		q, err := scanQuote(rows)
+		if err != nil {
+			tools.HandleInternalServerError(w, err)
+			return
+		}
+		data = append(data, q)
+	}
+	if err := rows.Err(); err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"data":       data,
+		"pagination": paginationMeta(page, pageSize, total),
+	})
+}
+
+// ---------- Read one (GET /api/quotes/{id}) ----------
+func getQuoteHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := atoiParam(chi.URLParam(r, "id"))
+	if err != nil {
+		tools.HandleBadRequest(w, err)
+		return
+	}
+	if err := expireQuotes(tools.DB); err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	q, err := loadQuote(tools.DB, id)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Quote not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(q)
+}
+
+// ---------- Versions (GET /api/quotes/{id}/versions) ----------
+// Oldest first; the last entry is the current version.
+func getQuoteVersionsHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := atoiParam(chi.URLParam(r, "id"))
+	if err != nil {
+		tools.HandleBadRequest(w, err)
+		return
+	}
+	rows, err := tools.DB.Query(`
+		SELECT version, valid_until, shipping_address_id, notes, total, created_by, created_at
+		FROM quote_versions WHERE quote_id = ? ORDER BY version`, id,
+	)
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	versions := []models.QuoteVersion{}
+	for rows.Next() {
+		var v models.QuoteVersion
+		if err := rows.Scan(&v.Version, &v.ValidUntil, &v.ShippingAddressID, &v.Notes, &v.Total, &v.CreatedBy, &v.CreatedAt); err != nil {
+			rows.Close()
+			tools.HandleInternalServerError(w, err)
+			return
+		}
+		versions = append(versions, v)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	if len(versions) == 0 {
+		http.Error(w, "Quote not found", http.StatusNotFound)
+		return
+	}
+	for i := range versions {
+		if versions[i].Lines, err = loadQuoteLines(tools.DB, id, versions[i].Version); err != nil {
+			tools.HandleInternalServerError(w, err)
+			return
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(versions)
+}
+
+// ---------- Update (PUT /api/quotes/{id}) ----------
+// Same body as create (customerId is ignored). The quote gets a new version and goes
+// back to draft, so a sent or expired quote has to be sent again. validUntil defaults
+// to the current version's; an expired quote needs a new one.
+func updateQuoteHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := atoiParam(chi.URLParam(r, "id"))
+	if err != nil {
+		tools.HandleBadRequest(w, err)
+		return
+	}
+	var in quoteCU
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		tools.HandleBadRequest(w, errors.New("invalid request"))
+		return
+	}
+
+	tx, err := tools.DB.Begin()
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	defer tx.Rollback()
+
+	cur, ok := loadQuoteForChange(w, tx, id)
+	if !ok {
+		return
+	}
+	if cur.Status == "accepted" {
+		http.Error(w, fmt.Sprintf("quote %d was accepted as order %s and can't be edited", id, formatOptionalID(cur.OrderID)), http.StatusConflict)
+		return
+	}
+	if strings.TrimSpace(in.ValidUntil) == "" {
+		in.ValidUntil = cur.ValidUntil
+	}
+	if err := in.normalize(tx, cur.CustomerID); err != nil {
+		writeFieldError(w, err)
+		return
+	}
+	version := cur.Version + 1
+	if err := insertQuoteVersion(tx, id, version, in); err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	if _, err := tx.Exec(
+		`UPDATE quotes SET version = ?, status = 'draft', sent_at = NULL, updated_at = ? WHERE id = ?`,
+		version, time.Now().UTC().Format(time.RFC3339), id,
+	); err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	q, err := loadQuote(tx, id)
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	broadcastQuote("quote.updated", q)
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(q)
+}
+
+// formatOptionalID renders an id that may be missing (an accepted quote whose order was deleted).
+func formatOptionalID(id *int) string {
+	if id == nil {
+		return "(deleted)"
+	}
+	return fmt.Sprint(*id)
+}
+
+// ---------- Send (POST /api/quotes/{id}/send) ----------
+// Marks a draft as sent to the customer; sending again just updates sentAt.
+func sendQuoteHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := atoiParam(chi.URLParam(r, "id"))
+	if err != nil {
+		tools.HandleBadRequest(w, err)
+		return
+	}
+	var in quoteStatusIn
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+			tools.HandleBadRequest(w, errors.New("invalid request"))
+			return
+		}
+	}
+	if err := checkUser(tools.DB, in.UserID); err != nil {
+		writeFieldError(w, err)
+		return
+	}
+
+	tx, err := tools.DB.Begin()
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	defer tx.Rollback()
+
+	cur, ok := loadQuoteForChange(w, tx, id)
+	if !ok {
+		return
+	}
+	switch cur.Status {
+	case "accepted":
+		http.Error(w, fmt.Sprintf("quote %d is already accepted", id), http.StatusConflict)
+		return
+	case "expired":
+		http.Error(w, fmt.Sprintf("quote %d expired after %s; edit it with a new validUntil first", id, cur.ValidUntil), http.StatusConflict)
+		return
+	}
+	now := time.Now().UTC().Format(time.RFC3339)
+	if _, err := tx.Exec(`UPDATE quotes SET status = 'sent', sent_at = ?, updated_at = ? WHERE id = ?`, now, now, id); err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	q, err := loadQuote(tx, id)
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	broadcastQuote("quote.sent", q)
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(q)
+}
+
+// ---------- Accept (POST /api/quotes/{id}/accept) ----------
+// body: { "orderId": 120, "userId": 1, "warehouseId": 2, "allowOverCredit": false, "allowBackorder": false }
+// — all optional.
+// The current version becomes an order at the quoted prices. Stock, credit limit and
+// archive checks fail the same way as on POST /api/orders and leave the quote open.
+func acceptQuoteHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := atoiParam(chi.URLParam(r, "id"))
+	if err != nil {
+		tools.HandleBadRequest(w, err)
+		return
+	}
+	var in acceptQuoteIn
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+			tools.HandleBadRequest(w, errors.New("invalid request"))
+			return
+		}
+	}
+	if err := ensureOrderItemsHasWarehouseColumn(tools.DB); err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+
+	tx, err := tools.DB.Begin()
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	defer tx.Rollback()
+
+	q, ok := loadQuoteForChange(w, tx, id)
+	if !ok {
+		return
+	}
+	switch q.Status {
+	case "accepted":
+		http.Error(w, fmt.Sprintf("quote %d is already accepted as order %s", id, formatOptionalID(q.OrderID)), http.StatusConflict)
+		return
+	case "expired":
+		http.Error(w, fmt.Sprintf("quote %d expired after %s", id, q.ValidUntil), http.StatusConflict)
+		return
+	}
+
+	order := createOrderIn{
+		OrderID:           in.OrderID,
+		CustomerID:        q.CustomerID,
+		ShippingAddressID: q.ShippingAddressID,
+		AllowOverCredit:   in.AllowOverCredit,
+		AllowBackorder:    in.AllowBackorder,
+	}
+	if in.UserID != nil {
+		if err := checkUser(tx, in.UserID); err != nil {
+			writeFieldError(w, err)
+			return
+		}
+		order.UserID = *in.UserID
+	} else if q.CreatedBy != nil {
+		order.UserID = *q.CreatedBy
+	} else {
+		tools.HandleBadRequest(w, errors.New("userId is required"))
+		return
+	}
+	if order.OrderID == 0 {
+		if err := tx.QueryRow(`SELECT COALESCE(MAX(orderId), 0) + 1 FROM orders`).Scan(&order.OrderID); err != nil {
+			tools.HandleInternalServerError(w, err)
+			return
+		}
+	} else {
+		var n int
+		if err := tx.QueryRow(`SELECT COUNT(*) FROM orders WHERE orderId = ?`, order.OrderID).Scan(&n); err != nil {
+			tools.HandleInternalServerError(w, err)
+			return
+		}
+		if n > 0 {
+			http.Error(w, fmt.Sprintf("order %d already exists", order.OrderID), http.StatusConflict)
+			return
+		}
+	}
+	for _, l := range q.Lines {
+		item := orderItemIn{ProductID: l.ProductID, Quantity: l.Quantity, SalePrice: l.UnitPrice, WarehouseID: in.WarehouseID}
+		if l.WarehouseID != nil {
+			item.WarehouseID = *l.WarehouseID
+		}
+		if item.WarehouseID == 0 {
+			tools.HandleBadRequest(w, fmt.Errorf("product %d has no warehouse; pass warehouseId", l.ProductID))
+			return
+		}
+		order.ProductItems = append(order.ProductItems, item)
+	}
+
+	o, err := createOrderTx(tx, order)
+	if err != nil {
+		writeOrderError(w, err)
+		return
+	}
+	now := time.Now().UTC().Format(time.RFC3339)
+	if _, err := tx.Exec(
+		`UPDATE quotes SET status = 'accepted', order_id = ?, accepted_at = ?, updated_at = ? WHERE id = ?`,
+		o.OrderID, now, now, id,
+	); err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	q, err = loadQuote(tx, id)
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	o.broadcast()
+	broadcastQuote("quote.accepted", q)
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"quote": q,
+		"order": o.response(),
+	})
+}
+
+// ---------- Delete (DELETE /api/quotes/{id}) ----------
+// Accepted quotes stay as the record of where their order came from.
+func deleteQuoteHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := atoiParam(chi.URLParam(r, "id"))
+	if err != nil {
+		tools.HandleBadRequest(w, err)
+		return
+	}
+	tx, err := tools.DB.Begin()
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	defer tx.Rollback()
+
+	var status string
+	err = tx.QueryRow(`SELECT status FROM quotes WHERE id = ?`, id).Scan(&status)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Quote not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	accepted := status == "accepted"
+	if !accepted {
+		// Checked again in the DELETE so an accept committed in between keeps the quote.
+		// Versions and their lines go with it (ON DELETE CASCADE).
+		res, err := tx.Exec(`DELETE FROM quotes WHERE id = ? AND status <> 'accepted'`, id)
+		if err != nil {
+			tools.HandleInternalServerError(w, err)
+			return
+		}
+		n, _ := res.RowsAffected()
+		accepted = n == 0
+	}
+	if accepted {
+		http.Error(w, fmt.Sprintf("quote %d is accepted and can't be deleted", id), http.StatusConflict)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
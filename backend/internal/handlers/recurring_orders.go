@@ -0,0 +1,818 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/MananKakkar1/SalesBoard/backend/internal/cron"
+	"github.com/MananKakkar1/SalesBoard/backend/internal/models"
+	"github.com/MananKakkar1/SalesBoard/backend/internal/tools"
+	"github.com/go-chi/chi"
+	log "github.com/sirupsen/logrus"
+)
+
+// Recurring orders are templates for customers who reorder the same basket. The
+// scheduler places an order through createOrderTx whenever a template's next run
+// comes due, then moves it to the following occurrence whether or not the order
+// went through. Runs missed while the server was down are not caught up: one order
+// is placed and the schedule continues from now.
+
+// recentRunsLimit is how many runs the template detail endpoint includes.
+const recentRunsLimit = 10
+
+// defaultRecurringTime is the time of day weekly and monthly templates run at.
+const defaultRecurringTime = "09:00"
+
+// recurringMu keeps the scheduler and manual runs from placing the same order twice.
+var recurringMu sync.Mutex
+
+// ---------- Input DTOs ----------
+
+type recurringOrderLineIn struct {
+	ProductID   int      `json:"productId"`
+	WarehouseID int      `json:"warehouseId"`
+	Quantity    int      `json:"quantity"`
+	UnitPrice   *float64 `json:"unitPrice"` // optional; omitted means the price on the day of each order
+}
+
+type recurringOrderCU struct {
+	CustomerID        int                    `json:"customerId"` // create only
+	UserID            int                    `json:"userId"`     // who the generated orders are placed by
+	Name              string                 `json:"name"`
+	Frequency         string                 `json:"frequency"`  // weekly | monthly | cron
+	Weekday           *int                   `json:"weekday"`    // weekly: 0 (Sunday) to 6
+	DayOfMonth        *int                   `json:"dayOfMonth"` // monthly: 1 to 28
+	Time              string                 `json:"time"`       // weekly and monthly: HH:MM in UTC, default 09:00
+	Cron              string                 `json:"cron"`       // cron: five fields, in UTC
+	ShippingAddressID *int                   `json:"shippingAddressId"`
+	AllowBackorder    bool                   `json:"allowBackorder"`
+	AllowOverCredit   bool                   `json:"allowOverCredit"`
+	Lines             []recurringOrderLineIn `json:"lines"`
+}
+
+// ---------- helpers ----------
+
+const recurringOrderColumns = `id, customer_id, user_id, name, frequency, weekday, day_of_month, at_time, cron,
+	shipping_address_id, allow_backorder, allow_over_credit, status, next_run_at, last_run_at, last_error,
+	failure_count, created_at, updated_at`
+
+func scanRecurringOrder(row rowScanner) (models.RecurringOrder, error) {
+	var t models.RecurringOrder
+	err := row.Scan(&t.ID, &t.CustomerID, &t.UserID, &t.Name, &t.Frequency, &t.Weekday, &t.DayOfMonth, &t.Time, &t.Cron,
+		&t.ShippingAddressID, &t.AllowBackorder, &t.AllowOverCredit, &t.Status, &t.NextRunAt, &t.LastRunAt, &t.LastError,
+		&t.FailureCount, &t.CreatedAt, &t.UpdatedAt)
+	return t, err
+}
+
+// queryRecurringOrders runs a SELECT of recurringOrderColumns (where and order are appended).
+func queryRecurringOrders(q queryer, where, order string, args ...any) ([]models.RecurringOrder, error) {
+	rows, err := q.Query(`SELECT `+recurringOrderColumns+` FROM recurring_orders WHERE `+where+` ORDER BY `+order, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := []models.RecurringOrder{}
+	for rows.Next() {
+		t, err := scanRecurringOrder(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, t)
+	}
+	return out, rows.Err()
+}
+
+// loadRecurringOrder returns the template with its lines and most recent runs.
+func loadRecurringOrder(q queryer, id int) (models.RecurringOrder, error) {
+	t, err := scanRecurringOrder(q.QueryRow(`SELECT `+recurringOrderColumns+` FROM recurring_orders WHERE id = ?`, id))
+	if err != nil {
+		return t, err
+	}
+	if t.Lines, err = loadRecurringOrderLines(q, id); err != nil {
+		return t, err
+	}
+	t.RecentRuns, err = loadRecurringOrderRuns(q, id, recentRunsLimit, 0)
+	return t, err
+}
+
+func loadRecurringOrderLines(q queryer, id int) ([]models.RecurringOrderLine, error) {
+	rows, err := q.Query(`
+		SELECT l.product_id, COALESCE(p.name, ''), l.warehouse_id, l.quantity, l.unit_price
+		FROM recurring_order_lines l
+		LEFT JOIN products p ON p.id = l.product_id
+		WHERE l.recurring_order_id = ?
+		ORDER BY l.id`, id,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := []models.RecurringOrderLine{}
+	for rows.Next() {
+		var l models.RecurringOrderLine
+		if err := rows.Scan(&l.ProductID, &l.ProductName, &l.WarehouseID, &l.Quantity, &l.UnitPrice); err != nil {
+			return nil, err
+		}
+		out = append(out, l)
+	}
+	return out, rows.Err()
+}
+
+// loadRecurringOrderRuns returns runs newest first.
+func loadRecurringOrderRuns(q queryer, id, limit, offset int) ([]models.RecurringOrderRun, error) {
+	rows, err := q.Query(`
+		SELECT id, scheduled_for, ran_at, status, order_id, error
+		FROM recurring_order_runs
+		WHERE recurring_order_id = ?
+		ORDER BY ran_at DESC, id DESC
+		LIMIT ? OFFSET ?`, id, limit, offset,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := []models.RecurringOrderRun{}
+	for rows.Next() {
+		var run models.RecurringOrderRun
+		if err := rows.Scan(&run.ID, &run.ScheduledFor, &run.RanAt, &run.Status, &run.OrderID, &run.Error); err != nil {
+			return nil, err
+		}
+		out = append(out, run)
+	}
+	return out, rows.Err()
+}
+
+// nextRunAfter is the first occurrence of the cron expression after t, as stored in next_run_at.
+func nextRunAfter(expr string, t time.Time) (string, error) {
+	s, err := cron.Parse(expr)
+	if err != nil {
+		return "", err
+	}
+	next := s.Next(t.UTC())
+	if next.IsZero() {
+		return "", fmt.Errorf("cron expression %q never matches", expr)
+	}
+	return next.Format(time.RFC3339), nil
+}
+
+// normalize validates the template for customerID and returns its schedule as a cron expression.
+func (in *recurringOrderCU) normalize(q queryer, customerID int) (string, error) {
+	in.Name = strings.TrimSpace(in.Name)
+	in.Time = strings.TrimSpace(in.Time)
+	in.Cron = strings.TrimSpace(in.Cron)
+
+	var expr string
+	switch in.Frequency {
+	case "weekly", "monthly":
+		if in.Cron != "" {
+			return "", &invalidFieldError{"cron is only used with frequency cron"}
+		}
+		if in.Time == "" {
+			in.Time = defaultRecurringTime
+		}
+		at, err := time.Parse("15:04", in.Time)
+		if err != nil {
+			return "", &invalidFieldError{"time must be HH:MM"}
+		}
+		if in.Frequency == "weekly" {
+			if in.Weekday == nil || *in.Weekday < 0 || *in.Weekday > 6 || in.DayOfMonth != nil {
+				return "", &invalidFieldError{"weekly schedules need weekday 0 (Sunday) to 6 and no dayOfMonth"}
+			}
+			expr = fmt.Sprintf("%d %d * * %d", at.Minute(), at.Hour(), *in.Weekday)
+		} else {
+			if in.DayOfMonth == nil || *in.DayOfMonth < 1 || *in.DayOfMonth > 28 || in.Weekday != nil {
+				return "", &invalidFieldError{"monthly schedules need dayOfMonth 1 to 28 and no weekday"}
+			}
+			expr = fmt.Sprintf("%d %d %d * *", at.Minute(), at.Hour(), *in.DayOfMonth)
+		}
+	case "cron":
+		if in.Weekday != nil || in.DayOfMonth != nil || in.Time != "" {
+			return "", &invalidFieldError{"cron schedules take only the cron expression"}
+		}
+		expr = in.Cron
+	default:
+		return "", &invalidFieldError{"frequency must be weekly, monthly or cron"}
+	}
+	if _, err := nextRunAfter(expr, time.Now()); err != nil {
+		return "", &invalidFieldError{err.Error()}
+	}
+
+	if in.UserID <= 0 {
+		return "", &invalidFieldError{"userId is required"}
+	}
+	if err := checkUser(q, &in.UserID); err != nil {
+		return "", err
+	}
+	if len(in.Lines) == 0 {
+		return "", &invalidFieldError{"lines are required"}
+	}
+	for _, l := range in.Lines {
+		if l.ProductID <= 0 || l.WarehouseID <= 0 || l.Quantity <= 0 {
+			return "", &invalidFieldError{"each line requires productId > 0, warehouseId > 0, quantity > 0"}
+		}
+		if l.UnitPrice != nil && *l.UnitPrice < 0 {
+			return "", &invalidFieldError{"unitPrice cannot be negative"}
+		}
+		var products, warehouses int
+		if err := q.QueryRow(`SELECT COUNT(*) FROM products WHERE id = ?`, l.ProductID).Scan(&products); err != nil {
+			return "", err
+		}
+		if err := q.QueryRow(`SELECT COUNT(*) FROM warehouses WHERE id = ?`, l.WarehouseID).Scan(&warehouses); err != nil {
+			return "", err
+		}
+		if products == 0 || warehouses == 0 {
+			return "", &invalidFieldError{fmt.Sprintf("product %d or warehouse %d does not exist", l.ProductID, l.WarehouseID)}
+		}
+		if err := ensureActive(q, productArchive, l.ProductID); err != nil {
+			return "", err
+		}
+		if err := ensureActive(q, warehouseArchive, l.WarehouseID); err != nil {
+			return "", err
+		}
+	}
+	if in.ShippingAddressID != nil {
+		if _, err := resolveShippingAddress(q, customerID, in.ShippingAddressID); err != nil {
+			return "", err
+		}
+	}
+	return expr, nil
+}
+
+// weeklyMonthlyFields returns the weekday, day of month and time to store for in.
+func (in *recurringOrderCU) weeklyMonthlyFields() (weekday, dayOfMonth, atTime any) {
+	if in.Frequency == "cron" {
+		return nil, nil, nil
+	}
+	return in.Weekday, in.DayOfMonth, in.Time
+}
+
+func insertRecurringOrderLines(tx *sql.Tx, id int, lines []recurringOrderLineIn) error {
+	for _, l := range lines {
+		if _, err := tx.Exec(`
+			INSERT INTO recurring_order_lines (recurring_order_id, product_id, warehouse_id, quantity, unit_price)
+			VALUES (?, ?, ?, ?, ?)`,
+			id, l.ProductID, l.WarehouseID, l.Quantity, l.UnitPrice,
+		); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func broadcastRecurringOrder(eventType string, t models.RecurringOrder, run models.RecurringOrderRun) {
+	tools.SSE.Broadcast(tools.Event{
+		Type: eventType,
+		Data: map[string]any{
+			"recurringOrderId": t.ID,
+			"customerId":       t.CustomerID,
+			"runId":            run.ID,
+			"scheduledFor":     run.ScheduledFor,
+			"orderId":          run.OrderID,
+			"error":            run.Error,
+			"failureCount":     t.FailureCount,
+			"nextRunAt":        t.NextRunAt,
+		},
+		Time: time.Now(),
+	})
+}
+
+// placeRecurringOrder builds the order for template t and writes it inside tx.
+func placeRecurringOrder(tx *sql.Tx, t models.RecurringOrder, now time.Time) (*createdOrder, error) {
+	in := createOrderIn{
+		CustomerID:        t.CustomerID,
+		UserID:            t.UserID,
+		CreatedAt:         now.UTC().Format(time.RFC3339),
+		ShippingAddressID: t.ShippingAddressID,
+		AllowOverCredit:   t.AllowOverCredit,
+		AllowBackorder:    t.AllowBackorder,
+	}
+	if err := tx.QueryRow(`SELECT COALESCE(MAX(orderId), 0) + 1 FROM orders`).Scan(&in.OrderID); err != nil {
+		return nil, err
+	}
+	for _, l := range t.Lines {
+		item := orderItemIn{ProductID: l.ProductID, WarehouseID: l.WarehouseID, Quantity: l.Quantity}
+		if l.UnitPrice != nil {
+			item.SalePrice = *l.UnitPrice
+		} else if err := tx.QueryRow(`SELECT price FROM products WHERE id = ?`, l.ProductID).Scan(&item.SalePrice); err != nil {
+			return nil, err
+		}
+		in.ProductItems = append(in.ProductItems, item)
+	}
+	return createOrderTx(tx, in)
+}
+
+// runRecurringOrder places one order from template id and records the run on it,
+// moving next_run_at on when advance is set. A failed order is recorded and
+// announced as recurring_order.failed, not returned; the error is for database
+// trouble only. Callers hold recurringMu.
+func runRecurringOrder(id int, now time.Time, advance bool) (models.RecurringOrderRun, error) {
+	run := models.RecurringOrderRun{RanAt: now.UTC().Format(time.RFC3339)}
+
+	tx, err := tools.DB.Begin()
+	if err != nil {
+		return run, err
+	}
+	defer tx.Rollback()
+
+	t, err := loadRecurringOrder(tx, id)
+	if err != nil {
+		return run, err
+	}
+	if advance {
+		run.ScheduledFor = t.NextRunAt
+	}
+	next := t.NextRunAt
+	if advance {
+		n, err := nextRunAfter(t.Cron, now)
+		if err != nil {
+			return run, err
+		}
+		next = &n
+	}
+
+	o, orderErr := placeRecurringOrder(tx, t, now)
+	if orderErr != nil {
+		// Nothing the order wrote survives; the failure is recorded in a fresh transaction
+		_ = tx.Rollback()
+		if tx, err = tools.DB.Begin(); err != nil {
+			return run, err
+		}
+		defer tx.Rollback()
+		msg := orderErr.Error()
+		run.Status, run.Error = "failed", &msg
+	} else {
+		run.Status, run.OrderID = "created", &o.OrderID
+	}
+
+	res, err := tx.Exec(`
+		INSERT INTO recurring_order_runs (recurring_order_id, scheduled_for, ran_at, status, order_id, error)
+		VALUES (?, ?, ?, ?, ?, ?)`,
+		id, run.ScheduledFor, run.RanAt, run.Status, run.OrderID, run.Error,
+	)
+	if err != nil {
+		return run, err
+	}
+	runID, _ := res.LastInsertId()
+	run.ID = int(runID)
+	if _, err := tx.Exec(`
+		UPDATE recurring_orders
+		   SET next_run_at = ?, last_run_at = ?, last_error = ?,
+		       failure_count = CASE WHEN ? IS NULL THEN 0 ELSE failure_count + 1 END, updated_at = ?
+		 WHERE id = ?`,
+		next, run.RanAt, run.Error, run.Error, run.RanAt, id,
+	); err != nil {
+		return run, err
+	}
+	if t, err = loadRecurringOrder(tx, id); err != nil {
+		return run, err
+	}
+	if err := tx.Commit(); err != nil {
+		return run, err
+	}
+
+	if orderErr == nil {
+		o.broadcast()
+		broadcastRecurringOrder("recurring_order.generated", t, run)
+	} else {
+		broadcastRecurringOrder("recurring_order.failed", t, run)
+	}
+	return run, nil
+}
+
+// runDueRecurringOrders places the orders of every active template due at now.
+func runDueRecurringOrders(now time.Time) error {
+	recurringMu.Lock()
+	defer recurringMu.Unlock()
+
+	due, err := queryRecurringOrders(tools.DB, `status = 'active' AND next_run_at <= ?`, `next_run_at, id`,
+		now.UTC().Format(time.RFC3339))
+	if err != nil {
+		return err
+	}
+	for _, t := range due {
+		if _, err := runRecurringOrder(t.ID, now, true); err != nil {
+			log.Errorf("recurring order %d: %v", t.ID, err)
+		}
+	}
+	return nil
+}
+
+// StartRecurringOrderScheduler places the orders of due recurring templates right
+// away and then every interval.
+func StartRecurringOrderScheduler(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			if err := runDueRecurringOrders(time.Now()); err != nil {
+				log.Errorf("recurring order scheduler: %v", err)
+			}
+			<-ticker.C
+		}
+	}()
+}
+
+// writeRecurringOrder answers with the template, or 404 when it doesn't exist.
+func writeRecurringOrder(w http.ResponseWriter, id, status int) {
+	t, err := loadRecurringOrder(tools.DB, id)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Recurring order not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(t)
+}
+
+// ---------- Create (POST /api/recurring-orders) ----------
+// body: { "customerId": 3, "userId": 1, "name": "Weekly restock", "frequency": "weekly", "weekday": 1,
+//
+//	"time": "08:00", "lines": [ { "productId": 5, "warehouseId": 1, "quantity": 10 } ] }
+//
+// "frequency": "monthly" takes dayOfMonth instead of weekday; "frequency": "cron" takes
+// "cron": "0 8 * * 1-5". New templates start active.
+func createRecurringOrderHandler(w http.ResponseWriter, r *http.Request) {
+	var in recurringOrderCU
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		tools.HandleBadRequest(w, errors.New("invalid request"))
+		return
+	}
+
+	tx, err := tools.DB.Begin()
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	defer tx.Rollback()
+
+	if ok, err := customerExists(tx, in.CustomerID); err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	} else if !ok {
+		tools.HandleBadRequest(w, fmt.Errorf("customer %d does not exist", in.CustomerID))
+		return
+	}
+	if err := ensureActive(tx, customerArchive, in.CustomerID); err != nil {
+		writeFieldError(w, err)
+		return
+	}
+	expr, err := in.normalize(tx, in.CustomerID)
+	if err != nil {
+		writeFieldError(w, err)
+		return
+	}
+	now := time.Now()
+	next, err := nextRunAfter(expr, now)
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	weekday, dayOfMonth, atTime := in.weeklyMonthlyFields()
+	ts := now.UTC().Format(time.RFC3339)
+	res, err := tx.Exec(`
+		INSERT INTO recurring_orders (customer_id, user_id, name, frequency, weekday, day_of_month, at_time, cron,
+		                              shipping_address_id, allow_backorder, allow_over_credit, status, next_run_at,
+		                              created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, 'active', ?, ?, ?)`,
+		in.CustomerID, in.UserID, in.Name, in.Frequency, weekday, dayOfMonth, atTime, expr,
+		in.ShippingAddressID, in.AllowBackorder, in.AllowOverCredit, next, ts, ts,
+	)
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	id, _ := res.LastInsertId()
+	if err := insertRecurringOrderLines(tx, int(id), in.Lines); err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	writeRecurringOrder(w, int(id), http.StatusCreated)
+}
+
+// ---------- List (GET /api/recurring-orders?customerId=&status=&page=&pageSize=) ----------
+// Soonest next run first; paused templates last.
+func getRecurringOrdersHandler(w http.ResponseWriter, r *http.Request) {
+	where := `1 = 1`
+	var args []any
+	if s := r.URL.Query().Get("customerId"); s != "" {
+		id, err := atoiParam(s)
+		if err != nil {
+			tools.HandleBadRequest(w, err)
+			return
+		}
+		where += ` AND customer_id = ?`
+		args = append(args, id)
+	}
+	if status := r.URL.Query().Get("status"); status != "" {
+		if status != "active" && status != "paused" {
+			tools.HandleBadRequest(w, errors.New("status must be active or paused"))
+			return
+		}
+		where += ` AND status = ?`
+		args = append(args, status)
+	}
+	page, pageSize, offset := parsePage(r)
+
+	var total int
+	if err := tools.DB.QueryRow(`SELECT COUNT(*) FROM recurring_orders WHERE `+where, args...).Scan(&total); err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	data, err := queryRecurringOrders(tools.DB, where, `status, next_run_at, id LIMIT ? OFFSET ?`, append(args, pageSize, offset)...)
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"data":       data,
+		"pagination": paginationMeta(page, pageSize, total),
+	})
+}
+
+// ---------- Read one (GET /api/recurring-orders/{id}) ----------
+func getRecurringOrderHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := atoiParam(chi.URLParam(r, "id"))
+	if err != nil {
+		tools.HandleBadRequest(w, err)
+		return
+	}
+	writeRecurringOrder(w, id, http.StatusOK)
+}
+
+// ---------- Runs (GET /api/recurring-orders/{id}/runs?page=&pageSize=) ----------
+func getRecurringOrderRunsHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := atoiParam(chi.URLParam(r, "id"))
+	if err != nil {
+		tools.HandleBadRequest(w, err)
+		return
+	}
+	var exists, total int
+	if err := tools.DB.QueryRow(`SELECT COUNT(*) FROM recurring_orders WHERE id = ?`, id).Scan(&exists); err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	if exists == 0 {
+		http.Error(w, "Recurring order not found", http.StatusNotFound)
+		return
+	}
+	if err := tools.DB.QueryRow(`SELECT COUNT(*) FROM recurring_order_runs WHERE recurring_order_id = ?`, id).Scan(&total); err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	page, pageSize, offset := parsePage(r)
+	data, err := loadRecurringOrderRuns(tools.DB, id, pageSize, offset)
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"data":       data,
+		"pagination": paginationMeta(page, pageSize, total),
+	})
+}
+
+// ---------- Update (PUT /api/recurring-orders/{id}) ----------
+// Same body as create (customerId is ignored); lines are replaced. An active
+// template's next run is recalculated from the new schedule.
+func updateRecurringOrderHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := atoiParam(chi.URLParam(r, "id"))
+	if err != nil {
+		tools.HandleBadRequest(w, err)
+		return
+	}
+	var in recurringOrderCU
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		tools.HandleBadRequest(w, errors.New("invalid request"))
+		return
+	}
+
+	recurringMu.Lock()
+	defer recurringMu.Unlock()
+
+	tx, err := tools.DB.Begin()
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	defer tx.Rollback()
+
+	var customerID int
+	var status string
+	err = tx.QueryRow(`SELECT customer_id, status FROM recurring_orders WHERE id = ?`, id).Scan(&customerID, &status)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Recurring order not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	expr, err := in.normalize(tx, customerID)
+	if err != nil {
+		writeFieldError(w, err)
+		return
+	}
+	var next any
+	if status == "active" {
+		if next, err = nextRunAfter(expr, time.Now()); err != nil {
+			tools.HandleInternalServerError(w, err)
+			return
+		}
+	}
+	weekday, dayOfMonth, atTime := in.weeklyMonthlyFields()
+	if _, err := tx.Exec(`
+		UPDATE recurring_orders
+		   SET user_id = ?, name = ?, frequency = ?, weekday = ?, day_of_month = ?, at_time = ?, cron = ?,
+		       shipping_address_id = ?, allow_backorder = ?, allow_over_credit = ?, next_run_at = ?, updated_at = ?
+		 WHERE id = ?`,
+		in.UserID, in.Name, in.Frequency, weekday, dayOfMonth, atTime, expr,
+		in.ShippingAddressID, in.AllowBackorder, in.AllowOverCredit, next, time.Now().UTC().Format(time.RFC3339), id,
+	); err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	if _, err := tx.Exec(`DELETE FROM recurring_order_lines WHERE recurring_order_id = ?`, id); err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	if err := insertRecurringOrderLines(tx, id, in.Lines); err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	writeRecurringOrder(w, id, http.StatusOK)
+}
+
+// setRecurringOrderStatus pauses a template, or resumes it from the next occurrence after now.
+func setRecurringOrderStatus(active bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := atoiParam(chi.URLParam(r, "id"))
+		if err != nil {
+			tools.HandleBadRequest(w, err)
+			return
+		}
+		recurringMu.Lock()
+		defer recurringMu.Unlock()
+
+		var expr string
+		err = tools.DB.QueryRow(`SELECT cron FROM recurring_orders WHERE id = ?`, id).Scan(&expr)
+		if err == sql.ErrNoRows {
+			http.Error(w, "Recurring order not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			tools.HandleInternalServerError(w, err)
+			return
+		}
+		status, next := "paused", any(nil)
+		if active {
+			if next, err = nextRunAfter(expr, time.Now()); err != nil {
+				tools.HandleInternalServerError(w, err)
+				return
+			}
+			status = "active"
+		}
+		if _, err := tools.DB.Exec(
+			`UPDATE recurring_orders SET status = ?, next_run_at = ?, updated_at = ? WHERE id = ?`,
+			status, next, time.Now().UTC().Format(time.RFC3339), id,
+		); err != nil {
+			tools.HandleInternalServerError(w, err)
+			return
+		}
+		writeRecurringOrder(w, id, http.StatusOK)
+	}
+}
+
+// ---------- Skip next (POST /api/recurring-orders/{id}/skip-next) ----------
+// The next due date is recorded as skipped and the template moves to the one after.
+func skipNextRecurringOrderHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := atoiParam(chi.URLParam(r, "id"))
+	if err != nil {
+		tools.HandleBadRequest(w, err)
+		return
+	}
+	recurringMu.Lock()
+	defer recurringMu.Unlock()
+
+	tx, err := tools.DB.Begin()
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	defer tx.Rollback()
+
+	var status, expr string
+	var nextRunAt sql.NullString
+	err = tx.QueryRow(`SELECT status, cron, next_run_at FROM recurring_orders WHERE id = ?`, id).Scan(&status, &expr, &nextRunAt)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Recurring order not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	if status != "active" || !nextRunAt.Valid {
+		http.Error(w, "Recurring order is paused; there is no next run to skip", http.StatusConflict)
+		return
+	}
+	skipped, err := time.Parse(time.RFC3339, nextRunAt.String)
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	next, err := nextRunAfter(expr, skipped)
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	now := time.Now().UTC().Format(time.RFC3339)
+	if _, err := tx.Exec(`
+		INSERT INTO recurring_order_runs (recurring_order_id, scheduled_for, ran_at, status)
+		VALUES (?, ?, ?, 'skipped')`, id, nextRunAt.String, now,
+	); err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	if _, err := tx.Exec(`UPDATE recurring_orders SET next_run_at = ?, updated_at = ? WHERE id = ?`, next, now, id); err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	writeRecurringOrder(w, id, http.StatusOK)
+}
+
+// ---------- Run now (POST /api/recurring-orders/{id}/run) ----------
+// Places an order from the template straight away, paused or not; the schedule is
+// left as it is. Answers 201 with the run when the order was created, and 200 with
+// the failed run (its error included) when it wasn't.
+func runRecurringOrderHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := atoiParam(chi.URLParam(r, "id"))
+	if err != nil {
+		tools.HandleBadRequest(w, err)
+		return
+	}
+	recurringMu.Lock()
+	defer recurringMu.Unlock()
+
+	run, err := runRecurringOrder(id, time.Now(), false)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Recurring order not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if run.Status == "created" {
+		w.WriteHeader(http.StatusCreated)
+	}
+	_ = json.NewEncoder(w).Encode(run)
+}
+
+// ---------- Delete (DELETE /api/recurring-orders/{id}) ----------
+// Orders already placed from the template are kept.
+func deleteRecurringOrderHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := atoiParam(chi.URLParam(r, "id"))
+	if err != nil {
+		tools.HandleBadRequest(w, err)
+		return
+	}
+	recurringMu.Lock()
+	defer recurringMu.Unlock()
+
+	// Lines and run history go with the template (ON DELETE CASCADE).
+	res, err := tools.DB.Exec(`DELETE FROM recurring_orders WHERE id = ?`, id)
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		http.Error(w, "Recurring order not found", http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,531 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/MananKakkar1/SalesBoard/backend/internal/models"
+	"github.com/MananKakkar1/SalesBoard/backend/internal/tools"
+	"github.com/go-chi/chi"
+)
+
+// Reorder planning per (warehouse, product):
+//
+//	velocity      = units sold per day over the trailing window (order_items, with
+//	                kit lines counted as the components they used)
+//	safety stock  = z * stddev(daily units) * sqrt(lead time)
+//	reorder point = velocity * lead time + safety stock
+//
+// Lead time comes from the preferred supplier (cheapest, then fastest). Kits hold no
+// stock of their own, so they get no reorder point.
+const (
+	reorderServiceZ      = 1.65 // ~95% service level
+	defaultReorderWindow = 30   // days of sales history
+	defaultLeadTimeDays  = 7    // used when a product has no supplier yet
+	defaultCoverDays     = 30   // how many days of demand a suggestion should cover
+)
+
+type supplierChoice struct {
+	ID           int     `json:"id"`
+	Name         string  `json:"name"`
+	LeadTimeDays int     `json:"leadTimeDays"`
+	SupplierCost float64 `json:"supplierCost"`
+}
+
+// preferredSupplier returns the cheapest supplier for a product (ties broken by lead time), or nil.
+func preferredSupplier(q queryer, productID int) (*supplierChoice, error) {
+	var s supplierChoice
+	err := q.QueryRow(`
+		SELECT s.id, s.name, s.lead_time_days, sp.supplier_cost
+		FROM supplier_products sp
+		JOIN suppliers s ON s.id = sp.supplier_id
+		WHERE sp.product_id = ?
+		ORDER BY sp.supplier_cost ASC, s.lead_time_days ASC, s.id ASC
+		LIMIT 1`, productID,
+	).Scan(&s.ID, &s.Name, &s.LeadTimeDays, &s.SupplierCost)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// computeReorderPoints derives reorder points for every stocked or recently sold
+// (warehouse, product) pair from the last windowDays of order history.
+func computeReorderPoints(windowDays, fallbackLead int) ([]models.ReorderPoint, error) {
+	if err := ensureOrderItemsHasWarehouseColumn(tools.DB); err != nil {
+		return nil, err
+	}
+	since := time.Now().UTC().AddDate(0, 0, -windowDays).Format(time.RFC3339)
+
+	// Per-day demand so we can get both mean and spread; a kit line is the
+	// components it consumed (order_item_components)
+	rows, err := tools.DB.Query(`
+		SELECT oi.warehouse_id, COALESCE(c.product_id, oi.productId), SUM(COALESCE(c.qty, oi.quantity))
+		FROM order_items oi
+		JOIN orders o ON o.orderId = oi.orderId
+		LEFT JOIN order_item_components c ON c.order_item_id = oi.id
+		WHERE o.createdAt >= ? AND oi.warehouse_id IS NOT NULL
+		  AND COALESCE(c.product_id, oi.productId) NOT IN (SELECT kit_id FROM kit_components)
+		GROUP BY oi.warehouse_id, COALESCE(c.product_id, oi.productId), substr(o.createdAt, 1, 10)`, since)
+	if err != nil {
+		return nil, err
+	}
+	type demand struct{ sum, sumSq float64 }
+	stats := map[stockKey]*demand{}
+	for rows.Next() {
+		var k stockKey
+		var units float64
+		if err := rows.Scan(&k.WarehouseID, &k.ProductID, &units); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		d := stats[k]
+		if d == nil {
+			d = &demand{}
+			stats[k] = d
+		}
+		d.sum += units
+		d.sumSq += units * units
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Every stocked pair gets a row too (velocity 0 -> reorder point 0)
+	invRows, err := tools.DB.Query(`
+		SELECT warehouse_id, product_id FROM warehouse_inventory
+		WHERE product_id NOT IN (SELECT kit_id FROM kit_components)`)
+	if err != nil {
+		return nil, err
+	}
+	for invRows.Next() {
+		var k stockKey
+		if err := invRows.Scan(&k.WarehouseID, &k.ProductID); err != nil {
+			invRows.Close()
+			return nil, err
+		}
+		if stats[k] == nil {
+			stats[k] = &demand{}
+		}
+	}
+	invRows.Close()
+	if err := invRows.Err(); err != nil {
+		return nil, err
+	}
+
+	leadTimes := map[int]int{}
+	now := time.Now().UTC().Format(time.RFC3339)
+	out := make([]models.ReorderPoint, 0, len(stats))
+	for k, d := range stats {
+		lead, ok := leadTimes[k.ProductID]
+		if !ok {
+			sup, err := preferredSupplier(tools.DB, k.ProductID)
+			if err != nil {
+				return nil, err
+			}
+			lead = fallbackLead
+			if sup != nil {
+				lead = sup.LeadTimeDays
+			}
+			leadTimes[k.ProductID] = lead
+		}
+
+		n := float64(windowDays)
+		velocity := d.sum / n
+		variance := d.sumSq/n - velocity*velocity
+		sigma := math.Sqrt(math.Max(variance, 0))
+		safety := int(math.Ceil(reorderServiceZ * sigma * math.Sqrt(float64(lead))))
+		rop := int(math.Ceil(velocity*float64(lead))) + safety
+
+		out = append(out, models.ReorderPoint{
+			WarehouseID:   k.WarehouseID,
+			ProductID:     k.ProductID,
+			ReorderPoint:  rop,
+			SafetyStock:   safety,
+			DailyVelocity: math.Round(velocity*1000) / 1000,
+			LeadTimeDays:  lead,
+			ComputedAt:    now,
+		})
+	}
+	return out, nil
+}
+
+// checkReorderPoint compares on-hand stock with the stored reorder point and
+// returns a product.reorder_needed event the first time stock drops to or below it.
+// The flag resets once stock climbs back above, so each crossing is announced once.
+// Callers broadcast the returned event after their transaction commits.
+func checkReorderPoint(tx *sql.Tx, k stockKey) (*tools.Event, error) {
+	var rop, below int
+	err := tx.QueryRow(
+		`SELECT reorder_point, below_point FROM reorder_points WHERE warehouse_id = ? AND product_id = ?`,
+		k.WarehouseID, k.ProductID,
+	).Scan(&rop, &below)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var onHand int
+	if err := tx.QueryRow(
+		`SELECT COALESCE(SUM(available_qty), 0) FROM warehouse_inventory_available WHERE warehouse_id = ? AND product_id = ?`,
+		k.WarehouseID, k.ProductID,
+	).Scan(&onHand); err != nil {
+		return nil, err
+	}
+
+	isBelow := rop > 0 && onHand <= rop
+	if isBelow == (below == 1) {
+		return nil, nil
+	}
+	flag := 0
+	if isBelow {
+		flag = 1
+	}
+	if _, err := tx.Exec(
+		`UPDATE reorder_points SET below_point = ? WHERE warehouse_id = ? AND product_id = ?`,
+		flag, k.WarehouseID, k.ProductID,
+	); err != nil {
+		return nil, err
+	}
+	if !isBelow {
+		return nil, nil
+	}
+	return &tools.Event{
+		Type: "product.reorder_needed",
+		Data: map[string]any{
+			"warehouseId":  k.WarehouseID,
+			"productId":    k.ProductID,
+			"onHand":       onHand,
+			"reorderPoint": rop,
+		},
+		Time: time.Now(),
+	}, nil
+}
+
+// checkReorderPoints runs checkReorderPoint for each key and collects the events to broadcast.
+func checkReorderPoints(tx *sql.Tx, keys ...stockKey) ([]tools.Event, error) {
+	var events []tools.Event
+	seen := map[stockKey]bool{}
+	for _, k := range keys {
+		if seen[k] {
+			continue
+		}
+		seen[k] = true
+		ev, err := checkReorderPoint(tx, k)
+		if err != nil {
+			return nil, err
+		}
+		if ev != nil {
+			events = append(events, *ev)
+		}
+	}
+	return events, nil
+}
+
+func broadcastEvents(events []tools.Event) {
+	for _, ev := range events {
+		tools.SSE.Broadcast(ev)
+	}
+}
+
+// ---------- Endpoints ----------
+
+// POST /api/reorder-points/recompute?days=30&defaultLeadTime=7
+// Recomputes every non-manual reorder point and announces any pair now at or below its point.
+func recomputeReorderPointsHandler(w http.ResponseWriter, r *http.Request) {
+	days := defaultReorderWindow
+	if d, err := strconv.Atoi(r.URL.Query().Get("days")); err == nil && d > 0 && d <= 365 {
+		days = d
+	}
+	lead := defaultLeadTimeDays
+	if l, err := strconv.Atoi(r.URL.Query().Get("defaultLeadTime")); err == nil && l >= 0 {
+		lead = l
+	}
+
+	points, err := computeReorderPoints(days, lead)
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+
+	tx, err := tools.DB.Begin()
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	defer tx.Rollback()
+
+	// Drop points left on products that have since become kits
+	if _, err := tx.Exec(`DELETE FROM reorder_points WHERE product_id IN (SELECT kit_id FROM kit_components)`); err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+
+	keys := make([]stockKey, 0, len(points))
+	for _, p := range points {
+		if _, err := tx.Exec(`
+			INSERT INTO reorder_points
+				(warehouse_id, product_id, reorder_point, safety_stock, daily_velocity, lead_time_days, computed_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?)
+			ON CONFLICT(warehouse_id, product_id) DO UPDATE SET
+				reorder_point  = excluded.reorder_point,
+				safety_stock   = excluded.safety_stock,
+				daily_velocity = excluded.daily_velocity,
+				lead_time_days = excluded.lead_time_days,
+				computed_at    = excluded.computed_at
+			WHERE reorder_points.manual = 0`,
+			p.WarehouseID, p.ProductID, p.ReorderPoint, p.SafetyStock, p.DailyVelocity, p.LeadTimeDays, p.ComputedAt,
+		); err != nil {
+			tools.HandleInternalServerError(w, err)
+			return
+		}
+		keys = append(keys, stockKey{p.WarehouseID, p.ProductID})
+	}
+
+	events, err := checkReorderPoints(tx, keys...)
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	broadcastEvents(events)
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"computed":      len(points),
+		"windowDays":    days,
+		"reorderAlerts": len(events),
+	})
+}
+
+// GET /api/reorder-points?warehouseId=&productId=
+func getReorderPointsHandler(w http.ResponseWriter, r *http.Request) {
+	var conds []string
+	var args []interface{}
+	if s := r.URL.Query().Get("warehouseId"); s != "" {
+		conds = append(conds, "rp.warehouse_id = ?")
+		args = append(args, s)
+	}
+	if s := r.URL.Query().Get("productId"); s != "" {
+		conds = append(conds, "rp.product_id = ?")
+		args = append(args, s)
+	}
+	where := ""
+	if len(conds) > 0 {
+		where = "WHERE " + strings.Join(conds, " AND ")
+	}
+
+	rows, err := tools.DB.Query(`
+		SELECT rp.warehouse_id, rp.product_id, p.name, rp.reorder_point, rp.safety_stock,
+		       rp.daily_velocity, rp.lead_time_days, rp.manual, COALESCE(wi.available_qty, 0), rp.computed_at
+		FROM reorder_points rp
+		JOIN products p ON p.id = rp.product_id
+		LEFT JOIN warehouse_inventory_available wi
+		  ON wi.warehouse_id = rp.warehouse_id AND wi.product_id = rp.product_id
+		`+where+`
+		ORDER BY rp.warehouse_id, rp.product_id`, args...)
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	defer rows.Close()
+
+	out := []models.ReorderPoint{}
+	for rows.Next() {
+		var p models.ReorderPoint
+		var manual int
+		if err := rows.Scan(&p.WarehouseID, &p.ProductID, &p.ProductName, &p.ReorderPoint, &p.SafetyStock,
+			&p.DailyVelocity, &p.LeadTimeDays, &manual, &p.OnHand, &p.ComputedAt); err != nil {
+			tools.HandleInternalServerError(w, err)
+			return
+		}
+		p.Manual = manual == 1
+		out = append(out, p)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(out)
+}
+
+type reorderPointPut struct {
+	WarehouseID  int `json:"warehouseId"`
+	ProductID    int `json:"productId"`
+	ReorderPoint int `json:"reorderPoint"`
+	SafetyStock  int `json:"safetyStock"`
+}
+
+// PUT /api/reorder-points  body: { "warehouseId": 1, "productId": 2, "reorderPoint": 40, "safetyStock": 10 }
+// Pins a manual reorder point that recompute will not overwrite.
+func putReorderPointHandler(w http.ResponseWriter, r *http.Request) {
+	var body reorderPointPut
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		tools.HandleBadRequest(w, errors.New("invalid request"))
+		return
+	}
+	if body.WarehouseID <= 0 || body.ProductID <= 0 || body.ReorderPoint < 0 || body.SafetyStock < 0 {
+		tools.HandleBadRequest(w, errors.New("warehouseId, productId and non-negative reorderPoint/safetyStock are required"))
+		return
+	}
+
+	tx, err := tools.DB.Begin()
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	defer tx.Rollback()
+
+	if err := ensureNotKit(tx, body.ProductID); err != nil {
+		writeStockError(w, err)
+		return
+	}
+	if _, err := tx.Exec(`
+		INSERT INTO reorder_points (warehouse_id, product_id, reorder_point, safety_stock, manual, computed_at)
+		VALUES (?, ?, ?, ?, 1, ?)
+		ON CONFLICT(warehouse_id, product_id) DO UPDATE SET
+			reorder_point = excluded.reorder_point,
+			safety_stock  = excluded.safety_stock,
+			manual        = 1,
+			computed_at   = excluded.computed_at`,
+		body.WarehouseID, body.ProductID, body.ReorderPoint, body.SafetyStock, time.Now().UTC().Format(time.RFC3339),
+	); err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+
+	events, err := checkReorderPoints(tx, stockKey{body.WarehouseID, body.ProductID})
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	broadcastEvents(events)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// DELETE /api/reorder-points/{warehouseId}/{productId}
+// Drops the manual pin; the next recompute fills the row from sales velocity again.
+func deleteReorderPointHandler(w http.ResponseWriter, r *http.Request) {
+	if _, err := tools.DB.Exec(
+		`UPDATE reorder_points SET manual = 0 WHERE warehouse_id = ? AND product_id = ?`,
+		chi.URLParam(r, "warehouseId"), chi.URLParam(r, "productId"),
+	); err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GET /api/reorder-suggestions?warehouseId=&coverDays=30
+// Lists pairs at or below their reorder point (counting open purchase orders as
+// incoming) with a suggested purchase quantity that covers coverDays of demand.
+func getReorderSuggestionsHandler(w http.ResponseWriter, r *http.Request) {
+	coverDays := defaultCoverDays
+	if c, err := strconv.Atoi(r.URL.Query().Get("coverDays")); err == nil && c >= 0 && c <= 365 {
+		coverDays = c
+	}
+	where := "WHERE rp.product_id NOT IN (SELECT kit_id FROM kit_components)"
+	var args []interface{}
+	if s := r.URL.Query().Get("warehouseId"); s != "" {
+		where += " AND rp.warehouse_id = ?"
+		args = append(args, s)
+	}
+
+	rows, err := tools.DB.Query(`
+		WITH incoming AS (
+			SELECT po.warehouse_id, l.product_id, SUM(l.qty_ordered - l.qty_received) AS qty
+			FROM purchase_order_lines l
+			JOIN purchase_orders po ON po.id = l.purchase_order_id
+			WHERE po.status IN ('ordered', 'partially_received')
+			GROUP BY po.warehouse_id, l.product_id
+		)
+		SELECT rp.warehouse_id, w.name, rp.product_id, p.name, rp.reorder_point, rp.safety_stock,
+		       rp.daily_velocity, COALESCE(wi.available_qty, 0), COALESCE(inc.qty, 0)
+		FROM reorder_points rp
+		JOIN products p   ON p.id = rp.product_id
+		JOIN warehouses w ON w.id = rp.warehouse_id
+		LEFT JOIN warehouse_inventory_available wi
+		  ON wi.warehouse_id = rp.warehouse_id AND wi.product_id = rp.product_id
+		LEFT JOIN incoming inc
+		  ON inc.warehouse_id = rp.warehouse_id AND inc.product_id = rp.product_id
+		`+where+`
+		ORDER BY rp.warehouse_id, rp.product_id`, args...)
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+
+	type suggestion struct {
+		WarehouseID   int             `json:"warehouseId"`
+		WarehouseName string          `json:"warehouseName"`
+		ProductID     int             `json:"productId"`
+		ProductName   string          `json:"productName"`
+		ReorderPoint  int             `json:"reorderPoint"`
+		SafetyStock   int             `json:"safetyStock"`
+		DailyVelocity float64         `json:"dailyVelocity"`
+		OnHand        int             `json:"onHand"`
+		OnOrder       int             `json:"onOrder"`
+		SuggestedQty  int             `json:"suggestedQty"`
+		Supplier      *supplierChoice `json:"supplier"`
+		EstimatedCost float64         `json:"estimatedCost"`
+	}
+	var list []suggestion
+	for rows.Next() {
+		var s suggestion
+		if err := rows.Scan(&s.WarehouseID, &s.WarehouseName, &s.ProductID, &s.ProductName, &s.ReorderPoint,
+			&s.SafetyStock, &s.DailyVelocity, &s.OnHand, &s.OnOrder); err != nil {
+			rows.Close()
+			tools.HandleInternalServerError(w, err)
+			return
+		}
+		position := s.OnHand + s.OnOrder
+		if s.ReorderPoint <= 0 || position > s.ReorderPoint {
+			continue
+		}
+		orderUpTo := s.ReorderPoint + int(math.Ceil(s.DailyVelocity*float64(coverDays)))
+		s.SuggestedQty = orderUpTo - position
+		if s.SuggestedQty <= 0 {
+			continue
+		}
+		list = append(list, s)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+
+	// Attach the preferred supplier once the cursor is closed
+	out := []suggestion{}
+	for _, s := range list {
+		sup, err := preferredSupplier(tools.DB, s.ProductID)
+		if err != nil {
+			tools.HandleInternalServerError(w, err)
+			return
+		}
+		s.Supplier = sup
+		if sup != nil {
+			s.EstimatedCost = float64(s.SuggestedQty) * sup.SupplierCost
+		}
+		out = append(out, s)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(out)
+}
@@ -0,0 +1,563 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/MananKakkar1/SalesBoard/backend/internal/models"
+	"github.com/MananKakkar1/SalesBoard/backend/internal/tools"
+	"github.com/go-chi/chi"
+)
+
+// Tags are free-form labels stored lower-cased ("wholesale", "vip"). Segments are
+// saved rules over tags and order history; their members are worked out on every
+// read, so a customer joins or leaves a segment as soon as their orders change.
+
+const maxTagLength = 50
+
+// ---------- Input DTOs ----------
+
+type tagsIn struct {
+	Tags []string `json:"tags"`
+}
+
+type segmentCU struct {
+	Name        string               `json:"name"`
+	Description string               `json:"description"`
+	Match       string               `json:"match"` // all (default) | any
+	Rules       []models.SegmentRule `json:"rules"`
+}
+
+// ---------- Tag helpers ----------
+
+func normalizeTag(tag string) (string, error) {
+	tag = strings.ToLower(strings.Join(strings.Fields(tag), " "))
+	if tag == "" || len(tag) > maxTagLength {
+		return "", &invalidFieldError{fmt.Sprintf("tags must be 1 to %d characters", maxTagLength)}
+	}
+	return tag, nil
+}
+
+func normalizeTags(tags []string) ([]string, error) {
+	seen := map[string]bool{}
+	out := []string{}
+	for _, t := range tags {
+		t, err := normalizeTag(t)
+		if err != nil {
+			return nil, err
+		}
+		if !seen[t] {
+			seen[t] = true
+			out = append(out, t)
+		}
+	}
+	return out, nil
+}
+
+func loadCustomerTags(q queryer, customerID int) ([]string, error) {
+	rows, err := q.Query(`SELECT tag FROM customer_tags WHERE customer_id = ? ORDER BY tag`, customerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	tags := []string{}
+	for rows.Next() {
+		var t string
+		if err := rows.Scan(&t); err != nil {
+			return nil, err
+		}
+		tags = append(tags, t)
+	}
+	return tags, rows.Err()
+}
+
+func addCustomerTags(tx *sql.Tx, customerID int, tags []string) error {
+	now := time.Now().UTC().Format(time.RFC3339)
+	for _, t := range tags {
+		if _, err := tx.Exec(
+			`INSERT OR IGNORE INTO customer_tags (customer_id, tag, created_at) VALUES (?, ?, ?)`, customerID, t, now,
+		); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ---------- Segment rules ----------
+
+// customerStatsSQL is one row of order totals per customer, joined as "s" next to
+// customers "c" when segment rules are evaluated.
+const customerStatsSQL = `
+	SELECT customerId,
+	       COUNT(*) AS orders,
+	       SUM(totalPrice) AS revenue,
+	       MAX(substr(createdAt, 1, 10)) AS last_order,
+	       SUM(CASE WHEN totalPrice - amount_paid > 0.005 THEN totalPrice - amount_paid ELSE 0 END) AS balance
+	FROM orders
+	GROUP BY customerId`
+
+// segmentFields maps rule fields to SQL over c and s. Customers who never ordered
+// count as having last ordered a million days ago, so "no order in 90 days"
+// (days_since_last_order gt 90) includes them.
+var segmentFields = map[string]string{
+	"lifetime_revenue":      `COALESCE(s.revenue, 0)`,
+	"order_count":           `COALESCE(s.orders, 0)`,
+	"avg_order_value":       `COALESCE(s.revenue / s.orders, 0)`,
+	"outstanding_balance":   `COALESCE(s.balance, 0)`,
+	"days_since_last_order": `COALESCE(CAST(julianday('now') - julianday(s.last_order) AS INTEGER), 1000000)`,
+}
+
+var segmentOps = map[string]string{"gt": ">", "gte": ">=", "lt": "<", "lte": "<=", "eq": "=", "neq": "<>"}
+
+// segmentCondition turns rules into a WHERE condition over customers c joined to
+// customerStatsSQL as s, validating them on the way.
+func segmentCondition(match string, rules []models.SegmentRule) (string, []any, error) {
+	if len(rules) == 0 {
+		return "", nil, &invalidFieldError{"a segment needs at least one rule"}
+	}
+	var conds []string
+	var args []any
+	for i, rule := range rules {
+		if rule.Field == "tag" {
+			tag, ok := rule.Value.(string)
+			if !ok {
+				return "", nil, &invalidFieldError{fmt.Sprintf("rule %d: tag value must be a string", i+1)}
+			}
+			tag, err := normalizeTag(tag)
+			if err != nil {
+				return "", nil, err
+			}
+			cond := `EXISTS (SELECT 1 FROM customer_tags t WHERE t.customer_id = c.id AND t.tag = ?)`
+			switch rule.Op {
+			case "has":
+			case "not_has":
+				cond = "NOT " + cond
+			default:
+				return "", nil, &invalidFieldError{fmt.Sprintf("rule %d: tag op must be has or not_has", i+1)}
+			}
+			conds = append(conds, cond)
+			args = append(args, tag)
+			continue
+		}
+		expr, ok := segmentFields[rule.Field]
+		if !ok {
+			return "", nil, &invalidFieldError{fmt.Sprintf(
+				"rule %d: field must be one of tag, lifetime_revenue, order_count, avg_order_value, outstanding_balance, days_since_last_order", i+1,
+			)}
+		}
+		op, ok := segmentOps[rule.Op]
+		if !ok {
+			return "", nil, &invalidFieldError{fmt.Sprintf("rule %d: op must be one of gt, gte, lt, lte, eq, neq", i+1)}
+		}
+		value, ok := rule.Value.(float64)
+		if !ok {
+			return "", nil, &invalidFieldError{fmt.Sprintf("rule %d: %s value must be a number", i+1, rule.Field)}
+		}
+		conds = append(conds, expr+" "+op+" ?")
+		args = append(args, value)
+	}
+	join := " AND "
+	switch match {
+	case "", "all":
+	case "any":
+		join = " OR "
+	default:
+		return "", nil, &invalidFieldError{"match must be all or any"}
+	}
+	return "(" + strings.Join(conds, join) + ")", args, nil
+}
+
+// segmentMembersSQL selects the ids of customers (archived included) in a segment.
+func segmentMembersSQL(match string, rules []models.SegmentRule) (string, []any, error) {
+	cond, args, err := segmentCondition(match, rules)
+	if err != nil {
+		return "", nil, err
+	}
+	return `SELECT c.id FROM customers c LEFT JOIN (` + customerStatsSQL + `) s ON s.customerId = c.id WHERE ` + cond, args, nil
+}
+
+const segmentColumns = `id, name, description, match, rules, created_at, updated_at`
+
+func scanSegment(row rowScanner) (models.CustomerSegment, error) {
+	var s models.CustomerSegment
+	var rules string
+	if err := row.Scan(&s.ID, &s.Name, &s.Description, &s.Match, &rules, &s.CreatedAt, &s.UpdatedAt); err != nil {
+		return s, err
+	}
+	return s, json.Unmarshal([]byte(rules), &s.Rules)
+}
+
+// loadSegment returns the segment with its count of active members, or sql.ErrNoRows.
+func loadSegment(q queryer, id int) (models.CustomerSegment, error) {
+	s, err := scanSegment(q.QueryRow(`SELECT `+segmentColumns+` FROM customer_segments WHERE id = ?`, id))
+	if err != nil {
+		return s, err
+	}
+	members, args, err := segmentMembersSQL(s.Match, s.Rules)
+	if err != nil {
+		return s, err
+	}
+	err = q.QueryRow(`SELECT COUNT(*) FROM customers WHERE deleted_at IS NULL AND id IN (`+members+`)`, args...).Scan(&s.MemberCount)
+	return s, err
+}
+
+// customerFilters reads ?tag= (repeatable; all must be present) and ?segment=<id>
+// for the customer list and search endpoints, returning an " AND ..." clause over
+// the customers table.
+func customerFilters(r *http.Request) (string, []any, error) {
+	var clause string
+	var args []any
+	for _, t := range r.URL.Query()["tag"] {
+		tag, err := normalizeTag(t)
+		if err != nil {
+			return "", nil, err
+		}
+		clause += ` AND id IN (SELECT customer_id FROM customer_tags WHERE tag = ?)`
+		args = append(args, tag)
+	}
+	if s := r.URL.Query().Get("segment"); s != "" {
+		id, err := atoiParam(s)
+		if err != nil {
+			return "", nil, &invalidFieldError{"invalid segment"}
+		}
+		seg, err := scanSegment(tools.DB.QueryRow(`SELECT `+segmentColumns+` FROM customer_segments WHERE id = ?`, id))
+		if err == sql.ErrNoRows {
+			return "", nil, &invalidFieldError{fmt.Sprintf("segment %d does not exist", id)}
+		}
+		if err != nil {
+			return "", nil, err
+		}
+		members, margs, err := segmentMembersSQL(seg.Match, seg.Rules)
+		if err != nil {
+			return "", nil, err
+		}
+		clause += ` AND id IN (` + members + `)`
+		args = append(args, margs...)
+	}
+	return clause, args, nil
+}
+
+// ---------- Customer tags ----------
+
+// GET /api/customers/{id}/tags
+func getCustomerTagsHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := atoiParam(chi.URLParam(r, "id"))
+	if err != nil {
+		tools.HandleBadRequest(w, err)
+		return
+	}
+	if ok, err := customerExists(tools.DB, id); err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	} else if !ok {
+		http.Error(w, "Customer not found", http.StatusNotFound)
+		return
+	}
+	tags, err := loadCustomerTags(tools.DB, id)
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]any{"customerId": id, "tags": tags})
+}
+
+// customerTagsHandler adds tags (POST) or replaces the customer's tags (PUT).
+// body: { "tags": ["wholesale", "vip"] }
+func customerTagsHandler(replace bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := atoiParam(chi.URLParam(r, "id"))
+		if err != nil {
+			tools.HandleBadRequest(w, err)
+			return
+		}
+		var in tagsIn
+		if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+			tools.HandleBadRequest(w, errors.New("invalid request"))
+			return
+		}
+		tags, err := normalizeTags(in.Tags)
+		if err != nil {
+			writeFieldError(w, err)
+			return
+		}
+
+		tx, err := tools.DB.Begin()
+		if err != nil {
+			tools.HandleInternalServerError(w, err)
+			return
+		}
+		defer tx.Rollback()
+		if ok, err := customerExists(tx, id); err != nil {
+			tools.HandleInternalServerError(w, err)
+			return
+		} else if !ok {
+			http.Error(w, "Customer not found", http.StatusNotFound)
+			return
+		}
+		if replace {
+			args := []any{id}
+			keep := ""
+			if len(tags) > 0 {
+				keep = ` AND tag NOT IN (?` + strings.Repeat(`, ?`, len(tags)-1) + `)`
+				for _, t := range tags {
+					args = append(args, t)
+				}
+			}
+			if _, err := tx.Exec(`DELETE FROM customer_tags WHERE customer_id = ?`+keep, args...); err != nil {
+				tools.HandleInternalServerError(w, err)
+				return
+			}
+		}
+		if err := addCustomerTags(tx, id, tags); err != nil {
+			tools.HandleInternalServerError(w, err)
+			return
+		}
+		if err := tx.Commit(); err != nil {
+			tools.HandleInternalServerError(w, err)
+			return
+		}
+		getCustomerTagsHandler(w, r)
+	}
+}
+
+// DELETE /api/customers/{id}/tags/{tag}
+func deleteCustomerTagHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := atoiParam(chi.URLParam(r, "id"))
+	if err != nil {
+		tools.HandleBadRequest(w, err)
+		return
+	}
+	raw, err := url.PathUnescape(chi.URLParam(r, "tag"))
+	if err != nil {
+		tools.HandleBadRequest(w, errors.New("invalid tag"))
+		return
+	}
+	tag, err := normalizeTag(raw)
+	if err != nil {
+		writeFieldError(w, err)
+		return
+	}
+	res, err := tools.DB.Exec(`DELETE FROM customer_tags WHERE customer_id = ? AND tag = ?`, id, tag)
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		http.Error(w, "Tag not found", http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GET /api/customer-tags  — tags in use, most used first
+func getCustomerTagCountsHandler(w http.ResponseWriter, r *http.Request) {
+	rows, err := tools.DB.Query(`
+		SELECT t.tag, COUNT(*) AS n
+		FROM customer_tags t
+		JOIN customers c ON c.id = t.customer_id AND c.deleted_at IS NULL
+		GROUP BY t.tag
+		ORDER BY n DESC, t.tag`)
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	defer rows.Close()
+	out := []models.CustomerTagCount{}
+	for rows.Next() {
+		var t models.CustomerTagCount
+		if err := rows.Scan(&t.Tag, &t.Customers); err != nil {
+			tools.HandleInternalServerError(w, err)
+			return
+		}
+		out = append(out, t)
+	}
+	if err := rows.Err(); err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(out)
+}
+
+// ---------- Segments ----------
+
+func (s *segmentCU) normalize() error {
+	s.Name = strings.TrimSpace(s.Name)
+	if s.Name == "" {
+		return &invalidFieldError{"name is required"}
+	}
+	s.Description = strings.TrimSpace(s.Description)
+	if s.Match == "" {
+		s.Match = "all"
+	}
+	_, _, err := segmentCondition(s.Match, s.Rules)
+	return err
+}
+
+// checkSegmentName rejects a second segment with the same name.
+func checkSegmentName(q queryer, id int, name string) error {
+	var n int
+	if err := q.QueryRow(
+		`SELECT COUNT(*) FROM customer_segments WHERE name = ? COLLATE NOCASE AND id <> ?`, name, id,
+	).Scan(&n); err != nil {
+		return err
+	}
+	if n > 0 {
+		return &conflictError{fmt.Sprintf("segment %s already exists", name)}
+	}
+	return nil
+}
+
+// POST /api/customer-segments
+// body: { "name": "Lapsed VIPs", "match": "all", "rules": [
+//
+//	{ "field": "tag", "op": "has", "value": "vip" },
+//	{ "field": "days_since_last_order", "op": "gt", "value": 90 } ] }
+func createSegmentHandler(w http.ResponseWriter, r *http.Request) {
+	var in segmentCU
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		tools.HandleBadRequest(w, errors.New("invalid request"))
+		return
+	}
+	if err := in.normalize(); err != nil {
+		writeFieldError(w, err)
+		return
+	}
+	if err := checkSegmentName(tools.DB, 0, in.Name); err != nil {
+		writeFieldError(w, err)
+		return
+	}
+	rules, _ := json.Marshal(in.Rules)
+	now := time.Now().UTC().Format(time.RFC3339)
+	res, err := tools.DB.Exec(
+		`INSERT INTO customer_segments (name, description, match, rules, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?)`,
+		in.Name, in.Description, in.Match, string(rules), now, now,
+	)
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	id, _ := res.LastInsertId()
+	s, err := loadSegment(tools.DB, int(id))
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(s)
+}
+
+// GET /api/customer-segments
+func getSegmentsHandler(w http.ResponseWriter, r *http.Request) {
+	rows, err := tools.DB.Query(`SELECT id FROM customer_segments ORDER BY name`)
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			tools.HandleInternalServerError(w, err)
+			return
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+
+	out := []models.CustomerSegment{}
+	for _, id := range ids {
+		s, err := loadSegment(tools.DB, id)
+		if err != nil {
+			tools.HandleInternalServerError(w, err)
+			return
+		}
+		out = append(out, s)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(out)
+}
+
+// GET /api/customer-segments/{id}
+func getSegmentHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := atoiParam(chi.URLParam(r, "id"))
+	if err != nil {
+		tools.HandleBadRequest(w, err)
+		return
+	}
+	s, err := loadSegment(tools.DB, id)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Segment not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(s)
+}
+
+// PUT /api/customer-segments/{id}  body as for create
+func updateSegmentHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := atoiParam(chi.URLParam(r, "id"))
+	if err != nil {
+		tools.HandleBadRequest(w, err)
+		return
+	}
+	var in segmentCU
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		tools.HandleBadRequest(w, errors.New("invalid request"))
+		return
+	}
+	if err := in.normalize(); err != nil {
+		writeFieldError(w, err)
+		return
+	}
+	if err := checkSegmentName(tools.DB, id, in.Name); err != nil {
+		writeFieldError(w, err)
+		return
+	}
+	rules, _ := json.Marshal(in.Rules)
+	res, err := tools.DB.Exec(
+		`UPDATE customer_segments SET name = ?, description = ?, match = ?, rules = ?, updated_at = ? WHERE id = ?`,
+		in.Name, in.Description, in.Match, string(rules), time.Now().UTC().Format(time.RFC3339), id,
+	)
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		http.Error(w, "Segment not found", http.StatusNotFound)
+		return
+	}
+	getSegmentHandler(w, r)
+}
+
+// DELETE /api/customer-segments/{id}
+func deleteSegmentHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := atoiParam(chi.URLParam(r, "id"))
+	if err != nil {
+		tools.HandleBadRequest(w, err)
+		return
+	}
+	res, err := tools.DB.Exec(`DELETE FROM customer_segments WHERE id = ?`, id)
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		http.Error(w, "Segment not found", http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
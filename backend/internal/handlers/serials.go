@@ -0,0 +1,483 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/MananKakkar1/SalesBoard/backend/internal/models"
+	"github.com/MananKakkar1/SalesBoard/backend/internal/tools"
+	"github.com/go-chi/chi"
+)
+
+const (
+	serialStatusInStock = "in_stock"
+	serialStatusSold    = "sold"
+)
+
+// ---------- Errors ----------
+
+// serialConflictError is returned when registering a serial the product already has.
+type serialConflictError struct {
+	ProductID    int
+	SerialNumber string
+}
+
+func (e *serialConflictError) Error() string {
+	return fmt.Sprintf("serial %s is already registered for product %d", e.SerialNumber, e.ProductID)
+}
+
+// serialUnavailableError is returned when a serial is not in stock where it is being taken from.
+type serialUnavailableError struct {
+	stockKey
+	SerialNumber string
+}
+
+func (e *serialUnavailableError) Error() string {
+	return fmt.Sprintf("serial %s of product %d is not in stock in warehouse %d",
+		e.SerialNumber, e.ProductID, e.WarehouseID)
+}
+
+// ---------- Input DTOs ----------
+
+// existingStock registers serials for units already on hand instead of receiving new ones.
+type serialRegisterBody struct {
+	ProductID     int      `json:"productId"`
+	Serials       []string `json:"serials"`
+	ExistingStock bool     `json:"existingStock"`
+}
+
+type serialAssignBody struct {
+	Serials []string `json:"serials"`
+}
+
+// ---------- helpers ----------
+
+func isSerialTracked(q queryer, productID int) (bool, error) {
+	var tracked bool
+	err := q.QueryRow(`SELECT serial_tracked FROM products WHERE id = ?`, productID).Scan(&tracked)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return tracked, err
+}
+
+// normalizeSerials trims serials and rejects blanks and repeats within one request.
+func normalizeSerials(in []string) ([]string, error) {
+	seen := make(map[string]bool, len(in))
+	out := make([]string, 0, len(in))
+	for _, s := range in {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			return nil, errors.New("serials cannot be blank")
+		}
+		if seen[s] {
+			return nil, fmt.Errorf("serial %s is listed twice", s)
+		}
+		seen[s] = true
+		out = append(out, s)
+	}
+	return out, nil
+}
+
+// checkSerialCount validates serials against the units they describe. Serial-tracked
+// products need one serial per unit when serials are required; untracked products take none.
+func checkSerialCount(tracked, required bool, productID, qty int, serials []string) error {
+	if !tracked {
+		if len(serials) > 0 {
+			return fmt.Errorf("product %d is not serial-tracked", productID)
+		}
+		return nil
+	}
+	if len(serials) == 0 && !required {
+		return nil
+	}
+	if len(serials) != qty {
+		return fmt.Errorf("product %d is serial-tracked: expected %d serials, got %d", productID, qty, len(serials))
+	}
+	return nil
+}
+
+// registerSerials records new in-stock units in a warehouse. purchaseOrderID is 0 when
+// the units did not arrive on a purchase order.
+func registerSerials(tx *sql.Tx, k stockKey, serials []string, purchaseOrderID int) error {
+	var poID any
+	if purchaseOrderID > 0 {
+		poID = purchaseOrderID
+	}
+	receivedAt := time.Now().UTC().Format(time.RFC3339)
+	for _, s := range serials {
+		var n int
+		if err := tx.QueryRow(
+			`SELECT COUNT(*) FROM serial_numbers WHERE product_id = ? AND serial_number = ?`, k.ProductID, s,
+		).Scan(&n); err != nil {
+			return err
+		}
+		if n > 0 {
+			return &serialConflictError{ProductID: k.ProductID, SerialNumber: s}
+		}
+		if _, err := tx.Exec(`
+			INSERT INTO serial_numbers (product_id, serial_number, status, warehouse_id, purchase_order_id, received_at)
+			VALUES (?, ?, ?, ?, ?, ?)`,
+			k.ProductID, s, serialStatusInStock, k.WarehouseID, poID, receivedAt,
+		); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// inStockSerialID returns the id of an in-stock serial at k, or *serialUnavailableError.
+func inStockSerialID(tx *sql.Tx, k stockKey, serial string) (int, error) {
+	var id int
+	err := tx.QueryRow(`
+		SELECT id FROM serial_numbers
+		WHERE product_id = ? AND serial_number = ? AND status = ? AND warehouse_id = ?`,
+		k.ProductID, serial, serialStatusInStock, k.WarehouseID,
+	).Scan(&id)
+	if err == sql.ErrNoRows {
+		return 0, &serialUnavailableError{stockKey: k, SerialNumber: serial}
+	}
+	return id, err
+}
+
+// sellSerials ties in-stock serials at k to an order line.
+func sellSerials(tx *sql.Tx, k stockKey, orderItemID int, serials []string) error {
+	soldAt := time.Now().UTC().Format(time.RFC3339)
+	for _, s := range serials {
+		id, err := inStockSerialID(tx, k, s)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(`
+			UPDATE serial_numbers
+			SET status = ?, order_item_id = ?, sold_at = ?, warehouse_id = NULL
+			WHERE id = ?`,
+			serialStatusSold, orderItemID, soldAt, id,
+		); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// moveSerials relocates in-stock serials between warehouses alongside a stock transfer.
+func moveSerials(tx *sql.Tx, from stockKey, toWarehouseID int, serials []string) error {
+	for _, s := range serials {
+		id, err := inStockSerialID(tx, from, s)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(`UPDATE serial_numbers SET warehouse_id = ? WHERE id = ?`, toWarehouseID, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadOrderItemSerials returns the serials assigned to an order line.
+func loadOrderItemSerials(q queryer, orderItemID int) ([]string, error) {
+	rows, err := q.Query(
+		`SELECT serial_number FROM serial_numbers WHERE order_item_id = ? ORDER BY serial_number`, orderItemID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []string{}
+	for rows.Next() {
+		var s string
+		if err := rows.Scan(&s); err != nil {
+			return nil, err
+		}
+		out = append(out, s)
+	}
+	return out, rows.Err()
+}
+
+// ---------- Register (POST /api/warehouses/{id}/serials) ----------
+// body: { "productId": 7, "serials": ["SN-001", "SN-002"], "existingStock": false }
+// Receives one unit per serial, or with existingStock labels units already on hand.
+func registerSerialsHandler(w http.ResponseWriter, r *http.Request) {
+	warehouseID, err := atoiParam(chi.URLParam(r, "id"))
+	if err != nil {
+		tools.HandleBadRequest(w, err)
+		return
+	}
+	var body serialRegisterBody
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		tools.HandleBadRequest(w, errors.New("invalid request"))
+		return
+	}
+	serials, err := normalizeSerials(body.Serials)
+	if err != nil {
+		tools.HandleBadRequest(w, err)
+		return
+	}
+	if body.ProductID <= 0 || len(serials) == 0 {
+		tools.HandleBadRequest(w, errors.New("productId and serials are required"))
+		return
+	}
+
+	tx, err := tools.DB.Begin()
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	defer tx.Rollback()
+
+	var exists int
+	if err := tx.QueryRow(`SELECT COUNT(*) FROM warehouses WHERE id = ?`, warehouseID).Scan(&exists); err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	if exists == 0 {
+		http.Error(w, "Warehouse not found", http.StatusNotFound)
+		return
+	}
+	tracked, err := isSerialTracked(tx, body.ProductID)
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	if !tracked {
+		tools.HandleBadRequest(w, fmt.Errorf("product %d is not serial-tracked", body.ProductID))
+		return
+	}
+
+	k := stockKey{warehouseID, body.ProductID}
+	if body.ExistingStock {
+		var unlabelled int
+		if err := tx.QueryRow(`
+			SELECT COALESCE((SELECT qty FROM warehouse_inventory WHERE warehouse_id = ? AND product_id = ?), 0)
+			     - (SELECT COUNT(*) FROM serial_numbers WHERE warehouse_id = ? AND product_id = ? AND status = ?)`,
+			warehouseID, body.ProductID, warehouseID, body.ProductID, serialStatusInStock,
+		).Scan(&unlabelled); err != nil {
+			tools.HandleInternalServerError(w, err)
+			return
+		}
+		if unlabelled < len(serials) {
+			tools.HandleBadRequest(w, fmt.Errorf("only %d units of product %d in warehouse %d have no serial",
+				max(unlabelled, 0), body.ProductID, warehouseID))
+			return
+		}
+	} else if err := addWarehouseStock(tx, warehouseID, body.ProductID, len(serials)); err != nil {
+		writeStockError(w, err)
+		return
+	}
+	if err := registerSerials(tx, k, serials, 0); err != nil {
+		writeStockError(w, err)
+		return
+	}
+
+	// Received units go to open backorders first; labelling stock on hand adds none
+	var filled []tools.Event
+	if !body.ExistingStock {
+		if filled, err = fillBackorders(tx, k); err != nil {
+			tools.HandleInternalServerError(w, err)
+			return
+		}
+	}
+	alerts, err := checkReorderPoints(tx, k)
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+
+	if !body.ExistingStock {
+		tools.SSE.Broadcast(tools.Event{
+			Type: "warehouse.inventory_updated",
+			Data: map[string]any{"warehouseId": warehouseID, "count": 1},
+			Time: time.Now(),
+		})
+	}
+	broadcastEvents(filled)
+	broadcastEvents(alerts)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(map[string]any{"productId": body.ProductID, "warehouseId": warehouseID, "registered": len(serials)})
+}
+
+// ---------- Fulfilment (POST /api/orders/{id}/items/{itemId}/serials) ----------
+// body: { "serials": ["SN-001"] }
+// Assigns serials to an order line that was created without them. Stock was already
+// deducted when the order was placed; this only records which units were shipped.
+func assignOrderItemSerialsHandler(w http.ResponseWriter, r *http.Request) {
+	orderID := chi.URLParam(r, "id")
+	itemID, err := atoiParam(chi.URLParam(r, "itemId"))
+	if err != nil {
+		tools.HandleBadRequest(w, err)
+		return
+	}
+	var body serialAssignBody
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		tools.HandleBadRequest(w, errors.New("invalid request"))
+		return
+	}
+	serials, err := normalizeSerials(body.Serials)
+	if err != nil {
+		tools.HandleBadRequest(w, err)
+		return
+	}
+	if len(serials) == 0 {
+		tools.HandleBadRequest(w, errors.New("serials are required"))
+		return
+	}
+
+	tx, err := tools.DB.Begin()
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	defer tx.Rollback()
+
+	var productID, qty, warehouseID int
+	err = tx.QueryRow(`
+		SELECT productId, quantity, COALESCE(warehouse_id, 0)
+		FROM order_items
+		WHERE id = ? AND orderId = ?`, itemID, orderID,
+	).Scan(&productID, &qty, &warehouseID)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Order item not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	tracked, err := isSerialTracked(tx, productID)
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	if !tracked {
+		tools.HandleBadRequest(w, fmt.Errorf("product %d is not serial-tracked", productID))
+		return
+	}
+
+	var assigned int
+	if err := tx.QueryRow(`SELECT COUNT(*) FROM serial_numbers WHERE order_item_id = ?`, itemID).Scan(&assigned); err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	if assigned+len(serials) > qty {
+		tools.HandleBadRequest(w, fmt.Errorf("order line has %d units and %d serials already assigned", qty, assigned))
+		return
+	}
+	if err := sellSerials(tx, stockKey{warehouseID, productID}, itemID, serials); err != nil {
+		writeStockError(w, err)
+		return
+	}
+
+	all, err := loadOrderItemSerials(tx, itemID)
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"orderItemId": itemID,
+		"quantity":    qty,
+		"serials":     all,
+		"pending":     qty - len(all),
+	})
+}
+
+// ---------- Search (GET /api/serials?serial=&q=&productId=&warehouseId=&status=&customerId=&orderId=&page=&pageSize=) ----------
+// serial is an exact match, q a substring match. Sold serials carry their order and customer.
+func getSerialsHandler(w http.ResponseWriter, r *http.Request) {
+	page, pageSize, offset := parsePage(r)
+	q := r.URL.Query()
+
+	var conds []string
+	var args []interface{}
+	if s := strings.TrimSpace(q.Get("serial")); s != "" {
+		conds = append(conds, "s.serial_number = ?")
+		args = append(args, s)
+	}
+	if s := strings.TrimSpace(q.Get("q")); s != "" {
+		conds = append(conds, "LOWER(s.serial_number) LIKE ?")
+		args = append(args, "%"+strings.ToLower(s)+"%")
+	}
+	for _, f := range [][2]string{
+		{"productId", "s.product_id"},
+		{"warehouseId", "s.warehouse_id"},
+		{"status", "s.status"},
+		{"customerId", "o.customerId"},
+		{"orderId", "o.orderId"},
+	} {
+		if s := q.Get(f[0]); s != "" {
+			conds = append(conds, f[1]+" = ?")
+			args = append(args, s)
+		}
+	}
+	where := ""
+	if len(conds) > 0 {
+		where = "WHERE " + strings.Join(conds, " AND ")
+	}
+
+	const from = `
+		FROM serial_numbers s
+		JOIN products p         ON p.id = s.product_id
+		LEFT JOIN warehouses w  ON w.id = s.warehouse_id
+		LEFT JOIN order_items oi ON oi.id = s.order_item_id
+		LEFT JOIN orders o      ON o.orderId = oi.orderId
+		LEFT JOIN customers c   ON c.id = o.customerId
+		`
+
+	var totalCount int
+	if err := tools.DB.QueryRow("SELECT COUNT(*) "+from+where, args...).Scan(&totalCount); err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+
+	rows, err := tools.DB.Query(`
+		SELECT s.id, s.product_id, p.name, s.serial_number, s.status, s.warehouse_id, COALESCE(w.name, ''),
+		       s.purchase_order_id, o.orderId, s.order_item_id, o.customerId, COALESCE(c.name, ''),
+		       s.received_at, COALESCE(s.sold_at, '')
+		`+from+where+`
+		ORDER BY s.serial_number, s.product_id
+		LIMIT ? OFFSET ?`, append(args, pageSize, offset)...)
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	defer rows.Close()
+
+	out := []models.SerialNumber{}
+	for rows.Next() {
+		var s models.SerialNumber
+		if err := rows.Scan(&s.ID, &s.ProductID, &s.ProductName, &s.SerialNumber, &s.Status, &s.WarehouseID,
+			&s.WarehouseName, &s.PurchaseOrderID, &s.OrderID, &s.OrderItemID, &s.CustomerID, &s.CustomerName,
+			&s.ReceivedAt, &s.SoldAt); err != nil {
+			tools.HandleInternalServerError(w, err)
+			return
+		}
+		out = append(out, s)
+	}
+	if err := rows.Err(); err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"data":       out,
+		"pagination": paginationMeta(page, pageSize, totalCount),
+	})
+}
@@ -1,6 +1,13 @@
 package handlers
 
-import "math"
+import (
+	"database/sql"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/MananKakkar1/SalesBoard/backend/internal/models"
+)
 
 // Haversine distance in kilometers
 func haversineKm(lat1, lon1, lat2, lon2 float64) float64 {
@@ -29,3 +36,49 @@ func shippingCostKm(base, ratePerKm, km, weight float64) float64 {
 	}
 	return base + ratePerKm*km*w
 }
+
+var defaultShipParams = ShipParams{BasePerShipment: 2.00, RatePerKm: 0.50, WeightFactor: 1.0}
+
+// warehouseCoords reads a warehouse's position: the numeric lat/lng columns when
+// set, else the latitude/longitude text the warehouse API stores.
+func warehouseCoords(q queryer, warehouseID int) (lat, lng float64, ok bool, err error) {
+	var nlat, nlng sql.NullFloat64
+	var slat, slng string
+	if err := q.QueryRow(
+		`SELECT lat, lng, latitude, longitude FROM warehouses WHERE id = ?`, warehouseID,
+	).Scan(&nlat, &nlng, &slat, &slng); err != nil {
+		return 0, 0, false, err
+	}
+	if nlat.Valid && nlng.Valid {
+		return nlat.Float64, nlng.Float64, true, nil
+	}
+	lat, err1 := strconv.ParseFloat(strings.TrimSpace(slat), 64)
+	lng, err2 := strconv.ParseFloat(strings.TrimSpace(slng), 64)
+	if err1 != nil || err2 != nil {
+		return 0, 0, false, nil
+	}
+	return lat, lng, true, nil
+}
+
+// orderShippingCost prices one shipment per warehouse to the shipping address:
+// base + rate * distance * weight, with weight the sum of product weight x qty.
+// It is 0 when the address has no coordinates; warehouses without usable
+// coordinates are skipped.
+func orderShippingCost(q queryer, addr *models.CustomerAddress, weightByWarehouse map[int]float64, p ShipParams) (float64, error) {
+	if addr == nil || addr.Lat == nil || addr.Lng == nil {
+		return 0, nil
+	}
+	total := 0.0
+	for warehouseID, weight := range weightByWarehouse {
+		lat, lng, ok, err := warehouseCoords(q, warehouseID)
+		if err != nil {
+			return 0, err
+		}
+		if !ok {
+			continue
+		}
+		km := haversineKm(lat, lng, *addr.Lat, *addr.Lng)
+		total += shippingCostKm(p.BasePerShipment, p.RatePerKm, km, weight*p.WeightFactor)
+	}
+	return math.Round(total*100) / 100, nil
+}
@@ -0,0 +1,307 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/MananKakkar1/SalesBoard/backend/internal/models"
+	"github.com/MananKakkar1/SalesBoard/backend/internal/tools"
+	"github.com/go-chi/chi"
+)
+
+// ---------- Input DTOs ----------
+
+type supplierCU struct {
+	Name         string `json:"name"`
+	ContactName  string `json:"contactName"`
+	Email        string `json:"email"`
+	Phone        string `json:"phone"`
+	LeadTimeDays int    `json:"leadTimeDays"`
+}
+
+type supplierProductIn struct {
+	ProductID    int     `json:"productId"`
+	SupplierCost float64 `json:"supplierCost"`
+	SupplierSKU  string  `json:"supplierSku"`
+}
+
+type supplierProductsPatch struct {
+	Items []supplierProductIn `json:"items"`
+}
+
+func (b supplierCU) validate() error {
+	if strings.TrimSpace(b.Name) == "" {
+		return errors.New("name is required")
+	}
+	if b.LeadTimeDays < 0 {
+		return errors.New("leadTimeDays cannot be negative")
+	}
+	return nil
+}
+
+// ---------- Suppliers CRUD ----------
+
+// POST /api/suppliers
+func createSupplierHandler(w http.ResponseWriter, r *http.Request) {
+	var body supplierCU
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		tools.HandleBadRequest(w, errors.New("invalid request"))
+		return
+	}
+	if err := body.validate(); err != nil {
+		tools.HandleBadRequest(w, err)
+		return
+	}
+
+	createdAt := time.Now().UTC().Format(time.RFC3339)
+	res, err := tools.DB.Exec(
+		`INSERT INTO suppliers (name, contact_name, email, phone, lead_time_days, created_at)
+		 VALUES (?, ?, ?, ?, ?, ?)`,
+		strings.TrimSpace(body.Name), body.ContactName, body.Email, body.Phone, body.LeadTimeDays, createdAt,
+	)
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	id, _ := res.LastInsertId()
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(models.Supplier{
+		ID:           int(id),
+		Name:         strings.TrimSpace(body.Name),
+		ContactName:  body.ContactName,
+		Email:        body.Email,
+		Phone:        body.Phone,
+		LeadTimeDays: body.LeadTimeDays,
+		CreatedAt:    createdAt,
+	})
+}
+
+// GET /api/suppliers?search=&page=&pageSize=
+func getSuppliersHandler(w http.ResponseWriter, r *http.Request) {
+	search := strings.TrimSpace(r.URL.Query().Get("search"))
+	page, pageSize, offset := parsePage(r)
+
+	where := ""
+	var args []interface{}
+	if search != "" {
+		like := "%" + strings.ToLower(search) + "%"
+		where = "WHERE LOWER(name) LIKE ? OR LOWER(COALESCE(contact_name, '')) LIKE ? OR LOWER(COALESCE(email, '')) LIKE ?"
+		args = []interface{}{like, like, like}
+	}
+
+	var totalCount int
+	if err := tools.DB.QueryRow("SELECT COUNT(*) FROM suppliers "+where, args...).Scan(&totalCount); err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+
+	rows, err := tools.DB.Query(`
+		SELECT id, name, COALESCE(contact_name, ''), COALESCE(email, ''), COALESCE(phone, ''), lead_time_days, created_at
+		FROM suppliers `+where+`
+		ORDER BY id
+		LIMIT ? OFFSET ?`, append(args, pageSize, offset)...)
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	defer rows.Close()
+
+	list := []models.Supplier{}
+	for rows.Next() {
+		var s models.Supplier
+		if err := rows.Scan(&s.ID, &s.Name, &s.ContactName, &s.Email, &s.Phone, &s.LeadTimeDays, &s.CreatedAt); err != nil {
+			tools.HandleInternalServerError(w, err)
+			return
+		}
+		list = append(list, s)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"data":       list,
+		"pagination": paginationMeta(page, pageSize, totalCount),
+	})
+}
+
+// GET /api/suppliers/{id}
+func getSupplierByIdHandler(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	var s models.Supplier
+	err := tools.DB.QueryRow(`
+		SELECT id, name, COALESCE(contact_name, ''), COALESCE(email, ''), COALESCE(phone, ''), lead_time_days, created_at
+		FROM suppliers WHERE id = ?`, id,
+	).Scan(&s.ID, &s.Name, &s.ContactName, &s.Email, &s.Phone, &s.LeadTimeDays, &s.CreatedAt)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Supplier not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(s)
+}
+
+// PUT /api/suppliers/{id}
+func updateSupplierHandler(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	var body supplierCU
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		tools.HandleBadRequest(w, errors.New("invalid request"))
+		return
+	}
+	if err := body.validate(); err != nil {
+		tools.HandleBadRequest(w, err)
+		return
+	}
+
+	res, err := tools.DB.Exec(
+		`UPDATE suppliers SET name=?, contact_name=?, email=?, phone=?, lead_time_days=? WHERE id=?`,
+		strings.TrimSpace(body.Name), body.ContactName, body.Email, body.Phone, body.LeadTimeDays, id,
+	)
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		http.Error(w, "Supplier not found", http.StatusNotFound)
+		return
+	}
+
+	// return updated row
+	getSupplierByIdHandler(w, r)
+}
+
+// DELETE /api/suppliers/{id}
+// Suppliers referenced by purchase orders are kept (FK RESTRICT) so PO history stays intact.
+func deleteSupplierHandler(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	var poCount int
+	if err := tools.DB.QueryRow(`SELECT COUNT(*) FROM purchase_orders WHERE supplier_id = ?`, id).Scan(&poCount); err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	if poCount > 0 {
+		http.Error(w, "Supplier has purchase orders and cannot be deleted", http.StatusConflict)
+		return
+	}
+
+	if _, err := tools.DB.Exec(`DELETE FROM suppliers WHERE id = ?`, id); err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ---------- Per-product supplier cost ----------
+
+// GET /api/suppliers/{id}/products -> [{supplierId, productId, productName, supplierCost, supplierSku}]
+func getSupplierProductsHandler(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	rows, err := tools.DB.Query(`
+		SELECT sp.supplier_id, sp.product_id, p.name, sp.supplier_cost, COALESCE(sp.supplier_sku, '')
+		FROM supplier_products sp
+		JOIN products p ON p.id = sp.product_id
+		WHERE sp.supplier_id = ?
+		ORDER BY sp.product_id`, id)
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	defer rows.Close()
+
+	out := []models.SupplierProduct{}
+	for rows.Next() {
+		var sp models.SupplierProduct
+		if err := rows.Scan(&sp.SupplierID, &sp.ProductID, &sp.ProductName, &sp.SupplierCost, &sp.SupplierSKU); err != nil {
+			tools.HandleInternalServerError(w, err)
+			return
+		}
+		out = append(out, sp)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(out)
+}
+
+// PATCH /api/suppliers/{id}/products  body: { "items": [ { "productId": 1, "supplierCost": 4.2, "supplierSku": "AC-1" } ] }
+func upsertSupplierProductsHandler(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	var body supplierProductsPatch
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		tools.HandleBadRequest(w, errors.New("invalid request"))
+		return
+	}
+	if len(body.Items) == 0 {
+		tools.HandleBadRequest(w, errors.New("no items to update"))
+		return
+	}
+
+	tx, err := tools.DB.Begin()
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	defer tx.Rollback()
+
+	var exists int
+	if err := tx.QueryRow(`SELECT COUNT(*) FROM suppliers WHERE id = ?`, id).Scan(&exists); err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	if exists == 0 {
+		http.Error(w, "Supplier not found", http.StatusNotFound)
+		return
+	}
+
+	for _, it := range body.Items {
+		if it.ProductID <= 0 || it.SupplierCost < 0 {
+			tools.HandleBadRequest(w, errors.New("invalid productId or supplierCost"))
+			return
+		}
+		var pexists int
+		if err := tx.QueryRow(`SELECT COUNT(*) FROM products WHERE id = ?`, it.ProductID).Scan(&pexists); err != nil {
+			tools.HandleInternalServerError(w, err)
+			return
+		}
+		if pexists == 0 {
+			tools.HandleBadRequest(w, errors.New("product does not exist"))
+			return
+		}
+		if _, err := tx.Exec(`
+			INSERT INTO supplier_products (supplier_id, product_id, supplier_cost, supplier_sku)
+			VALUES (?, ?, ?, ?)
+			ON CONFLICT(supplier_id, product_id)
+			DO UPDATE SET supplier_cost = excluded.supplier_cost, supplier_sku = excluded.supplier_sku`,
+			id, it.ProductID, it.SupplierCost, it.SupplierSKU,
+		); err != nil {
+			tools.HandleInternalServerError(w, err)
+			return
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// DELETE /api/suppliers/{id}/products/{productId}
+func deleteSupplierProductHandler(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	productID := chi.URLParam(r, "productId")
+	if _, err := tools.DB.Exec(
+		`DELETE FROM supplier_products WHERE supplier_id = ? AND product_id = ?`, id, productID,
+	); err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
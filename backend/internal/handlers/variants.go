@@ -0,0 +1,632 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/MananKakkar1/SalesBoard/backend/internal/models"
+	"github.com/MananKakkar1/SalesBoard/backend/internal/tools"
+	"github.com/go-chi/chi"
+)
+
+var (
+	errProductNotFound = errors.New("product not found")
+	errVariantAsParent = errors.New("a variant cannot have variants of its own")
+)
+
+// ---------- Input DTOs ----------
+
+type variantAttributesBody struct {
+	Attributes map[string][]string `json:"attributes"`
+}
+
+type variantIn struct {
+	Name          string            `json:"name"`
+	SKU           string            `json:"sku"`
+	Attributes    map[string]string `json:"attributes"`
+	PriceOverride *float64          `json:"priceOverride"`
+}
+
+// generate creates every missing combination of the parent's attribute values.
+type variantGenerateBody struct {
+	SKUPrefix     string   `json:"skuPrefix"`
+	PriceOverride *float64 `json:"priceOverride"`
+}
+
+// variantParent is the parent product with its attribute definitions decoded.
+type variantParent struct {
+	ID    int
+	Name  string
+	Price float64
+	Defs  map[string][]string
+}
+
+// ---------- helpers ----------
+
+// loadVariantParent loads a product that may carry variants.
+func loadVariantParent(q queryer, id int) (*variantParent, error) {
+	var p variantParent
+	var parentID sql.NullInt64
+	var defs sql.NullString
+	err := q.QueryRow(
+		`SELECT id, name, price, parent_id, variant_attributes FROM products WHERE id = ?`, id,
+	).Scan(&p.ID, &p.Name, &p.Price, &parentID, &defs)
+	if err == sql.ErrNoRows {
+		return nil, errProductNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if parentID.Valid {
+		return nil, errVariantAsParent
+	}
+	if defs.Valid && defs.String != "" {
+		if err := json.Unmarshal([]byte(defs.String), &p.Defs); err != nil {
+			return nil, err
+		}
+	}
+	return &p, nil
+}
+
+// writeVariantParentError maps loadVariantParent errors onto responses.
+func writeVariantParentError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, errProductNotFound):
+		http.Error(w, "Product not found", http.StatusNotFound)
+	case errors.Is(err, errVariantAsParent):
+		tools.HandleBadRequest(w, err)
+	default:
+		tools.HandleInternalServerError(w, err)
+	}
+}
+
+// normalizeVariantDefs trims names and values, dropping duplicate values.
+func normalizeVariantDefs(in map[string][]string) (map[string][]string, error) {
+	out := make(map[string][]string, len(in))
+	for name, values := range in {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			return nil, errors.New("attribute names cannot be blank")
+		}
+		var vals []string
+		for _, v := range values {
+			v = strings.TrimSpace(v)
+			if v != "" && !slices.Contains(vals, v) {
+				vals = append(vals, v)
+			}
+		}
+		if len(vals) == 0 {
+			return nil, fmt.Errorf("attribute %s needs at least one value", name)
+		}
+		out[name] = vals
+	}
+	return out, nil
+}
+
+// checkVariantAttrs requires exactly one allowed value for every defined attribute
+// and returns the attributes with normalised keys.
+func checkVariantAttrs(defs map[string][]string, attrs map[string]string) (map[string]string, error) {
+	if len(defs) == 0 {
+		return nil, errors.New("define the parent's variant attributes first")
+	}
+	out := make(map[string]string, len(attrs))
+	for k, v := range attrs {
+		out[strings.ToLower(strings.TrimSpace(k))] = strings.TrimSpace(v)
+	}
+	for name, allowed := range defs {
+		v, ok := out[name]
+		if !ok || v == "" {
+			return nil, fmt.Errorf("attribute %s is required", name)
+		}
+		if !slices.Contains(allowed, v) {
+			return nil, fmt.Errorf("%q is not an allowed value for %s", v, name)
+		}
+	}
+	for name := range out {
+		if _, ok := defs[name]; !ok {
+			return nil, fmt.Errorf("unknown attribute %s", name)
+		}
+	}
+	return out, nil
+}
+
+// variantLabel renders attribute values in attribute-name order, e.g. "M / Red".
+func variantLabel(attrs map[string]string) string {
+	names := make([]string, 0, len(attrs))
+	for k := range attrs {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	vals := make([]string, len(names))
+	for i, k := range names {
+		vals[i] = attrs[k]
+	}
+	return strings.Join(vals, " / ")
+}
+
+// nullableCode stores blank SKUs and barcodes as NULL so the unique indexes ignore them.
+func nullableCode(code string) any {
+	if code = strings.TrimSpace(code); code != "" {
+		return code
+	}
+	return nil
+}
+
+// insertVariant creates one variant row under parent. Attributes must already be checked.
+func insertVariant(tx *sql.Tx, parent *variantParent, in variantIn) (int, error) {
+	attrJSON, _ := json.Marshal(in.Attributes) // map keys are marshalled sorted
+
+	var dup int
+	if err := tx.QueryRow(
+		`SELECT COUNT(*) FROM products WHERE parent_id = ? AND attributes = ?`, parent.ID, string(attrJSON),
+	).Scan(&dup); err != nil {
+		return 0, err
+	}
+	if dup > 0 {
+		return 0, &conflictError{fmt.Sprintf("variant %s already exists", variantLabel(in.Attributes))}
+	}
+	if sku := nullableCode(in.SKU); sku != nil {
+		if err := tx.QueryRow(`SELECT COUNT(*) FROM products WHERE sku = ? COLLATE NOCASE`, sku).Scan(&dup); err != nil {
+			return 0, err
+		}
+		if dup > 0 {
+			return 0, &conflictError{fmt.Sprintf("sku %s is already in use", sku)}
+		}
+	}
+
+	name := strings.TrimSpace(in.Name)
+	if name == "" {
+		name = parent.Name + " - " + variantLabel(in.Attributes)
+	}
+	price := parent.Price
+	if in.PriceOverride != nil {
+		price = *in.PriceOverride
+	}
+	res, err := tx.Exec(`
+		INSERT INTO products (name, price, stock, parent_id, sku, attributes, price_override)
+		VALUES (?, ?, 0, ?, ?, ?, ?)`,
+		name, price, parent.ID, nullableCode(in.SKU), string(attrJSON), in.PriceOverride,
+	)
+	if err != nil {
+		return 0, err
+	}
+	id, _ := res.LastInsertId()
+	if err := recordPrice(tx, int(id), price, time.Now().UTC().Format(time.RFC3339), nil, "initial price", 0); err != nil {
+		return 0, err
+	}
+	return int(id), nil
+}
+
+// loadVariants returns the variants of the given parents keyed by parent id,
+// with stock summed over warehouses (expired lots excluded).
+func loadVariants(q queryer, parentIDs ...int) (map[int][]models.ProductVariant, error) {
+	out := make(map[int][]models.ProductVariant, len(parentIDs))
+	if len(parentIDs) == 0 {
+		return out, nil
+	}
+	args := make([]interface{}, len(parentIDs))
+	for i, id := range parentIDs {
+		args[i] = id
+	}
+	rows, err := q.Query(`
+		SELECT p.id, p.parent_id, p.name, COALESCE(p.sku, ''), COALESCE(p.attributes, '{}'), p.price, p.price_override,
+		       COALESCE((SELECT SUM(available_qty) FROM product_inventory_available a WHERE a.product_id = p.id), 0)
+		FROM products p
+		WHERE p.parent_id IN (?`+strings.Repeat(", ?", len(parentIDs)-1)+`)
+		  -- archived variants only show under a parent archived along with them
+		  AND (p.deleted_at IS NULL OR p.deleted_at = (SELECT pp.deleted_at FROM products pp WHERE pp.id = p.parent_id))
+		ORDER BY p.parent_id, p.attributes`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var v models.ProductVariant
+		var attrs string
+		if err := rows.Scan(&v.ID, &v.ParentID, &v.Name, &v.SKU, &attrs, &v.Price, &v.PriceOverride, &v.TotalStock); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(attrs), &v.Attributes); err != nil {
+			return nil, err
+		}
+		out[v.ParentID] = append(out[v.ParentID], v)
+	}
+	return out, rows.Err()
+}
+
+func loadVariant(q queryer, parentID, variantID int) (*models.ProductVariant, error) {
+	all, err := loadVariants(q, parentID)
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range all[parentID] {
+		if v.ID == variantID {
+			return &v, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+// ---------- Attribute definitions (PUT /api/products/{id}/variant-attributes) ----------
+// body: { "attributes": { "size": ["S", "M", "L"], "colour": ["Red", "Blue"] } }
+// Existing variants must still fit the new definition.
+func putVariantAttributesHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := atoiParam(chi.URLParam(r, "id"))
+	if err != nil {
+		tools.HandleBadRequest(w, err)
+		return
+	}
+	var body variantAttributesBody
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		tools.HandleBadRequest(w, errors.New("invalid request"))
+		return
+	}
+	defs, err := normalizeVariantDefs(body.Attributes)
+	if err != nil {
+		tools.HandleBadRequest(w, err)
+		return
+	}
+
+	tx, err := tools.DB.Begin()
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	defer tx.Rollback()
+
+	if _, err := loadVariantParent(tx, id); err != nil {
+		writeVariantParentError(w, err)
+		return
+	}
+	existing, err := loadVariants(tx, id)
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	for _, v := range existing[id] {
+		if _, err := checkVariantAttrs(defs, v.Attributes); err != nil {
+			http.Error(w, fmt.Sprintf("variant %d (%s) does not fit: %v", v.ID, v.Name, err), http.StatusConflict)
+			return
+		}
+	}
+
+	var stored any
+	if len(defs) > 0 {
+		b, _ := json.Marshal(defs)
+		stored = string(b)
+	}
+	if _, err := tx.Exec(`UPDATE products SET variant_attributes = ? WHERE id = ?`, stored, id); err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]any{"productId": id, "attributes": defs})
+}
+
+// ---------- List (GET /api/products/{id}/variants) ----------
+func getProductVariantsHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := atoiParam(chi.URLParam(r, "id"))
+	if err != nil {
+		tools.HandleBadRequest(w, err)
+		return
+	}
+	parent, err := loadVariantParent(tools.DB, id)
+	if err != nil {
+		writeVariantParentError(w, err)
+		return
+	}
+	variants, err := loadVariants(tools.DB, id)
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	out := variants[id]
+	if out == nil {
+		out = []models.ProductVariant{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"productId":  id,
+		"attributes": parent.Defs,
+		"data":       out,
+	})
+}
+
+// ---------- Create (POST /api/products/{id}/variants) ----------
+// body: { "sku": "TEE-M-RED", "attributes": { "size": "M", "colour": "Red" }, "priceOverride": 21.5 }
+func createProductVariantHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := atoiParam(chi.URLParam(r, "id"))
+	if err != nil {
+		tools.HandleBadRequest(w, err)
+		return
+	}
+	var in variantIn
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		tools.HandleBadRequest(w, errors.New("invalid request"))
+		return
+	}
+	if in.PriceOverride != nil && *in.PriceOverride <= 0 {
+		tools.HandleBadRequest(w, errors.New("priceOverride must be positive"))
+		return
+	}
+
+	tx, err := tools.DB.Begin()
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	defer tx.Rollback()
+
+	parent, err := loadVariantParent(tx, id)
+	if err != nil {
+		writeVariantParentError(w, err)
+		return
+	}
+	if in.Attributes, err = checkVariantAttrs(parent.Defs, in.Attributes); err != nil {
+		tools.HandleBadRequest(w, err)
+		return
+	}
+	variantID, err := insertVariant(tx, parent, in)
+	if err != nil {
+		writeVariantError(w, err)
+		return
+	}
+	v, err := loadVariant(tx, id, variantID)
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// writeVariantError sends duplicates as 409 and anything else as 500.
+func writeVariantError(w http.ResponseWriter, err error) {
+	var conflict *conflictError
+	if errors.As(err, &conflict) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	tools.HandleInternalServerError(w, err)
+}
+
+// ---------- Generate (POST /api/products/{id}/variants/generate) ----------
+// body: { "skuPrefix": "TEE", "priceOverride": null }
+// Creates one variant per missing attribute combination; SKUs are prefix-VALUE-VALUE.
+func generateProductVariantsHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := atoiParam(chi.URLParam(r, "id"))
+	if err != nil {
+		tools.HandleBadRequest(w, err)
+		return
+	}
+	var body variantGenerateBody
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			tools.HandleBadRequest(w, errors.New("invalid request"))
+			return
+		}
+	}
+	if body.PriceOverride != nil && *body.PriceOverride <= 0 {
+		tools.HandleBadRequest(w, errors.New("priceOverride must be positive"))
+		return
+	}
+
+	tx, err := tools.DB.Begin()
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	defer tx.Rollback()
+
+	parent, err := loadVariantParent(tx, id)
+	if err != nil {
+		writeVariantParentError(w, err)
+		return
+	}
+	if len(parent.Defs) == 0 {
+		tools.HandleBadRequest(w, errors.New("define the parent's variant attributes first"))
+		return
+	}
+	existing, err := loadVariants(tx, id)
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	have := make(map[string]bool, len(existing[id]))
+	for _, v := range existing[id] {
+		have[variantLabel(v.Attributes)] = true
+	}
+
+	names := make([]string, 0, len(parent.Defs))
+	for k := range parent.Defs {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	combos := []map[string]string{{}}
+	for _, name := range names {
+		var next []map[string]string
+		for _, c := range combos {
+			for _, v := range parent.Defs[name] {
+				m := make(map[string]string, len(c)+1)
+				for k, x := range c {
+					m[k] = x
+				}
+				m[name] = v
+				next = append(next, m)
+			}
+		}
+		combos = next
+	}
+
+	prefix := strings.TrimSpace(body.SKUPrefix)
+	var created []int
+	for _, attrs := range combos {
+		if have[variantLabel(attrs)] {
+			continue
+		}
+		in := variantIn{Attributes: attrs, PriceOverride: body.PriceOverride}
+		if prefix != "" {
+			parts := []string{prefix}
+			for _, name := range names {
+				parts = append(parts, strings.ToUpper(strings.ReplaceAll(attrs[name], " ", "")))
+			}
+			in.SKU = strings.Join(parts, "-")
+		}
+		variantID, err := insertVariant(tx, parent, in)
+		if err != nil {
+			writeVariantError(w, err)
+			return
+		}
+		created = append(created, variantID)
+	}
+
+	all, err := loadVariants(tx, id)
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"productId": id,
+		"created":   len(created),
+		"data":      all[id],
+	})
+}
+
+// ---------- Update (PUT /api/products/{id}/variants/{variantId}) ----------
+// body: { "name": "", "sku": "TEE-M-RED", "attributes": {...}, "priceOverride": null }
+// A null priceOverride makes the variant follow the parent's price again.
+func updateProductVariantHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := atoiParam(chi.URLParam(r, "id"))
+	if err != nil {
+		tools.HandleBadRequest(w, err)
+		return
+	}
+	variantID, err := atoiParam(chi.URLParam(r, "variantId"))
+	if err != nil {
+		tools.HandleBadRequest(w, err)
+		return
+	}
+	var in variantIn
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		tools.HandleBadRequest(w, errors.New("invalid request"))
+		return
+	}
+	if in.PriceOverride != nil && *in.PriceOverride <= 0 {
+		tools.HandleBadRequest(w, errors.New("priceOverride must be positive"))
+		return
+	}
+
+	tx, err := tools.DB.Begin()
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	defer tx.Rollback()
+
+	parent, err := loadVariantParent(tx, id)
+	if err != nil {
+		writeVariantParentError(w, err)
+		return
+	}
+	before, err := loadVariant(tx, id, variantID)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Variant not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	if in.Attributes, err = checkVariantAttrs(parent.Defs, in.Attributes); err != nil {
+		tools.HandleBadRequest(w, err)
+		return
+	}
+	attrJSON, _ := json.Marshal(in.Attributes)
+
+	var dup int
+	if err := tx.QueryRow(
+		`SELECT COUNT(*) FROM products WHERE parent_id = ? AND attributes = ? AND id <> ?`, id, string(attrJSON), variantID,
+	).Scan(&dup); err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	if dup > 0 {
+		http.Error(w, fmt.Sprintf("variant %s already exists", variantLabel(in.Attributes)), http.StatusConflict)
+		return
+	}
+	if sku := nullableCode(in.SKU); sku != nil {
+		if err := tx.QueryRow(`SELECT COUNT(*) FROM products WHERE sku = ? COLLATE NOCASE AND id <> ?`, sku, variantID).Scan(&dup); err != nil {
+			tools.HandleInternalServerError(w, err)
+			return
+		}
+		if dup > 0 {
+			http.Error(w, fmt.Sprintf("sku %s is already in use", sku), http.StatusConflict)
+			return
+		}
+	}
+
+	name := strings.TrimSpace(in.Name)
+	if name == "" {
+		name = parent.Name + " - " + variantLabel(in.Attributes)
+	}
+	price := parent.Price
+	if in.PriceOverride != nil {
+		price = *in.PriceOverride
+	}
+	if _, err := tx.Exec(`
+		UPDATE products
+		SET name = ?, price = ?, sku = ?, attributes = ?, price_override = ?
+		WHERE id = ?`,
+		name, price, nullableCode(in.SKU), string(attrJSON), in.PriceOverride, variantID,
+	); err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	if price != before.Price {
+		reason := "variant price override"
+		if in.PriceOverride == nil {
+			reason = "follows parent"
+		}
+		if err := recordPrice(tx, variantID, price, time.Now().UTC().Format(time.RFC3339), nil, reason, 0); err != nil {
+			tools.HandleInternalServerError(w, err)
+			return
+		}
+	}
+	v, err := loadVariant(tx, id, variantID)
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -10,6 +10,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/MananKakkar1/SalesBoard/backend/internal/geocode"
 	"github.com/MananKakkar1/SalesBoard/backend/internal/models"
 	"github.com/MananKakkar1/SalesBoard/backend/internal/tools"
 	"github.com/go-chi/chi"
@@ -18,10 +19,47 @@ import (
 // --- Request/response bodies ---
 
 type warehouseCU struct {
-	Name      string `json:"name"`
-	Latitude  string `json:"latitude"`
-	Longitude string `json:"longitude"`
-	Capacity  int    `json:"capacity"`
+	Name       string `json:"name"`
+	Latitude   string `json:"latitude"`
+	Longitude  string `json:"longitude"`
+	Capacity   int    `json:"capacity"`
+	City       string `json:"city"`
+	Region     string `json:"region"`
+	PostalCode string `json:"postalCode"`
+	Country    string `json:"country"`
+
+	// set by locate
+	lat, lng  float64
+	precision string
+}
+
+// locate validates latitude/longitude and converts them for the numeric lat/lng columns.
+// When both are blank the address is geocoded instead and the text columns are filled in.
+func (b *warehouseCU) locate() error {
+	for _, f := range []*string{&b.Name, &b.Latitude, &b.Longitude, &b.City, &b.Region, &b.PostalCode, &b.Country} {
+		*f = strings.TrimSpace(*f)
+	}
+	if b.Name == "" {
+		return errors.New("name is required")
+	}
+	if b.Latitude != "" || b.Longitude != "" {
+		lat, lng, err := geocode.ParseCoords(b.Latitude, b.Longitude)
+		if err != nil {
+			return err
+		}
+		b.lat, b.lng, b.precision = lat, lng, geocode.Manual
+		return nil
+	}
+	res, ok := geocode.Default().Lookup(geocode.Query{
+		City: b.City, Region: b.Region, PostalCode: b.PostalCode, Country: b.Country,
+	})
+	if !ok {
+		return errors.New("latitude and longitude are required when the address can't be geocoded")
+	}
+	b.lat, b.lng, b.precision = res.Lat, res.Lng, res.Precision
+	b.Latitude = strconv.FormatFloat(res.Lat, 'f', -1, 64)
+	b.Longitude = strconv.FormatFloat(res.Lng, 'f', -1, 64)
+	return nil
 }
 
 type invItemPatch struct {
@@ -35,14 +73,15 @@ type invBulkPatch struct {
 // --- Warehouses CRUD ---
 
 // POST /warehouses
+// Give latitude/longitude, or an address (postalCode and/or city, region, country) to geocode.
 func createWarehouseHandler(w http.ResponseWriter, r *http.Request) {
 	var body warehouseCU
 	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
 		tools.HandleBadRequest(w, errors.New("invalid request"))
 		return
 	}
-	if strings.TrimSpace(body.Name) == "" || strings.TrimSpace(body.Latitude) == "" || strings.TrimSpace(body.Longitude) == "" {
-		tools.HandleBadRequest(w, errors.New("name, latitude, longitude are required"))
+	if err := body.locate(); err != nil {
+		tools.HandleBadRequest(w, err)
 		return
 	}
 	if body.Capacity < 0 {
@@ -50,9 +89,12 @@ func createWarehouseHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	res, err := tools.DB.Exec(
-		"INSERT INTO warehouses (name, latitude, longitude, productsCount, capacity) VALUES (?, ?, ?, ?, ?)",
+	res, err := tools.DB.Exec(`
+		INSERT INTO warehouses (name, latitude, longitude, productsCount, capacity,
+		                        city, region, postal_code, country, lat, lng, geo_precision)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
 		body.Name, body.Latitude, body.Longitude, 0, body.Capacity,
+		body.City, body.Region, body.PostalCode, body.Country, body.lat, body.lng, body.precision,
 	)
 	if err != nil {
 		tools.HandleInternalServerError(w, err)
@@ -67,6 +109,13 @@ func createWarehouseHandler(w http.ResponseWriter, r *http.Request) {
 		Longitude:     body.Longitude,
 		ProductsCount: 0,
 		Capacity:      body.Capacity,
+		City:          body.City,
+		Region:        body.Region,
+		PostalCode:    body.PostalCode,
+		Country:       body.Country,
+		Lat:           &body.lat,
+		Lng:           &body.lng,
+		GeoPrecision:  &body.precision,
 	}
 	w.Header().Set("Content-Type", "application/json")
 	_ = json.NewEncoder(w).Encode(out)
@@ -95,12 +144,13 @@ func getWarehousesHandler(w http.ResponseWriter, r *http.Request) {
 	var totalCount int
 	var countQuery string
 	var countArgs []interface{}
+	scope := archivedScope(r, "deleted_at")
 	if search != "" {
 		like := "%" + strings.ToLower(search) + "%"
-		countQuery = "SELECT COUNT(*) FROM warehouses WHERE LOWER(name) LIKE ?"
+		countQuery = "SELECT COUNT(*) FROM warehouses WHERE " + scope + " AND LOWER(name) LIKE ?"
 		countArgs = []interface{}{like}
 	} else {
-		countQuery = "SELECT COUNT(*) FROM warehouses"
+		countQuery = "SELECT COUNT(*) FROM warehouses WHERE " + scope
 		countArgs = []interface{}{}
 	}
 	if err := tools.DB.QueryRow(countQuery, countArgs...).Scan(&totalCount); err != nil {
@@ -118,26 +168,29 @@ func getWarehousesHandler(w http.ResponseWriter, r *http.Request) {
 	if search != "" {
 		like := "%" + strings.ToLower(search) + "%"
 		dataQuery = `
-			SELECT w.id, w.name, w.latitude, w.longitude, w.capacity,
+			SELECT w.id, w.name, w.latitude, w.longitude, w.capacity, w.deleted_at,
+			       w.city, w.region, w.postal_code, w.country, w.lat, w.lng, w.geo_precision,
 			       COALESCE((
 			         SELECT COUNT(*)
 			         FROM warehouse_inventory wi
 			         WHERE wi.warehouse_id = w.id AND wi.qty > 0
 			       ), 0) AS productsCount
 			FROM warehouses w
-			WHERE LOWER(w.name) LIKE ?
+			WHERE w.` + scope + ` AND LOWER(w.name) LIKE ?
 			ORDER BY w.id
 			LIMIT ? OFFSET ?`
 		args = []interface{}{like, pageSize, offset}
 	} else {
 		dataQuery = `
-			SELECT w.id, w.name, w.latitude, w.longitude, w.capacity,
+			SELECT w.id, w.name, w.latitude, w.longitude, w.capacity, w.deleted_at,
+			       w.city, w.region, w.postal_code, w.country, w.lat, w.lng, w.geo_precision,
 			       COALESCE((
 			         SELECT COUNT(*)
 			         FROM warehouse_inventory wi
 			         WHERE wi.warehouse_id = w.id AND wi.qty > 0
 			       ), 0) AS productsCount
 			FROM warehouses w
+			WHERE w.` + scope + `
 			ORDER BY w.id
 			LIMIT ? OFFSET ?`
 		args = []interface{}{pageSize, offset}
@@ -153,7 +206,8 @@ func getWarehousesHandler(w http.ResponseWriter, r *http.Request) {
 	var list []models.Warehouse
 	for rows.Next() {
 		var wh models.Warehouse
-		if err := rows.Scan(&wh.ID, &wh.Name, &wh.Latitude, &wh.Longitude, &wh.Capacity, &wh.ProductsCount); err != nil {
+		if err := rows.Scan(&wh.ID, &wh.Name, &wh.Latitude, &wh.Longitude, &wh.Capacity, &wh.DeletedAt,
+			&wh.City, &wh.Region, &wh.PostalCode, &wh.Country, &wh.Lat, &wh.Lng, &wh.GeoPrecision, &wh.ProductsCount); err != nil {
 			tools.HandleInternalServerError(w, err)
 			return
 		}
@@ -178,7 +232,8 @@ func getWarehousesHandler(w http.ResponseWriter, r *http.Request) {
 func getWarehouseByIdHandler(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "id")
 	row := tools.DB.QueryRow(`
-		SELECT w.id, w.name, w.latitude, w.longitude, w.capacity,
+		SELECT w.id, w.name, w.latitude, w.longitude, w.capacity, w.deleted_at,
+		       w.city, w.region, w.postal_code, w.country, w.lat, w.lng, w.geo_precision,
 		       COALESCE((
 		         SELECT COUNT(*)
 		         FROM warehouse_inventory wi
@@ -188,7 +243,8 @@ func getWarehouseByIdHandler(w http.ResponseWriter, r *http.Request) {
 		WHERE w.id = ?`, id)
 
 	var wh models.Warehouse
-	if err := row.Scan(&wh.ID, &wh.Name, &wh.Latitude, &wh.Longitude, &wh.Capacity, &wh.ProductsCount); err != nil {
+	if err := row.Scan(&wh.ID, &wh.Name, &wh.Latitude, &wh.Longitude, &wh.Capacity, &wh.DeletedAt,
+		&wh.City, &wh.Region, &wh.PostalCode, &wh.Country, &wh.Lat, &wh.Lng, &wh.GeoPrecision, &wh.ProductsCount); err != nil {
 		if err == sql.ErrNoRows {
 			http.Error(w, "Warehouse not found", http.StatusNotFound)
 			return
@@ -208,8 +264,8 @@ func updateWarehouseHandler(w http.ResponseWriter, r *http.Request) {
 		tools.HandleBadRequest(w, errors.New("invalid request"))
 		return
 	}
-	if strings.TrimSpace(body.Name) == "" || strings.TrimSpace(body.Latitude) == "" || strings.TrimSpace(body.Longitude) == "" {
-		tools.HandleBadRequest(w, errors.New("name, latitude, longitude are required"))
+	if err := body.locate(); err != nil {
+		tools.HandleBadRequest(w, err)
 		return
 	}
 	if body.Capacity < 0 {
@@ -218,8 +274,11 @@ func updateWarehouseHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if _, err := tools.DB.Exec(
-		"UPDATE warehouses SET name=?, latitude=?, longitude=?, capacity=? WHERE id=?",
-		body.Name, body.Latitude, body.Longitude, body.Capacity, id,
+		`UPDATE warehouses SET name=?, latitude=?, longitude=?, capacity=?,
+		        city=?, region=?, postal_code=?, country=?, lat=?, lng=?, geo_precision=?
+		  WHERE id=?`,
+		body.Name, body.Latitude, body.Longitude, body.Capacity,
+		body.City, body.Region, body.PostalCode, body.Country, body.lat, body.lng, body.precision, id,
 	); err != nil {
 		tools.HandleInternalServerError(w, err)
 		return
@@ -230,25 +289,28 @@ func updateWarehouseHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 // DELETE /warehouses/{id}
+// Archives by default; ?hard=true deletes a warehouse with no stock or history.
 func deleteWarehouseHandler(w http.ResponseWriter, r *http.Request) {
-	id := chi.URLParam(r, "id")
-	if _, err := tools.DB.Exec("DELETE FROM warehouses WHERE id = ?", id); err != nil {
-		tools.HandleInternalServerError(w, err)
-		return
-	}
-	w.WriteHeader(http.StatusNoContent)
+	deleteArchivable(w, r, warehouseArchive)
+}
+
+// POST /warehouses/{id}/restore
+func restoreWarehouseHandler(w http.ResponseWriter, r *http.Request) {
+	restoreArchivable(w, r, warehouseArchive)
 }
 
 // GET /warehouses/recent
 func getRecentWarehousesHandler(w http.ResponseWriter, r *http.Request) {
 	rows, err := tools.DB.Query(`
-		SELECT w.id, w.name, w.latitude, w.longitude, w.capacity,
+		SELECT w.id, w.name, w.latitude, w.longitude, w.capacity, w.deleted_at,
+		       w.city, w.region, w.postal_code, w.country, w.lat, w.lng, w.geo_precision,
 		       COALESCE((
 		         SELECT COUNT(*)
 		         FROM warehouse_inventory wi
 		         WHERE wi.warehouse_id = w.id AND wi.qty > 0
 		       ), 0) AS productsCount
 		FROM warehouses w
+		WHERE w.deleted_at IS NULL
 		ORDER BY w.id DESC
 		LIMIT 3`,
 	)
@@ -261,7 +323,8 @@ func getRecentWarehousesHandler(w http.ResponseWriter, r *http.Request) {
 	var list []models.Warehouse
 	for rows.Next() {
 		var wh models.Warehouse
-		if err := rows.Scan(&wh.ID, &wh.Name, &wh.Latitude, &wh.Longitude, &wh.Capacity, &wh.ProductsCount); err != nil {
+		if err := rows.Scan(&wh.ID, &wh.Name, &wh.Latitude, &wh.Longitude, &wh.Capacity, &wh.DeletedAt,
+			&wh.City, &wh.Region, &wh.PostalCode, &wh.Country, &wh.Lat, &wh.Lng, &wh.GeoPrecision, &wh.ProductsCount); err != nil {
 			tools.HandleInternalServerError(w, err)
 			return
 		}
@@ -274,7 +337,7 @@ func getRecentWarehousesHandler(w http.ResponseWriter, r *http.Request) {
 // GET /warehouses/total
 func getTotalWarehousesHandler(w http.ResponseWriter, r *http.Request) {
 	var count int
-	if err := tools.DB.QueryRow("SELECT COUNT(*) FROM warehouses").Scan(&count); err != nil {
+	if err := tools.DB.QueryRow("SELECT COUNT(*) FROM warehouses WHERE deleted_at IS NULL").Scan(&count); err != nil {
 		tools.HandleInternalServerError(w, err)
 		return
 	}
@@ -290,24 +353,27 @@ func searchWarehousesSimpleHandler(w http.ResponseWriter, r *http.Request) {
 	if q != "" {
 		like := "%" + strings.ToLower(q) + "%"
 		rows, err = tools.DB.Query(`
-			SELECT w.id, w.name, w.latitude, w.longitude, w.capacity,
+			SELECT w.id, w.name, w.latitude, w.longitude, w.capacity, w.deleted_at,
+			       w.city, w.region, w.postal_code, w.country, w.lat, w.lng, w.geo_precision,
 			       COALESCE((
 			         SELECT COUNT(*)
 			         FROM warehouse_inventory wi
 			         WHERE wi.warehouse_id = w.id AND wi.qty > 0
 			       ), 0) AS productsCount
 			FROM warehouses w
-			WHERE LOWER(w.name) LIKE ?
+			WHERE w.deleted_at IS NULL AND LOWER(w.name) LIKE ?
 			ORDER BY w.id`, like)
 	} else {
 		rows, err = tools.DB.Query(`
-			SELECT w.id, w.name, w.latitude, w.longitude, w.capacity,
+			SELECT w.id, w.name, w.latitude, w.longitude, w.capacity, w.deleted_at,
+			       w.city, w.region, w.postal_code, w.country, w.lat, w.lng, w.geo_precision,
 			       COALESCE((
 			         SELECT COUNT(*)
 			         FROM warehouse_inventory wi
 			         WHERE wi.warehouse_id = w.id AND wi.qty > 0
 			       ), 0) AS productsCount
 			FROM warehouses w
+			WHERE w.deleted_at IS NULL
 			ORDER BY w.id`)
 	}
 	if err != nil {
@@ -319,7 +385,8 @@ func searchWarehousesSimpleHandler(w http.ResponseWriter, r *http.Request) {
 	var list []models.Warehouse
 	for rows.Next() {
 		var wh models.Warehouse
-		if err := rows.Scan(&wh.ID, &wh.Name, &wh.Latitude, &wh.Longitude, &wh.Capacity, &wh.ProductsCount); err != nil {
+		if err := rows.Scan(&wh.ID, &wh.Name, &wh.Latitude, &wh.Longitude, &wh.Capacity, &wh.DeletedAt,
+			&wh.City, &wh.Region, &wh.PostalCode, &wh.Country, &wh.Lat, &wh.Lng, &wh.GeoPrecision, &wh.ProductsCount); err != nil {
 			tools.HandleInternalServerError(w, err)
 			return
 		}
@@ -331,7 +398,7 @@ func searchWarehousesSimpleHandler(w http.ResponseWriter, r *http.Request) {
 
 // --- Inventory per warehouse ---
 
-// GET /warehouses/{id}/inventory  ->  [{product_id, name, qty}]
+// GET /warehouses/{id}/inventory  ->  [{product_id, name, qty, expired_qty, available_qty}]
 func getWarehouseInventoryHandler(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "id")
 
@@ -347,8 +414,8 @@ func getWarehouseInventoryHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	rows, err := tools.DB.Query(`
-		SELECT p.id AS product_id, p.name, wi.qty
-		FROM warehouse_inventory wi
+		SELECT p.id AS product_id, p.name, wi.qty, wi.expired_qty, wi.available_qty
+		FROM warehouse_inventory_available wi
 		JOIN products p ON p.id = wi.product_id
 		WHERE wi.warehouse_id = ?
 		ORDER BY p.id`, id)
@@ -359,14 +426,16 @@ func getWarehouseInventoryHandler(w http.ResponseWriter, r *http.Request) {
 	defer rows.Close()
 
 	type invRow struct {
-		ProductID int    `json:"product_id"`
-		Name      string `json:"name"`
-		Qty       int    `json:"qty"`
+		ProductID    int    `json:"product_id"`
+		Name         string `json:"name"`
+		Qty          int    `json:"qty"`
+		ExpiredQty   int    `json:"expired_qty"`
+		AvailableQty int    `json:"available_qty"`
 	}
 	var out []invRow
 	for rows.Next() {
 		var r invRow
-		if err := rows.Scan(&r.ProductID, &r.Name, &r.Qty); err != nil {
+		if err := rows.Scan(&r.ProductID, &r.Name, &r.Qty, &r.ExpiredQty, &r.AvailableQty); err != nil {
 			tools.HandleInternalServerError(w, err)
 			return
 		}
@@ -407,18 +476,8 @@ func upsertWarehouseInventoryHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	stmt, err := tx.Prepare(`
-		INSERT INTO warehouse_inventory (warehouse_id, product_id, qty)
-		VALUES (?, ?, ?)
-		ON CONFLICT(warehouse_id, product_id)
-		DO UPDATE SET qty = excluded.qty
-	`)
-	if err != nil {
-		tools.HandleInternalServerError(w, err)
-		return
-	}
-	defer stmt.Close()
-
+	warehouseID, _ := strconv.Atoi(id)
+	var touched []stockKey
 	for _, it := range body.Items {
 		if it.ProductID <= 0 || it.Qty < 0 {
 			tools.HandleBadRequest(w, errors.New("invalid product_id or qty"))
@@ -435,10 +494,22 @@ func upsertWarehouseInventoryHandler(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		if _, err := stmt.Exec(id, it.ProductID, it.Qty); err != nil {
-			tools.HandleInternalServerError(w, err)
+		if err := setWarehouseStock(tx, warehouseID, it.ProductID, it.Qty); err != nil {
+			writeStockError(w, err)
 			return
 		}
+		touched = append(touched, stockKey{warehouseID, it.ProductID})
+	}
+
+	filled, err := fillBackorders(tx, touched...)
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	alerts, err := checkReorderPoints(tx, touched...)
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
 	}
 
 	if err := tx.Commit(); err != nil {
@@ -452,6 +523,8 @@ func upsertWarehouseInventoryHandler(w http.ResponseWriter, r *http.Request) {
 		Data: map[string]any{"warehouseId": id, "count": len(body.Items)},
 		Time: time.Now(),
 	})
+	broadcastEvents(filled)
+	broadcastEvents(alerts)
 
 	w.WriteHeader(http.StatusNoContent)
 }
@@ -461,6 +534,8 @@ type transferBody struct {
 	FromWarehouseID int `json:"fromWarehouseId"`
 	ToWarehouseID   int `json:"toWarehouseId"`
 	Qty             int `json:"qty"`
+	// serial-tracked products: which units are moving (one per unit, optional)
+	Serials []string `json:"serials"`
 }
 
 func transferInventoryHandler(w http.ResponseWriter, r *http.Request) {
@@ -478,6 +553,12 @@ func transferInventoryHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	serials, err := normalizeSerials(body.Serials)
+	if err != nil {
+		tools.HandleBadRequest(w, err)
+		return
+	}
+
 	tx, err := tools.DB.Begin()
 	if err != nil {
 		tools.HandleInternalServerError(w, err)
@@ -485,44 +566,60 @@ func transferInventoryHandler(w http.ResponseWriter, r *http.Request) {
 	}
 	defer tx.Rollback()
 
-	// Ensure source row exists
-	var fromQty int
-	err = tx.QueryRow(`
-		SELECT COALESCE(qty, 0) FROM warehouse_inventory
-		WHERE warehouse_id = ? AND product_id = ?`,
-		body.FromWarehouseID, body.ProductID,
-	).Scan(&fromQty)
-	if err == sql.ErrNoRows {
-		fromQty = 0
-	} else if err != nil {
+	tracked, err := isSerialTracked(tx, body.ProductID)
+	if err != nil {
 		tools.HandleInternalServerError(w, err)
 		return
 	}
-
-	if fromQty < body.Qty {
-		tools.HandleBadRequest(w, errors.New("insufficient quantity in source warehouse"))
+	if err := checkSerialCount(tracked, false, body.ProductID, body.Qty, serials); err != nil {
+		tools.HandleBadRequest(w, err)
+		return
+	}
+	if err := moveSerials(tx, stockKey{body.FromWarehouseID, body.ProductID}, body.ToWarehouseID, serials); err != nil {
+		writeStockError(w, err)
 		return
 	}
 
 	// Deduct from source
-	_, err = tx.Exec(`
-		UPDATE warehouse_inventory
-		SET qty = qty - ?
-		WHERE warehouse_id = ? AND product_id = ?`,
-		body.Qty, body.FromWarehouseID, body.ProductID,
-	)
+	removed, err := removeWarehouseStock(tx, body.FromWarehouseID, body.ProductID, body.Qty)
 	if err != nil {
-		tools.HandleInternalServerError(w, err)
+		var short *insufficientStockError
+		if errors.As(err, &short) {
+			tools.HandleBadRequest(w, errors.New("insufficient quantity in source warehouse"))
+			return
+		}
+		writeStockError(w, err)
 		return
 	}
 
-	// Add to destination (upsert)
-	_, err = tx.Exec(`
-		INSERT INTO warehouse_inventory (warehouse_id, product_id, qty)
-		VALUES (?, ?, ?)
-		ON CONFLICT(warehouse_id, product_id)
-		DO UPDATE SET qty = qty + excluded.qty`,
-		body.ToWarehouseID, body.ProductID, body.Qty,
+	// Add to destination (upsert); lots travel with their number and expiry
+	unlotted := body.Qty
+	for _, l := range removed.Lots {
+		exp := ""
+		if l.ExpiresAt != nil {
+			exp = *l.ExpiresAt
+		}
+		if err := addWarehouseLotStock(tx, body.ToWarehouseID, body.ProductID, l.LotNumber, exp, l.Qty); err != nil {
+			writeStockError(w, err)
+			return
+		}
+		unlotted -= l.Qty
+	}
+	if unlotted > 0 {
+		if err := addWarehouseStock(tx, body.ToWarehouseID, body.ProductID, unlotted); err != nil {
+			writeStockError(w, err)
+			return
+		}
+	}
+
+	filled, err := fillBackorders(tx, stockKey{body.ToWarehouseID, body.ProductID})
+	if err != nil {
+		tools.HandleInternalServerError(w, err)
+		return
+	}
+	alerts, err := checkReorderPoints(tx,
+		stockKey{body.FromWarehouseID, body.ProductID},
+		stockKey{body.ToWarehouseID, body.ProductID},
 	)
 	if err != nil {
 		tools.HandleInternalServerError(w, err)
@@ -533,6 +630,8 @@ func transferInventoryHandler(w http.ResponseWriter, r *http.Request) {
 		tools.HandleInternalServerError(w, err)
 		return
 	}
+	broadcastEvents(filled)
+	broadcastEvents(alerts)
 
 	w.WriteHeader(http.StatusNoContent)
 }
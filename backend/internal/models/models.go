@@ -9,13 +9,25 @@ type Customer struct {
     Email   string `json:"email"`
     Phone   string `json:"phone"`
     Address string `json:"address"`
+    DeletedAt *string `json:"deletedAt,omitempty"`
+    MergedInto *int `json:"mergedInto,omitempty"`
+    Addresses []CustomerAddress `json:"addresses,omitempty"`
+    Credit *CustomerCredit `json:"credit,omitempty"`
+    Tags []string `json:"tags,omitempty"`
+    RecentActivity []Activity `json:"recentActivity,omitempty"`
 }
 
 type Product struct {
-    ID          int     `json:"id"`
-    Name        string  `json:"name"`
-    Price       float64 `json:"price"`
-    Stock       int     `json:"stock"`
+    ID            int     `json:"id"`
+    Name          string  `json:"name"`
+    Price         float64 `json:"price"`
+    Stock         int     `json:"stock"`
+    SerialTracked bool    `json:"serialTracked"`
+    SKU           string  `json:"sku"`
+    Barcode       string  `json:"barcode"`
+    Description   string  `json:"description"`
+    CategoryID    *int    `json:"categoryId"`
+    DeletedAt     *string `json:"deletedAt,omitempty"`
 }
 
 type Order struct {
@@ -47,10 +59,563 @@ type Warehouse struct {
     Longitude string `json:"longitude"`
     ProductsCount int    `json:"productsCount"`
     Capacity int    `json:"capacity"`
+    City       string   `json:"city"`
+    Region     string   `json:"region"`
+    PostalCode string   `json:"postalCode"`
+    Country    string   `json:"country"`
+    Lat        *float64 `json:"lat"`
+    Lng        *float64 `json:"lng"`
+    GeoPrecision *string `json:"geoPrecision"`
+    DeletedAt *string `json:"deletedAt,omitempty"`
 }
 
 type WarehouseInventoryItem struct {
 	ProductID int    `json:"product_id"`
 	Name      string `json:"name"`
 	Qty       int    `json:"qty"`
-}
\ No newline at end of file
+}
+
+type Supplier struct {
+	ID           int    `json:"id"`
+	Name         string `json:"name"`
+	ContactName  string `json:"contactName"`
+	Email        string `json:"email"`
+	Phone        string `json:"phone"`
+	LeadTimeDays int    `json:"leadTimeDays"`
+	CreatedAt    string `json:"createdAt"`
+}
+
+type SupplierProduct struct {
+	SupplierID   int     `json:"supplierId"`
+	ProductID    int     `json:"productId"`
+	ProductName  string  `json:"productName,omitempty"`
+	SupplierCost float64 `json:"supplierCost"`
+	SupplierSKU  string  `json:"supplierSku"`
+}
+
+type PurchaseOrder struct {
+	ID          int                 `json:"id"`
+	SupplierID  int                 `json:"supplierId"`
+	WarehouseID int                 `json:"warehouseId"`
+	Status      string              `json:"status"`
+	Notes       string              `json:"notes"`
+	ExpectedAt  string              `json:"expectedAt"`
+	CreatedAt   string              `json:"createdAt"`
+	TotalCost   float64             `json:"totalCost"`
+	Lines       []PurchaseOrderLine `json:"lines,omitempty"`
+}
+
+type PurchaseOrderLine struct {
+	ID          int     `json:"id"`
+	ProductID   int     `json:"productId"`
+	ProductName string  `json:"productName,omitempty"`
+	QtyOrdered  int     `json:"qtyOrdered"`
+	QtyReceived int     `json:"qtyReceived"`
+	UnitCost    float64 `json:"unitCost"`
+}
+
+type ReorderPoint struct {
+	WarehouseID   int     `json:"warehouseId"`
+	ProductID     int     `json:"productId"`
+	ProductName   string  `json:"productName"`
+	ReorderPoint  int     `json:"reorderPoint"`
+	SafetyStock   int     `json:"safetyStock"`
+	DailyVelocity float64 `json:"dailyVelocity"`
+	LeadTimeDays  int     `json:"leadTimeDays"`
+	Manual        bool    `json:"manual"`
+	OnHand        int     `json:"onHand"`
+	ComputedAt    string  `json:"computedAt"`
+}
+
+type CycleCount struct {
+	ID          int              `json:"id"`
+	WarehouseID int              `json:"warehouseId"`
+	Status      string           `json:"status"`
+	Notes       string           `json:"notes"`
+	CreatedAt   string           `json:"createdAt"`
+	ClosedAt    string           `json:"closedAt,omitempty"`
+	Lines       []CycleCountLine `json:"lines,omitempty"`
+}
+
+type CycleCountLine struct {
+	ProductID     int     `json:"productId"`
+	ProductName   string  `json:"productName"`
+	SystemQty     int     `json:"systemQty"`
+	CountedQty    *int    `json:"countedQty"`
+	Variance      *int    `json:"variance"`
+	VarianceValue float64 `json:"varianceValue"`
+	Reason        string  `json:"reason,omitempty"`
+}
+
+type Bin struct {
+	ID          int    `json:"id"`
+	WarehouseID int    `json:"warehouseId"`
+	Code        string `json:"code"`
+	Description string `json:"description"`
+	TotalQty    int    `json:"totalQty"`
+	CreatedAt   string `json:"createdAt"`
+}
+
+// PickLocation is one slice of an order line: qty taken from a bin.
+// BinID 0 / empty BinCode means the stock was not assigned to a bin.
+// ProductID is only set on kit lines, where it names the component picked.
+type PickLocation struct {
+	BinID     int    `json:"binId"`
+	BinCode   string `json:"binCode"`
+	Qty       int    `json:"qty"`
+	ProductID int    `json:"productId,omitempty"`
+}
+
+type InventoryLot struct {
+	ID          int     `json:"id"`
+	WarehouseID int     `json:"warehouseId"`
+	ProductID   int     `json:"productId"`
+	ProductName string  `json:"productName"`
+	LotNumber   string  `json:"lotNumber"`
+	ExpiresAt   *string `json:"expiresAt"`
+	Qty         int     `json:"qty"`
+	Expired     bool    `json:"expired"`
+	ReceivedAt  string  `json:"receivedAt"`
+}
+
+// LotAllocation is qty of an order line (or transfer) drawn from one lot.
+type LotAllocation struct {
+	LotID     int     `json:"lotId"`
+	LotNumber string  `json:"lotNumber"`
+	ExpiresAt *string `json:"expiresAt"`
+	Qty       int     `json:"qty"`
+	ProductID int     `json:"productId,omitempty"` // component, on kit lines
+}
+
+// SerialNumber is one serial-tracked unit. Order and customer fields are set once sold.
+type SerialNumber struct {
+	ID              int    `json:"id"`
+	ProductID       int    `json:"productId"`
+	ProductName     string `json:"productName"`
+	SerialNumber    string `json:"serialNumber"`
+	Status          string `json:"status"`
+	WarehouseID     *int   `json:"warehouseId"`
+	WarehouseName   string `json:"warehouseName,omitempty"`
+	PurchaseOrderID *int   `json:"purchaseOrderId,omitempty"`
+	OrderID         *int   `json:"orderId,omitempty"`
+	OrderItemID     *int   `json:"orderItemId,omitempty"`
+	CustomerID      *int   `json:"customerId,omitempty"`
+	CustomerName    string `json:"customerName,omitempty"`
+	ReceivedAt      string `json:"receivedAt"`
+	SoldAt          string `json:"soldAt,omitempty"`
+}
+
+// ProductVariant is a product row that belongs to a parent product. Price is the
+// effective price: PriceOverride when set, otherwise the parent's price.
+type ProductVariant struct {
+	ID            int               `json:"id"`
+	ParentID      int               `json:"parentId"`
+	Name          string            `json:"name"`
+	SKU           string            `json:"sku"`
+	Attributes    map[string]string `json:"attributes"`
+	Price         float64           `json:"price"`
+	PriceOverride *float64          `json:"priceOverride"`
+	TotalStock    int               `json:"totalStock"`
+}
+
+// Category is a node in the product category tree. Path is the names from the root,
+// e.g. "Apparel / Shirts". ProductCount covers the whole subtree.
+type Category struct {
+	ID           int        `json:"id"`
+	Name         string     `json:"name"`
+	ParentID     *int       `json:"parentId"`
+	Path         string     `json:"path"`
+	ProductCount int        `json:"productCount"`
+	Children     []Category `json:"children,omitempty"`
+}
+
+// KitComponent is one product in a kit and how many of it a single kit uses.
+type KitComponent struct {
+	ProductID    int    `json:"productId"`
+	ProductName  string `json:"productName"`
+	SKU          string `json:"sku,omitempty"`
+	Quantity     int    `json:"quantity"`
+	AvailableQty int    `json:"availableQty"` // component stock across warehouses
+}
+
+// OrderItemComponent records the component units a kit order line consumed.
+type OrderItemComponent struct {
+	ProductID   int    `json:"productId"`
+	ProductName string `json:"productName"`
+	Qty         int    `json:"qty"`
+}
+
+// PriceChange is one entry in a product's price history. EffectiveTo is nil for the
+// current price and for scheduled changes.
+type PriceChange struct {
+	ID            int     `json:"id"`
+	ProductID     int     `json:"productId"`
+	Price         float64 `json:"price"`
+	EffectiveFrom string  `json:"effectiveFrom"`
+	EffectiveTo   *string `json:"effectiveTo"`
+	Status        string  `json:"status"` // scheduled | applied | cancelled
+	ChangedBy     *int    `json:"changedBy"`
+	ChangedByName string  `json:"changedByName,omitempty"`
+	Reason        string  `json:"reason"`
+	CreatedAt     string  `json:"createdAt"`
+	AppliedAt     *string `json:"appliedAt"`
+}
+
+// ProductCost is how a product is costed and what one unit costs now. UnitCost is nil
+// until a cost is known; for kits it is the sum of the components.
+type ProductCost struct {
+	ProductID    int      `json:"productId"`
+	CostMethod   string   `json:"costMethod"` // average | standard
+	AverageCost  *float64 `json:"averageCost"`
+	StandardCost *float64 `json:"standardCost"`
+	UnitCost     *float64 `json:"unitCost"`
+	Price        float64  `json:"price"`
+	MarginPct    *float64 `json:"marginPct"`
+}
+
+// MarginRow is revenue against cost for one product, customer or period. Cost and
+// GrossMargin cover only the lines with a known unit cost (CostedRevenue).
+type MarginRow struct {
+	ProductID       int      `json:"productId,omitempty"`
+	ProductName     string   `json:"productName,omitempty"`
+	CustomerID      int      `json:"customerId,omitempty"`
+	CustomerName    string   `json:"customerName,omitempty"`
+	Period          string   `json:"period,omitempty"`
+	Orders          int      `json:"orders"`
+	Units           int      `json:"units"`
+	Revenue         float64  `json:"revenue"`
+	CostedRevenue   float64  `json:"costedRevenue"`
+	Cost            float64  `json:"cost"`
+	GrossMargin     float64  `json:"grossMargin"`
+	MarginPct       *float64 `json:"marginPct"`
+	UncostedRevenue float64  `json:"uncostedRevenue"`
+}
+
+// Attachment is an uploaded file owned by a product (images) or an order (documents
+// such as signed delivery notes). URL and ThumbnailURL are API paths that stream the
+// stored bytes; ThumbnailURL is empty for files that aren't images.
+type Attachment struct {
+	ID           int    `json:"id"`
+	OwnerType    string `json:"ownerType"` // product | order
+	OwnerID      int    `json:"ownerId"`
+	Filename     string `json:"filename"`
+	ContentType  string `json:"contentType"`
+	SizeBytes    int64  `json:"sizeBytes"`
+	Width        *int   `json:"width,omitempty"`
+	Height       *int   `json:"height,omitempty"`
+	URL          string `json:"url"`
+	ThumbnailURL string `json:"thumbnailUrl,omitempty"`
+	Position     int    `json:"position"`
+	UploadedBy   *int   `json:"uploadedBy"`
+	CreatedAt    string `json:"createdAt"`
+}
+
+// CustomerAddress is one of a customer's structured addresses. Lat/Lng are nil until
+// the address is geocoded or set by hand; shipping distance needs them.
+type CustomerAddress struct {
+	ID                int      `json:"id"`
+	CustomerID        int      `json:"customerId"`
+	Label             string   `json:"label"`
+	Street            string   `json:"street"`
+	City              string   `json:"city"`
+	Region            string   `json:"region"`
+	PostalCode        string   `json:"postalCode"`
+	Country           string   `json:"country"`
+	Lat               *float64 `json:"lat"`
+	Lng               *float64 `json:"lng"`
+	GeoPrecision      *string  `json:"geoPrecision"`
+	IsDefaultBilling  bool     `json:"isDefaultBilling"`
+	IsDefaultShipping bool     `json:"isDefaultShipping"`
+	CreatedAt         string   `json:"createdAt"`
+}
+
+// DuplicateCandidate is a pair of customers that look like the same person.
+// Reasons lists what matched: "email" (normalized), "phone" (digits) and/or "name" (fuzzy).
+type DuplicateCandidate struct {
+	Customer  Customer `json:"customer"`
+	Duplicate Customer `json:"duplicate"`
+	Score     float64  `json:"score"`
+	Reasons   []string `json:"reasons"`
+}
+
+// CustomerMerge is one entry in the merge log. Moved counts the rows re-pointed
+// from the merged customer to the survivor, by table.
+type CustomerMerge struct {
+	ID         int            `json:"id"`
+	SurvivorID int            `json:"survivorId"`
+	MergedID   int            `json:"mergedId"`
+	MergedBy   *int           `json:"mergedBy"`
+	Reason     string         `json:"reason"`
+	CreatedAt  string         `json:"createdAt"`
+	UndoneAt   *string        `json:"undoneAt"`
+	UndoneBy   *int           `json:"undoneBy"`
+	Moved      map[string]int `json:"moved"`
+}
+
+// CustomerSummary is the account view of one customer: lifetime totals over their
+// orders, the products they buy most and one page of their orders, newest first.
+type CustomerSummary struct {
+	Customer          Customer             `json:"customer"`
+	LifetimeRevenue   float64              `json:"lifetimeRevenue"`
+	OrderCount        int                  `json:"orderCount"`
+	AverageOrderValue float64              `json:"averageOrderValue"`
+	UnitsPurchased    int                  `json:"unitsPurchased"`
+	FirstOrderAt      *string              `json:"firstOrderAt"`
+	LastOrderAt       *string              `json:"lastOrderAt"`
+	TopProducts       []CustomerTopProduct `json:"topProducts"`
+	Orders            []CustomerOrderRow   `json:"orders"`
+	Pagination        map[string]any       `json:"pagination"`
+}
+
+// CustomerTopProduct is one product's share of a customer's purchases.
+type CustomerTopProduct struct {
+	ProductID   int     `json:"productId"`
+	ProductName string  `json:"productName"`
+	Quantity    int     `json:"quantity"`
+	Revenue     float64 `json:"revenue"`
+	Orders      int     `json:"orders"`
+}
+
+// CustomerOrderRow is an order in a customer's order history.
+type CustomerOrderRow struct {
+	OrderID      int     `json:"orderId"`
+	CreatedAt    string  `json:"createdAt"`
+	TotalPrice   float64 `json:"totalPrice"`
+	ShippingCost float64 `json:"shippingCost"`
+	ItemCount    int     `json:"itemCount"`
+	Units        int     `json:"units"`
+}
+
+// CustomerCredit is a customer's credit terms and where they stand against them.
+// CreditLimit nil means no limit, and then AvailableCredit is nil too.
+type CustomerCredit struct {
+	CustomerID         int      `json:"customerId"`
+	CreditLimit        *float64 `json:"creditLimit"`
+	PaymentTermsDays   int      `json:"paymentTermsDays"`
+	CreditAction       string   `json:"creditAction"` // block | flag
+	OutstandingBalance float64  `json:"outstandingBalance"`
+	OverdueBalance     float64  `json:"overdueBalance"`
+	AvailableCredit    *float64 `json:"availableCredit"`
+	UnpaidOrders       int      `json:"unpaidOrders"`
+	// UnappliedCredit is money paid in but not yet applied to an order (overpayments).
+	UnappliedCredit float64 `json:"unappliedCredit"`
+}
+
+// ARAgingRow is one customer's unpaid balance split by the age of the orders
+// (days since the order date). Overdue is the part past its due date.
+type ARAgingRow struct {
+	CustomerID   int     `json:"customerId,omitempty"`
+	CustomerName string  `json:"customerName,omitempty"`
+	Days0To30    float64 `json:"days0To30"`
+	Days31To60   float64 `json:"days31To60"`
+	Days61To90   float64 `json:"days61To90"`
+	DaysOver90   float64 `json:"daysOver90"`
+	Total        float64 `json:"total"`
+	Overdue      float64 `json:"overdue"`
+}
+
+// Payment is money received from a customer (kind "payment") or returned to them
+// (kind "refund"). Unapplied is the part not allocated to orders: customer credit
+// for a payment, a payout of that credit for a refund.
+type Payment struct {
+	ID          int                 `json:"id"`
+	CustomerID  int                 `json:"customerId"`
+	Kind        string              `json:"kind"`
+	Method      string              `json:"method"`
+	Amount      float64             `json:"amount"`
+	Reference   string              `json:"reference"`
+	Notes       string              `json:"notes"`
+	ReceivedAt  string              `json:"receivedAt"`
+	RecordedBy  *int                `json:"recordedBy"`
+	CreatedAt   string              `json:"createdAt"`
+	IntentID    *int                `json:"intentId"` // set for gateway card payments
+	Allocations []PaymentAllocation `json:"allocations"`
+	Unapplied   float64             `json:"unapplied"`
+}
+
+// PaymentAllocation is the part of a payment applied to (or refund taken off) one order.
+type PaymentAllocation struct {
+	ID        int     `json:"id"`
+	PaymentID int     `json:"paymentId"`
+	OrderID   int     `json:"orderId"`
+	Amount    float64 `json:"amount"`
+	CreatedAt string  `json:"createdAt"`
+}
+
+// OrderPayment is a payment or refund as it applies to one order.
+type OrderPayment struct {
+	PaymentID  int     `json:"paymentId"`
+	Kind       string  `json:"kind"`
+	Method     string  `json:"method"`
+	Reference  string  `json:"reference"`
+	ReceivedAt string  `json:"receivedAt"`
+	Amount     float64 `json:"amount"`
+}
+
+// PaymentIntent is a card charge for an order made through a payment gateway.
+type PaymentIntent struct {
+	ID             int     `json:"id"`
+	OrderID        int     `json:"orderId"`
+	CustomerID     int     `json:"customerId"`
+	Provider       string  `json:"provider"`
+	ProviderRef    string  `json:"providerRef"`
+	PaymentMethod  string  `json:"paymentMethod"`
+	Amount         float64 `json:"amount"`
+	Status         string  `json:"status"`
+	AutoCapture    bool    `json:"autoCapture"`
+	AmountCaptured float64 `json:"amountCaptured"`
+	AmountRefunded float64 `json:"amountRefunded"`
+	DeclineReason  *string `json:"declineReason"`
+	CreatedBy      *int    `json:"createdBy"`
+	CreatedAt      string  `json:"createdAt"`
+	UpdatedAt      string  `json:"updatedAt"`
+}
+
+// SegmentRule is one condition of a customer segment, e.g.
+// {"field": "lifetime_revenue", "op": "gt", "value": 10000} or
+// {"field": "tag", "op": "has", "value": "wholesale"}.
+type SegmentRule struct {
+	Field string `json:"field"`
+	Op    string `json:"op"`
+	Value any    `json:"value"`
+}
+
+// CustomerSegment is a saved set of rules; customers matching all (or any) of them
+// are its members.
+type CustomerSegment struct {
+	ID          int           `json:"id"`
+	Name        string        `json:"name"`
+	Description string        `json:"description"`
+	Match       string        `json:"match"`
+	Rules       []SegmentRule `json:"rules"`
+	MemberCount int           `json:"memberCount"`
+	CreatedAt   string        `json:"createdAt"`
+	UpdatedAt   string        `json:"updatedAt"`
+}
+
+// CustomerTagCount is a tag in use and how many active customers carry it.
+type CustomerTagCount struct {
+	Tag       string `json:"tag"`
+	Customers int    `json:"customers"`
+}
+
+// Activity is a note, call or follow-up task on a customer, optionally about one of
+// their orders. Due, assignee and status apply to tasks.
+type Activity struct {
+	ID          int     `json:"id"`
+	Kind        string  `json:"kind"`
+	CustomerID  int     `json:"customerId"`
+	OrderID     *int    `json:"orderId"`
+	Subject     string  `json:"subject"`
+	Body        string  `json:"body"`
+	DueAt       *string `json:"dueAt,omitempty"`
+	AssignedTo  *int    `json:"assignedTo,omitempty"`
+	Status      *string `json:"status,omitempty"`
+	Overdue     bool    `json:"overdue,omitempty"`
+	CompletedAt *string `json:"completedAt,omitempty"`
+	CompletedBy *int    `json:"completedBy,omitempty"`
+	CreatedBy   *int    `json:"createdBy"`
+	CreatedAt   string  `json:"createdAt"`
+	UpdatedAt   string  `json:"updatedAt"`
+}
+
+// Quote is an offer to a customer, shown at its current version. Lines without a
+// warehouse get one when the quote is accepted and becomes order OrderID.
+type Quote struct {
+	ID                int         `json:"id"`
+	CustomerID        int         `json:"customerId"`
+	Status            string      `json:"status"`
+	Version           int         `json:"version"`
+	ValidUntil        string      `json:"validUntil"`
+	ShippingAddressID *int        `json:"shippingAddressId"`
+	Notes             string      `json:"notes"`
+	Total             float64     `json:"total"`
+	OrderID           *int        `json:"orderId"`
+	SentAt            *string     `json:"sentAt,omitempty"`
+	AcceptedAt        *string     `json:"acceptedAt,omitempty"`
+	CreatedBy         *int        `json:"createdBy"`
+	CreatedAt         string      `json:"createdAt"`
+	UpdatedAt         string      `json:"updatedAt"`
+	Lines             []QuoteLine `json:"lines,omitempty"`
+}
+
+type QuoteLine struct {
+	ProductID   int     `json:"productId"`
+	ProductName string  `json:"productName"`
+	WarehouseID *int    `json:"warehouseId"`
+	Quantity    int     `json:"quantity"`
+	UnitPrice   float64 `json:"unitPrice"`
+	LineTotal   float64 `json:"lineTotal"`
+}
+
+// QuoteVersion is one saved revision of a quote.
+type QuoteVersion struct {
+	Version           int         `json:"version"`
+	ValidUntil        string      `json:"validUntil"`
+	ShippingAddressID *int        `json:"shippingAddressId"`
+	Notes             string      `json:"notes"`
+	Total             float64     `json:"total"`
+	CreatedBy         *int        `json:"createdBy"`
+	CreatedAt         string      `json:"createdAt"`
+	Lines             []QuoteLine `json:"lines"`
+}
+
+// Backorder is the part of an order line that wasn't in stock when the order was
+// placed. It is filled first-in-first-out as stock arrives in its warehouse.
+type Backorder struct {
+	ID          int     `json:"id"`
+	OrderID     int     `json:"orderId"`
+	OrderItemID int     `json:"orderItemId"`
+	ProductID   int     `json:"productId"`
+	WarehouseID int     `json:"warehouseId"`
+	Qty         int     `json:"qty"`
+	FilledQty   int     `json:"filledQty"`
+	Status      string  `json:"status"`
+	CreatedAt   string  `json:"createdAt"`
+	FilledAt    *string `json:"filledAt,omitempty"`
+}
+
+// RecurringOrder is a template the scheduler turns into an order at each NextRunAt.
+// Weekly and monthly schedules are kept as their Cron equivalent; times are UTC.
+type RecurringOrder struct {
+	ID                int                  `json:"id"`
+	CustomerID        int                  `json:"customerId"`
+	UserID            int                  `json:"userId"`
+	Name              string               `json:"name"`
+	Frequency         string               `json:"frequency"`
+	Weekday           *int                 `json:"weekday,omitempty"`
+	DayOfMonth        *int                 `json:"dayOfMonth,omitempty"`
+	Time              *string              `json:"time,omitempty"`
+	Cron              string               `json:"cron"`
+	ShippingAddressID *int                 `json:"shippingAddressId"`
+	AllowBackorder    bool                 `json:"allowBackorder"`
+	AllowOverCredit   bool                 `json:"allowOverCredit"`
+	Status            string               `json:"status"`
+	NextRunAt         *string              `json:"nextRunAt"`
+	LastRunAt         *string              `json:"lastRunAt"`
+	LastError         *string              `json:"lastError"`
+	FailureCount      int                  `json:"failureCount"`
+	CreatedAt         string               `json:"createdAt"`
+	UpdatedAt         string               `json:"updatedAt"`
+	Lines             []RecurringOrderLine `json:"lines,omitempty"`
+	RecentRuns        []RecurringOrderRun  `json:"recentRuns,omitempty"`
+}
+
+type RecurringOrderLine struct {
+	ProductID   int      `json:"productId"`
+	ProductName string   `json:"productName"`
+	WarehouseID int      `json:"warehouseId"`
+	Quantity    int      `json:"quantity"`
+	UnitPrice   *float64 `json:"unitPrice"` // nil: the product's price when the order is generated
+}
+
+// RecurringOrderRun is one due date of a template: the order it created, why it
+// failed, or that it was skipped.
+type RecurringOrderRun struct {
+	ID           int     `json:"id"`
+	ScheduledFor *string `json:"scheduledFor"`
+	RanAt        string  `json:"ranAt"`
+	Status       string  `json:"status"`
+	OrderID      *int    `json:"orderId,omitempty"`
+	Error        *string `json:"error,omitempty"`
+}
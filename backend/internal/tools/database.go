@@ -3,6 +3,8 @@ package tools
 
 import (
 	"database/sql"
+	"time"
+
 	_ "github.com/mattn/go-sqlite3"
 	log "github.com/sirupsen/logrus"
 )
@@ -13,16 +15,13 @@ var DB *sql.DB
 // InitDB initializes the SQLite database at the given filepath and creates required tables.
 func InitDB(filepath string) {
 	var err error
-	DB, err = sql.Open("sqlite3", filepath)
+	// Always enforce foreign keys in SQLite; the pragma is per connection, so it goes in
+	// the DSN for the driver to set on every connection the pool opens.
+	DB, err = sql.Open("sqlite3", filepath+"?_foreign_keys=on")
 	if err != nil {
 		log.Fatalf("Failed to open database: %v", err)
 	}
 
-	// Always enforce foreign keys in SQLite
-	if _, err := DB.Exec(`PRAGMA foreign_keys = ON;`); err != nil {
-		log.Fatalf("Failed to enable foreign keys: %v", err)
-	}
-
 	createUsersTable := `
 	CREATE TABLE IF NOT EXISTS users (
 		userId INTEGER PRIMARY KEY AUTOINCREMENT,
@@ -216,6 +215,117 @@ func InitDB(filepath string) {
 	if _, err = DB.Exec(modify); err != nil {
 		log.Printf("Failed to modify tables (might be already modified): %v", err)
 	}
+
+	createPurchasingTables()
+	createReorderTables()
+	createCycleCountTables()
+	createBinTables()
+	createLotTables()
+	createSerialTables()
+	createVariantColumns()
+	createCatalogTables()
+	createKitTables()
+	createPriceHistoryTables()
+	createCostColumns()
+	createSoftDeleteColumns()
+	createAttachmentTables()
+	createAddressTables()
+	createGeocodeColumns()
+	createCustomerMergeTables()
+	createCreditColumns()
+	createPaymentTables()
+	createPaymentIntentTables()
+	createSegmentTables()
+	createActivityTables()
+	createQuoteTables()
+	createBackorderTables()
+	createRecurringOrderTables()
+}
+
+// createPurchasingTables creates suppliers, their per-product costs and purchase orders.
+func createPurchasingTables() {
+	createSuppliersTable := `
+	CREATE TABLE IF NOT EXISTS suppliers (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL,
+		contact_name TEXT,
+		email TEXT,
+		phone TEXT,
+		lead_time_days INTEGER NOT NULL DEFAULT 0 CHECK (lead_time_days >= 0),
+		created_at TEXT NOT NULL
+	);`
+	if _, err := DB.Exec(createSuppliersTable); err != nil {
+		log.Fatalf("Failed to create suppliers table: %v", err)
+	}
+
+	// What a supplier charges us for a product (one row per supplier/product pair)
+	createSupplierProductsTable := `
+	CREATE TABLE IF NOT EXISTS supplier_products (
+		supplier_id   INTEGER NOT NULL,
+		product_id    INTEGER NOT NULL,
+		supplier_cost REAL NOT NULL CHECK (supplier_cost >= 0),
+		supplier_sku  TEXT,
+		PRIMARY KEY (supplier_id, product_id),
+		FOREIGN KEY(supplier_id) REFERENCES suppliers(id) ON DELETE CASCADE,
+		FOREIGN KEY(product_id)  REFERENCES products(id)  ON DELETE RESTRICT
+	);`
+	if _, err := DB.Exec(createSupplierProductsTable); err != nil {
+		log.Fatalf("Failed to create supplier_products table: %v", err)
+	}
+
+	// status: draft -> ordered -> partially_received -> received, or cancelled
+	createPurchaseOrdersTable := `
+	CREATE TABLE IF NOT EXISTS purchase_orders (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		supplier_id  INTEGER NOT NULL,
+		warehouse_id INTEGER NOT NULL,
+		status       TEXT NOT NULL DEFAULT 'draft',
+		notes        TEXT,
+		expected_at  TEXT,
+		created_at   TEXT NOT NULL,
+		FOREIGN KEY(supplier_id)  REFERENCES suppliers(id)  ON DELETE RESTRICT,
+		FOREIGN KEY(warehouse_id) REFERENCES warehouses(id) ON DELETE RESTRICT
+	);`
+	if _, err := DB.Exec(createPurchaseOrdersTable); err != nil {
+		log.Fatalf("Failed to create purchase_orders table: %v", err)
+	}
+
+	createPurchaseOrderLinesTable := `
+	CREATE TABLE IF NOT EXISTS purchase_order_lines (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		purchase_order_id INTEGER NOT NULL,
+		product_id        INTEGER NOT NULL,
+		qty_ordered       INTEGER NOT NULL CHECK (qty_ordered > 0),
+		qty_received      INTEGER NOT NULL DEFAULT 0 CHECK (qty_received >= 0),
+		unit_cost         REAL NOT NULL CHECK (unit_cost >= 0),
+		FOREIGN KEY(purchase_order_id) REFERENCES purchase_orders(id) ON DELETE CASCADE,
+		FOREIGN KEY(product_id)        REFERENCES products(id)        ON DELETE RESTRICT
+	);`
+	if _, err := DB.Exec(createPurchaseOrderLinesTable); err != nil {
+		log.Fatalf("Failed to create purchase_order_lines table: %v", err)
+	}
+
+	// Every receiving event, so partial deliveries stay auditable
+	createPurchaseOrderReceiptsTable := `
+	CREATE TABLE IF NOT EXISTS purchase_order_receipts (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		purchase_order_id INTEGER NOT NULL,
+		line_id           INTEGER NOT NULL,
+		qty               INTEGER NOT NULL CHECK (qty > 0),
+		received_at       TEXT NOT NULL,
+		FOREIGN KEY(purchase_order_id) REFERENCES purchase_orders(id)      ON DELETE CASCADE,
+		FOREIGN KEY(line_id)           REFERENCES purchase_order_lines(id) ON DELETE CASCADE
+	);`
+	if _, err := DB.Exec(createPurchaseOrderReceiptsTable); err != nil {
+		log.Fatalf("Failed to create purchase_order_receipts table: %v", err)
+	}
+
+	if _, err := DB.Exec(`CREATE INDEX IF NOT EXISTS idx_pol_po ON purchase_order_lines(purchase_order_id);`); err != nil {
+		log.Fatalf("Failed to create idx_pol_po: %v", err)
+	}
+	if _, err := DB.Exec(`CREATE INDEX IF NOT EXISTS idx_sp_product ON supplier_products(product_id);`); err != nil {
+		log.Fatalf("Failed to create idx_sp_product: %v", err)
+	}
 }
 
 // InsertDummyUser inserts a default user into the users table if not already present for sample login.
@@ -228,3 +338,1020 @@ func InsertDummyUser() {
 		log.Fatalf("Failed to insert dummy user: %v", err)
 	}
 }
+
+// createReorderTables creates the per-warehouse reorder points derived from sales velocity.
+func createReorderTables() {
+	// manual = 1 means the values were set by a user and recompute leaves them alone.
+	// below_point remembers whether we already announced product.reorder_needed.
+	createReorderPointsTable := `
+	CREATE TABLE IF NOT EXISTS reorder_points (
+		warehouse_id   INTEGER NOT NULL,
+		product_id     INTEGER NOT NULL,
+		reorder_point  INTEGER NOT NULL CHECK (reorder_point >= 0),
+		safety_stock   INTEGER NOT NULL CHECK (safety_stock >= 0),
+		daily_velocity REAL NOT NULL DEFAULT 0,
+		lead_time_days INTEGER NOT NULL DEFAULT 0,
+		manual         INTEGER NOT NULL DEFAULT 0,
+		below_point    INTEGER NOT NULL DEFAULT 0,
+		computed_at    TEXT NOT NULL,
+		PRIMARY KEY (warehouse_id, product_id),
+		FOREIGN KEY(warehouse_id) REFERENCES warehouses(id) ON DELETE CASCADE,
+		FOREIGN KEY(product_id)   REFERENCES products(id)   ON DELETE CASCADE
+	);`
+	if _, err := DB.Exec(createReorderPointsTable); err != nil {
+		log.Fatalf("Failed to create reorder_points table: %v", err)
+	}
+}
+
+// createCycleCountTables creates stock-take sessions and the adjustment ledger they post to.
+func createCycleCountTables() {
+	// status: open (products frozen) -> approved | cancelled
+	createCycleCountsTable := `
+	CREATE TABLE IF NOT EXISTS cycle_counts (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		warehouse_id INTEGER NOT NULL,
+		status       TEXT NOT NULL DEFAULT 'open',
+		notes        TEXT,
+		created_at   TEXT NOT NULL,
+		closed_at    TEXT,
+		FOREIGN KEY(warehouse_id) REFERENCES warehouses(id) ON DELETE CASCADE
+	);`
+	if _, err := DB.Exec(createCycleCountsTable); err != nil {
+		log.Fatalf("Failed to create cycle_counts table: %v", err)
+	}
+
+	// system_qty is the on-hand snapshot taken when the session froze the product
+	createCycleCountLinesTable := `
+	CREATE TABLE IF NOT EXISTS cycle_count_lines (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		cycle_count_id INTEGER NOT NULL,
+		product_id     INTEGER NOT NULL,
+		system_qty     INTEGER NOT NULL,
+		counted_qty    INTEGER CHECK (counted_qty >= 0),
+		counted_at     TEXT,
+		reason         TEXT,
+		UNIQUE (cycle_count_id, product_id),
+		FOREIGN KEY(cycle_count_id) REFERENCES cycle_counts(id) ON DELETE CASCADE,
+		FOREIGN KEY(product_id)     REFERENCES products(id)     ON DELETE CASCADE
+	);`
+	if _, err := DB.Exec(createCycleCountLinesTable); err != nil {
+		log.Fatalf("Failed to create cycle_count_lines table: %v", err)
+	}
+
+	// Every non-sale correction to on-hand stock, with why it happened
+	createStockAdjustmentsTable := `
+	CREATE TABLE IF NOT EXISTS stock_adjustments (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		warehouse_id INTEGER NOT NULL,
+		product_id   INTEGER NOT NULL,
+		qty_before   INTEGER NOT NULL,
+		qty_after    INTEGER NOT NULL,
+		delta        INTEGER NOT NULL,
+		reason       TEXT NOT NULL,
+		source       TEXT NOT NULL,
+		source_id    INTEGER,
+		created_at   TEXT NOT NULL,
+		FOREIGN KEY(warehouse_id) REFERENCES warehouses(id) ON DELETE CASCADE,
+		FOREIGN KEY(product_id)   REFERENCES products(id)   ON DELETE CASCADE
+	);`
+	if _, err := DB.Exec(createStockAdjustmentsTable); err != nil {
+		log.Fatalf("Failed to create stock_adjustments table: %v", err)
+	}
+
+	if _, err := DB.Exec(`CREATE INDEX IF NOT EXISTS idx_ccl_product ON cycle_count_lines(product_id);`); err != nil {
+		log.Fatalf("Failed to create idx_ccl_product: %v", err)
+	}
+	if _, err := DB.Exec(`CREATE INDEX IF NOT EXISTS idx_sa_wh_created ON stock_adjustments(warehouse_id, created_at);`); err != nil {
+		log.Fatalf("Failed to create idx_sa_wh_created: %v", err)
+	}
+}
+
+// createBinTables creates named bin locations inside warehouses and per-bin stock.
+// Bin quantities are a breakdown of warehouse_inventory.qty: whatever is not in a
+// bin (SUM(bin_inventory.qty) < warehouse_inventory.qty) is unassigned, e.g. still on the dock.
+func createBinTables() {
+	createBinsTable := `
+	CREATE TABLE IF NOT EXISTS bins (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		warehouse_id INTEGER NOT NULL,
+		code         TEXT NOT NULL,
+		description  TEXT,
+		created_at   TEXT NOT NULL,
+		UNIQUE (warehouse_id, code),
+		FOREIGN KEY(warehouse_id) REFERENCES warehouses(id) ON DELETE CASCADE
+	);`
+	if _, err := DB.Exec(createBinsTable); err != nil {
+		log.Fatalf("Failed to create bins table: %v", err)
+	}
+
+	createBinInventoryTable := `
+	CREATE TABLE IF NOT EXISTS bin_inventory (
+		bin_id     INTEGER NOT NULL,
+		product_id INTEGER NOT NULL,
+		qty        INTEGER NOT NULL CHECK (qty >= 0),
+		PRIMARY KEY (bin_id, product_id),
+		FOREIGN KEY(bin_id)     REFERENCES bins(id)     ON DELETE CASCADE,
+		FOREIGN KEY(product_id) REFERENCES products(id) ON DELETE CASCADE
+	);`
+	if _, err := DB.Exec(createBinInventoryTable); err != nil {
+		log.Fatalf("Failed to create bin_inventory table: %v", err)
+	}
+
+	// Where each order line was picked from. bin_code is kept so the pick list
+	// survives a bin being removed later; bin_id NULL means "not in a bin".
+	createOrderItemPicksTable := `
+	CREATE TABLE IF NOT EXISTS order_item_picks (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		order_item_id INTEGER NOT NULL,
+		bin_id        INTEGER,
+		bin_code      TEXT NOT NULL DEFAULT '',
+		qty           INTEGER NOT NULL CHECK (qty > 0),
+		FOREIGN KEY(order_item_id) REFERENCES order_items(id) ON DELETE CASCADE,
+		FOREIGN KEY(bin_id)        REFERENCES bins(id)        ON DELETE SET NULL
+	);`
+	if _, err := DB.Exec(createOrderItemPicksTable); err != nil {
+		log.Fatalf("Failed to create order_item_picks table: %v", err)
+	}
+
+	if _, err := DB.Exec(`CREATE INDEX IF NOT EXISTS idx_bi_product ON bin_inventory(product_id);`); err != nil {
+		log.Fatalf("Failed to create idx_bi_product: %v", err)
+	}
+	if _, err := DB.Exec(`CREATE INDEX IF NOT EXISTS idx_oip_item ON order_item_picks(order_item_id);`); err != nil {
+		log.Fatalf("Failed to create idx_oip_item: %v", err)
+	}
+}
+
+// createLotTables creates lot/batch tracking. Like bins, lots are a breakdown of
+// warehouse_inventory.qty; stock outside any lot is treated as non-perishable.
+// expires_at is a YYYY-MM-DD date and a lot is expired once that date has passed.
+func createLotTables() {
+	createInventoryLotsTable := `
+	CREATE TABLE IF NOT EXISTS inventory_lots (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		warehouse_id INTEGER NOT NULL,
+		product_id   INTEGER NOT NULL,
+		lot_number   TEXT NOT NULL,
+		expires_at   TEXT,
+		qty          INTEGER NOT NULL CHECK (qty >= 0),
+		received_at  TEXT NOT NULL,
+		UNIQUE (warehouse_id, product_id, lot_number),
+		FOREIGN KEY(warehouse_id) REFERENCES warehouses(id) ON DELETE CASCADE,
+		FOREIGN KEY(product_id)   REFERENCES products(id)   ON DELETE CASCADE
+	);`
+	if _, err := DB.Exec(createInventoryLotsTable); err != nil {
+		log.Fatalf("Failed to create inventory_lots table: %v", err)
+	}
+
+	// Lots consumed by each order line; number and expiry are copied so the
+	// trace survives the lot's warehouse being removed.
+	createOrderItemLotsTable := `
+	CREATE TABLE IF NOT EXISTS order_item_lots (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		order_item_id INTEGER NOT NULL,
+		lot_id        INTEGER,
+		lot_number    TEXT NOT NULL,
+		expires_at    TEXT,
+		qty           INTEGER NOT NULL CHECK (qty > 0),
+		FOREIGN KEY(order_item_id) REFERENCES order_items(id)    ON DELETE CASCADE,
+		FOREIGN KEY(lot_id)        REFERENCES inventory_lots(id) ON DELETE SET NULL
+	);`
+	if _, err := DB.Exec(createOrderItemLotsTable); err != nil {
+		log.Fatalf("Failed to create order_item_lots table: %v", err)
+	}
+
+	if _, err := DB.Exec(`CREATE INDEX IF NOT EXISTS idx_lots_expires ON inventory_lots(expires_at);`); err != nil {
+		log.Fatalf("Failed to create idx_lots_expires: %v", err)
+	}
+	if _, err := DB.Exec(`CREATE INDEX IF NOT EXISTS idx_oil_item ON order_item_lots(order_item_id);`); err != nil {
+		log.Fatalf("Failed to create idx_oil_item: %v", err)
+	}
+
+	// On-hand minus expired lots. Anything that reports sellable stock reads this.
+	createAvailableView := `
+	CREATE VIEW IF NOT EXISTS warehouse_inventory_available AS
+	SELECT wi.warehouse_id,
+	       wi.product_id,
+	       wi.qty,
+	       COALESCE(x.expired_qty, 0)          AS expired_qty,
+	       wi.qty - COALESCE(x.expired_qty, 0) AS available_qty
+	FROM warehouse_inventory wi
+	LEFT JOIN (
+		SELECT warehouse_id, product_id, SUM(qty) AS expired_qty
+		FROM inventory_lots
+		WHERE expires_at < date('now')
+		GROUP BY warehouse_id, product_id
+	) x ON x.warehouse_id = wi.warehouse_id AND x.product_id = wi.product_id;`
+	if _, err := DB.Exec(createAvailableView); err != nil {
+		log.Fatalf("Failed to create warehouse_inventory_available view: %v", err)
+	}
+}
+
+// hasColumn reports whether table already has column.
+func hasColumn(table, column string) bool {
+	var n int
+	if err := DB.QueryRow(`SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?`, table, column).Scan(&n); err != nil {
+		log.Fatalf("Failed to inspect %s: %v", table, err)
+	}
+	return n > 0
+}
+
+// addColumnIfMissing adds a column to an existing table unless it is already there,
+// so new columns reach databases created before they existed.
+func addColumnIfMissing(table, column, definition string) {
+	if hasColumn(table, column) {
+		return
+	}
+	if _, err := DB.Exec(`ALTER TABLE ` + table + ` ADD COLUMN ` + column + ` ` + definition); err != nil {
+		log.Fatalf("Failed to add %s.%s: %v", table, column, err)
+	}
+}
+
+// createSerialTables creates per-unit serial tracking for products flagged serial_tracked.
+// A serial is in_stock in exactly one warehouse until it is assigned to an order line (sold).
+func createSerialTables() {
+	addColumnIfMissing("products", "serial_tracked", "INTEGER NOT NULL DEFAULT 0")
+
+	createSerialNumbersTable := `
+	CREATE TABLE IF NOT EXISTS serial_numbers (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		product_id        INTEGER NOT NULL,
+		serial_number     TEXT NOT NULL,
+		status            TEXT NOT NULL DEFAULT 'in_stock' CHECK (status IN ('in_stock', 'sold')),
+		warehouse_id      INTEGER,
+		order_item_id     INTEGER,
+		purchase_order_id INTEGER,
+		received_at       TEXT NOT NULL,
+		sold_at           TEXT,
+		UNIQUE (product_id, serial_number),
+		FOREIGN KEY(product_id)        REFERENCES products(id)        ON DELETE CASCADE,
+		FOREIGN KEY(warehouse_id)      REFERENCES warehouses(id)      ON DELETE SET NULL,
+		FOREIGN KEY(order_item_id)     REFERENCES order_items(id)     ON DELETE SET NULL,
+		FOREIGN KEY(purchase_order_id) REFERENCES purchase_orders(id) ON DELETE SET NULL
+	);`
+	if _, err := DB.Exec(createSerialNumbersTable); err != nil {
+		log.Fatalf("Failed to create serial_numbers table: %v", err)
+	}
+
+	if _, err := DB.Exec(`CREATE INDEX IF NOT EXISTS idx_sn_serial ON serial_numbers(serial_number);`); err != nil {
+		log.Fatalf("Failed to create idx_sn_serial: %v", err)
+	}
+	if _, err := DB.Exec(`CREATE INDEX IF NOT EXISTS idx_sn_order_item ON serial_numbers(order_item_id);`); err != nil {
+		log.Fatalf("Failed to create idx_sn_order_item: %v", err)
+	}
+	if _, err := DB.Exec(`CREATE INDEX IF NOT EXISTS idx_sn_wh_product ON serial_numbers(warehouse_id, product_id, status);`); err != nil {
+		log.Fatalf("Failed to create idx_sn_wh_product: %v", err)
+	}
+}
+
+// createVariantColumns lets a product act as the parent of size/colour style variants.
+// Variants are ordinary product rows (own inventory, orders, price) pointing at their
+// parent via parent_id. The parent lists the allowed values per attribute in
+// variant_attributes ({"size":["S","M"]}); each variant stores its own values in
+// attributes ({"size":"M"}). Both are JSON with sorted keys.
+func createVariantColumns() {
+	addColumnIfMissing("products", "parent_id", "INTEGER REFERENCES products(id) ON DELETE CASCADE")
+	addColumnIfMissing("products", "sku", "TEXT")
+	addColumnIfMissing("products", "attributes", "TEXT")
+	addColumnIfMissing("products", "variant_attributes", "TEXT")
+	addColumnIfMissing("products", "price_override", "REAL")
+
+	if _, err := DB.Exec(`CREATE UNIQUE INDEX IF NOT EXISTS idx_products_sku ON products(sku) WHERE sku IS NOT NULL;`); err != nil {
+		log.Fatalf("Failed to create idx_products_sku: %v", err)
+	}
+	if _, err := DB.Exec(`CREATE UNIQUE INDEX IF NOT EXISTS idx_products_variant ON products(parent_id, attributes) WHERE parent_id IS NOT NULL;`); err != nil {
+		log.Fatalf("Failed to create idx_products_variant: %v", err)
+	}
+}
+
+// createCatalogTables adds the category tree and the barcode/description/category
+// product columns. categories.parent_id NULL marks a root category.
+func createCatalogTables() {
+	createCategoriesTable := `
+	CREATE TABLE IF NOT EXISTS categories (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name       TEXT NOT NULL,
+		parent_id  INTEGER,
+		created_at TEXT NOT NULL,
+		FOREIGN KEY(parent_id) REFERENCES categories(id) ON DELETE RESTRICT
+	);`
+	if _, err := DB.Exec(createCategoriesTable); err != nil {
+		log.Fatalf("Failed to create categories table: %v", err)
+	}
+
+	addColumnIfMissing("products", "barcode", "TEXT")
+	addColumnIfMissing("products", "description", "TEXT")
+	addColumnIfMissing("products", "category_id", "INTEGER REFERENCES categories(id) ON DELETE SET NULL")
+
+	if _, err := DB.Exec(`CREATE INDEX IF NOT EXISTS idx_categories_parent ON categories(parent_id);`); err != nil {
+		log.Fatalf("Failed to create idx_categories_parent: %v", err)
+	}
+	if _, err := DB.Exec(`CREATE UNIQUE INDEX IF NOT EXISTS idx_products_barcode ON products(barcode) WHERE barcode IS NOT NULL;`); err != nil {
+		log.Fatalf("Failed to create idx_products_barcode: %v", err)
+	}
+	if _, err := DB.Exec(`CREATE INDEX IF NOT EXISTS idx_products_category ON products(category_id);`); err != nil {
+		log.Fatalf("Failed to create idx_products_category: %v", err)
+	}
+}
+
+// createKitTables adds kits: products sold as a set of component products. A kit holds
+// no stock of its own; orders deduct the components and availability is derived from them.
+func createKitTables() {
+	createKitComponentsTable := `
+	CREATE TABLE IF NOT EXISTS kit_components (
+		kit_id       INTEGER NOT NULL,
+		component_id INTEGER NOT NULL,
+		quantity     INTEGER NOT NULL CHECK (quantity > 0),
+		PRIMARY KEY (kit_id, component_id),
+		FOREIGN KEY(kit_id) REFERENCES products(id) ON DELETE CASCADE,
+		FOREIGN KEY(component_id) REFERENCES products(id) ON DELETE RESTRICT
+	);`
+	if _, err := DB.Exec(createKitComponentsTable); err != nil {
+		log.Fatalf("Failed to create kit_components table: %v", err)
+	}
+	if _, err := DB.Exec(`CREATE INDEX IF NOT EXISTS idx_kit_components_component ON kit_components(component_id);`); err != nil {
+		log.Fatalf("Failed to create idx_kit_components_component: %v", err)
+	}
+
+	// Snapshot of what each kit order line consumed
+	createOrderItemComponentsTable := `
+	CREATE TABLE IF NOT EXISTS order_item_components (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		order_item_id INTEGER NOT NULL,
+		product_id    INTEGER NOT NULL,
+		product_name  TEXT NOT NULL,
+		qty           INTEGER NOT NULL,
+		FOREIGN KEY(order_item_id) REFERENCES order_items(id) ON DELETE CASCADE
+	);`
+	if _, err := DB.Exec(createOrderItemComponentsTable); err != nil {
+		log.Fatalf("Failed to create order_item_components table: %v", err)
+	}
+	if _, err := DB.Exec(`CREATE INDEX IF NOT EXISTS idx_oic_item ON order_item_components(order_item_id);`); err != nil {
+		log.Fatalf("Failed to create idx_oic_item: %v", err)
+	}
+
+	// Picks and lots of a kit line belong to its components; NULL means the line's own product
+	addColumnIfMissing("order_item_picks", "product_id", "INTEGER")
+	addColumnIfMissing("order_item_lots", "product_id", "INTEGER")
+
+	// warehouse_inventory_available plus one derived row per kit and warehouse: the
+	// number of complete kits the components can make there.
+	createProductAvailableView := `
+	CREATE VIEW IF NOT EXISTS product_inventory_available AS
+	SELECT warehouse_id, product_id, qty, expired_qty, available_qty
+	FROM warehouse_inventory_available
+	UNION ALL
+	SELECT warehouse_id, product_id, kits, 0, kits
+	FROM (
+		SELECT w.id AS warehouse_id, c.kit_id AS product_id,
+		       MIN(MAX(COALESCE(a.available_qty, 0), 0) / c.quantity) AS kits
+		FROM kit_components c
+		CROSS JOIN warehouses w
+		LEFT JOIN warehouse_inventory_available a
+		  ON a.warehouse_id = w.id AND a.product_id = c.component_id
+		GROUP BY w.id, c.kit_id
+	);`
+	if _, err := DB.Exec(createProductAvailableView); err != nil {
+		log.Fatalf("Failed to create product_inventory_available view: %v", err)
+	}
+}
+
+// createPriceHistoryTables records every product price with the period it was in effect.
+// A row is scheduled until its effective_from passes, then applied; the applied row with
+// effective_to NULL is the current price. changed_by is users.userId when known.
+func createPriceHistoryTables() {
+	createPriceHistoryTable := `
+	CREATE TABLE IF NOT EXISTS product_price_history (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		product_id     INTEGER NOT NULL,
+		price          REAL NOT NULL CHECK (price > 0),
+		effective_from TEXT NOT NULL,
+		effective_to   TEXT,
+		status         TEXT NOT NULL CHECK (status IN ('scheduled', 'applied', 'cancelled')),
+		changed_by     INTEGER,
+		reason         TEXT NOT NULL DEFAULT '',
+		created_at     TEXT NOT NULL,
+		applied_at     TEXT,
+		FOREIGN KEY(product_id) REFERENCES products(id) ON DELETE CASCADE,
+		FOREIGN KEY(changed_by) REFERENCES users(userId) ON DELETE SET NULL
+	);`
+	if _, err := DB.Exec(createPriceHistoryTable); err != nil {
+		log.Fatalf("Failed to create product_price_history table: %v", err)
+	}
+	if _, err := DB.Exec(`CREATE INDEX IF NOT EXISTS idx_price_history_product ON product_price_history(product_id, effective_from);`); err != nil {
+		log.Fatalf("Failed to create idx_price_history_product: %v", err)
+	}
+	if _, err := DB.Exec(`CREATE INDEX IF NOT EXISTS idx_price_history_due ON product_price_history(status, effective_from);`); err != nil {
+		log.Fatalf("Failed to create idx_price_history_due: %v", err)
+	}
+
+	// Products that predate price tracking start their history at the current price
+	now := time.Now().UTC().Format(time.RFC3339)
+	if _, err := DB.Exec(`
+		INSERT INTO product_price_history (product_id, price, effective_from, status, reason, created_at, applied_at)
+		SELECT p.id, p.price, ?, 'applied', 'initial price', ?, ?
+		FROM products p
+		WHERE p.price > 0
+		  AND NOT EXISTS (SELECT 1 FROM product_price_history h WHERE h.product_id = p.id)`,
+		now, now, now,
+	); err != nil {
+		log.Fatalf("Failed to seed product_price_history: %v", err)
+	}
+}
+
+// createCostColumns adds product costing. cost_method 'average' values stock at the
+// weighted-average cost of purchase receipts (average_cost, NULL until the first one);
+// 'standard' uses the fixed standard_cost. order_items.unit_cost snapshots the cost at
+// sale time and stays NULL on lines sold before any cost was known.
+func createCostColumns() {
+	addColumnIfMissing("products", "cost_method", "TEXT NOT NULL DEFAULT 'average' CHECK (cost_method IN ('average', 'standard'))")
+	addColumnIfMissing("products", "average_cost", "REAL")
+	addColumnIfMissing("products", "standard_cost", "REAL")
+	addColumnIfMissing("order_items", "unit_cost", "REAL")
+}
+
+// createSoftDeleteColumns lets products, customers and warehouses be archived rather
+// than removed. Lists hide rows with deleted_at set; the rows stay so order history
+// keeps resolving. order_items.warehouse_id was added lazily by the order handler;
+// it is created here too because the delete checks read it.
+func createSoftDeleteColumns() {
+	addColumnIfMissing("products", "deleted_at", "TEXT")
+	addColumnIfMissing("customers", "deleted_at", "TEXT")
+	addColumnIfMissing("warehouses", "deleted_at", "TEXT")
+	addColumnIfMissing("order_items", "warehouse_id", "INTEGER")
+}
+
+// createAttachmentTables stores metadata for uploaded files (product images, order
+// documents). owner_type/owner_id point at the owning row; the bytes live in
+// tools.Files under storage_key, with an optional generated thumbnail.
+func createAttachmentTables() {
+	createAttachmentsTable := `
+	CREATE TABLE IF NOT EXISTS attachments (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		owner_type    TEXT NOT NULL CHECK (owner_type IN ('product', 'order')),
+		owner_id      INTEGER NOT NULL,
+		filename      TEXT NOT NULL,
+		content_type  TEXT NOT NULL,
+		size_bytes    INTEGER NOT NULL,
+		width         INTEGER,
+		height        INTEGER,
+		storage_key   TEXT NOT NULL UNIQUE,
+		thumbnail_key TEXT,
+		position      INTEGER NOT NULL DEFAULT 0,
+		uploaded_by   INTEGER,
+		created_at    TEXT NOT NULL,
+		FOREIGN KEY(uploaded_by) REFERENCES users(userId) ON DELETE SET NULL
+	);`
+	if _, err := DB.Exec(createAttachmentsTable); err != nil {
+		log.Fatalf("Failed to create attachments table: %v", err)
+	}
+	if _, err := DB.Exec(`CREATE INDEX IF NOT EXISTS idx_attachments_owner ON attachments(owner_type, owner_id, position);`); err != nil {
+		log.Fatalf("Failed to create idx_attachments_owner: %v", err)
+	}
+}
+
+// createAddressTables adds structured customer addresses. A customer has any number,
+// with at most one default billing and one default shipping address. customers.address
+// stays as display text and customers.lat/lng are superseded by the per-address
+// coordinates; customers that only had the free-text address get it as their default.
+// Orders keep the shipping address id plus a text snapshot, so editing or removing an
+// address doesn't rewrite where past orders went.
+func createAddressTables() {
+	createCustomerAddressesTable := `
+	CREATE TABLE IF NOT EXISTS customer_addresses (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		customer_id         INTEGER NOT NULL,
+		label               TEXT NOT NULL DEFAULT '',
+		street              TEXT NOT NULL,
+		city                TEXT NOT NULL DEFAULT '',
+		region              TEXT NOT NULL DEFAULT '',
+		postal_code         TEXT NOT NULL DEFAULT '',
+		country             TEXT NOT NULL DEFAULT '',
+		lat                 REAL,
+		lng                 REAL,
+		is_default_billing  INTEGER NOT NULL DEFAULT 0,
+		is_default_shipping INTEGER NOT NULL DEFAULT 0,
+		created_at          TEXT NOT NULL,
+		FOREIGN KEY(customer_id) REFERENCES customers(id) ON DELETE CASCADE
+	);`
+	if _, err := DB.Exec(createCustomerAddressesTable); err != nil {
+		log.Fatalf("Failed to create customer_addresses table: %v", err)
+	}
+	if _, err := DB.Exec(`CREATE INDEX IF NOT EXISTS idx_customer_addresses_customer ON customer_addresses(customer_id);`); err != nil {
+		log.Fatalf("Failed to create idx_customer_addresses_customer: %v", err)
+	}
+	if _, err := DB.Exec(`CREATE UNIQUE INDEX IF NOT EXISTS ux_customer_default_billing ON customer_addresses(customer_id) WHERE is_default_billing = 1;`); err != nil {
+		log.Fatalf("Failed to create ux_customer_default_billing: %v", err)
+	}
+	if _, err := DB.Exec(`CREATE UNIQUE INDEX IF NOT EXISTS ux_customer_default_shipping ON customer_addresses(customer_id) WHERE is_default_shipping = 1;`); err != nil {
+		log.Fatalf("Failed to create ux_customer_default_shipping: %v", err)
+	}
+
+	addColumnIfMissing("orders", "shipping_address_id", "INTEGER REFERENCES customer_addresses(id) ON DELETE SET NULL")
+	addColumnIfMissing("orders", "shipping_address", "TEXT")
+
+	if _, err := DB.Exec(`
+		INSERT INTO customer_addresses (customer_id, street, lat, lng, is_default_billing, is_default_shipping, created_at)
+		SELECT c.id, TRIM(c.address), c.lat, c.lng, 1, 1, ?
+		FROM customers c
+		WHERE TRIM(COALESCE(c.address, '')) <> ''
+		  AND NOT EXISTS (SELECT 1 FROM customer_addresses a WHERE a.customer_id = c.id)`,
+		time.Now().UTC().Format(time.RFC3339),
+	); err != nil {
+		log.Fatalf("Failed to migrate customer addresses: %v", err)
+	}
+}
+
+// createGeocodeColumns gives warehouses a structured address to geocode from and records
+// where each address's coordinates came from: geo_precision is 'manual' (entered by hand),
+// 'postal' or 'city' (gazetteer match), or NULL when the row predates geocoding.
+func createGeocodeColumns() {
+	addColumnIfMissing("warehouses", "city", "TEXT NOT NULL DEFAULT ''")
+	addColumnIfMissing("warehouses", "region", "TEXT NOT NULL DEFAULT ''")
+	addColumnIfMissing("warehouses", "postal_code", "TEXT NOT NULL DEFAULT ''")
+	addColumnIfMissing("warehouses", "country", "TEXT NOT NULL DEFAULT ''")
+	addColumnIfMissing("warehouses", "geo_precision", "TEXT")
+	addColumnIfMissing("customer_addresses", "geo_precision", "TEXT")
+}
+
+// createCustomerMergeTables logs customer merges. The merged customer is archived with
+// merged_into pointing at the survivor, and every row re-pointed by the merge is listed
+// in customer_merge_moves so an undo moves exactly those rows back.
+func createCustomerMergeTables() {
+	addColumnIfMissing("customers", "merged_into", "INTEGER REFERENCES customers(id) ON DELETE SET NULL")
+
+	createCustomerMergesTable := `
+	CREATE TABLE IF NOT EXISTS customer_merges (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		survivor_id         INTEGER NOT NULL,
+		merged_id           INTEGER NOT NULL,
+		merged_deleted_at   TEXT,
+		merged_by           INTEGER,
+		reason              TEXT NOT NULL DEFAULT '',
+		created_at          TEXT NOT NULL,
+		undone_at           TEXT,
+		undone_by           INTEGER,
+		FOREIGN KEY(survivor_id) REFERENCES customers(id) ON DELETE CASCADE,
+		FOREIGN KEY(merged_id) REFERENCES customers(id) ON DELETE CASCADE,
+		FOREIGN KEY(merged_by) REFERENCES users(userId) ON DELETE SET NULL,
+		FOREIGN KEY(undone_by) REFERENCES users(userId) ON DELETE SET NULL
+	);`
+	if _, err := DB.Exec(createCustomerMergesTable); err != nil {
+		log.Fatalf("Failed to create customer_merges table: %v", err)
+	}
+
+	createCustomerMergeMovesTable := `
+	CREATE TABLE IF NOT EXISTS customer_merge_moves (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		merge_id             INTEGER NOT NULL,
+		table_name           TEXT NOT NULL,
+		row_id               INTEGER NOT NULL,
+		was_default_billing  INTEGER NOT NULL DEFAULT 0,
+		was_default_shipping INTEGER NOT NULL DEFAULT 0,
+		FOREIGN KEY(merge_id) REFERENCES customer_merges(id) ON DELETE CASCADE
+	);`
+	if _, err := DB.Exec(createCustomerMergeMovesTable); err != nil {
+		log.Fatalf("Failed to create customer_merge_moves table: %v", err)
+	}
+	if _, err := DB.Exec(`CREATE INDEX IF NOT EXISTS idx_customer_merge_moves_merge ON customer_merge_moves(merge_id);`); err != nil {
+		log.Fatalf("Failed to create idx_customer_merge_moves_merge: %v", err)
+	}
+}
+
+// createCreditColumns adds per-customer credit limits and payment terms, and tracks what
+// each order is owed. credit_limit NULL means no limit; payment_terms_days 0 is due on
+// receipt. An order's balance is totalPrice - amount_paid. Orders that predate credit
+// tracking are taken as settled, so upgrading doesn't put every customer over their limit;
+// createPaymentTables gives them the opening-balance payments that back that up.
+func createCreditColumns() {
+	addColumnIfMissing("customers", "credit_limit", "REAL CHECK (credit_limit IS NULL OR credit_limit >= 0)")
+	addColumnIfMissing("customers", "payment_terms_days", "INTEGER NOT NULL DEFAULT 0 CHECK (payment_terms_days >= 0)")
+	addColumnIfMissing("customers", "credit_action", "TEXT NOT NULL DEFAULT 'block' CHECK (credit_action IN ('block', 'flag'))")
+
+	tracked := hasColumn("orders", "amount_paid")
+	addColumnIfMissing("orders", "due_date", "TEXT")
+	addColumnIfMissing("orders", "amount_paid", "REAL NOT NULL DEFAULT 0")
+	addColumnIfMissing("orders", "paid_at", "TEXT")
+	addColumnIfMissing("orders", "credit_flagged", "INTEGER NOT NULL DEFAULT 0")
+	if !tracked {
+		if _, err := DB.Exec(`
+			UPDATE orders
+			   SET due_date = substr(createdAt, 1, 10), amount_paid = totalPrice, paid_at = createdAt`); err != nil {
+			log.Fatalf("Failed to settle orders that predate credit tracking: %v", err)
+		}
+	}
+	if _, err := DB.Exec(`CREATE INDEX IF NOT EXISTS idx_orders_customer ON orders(customerId, createdAt);`); err != nil {
+		log.Fatalf("Failed to create idx_orders_customer: %v", err)
+	}
+}
+
+// createPaymentTables records money received from (kind 'payment') and returned to
+// (kind 'refund') customers. Allocations apply a payment to orders, or take a refund
+// back off them; whatever a payment doesn't allocate is credit the customer can use on
+// later orders, and a refund that isn't allocated pays that credit out.
+// orders.amount_paid is kept as the running total of its allocations.
+func createPaymentTables() {
+	createPaymentsTable := `
+	CREATE TABLE IF NOT EXISTS payments (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		customer_id  INTEGER NOT NULL,
+		kind         TEXT NOT NULL DEFAULT 'payment' CHECK (kind IN ('payment', 'refund')),
+		method       TEXT NOT NULL CHECK (method IN ('cash', 'card', 'bank_transfer', 'cheque', 'other')),
+		amount       REAL NOT NULL CHECK (amount > 0),
+		reference    TEXT NOT NULL DEFAULT '',
+		notes        TEXT NOT NULL DEFAULT '',
+		received_at  TEXT NOT NULL,
+		recorded_by  INTEGER,
+		created_at   TEXT NOT NULL,
+		FOREIGN KEY(customer_id) REFERENCES customers(id),
+		FOREIGN KEY(recorded_by) REFERENCES users(userId) ON DELETE SET NULL
+	);`
+	if _, err := DB.Exec(createPaymentsTable); err != nil {
+		log.Fatalf("Failed to create payments table: %v", err)
+	}
+	if _, err := DB.Exec(`CREATE INDEX IF NOT EXISTS idx_payments_customer ON payments(customer_id, received_at);`); err != nil {
+		log.Fatalf("Failed to create idx_payments_customer: %v", err)
+	}
+
+	createPaymentAllocationsTable := `
+	CREATE TABLE IF NOT EXISTS payment_allocations (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		payment_id INTEGER NOT NULL,
+		order_id   INTEGER NOT NULL,
+		amount     REAL NOT NULL CHECK (amount > 0),
+		created_at TEXT NOT NULL,
+		FOREIGN KEY(payment_id) REFERENCES payments(id) ON DELETE CASCADE,
+		FOREIGN KEY(order_id) REFERENCES orders(orderId)
+	);`
+	if _, err := DB.Exec(createPaymentAllocationsTable); err != nil {
+		log.Fatalf("Failed to create payment_allocations table: %v", err)
+	}
+	if _, err := DB.Exec(`CREATE INDEX IF NOT EXISTS idx_payment_allocations_payment ON payment_allocations(payment_id);`); err != nil {
+		log.Fatalf("Failed to create idx_payment_allocations_payment: %v", err)
+	}
+	if _, err := DB.Exec(`CREATE INDEX IF NOT EXISTS idx_payment_allocations_order ON payment_allocations(order_id);`); err != nil {
+		log.Fatalf("Failed to create idx_payment_allocations_order: %v", err)
+	}
+	backfillOpeningPayments()
+}
+
+// backfillOpeningPayments records an 'other' payment, allocated in full, for every order
+// whose amount_paid has no allocations behind it: orders settled by the credit-tracking
+// upgrade or marked paid before the ledger existed. It does nothing once they have one.
+func backfillOpeningPayments() {
+	tx, err := DB.Begin()
+	if err != nil {
+		log.Fatalf("Failed to backfill opening payments: %v", err)
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(`
+		SELECT o.orderId, o.customerId, o.amount_paid, COALESCE(o.paid_at, o.createdAt)
+		FROM orders o
+		WHERE o.amount_paid > 0
+		  AND o.customerId IN (SELECT id FROM customers)
+		  AND NOT EXISTS (SELECT 1 FROM payment_allocations a WHERE a.order_id = o.orderId)`)
+	if err != nil {
+		log.Fatalf("Failed to find orders without payments: %v", err)
+	}
+	type opening struct {
+		orderID, customerID int
+		amount              float64
+		receivedAt          string
+	}
+	var pending []opening
+	for rows.Next() {
+		var o opening
+		if err := rows.Scan(&o.orderID, &o.customerID, &o.amount, &o.receivedAt); err != nil {
+			log.Fatalf("Failed to read orders without payments: %v", err)
+		}
+		pending = append(pending, o)
+	}
+	if err := rows.Err(); err != nil {
+		log.Fatalf("Failed to read orders without payments: %v", err)
+	}
+	rows.Close()
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	for _, o := range pending {
+		res, err := tx.Exec(`
+			INSERT INTO payments (customer_id, kind, method, amount, reference, notes, received_at, created_at)
+			VALUES (?, 'payment', 'other', ?, 'opening balance', 'Paid before the payments ledger', ?, ?)`,
+			o.customerID, o.amount, o.receivedAt, now,
+		)
+		if err != nil {
+			log.Fatalf("Failed to record opening payment for order %d: %v", o.orderID, err)
+		}
+		paymentID, _ := res.LastInsertId()
+		if _, err := tx.Exec(
+			`INSERT INTO payment_allocations (payment_id, order_id, amount, created_at) VALUES (?, ?, ?, ?)`,
+			paymentID, o.orderID, o.amount, now,
+		); err != nil {
+			log.Fatalf("Failed to allocate opening payment for order %d: %v", o.orderID, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		log.Fatalf("Failed to backfill opening payments: %v", err)
+	}
+}
+
+// createPaymentIntentTables tracks card charges made through a payment gateway for an
+// order: authorized, then captured into the payments ledger (payments.intent_id), and
+// possibly refunded. Webhook event ids are kept so a redelivered webhook is ignored.
+func createPaymentIntentTables() {
+	createPaymentIntentsTable := `
+	CREATE TABLE IF NOT EXISTS payment_intents (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		order_id        INTEGER NOT NULL,
+		customer_id     INTEGER NOT NULL,
+		provider        TEXT NOT NULL,
+		provider_ref    TEXT NOT NULL DEFAULT '',
+		payment_method  TEXT NOT NULL,
+		amount          REAL NOT NULL CHECK (amount > 0),
+		status          TEXT NOT NULL DEFAULT 'pending'
+			CHECK (status IN ('pending', 'authorized', 'declined', 'failed', 'captured', 'refunded')),
+		auto_capture    INTEGER NOT NULL DEFAULT 0,
+		amount_captured REAL NOT NULL DEFAULT 0,
+		amount_refunded REAL NOT NULL DEFAULT 0,
+		decline_reason  TEXT,
+		created_by      INTEGER,
+		created_at      TEXT NOT NULL,
+		updated_at      TEXT NOT NULL,
+		FOREIGN KEY(order_id) REFERENCES orders(orderId),
+		FOREIGN KEY(customer_id) REFERENCES customers(id),
+		FOREIGN KEY(created_by) REFERENCES users(userId) ON DELETE SET NULL
+	);`
+	if _, err := DB.Exec(createPaymentIntentsTable); err != nil {
+		log.Fatalf("Failed to create payment_intents table: %v", err)
+	}
+	if _, err := DB.Exec(`CREATE INDEX IF NOT EXISTS idx_payment_intents_order ON payment_intents(order_id);`); err != nil {
+		log.Fatalf("Failed to create idx_payment_intents_order: %v", err)
+	}
+	if _, err := DB.Exec(`CREATE INDEX IF NOT EXISTS idx_payment_intents_ref ON payment_intents(provider, provider_ref);`); err != nil {
+		log.Fatalf("Failed to create idx_payment_intents_ref: %v", err)
+	}
+
+	createWebhookEventsTable := `
+	CREATE TABLE IF NOT EXISTS payment_webhook_events (
+		provider    TEXT NOT NULL,
+		event_id    TEXT NOT NULL,
+		received_at TEXT NOT NULL,
+		PRIMARY KEY (provider, event_id)
+	);`
+	if _, err := DB.Exec(createWebhookEventsTable); err != nil {
+		log.Fatalf("Failed to create payment_webhook_events table: %v", err)
+	}
+
+	addColumnIfMissing("payments", "intent_id", "INTEGER REFERENCES payment_intents(id)")
+}
+
+// createSegmentTables holds free-form customer tags and saved segments. A segment's
+// rules (JSON) are evaluated against orders whenever it is read, so membership is
+// always current.
+func createSegmentTables() {
+	createCustomerTagsTable := `
+	CREATE TABLE IF NOT EXISTS customer_tags (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		customer_id INTEGER NOT NULL,
+		tag         TEXT NOT NULL,
+		created_at  TEXT NOT NULL,
+		UNIQUE(customer_id, tag),
+		FOREIGN KEY(customer_id) REFERENCES customers(id) ON DELETE CASCADE
+	);`
+	if _, err := DB.Exec(createCustomerTagsTable); err != nil {
+		log.Fatalf("Failed to create customer_tags table: %v", err)
+	}
+	if _, err := DB.Exec(`CREATE INDEX IF NOT EXISTS idx_customer_tags_tag ON customer_tags(tag);`); err != nil {
+		log.Fatalf("Failed to create idx_customer_tags_tag: %v", err)
+	}
+
+	createCustomerSegmentsTable := `
+	CREATE TABLE IF NOT EXISTS customer_segments (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name        TEXT NOT NULL UNIQUE COLLATE NOCASE,
+		description TEXT NOT NULL DEFAULT '',
+		match       TEXT NOT NULL DEFAULT 'all' CHECK (match IN ('all', 'any')),
+		rules       TEXT NOT NULL,
+		created_at  TEXT NOT NULL,
+		updated_at  TEXT NOT NULL
+	);`
+	if _, err := DB.Exec(createCustomerSegmentsTable); err != nil {
+		log.Fatalf("Failed to create customer_segments table: %v", err)
+	}
+}
+
+// createActivityTables holds the notes, calls and follow-up tasks reps log against a
+// customer or one of their orders. Activities on an order carry its customer too, so
+// the customer's timeline shows them. overdue_notified_at marks tasks whose overdue
+// event has been sent.
+func createActivityTables() {
+	createActivitiesTable := `
+	CREATE TABLE IF NOT EXISTS activities (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		kind         TEXT NOT NULL CHECK (kind IN ('note', 'call', 'task')),
+		customer_id  INTEGER NOT NULL,
+		order_id     INTEGER,
+		subject      TEXT NOT NULL DEFAULT '',
+		body         TEXT NOT NULL DEFAULT '',
+		due_at       TEXT,
+		assigned_to  INTEGER,
+		status       TEXT CHECK (status IN ('open', 'done')),
+		completed_at TEXT,
+		completed_by INTEGER,
+		overdue_notified_at TEXT,
+		created_by   INTEGER,
+		created_at   TEXT NOT NULL,
+		updated_at   TEXT NOT NULL,
+		FOREIGN KEY(customer_id) REFERENCES customers(id),
+		FOREIGN KEY(order_id) REFERENCES orders(orderId),
+		FOREIGN KEY(assigned_to) REFERENCES users(userId) ON DELETE SET NULL,
+		FOREIGN KEY(completed_by) REFERENCES users(userId) ON DELETE SET NULL,
+		FOREIGN KEY(created_by) REFERENCES users(userId) ON DELETE SET NULL
+	);`
+	if _, err := DB.Exec(createActivitiesTable); err != nil {
+		log.Fatalf("Failed to create activities table: %v", err)
+	}
+	for _, idx := range []string{
+		`CREATE INDEX IF NOT EXISTS idx_activities_customer ON activities(customer_id, created_at);`,
+		`CREATE INDEX IF NOT EXISTS idx_activities_order ON activities(order_id);`,
+		`CREATE INDEX IF NOT EXISTS idx_activities_open_tasks ON activities(assigned_to, due_at) WHERE status = 'open';`,
+	} {
+		if _, err := DB.Exec(idx); err != nil {
+			log.Fatalf("Failed to create activities index: %v", err)
+		}
+	}
+}
+
+// createQuoteTables holds quotes sent to customers before they order. Every edit
+// adds a quote_versions row with its own lines, and quotes.version points at the
+// current one. order_id is the order an accepted quote became.
+func createQuoteTables() {
+	createQuotesTable := `
+	CREATE TABLE IF NOT EXISTS quotes (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		customer_id INTEGER NOT NULL,
+		status      TEXT NOT NULL DEFAULT 'draft' CHECK (status IN ('draft', 'sent', 'accepted', 'expired')),
+		version     INTEGER NOT NULL DEFAULT 1,
+		order_id    INTEGER,
+		sent_at     TEXT,
+		accepted_at TEXT,
+		created_by  INTEGER,
+		created_at  TEXT NOT NULL,
+		updated_at  TEXT NOT NULL,
+		FOREIGN KEY(customer_id) REFERENCES customers(id),
+		FOREIGN KEY(order_id) REFERENCES orders(orderId),
+		FOREIGN KEY(created_by) REFERENCES users(userId) ON DELETE SET NULL
+	);`
+	if _, err := DB.Exec(createQuotesTable); err != nil {
+		log.Fatalf("Failed to create quotes table: %v", err)
+	}
+
+	createQuoteVersionsTable := `
+	CREATE TABLE IF NOT EXISTS quote_versions (
+		quote_id    INTEGER NOT NULL,
+		version     INTEGER NOT NULL,
+		valid_until TEXT NOT NULL,
+		shipping_address_id INTEGER,
+		notes       TEXT NOT NULL DEFAULT '',
+		total       REAL NOT NULL DEFAULT 0,
+		created_by  INTEGER,
+		created_at  TEXT NOT NULL,
+		PRIMARY KEY (quote_id, version),
+		FOREIGN KEY(quote_id) REFERENCES quotes(id) ON DELETE CASCADE,
+		FOREIGN KEY(shipping_address_id) REFERENCES customer_addresses(id) ON DELETE SET NULL,
+		FOREIGN KEY(created_by) REFERENCES users(userId) ON DELETE SET NULL
+	);`
+	if _, err := DB.Exec(createQuoteVersionsTable); err != nil {
+		log.Fatalf("Failed to create quote_versions table: %v", err)
+	}
+
+	createQuoteLinesTable := `
+	CREATE TABLE IF NOT EXISTS quote_lines (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		quote_id     INTEGER NOT NULL,
+		version      INTEGER NOT NULL,
+		product_id   INTEGER NOT NULL,
+		warehouse_id INTEGER,
+		quantity     INTEGER NOT NULL CHECK (quantity > 0),
+		unit_price   REAL NOT NULL,
+		FOREIGN KEY(quote_id, version) REFERENCES quote_versions(quote_id, version) ON DELETE CASCADE,
+		FOREIGN KEY(product_id) REFERENCES products(id),
+		FOREIGN KEY(warehouse_id) REFERENCES warehouses(id)
+	);`
+	if _, err := DB.Exec(createQuoteLinesTable); err != nil {
+		log.Fatalf("Failed to create quote_lines table: %v", err)
+	}
+	for _, idx := range []string{
+		`CREATE INDEX IF NOT EXISTS idx_quotes_customer ON quotes(customer_id);`,
+		`CREATE INDEX IF NOT EXISTS idx_quote_lines_version ON quote_lines(quote_id, version);`,
+	} {
+		if _, err := DB.Exec(idx); err != nil {
+			log.Fatalf("Failed to create quotes index: %v", err)
+		}
+	}
+}
+
+// createBackorderTables holds the shortfall of order lines accepted without enough
+// stock. Each row is filled from stock arriving in its warehouse, oldest first;
+// filled_qty counts the units taken so far and status turns 'filled' at qty.
+func createBackorderTables() {
+	createBackordersTable := `
+	CREATE TABLE IF NOT EXISTS backorders (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		order_id      INTEGER NOT NULL,
+		order_item_id INTEGER NOT NULL,
+		product_id    INTEGER NOT NULL,
+		warehouse_id  INTEGER NOT NULL,
+		qty           INTEGER NOT NULL CHECK (qty > 0),
+		filled_qty    INTEGER NOT NULL DEFAULT 0 CHECK (filled_qty >= 0 AND filled_qty <= qty),
+		status        TEXT NOT NULL DEFAULT 'open' CHECK (status IN ('open', 'filled')),
+		created_at    TEXT NOT NULL,
+		filled_at     TEXT,
+		FOREIGN KEY(order_id) REFERENCES orders(orderId) ON DELETE CASCADE,
+		FOREIGN KEY(order_item_id) REFERENCES order_items(id) ON DELETE CASCADE,
+		FOREIGN KEY(product_id) REFERENCES products(id),
+		FOREIGN KEY(warehouse_id) REFERENCES warehouses(id)
+	);`
+	if _, err := DB.Exec(createBackordersTable); err != nil {
+		log.Fatalf("Failed to create backorders table: %v", err)
+	}
+	if _, err := DB.Exec(`CREATE INDEX IF NOT EXISTS idx_backorders_open ON backorders(warehouse_id, product_id, created_at) WHERE status = 'open';`); err != nil {
+		log.Fatalf("Failed to create idx_backorders_open: %v", err)
+	}
+}
+
+// createRecurringOrderTables holds order templates that the scheduler turns into
+// orders. cron is the schedule every frequency is stored as; weekday, day_of_month
+// and at_time keep the weekly and monthly inputs. next_run_at is when the next
+// order is due, and failure_count counts failed runs since the last success. Every
+// run, including failed and skipped ones, is kept in recurring_order_runs.
+func createRecurringOrderTables() {
+	createRecurringOrdersTable := `
+	CREATE TABLE IF NOT EXISTS recurring_orders (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		customer_id   INTEGER NOT NULL,
+		user_id       INTEGER NOT NULL,
+		name          TEXT NOT NULL DEFAULT '',
+		frequency     TEXT NOT NULL CHECK (frequency IN ('weekly', 'monthly', 'cron')),
+		weekday       INTEGER,
+		day_of_month  INTEGER,
+		at_time       TEXT,
+		cron          TEXT NOT NULL,
+		shipping_address_id INTEGER,
+		allow_backorder   INTEGER NOT NULL DEFAULT 0,
+		allow_over_credit INTEGER NOT NULL DEFAULT 0,
+		status        TEXT NOT NULL DEFAULT 'active' CHECK (status IN ('active', 'paused')),
+		next_run_at   TEXT,
+		last_run_at   TEXT,
+		last_error    TEXT,
+		failure_count INTEGER NOT NULL DEFAULT 0,
+		created_at    TEXT NOT NULL,
+		updated_at    TEXT NOT NULL,
+		FOREIGN KEY(customer_id) REFERENCES customers(id),
+		FOREIGN KEY(user_id) REFERENCES users(userId),
+		FOREIGN KEY(shipping_address_id) REFERENCES customer_addresses(id) ON DELETE SET NULL
+	);`
+	if _, err := DB.Exec(createRecurringOrdersTable); err != nil {
+		log.Fatalf("Failed to create recurring_orders table: %v", err)
+	}
+
+	// unit_price NULL means the product's price on the day the order is generated.
+	createRecurringOrderLinesTable := `
+	CREATE TABLE IF NOT EXISTS recurring_order_lines (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		recurring_order_id INTEGER NOT NULL,
+		product_id   INTEGER NOT NULL,
+		warehouse_id INTEGER NOT NULL,
+		quantity     INTEGER NOT NULL CHECK (quantity > 0),
+		unit_price   REAL,
+		FOREIGN KEY(recurring_order_id) REFERENCES recurring_orders(id) ON DELETE CASCADE,
+		FOREIGN KEY(product_id) REFERENCES products(id),
+		FOREIGN KEY(warehouse_id) REFERENCES warehouses(id)
+	);`
+	if _, err := DB.Exec(createRecurringOrderLinesTable); err != nil {
+		log.Fatalf("Failed to create recurring_order_lines table: %v", err)
+	}
+
+	createRecurringOrderRunsTable := `
+	CREATE TABLE IF NOT EXISTS recurring_order_runs (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		recurring_order_id INTEGER NOT NULL,
+		scheduled_for TEXT,
+		ran_at        TEXT NOT NULL,
+		status        TEXT NOT NULL CHECK (status IN ('created', 'failed', 'skipped')),
+		order_id      INTEGER,
+		error         TEXT,
+		FOREIGN KEY(recurring_order_id) REFERENCES recurring_orders(id) ON DELETE CASCADE,
+		FOREIGN KEY(order_id) REFERENCES orders(orderId) ON DELETE SET NULL
+	);`
+	if _, err := DB.Exec(createRecurringOrderRunsTable); err != nil {
+		log.Fatalf("Failed to create recurring_order_runs table: %v", err)
+	}
+	for _, idx := range []string{
+		`CREATE INDEX IF NOT EXISTS idx_recurring_orders_due ON recurring_orders(next_run_at) WHERE status = 'active';`,
+		`CREATE INDEX IF NOT EXISTS idx_recurring_order_runs ON recurring_order_runs(recurring_order_id, ran_at);`,
+	} {
+		if _, err := DB.Exec(idx); err != nil {
+			log.Fatalf("Failed to create recurring orders index: %v", err)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package tools
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Storage holds uploaded files. Keys are slash-separated relative paths such as
+// "products/12/3f9c.jpg"; the database keeps the key, never a filesystem path,
+// so another backend (S3, GCS, ...) can be swapped in behind the same interface.
+type Storage interface {
+	Put(key string, r io.Reader) error
+	// Open returns the stored bytes; implementations may also return an io.ReadSeeker
+	// so range requests work. A missing key yields an error wrapping fs.ErrNotExist.
+	Open(key string) (io.ReadCloser, error)
+	// Delete removes the key; deleting a missing key is not an error.
+	Delete(key string) error
+}
+
+// Files is the storage used by the attachment handlers; set it with InitStorage.
+var Files Storage
+
+// InitStorage stores uploads on local disk under root.
+func InitStorage(root string) {
+	Files = &LocalStorage{Root: root}
+}
+
+// LocalStorage keeps files under Root on the local filesystem.
+type LocalStorage struct {
+	Root string
+}
+
+var errBadKey = errors.New("invalid storage key")
+
+func (s *LocalStorage) path(key string) (string, error) {
+	clean := filepath.Clean(filepath.FromSlash(key))
+	if key == "" || filepath.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, ".."+string(filepath.Separator)) {
+		return "", errBadKey
+	}
+	return filepath.Join(s.Root, clean), nil
+}
+
+// Put writes to a temporary file first so a failed upload never leaves a partial file under key.
+func (s *LocalStorage) Put(key string, r io.Reader) error {
+	p, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(p), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), p)
+}
+
+func (s *LocalStorage) Open(key string) (io.ReadCloser, error) {
+	p, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(p)
+}
+
+func (s *LocalStorage) Delete(key string) error {
+	p, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(p); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}